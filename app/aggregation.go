@@ -0,0 +1,168 @@
+package app
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"quoteship/domain"
+)
+
+const (
+	MethodMean                = "mean"                  // MethodMean is the name of the MeanAggregator.
+	MethodMedian              = "median"                // MethodMedian is the name of the MedianAggregator.
+	MethodTrimmedMean         = "trimmed-mean"          // MethodTrimmedMean is the name of the TrimmedMeanAggregator.
+	MethodRecencyWeightedMean = "recency-weighted-mean" // MethodRecencyWeightedMean is the name of the RecencyWeightedMeanAggregator.
+	MethodMin                 = "min"                   // MethodMin is the name of the MinAggregator.
+	MethodMax                 = "max"                   // MethodMax is the name of the MaxAggregator.
+
+	DefaultMethod      = MethodMean // DefaultMethod is the aggregation method used when none is configured.
+	defaultTrimPercent = 10         // defaultTrimPercent is the percentage of quotes trimmed from each end by the built-in trimmed mean.
+)
+
+// MeanAggregator calculates the expected rate as the arithmetic mean of the prices.
+type MeanAggregator struct{}
+
+// Name returns MethodMean.
+func (MeanAggregator) Name() string {
+	return MethodMean
+}
+
+// Aggregate returns the mean price of the quotes.
+func (MeanAggregator) Aggregate(quotes []domain.ShipmentQuote) *big.Rat {
+	return meanPrice(quotes)
+}
+
+// MedianAggregator calculates the expected rate as the median of the prices, the mean of the two middle prices for an
+// even number of quotes.
+type MedianAggregator struct{}
+
+// Name returns MethodMedian.
+func (MedianAggregator) Name() string {
+	return MethodMedian
+}
+
+// Aggregate returns the median price of the quotes.
+func (MedianAggregator) Aggregate(quotes []domain.ShipmentQuote) *big.Rat {
+	middle := len(quotes) / 2
+	if len(quotes)%2 == 1 {
+		return big.NewRat(int64(quotes[middle].Price), 1)
+	}
+
+	return meanPrice(quotes[middle-1 : middle+1])
+}
+
+// TrimmedMeanAggregator calculates the expected rate as the mean of the prices without the cheapest and the most
+// expensive ones. Percent of the quotes, rounded down, are trimmed from each end, so a small number of quotes is not
+// trimmed.
+type TrimmedMeanAggregator struct {
+	Percent int // Percent is the percentage of quotes trimmed from each end, between 0 and 49.
+}
+
+// Name returns MethodTrimmedMean.
+func (TrimmedMeanAggregator) Name() string {
+	return MethodTrimmedMean
+}
+
+// Aggregate returns the trimmed mean price of the quotes.
+func (a TrimmedMeanAggregator) Aggregate(quotes []domain.ShipmentQuote) *big.Rat {
+	trimmed := len(quotes) * a.Percent / 100
+
+	return meanPrice(quotes[trimmed : len(quotes)-trimmed])
+}
+
+// RecencyWeightedMeanAggregator calculates the expected rate as the mean of the prices weighted by the recency of the
+// quotes. A quote that starts d days before the most recent one weighs 1/(1+d), so the most recent quotes count the
+// most.
+type RecencyWeightedMeanAggregator struct{}
+
+// Name returns MethodRecencyWeightedMean.
+func (RecencyWeightedMeanAggregator) Name() string {
+	return MethodRecencyWeightedMean
+}
+
+// Aggregate returns the recency weighted mean price of the quotes.
+func (RecencyWeightedMeanAggregator) Aggregate(quotes []domain.ShipmentQuote) *big.Rat {
+	newest := quotes[0].Date
+	for _, quote := range quotes[1:] {
+		if quote.Date.After(newest) {
+			newest = quote.Date
+		}
+	}
+
+	total, weights := new(big.Rat), new(big.Rat)
+	for _, quote := range quotes {
+		days := int64(newest.Sub(quote.Date) / (24 * time.Hour))
+		weight := big.NewRat(1, 1+days)
+
+		total.Add(total, new(big.Rat).Mul(weight, big.NewRat(int64(quote.Price), 1)))
+		weights.Add(weights, weight)
+	}
+
+	return total.Quo(total, weights)
+}
+
+// MinAggregator calculates the expected rate as the cheapest price.
+type MinAggregator struct{}
+
+// Name returns MethodMin.
+func (MinAggregator) Name() string {
+	return MethodMin
+}
+
+// Aggregate returns the price of the cheapest quote.
+func (MinAggregator) Aggregate(quotes []domain.ShipmentQuote) *big.Rat {
+	return big.NewRat(int64(quotes[0].Price), 1)
+}
+
+// MaxAggregator calculates the expected rate as the most expensive price.
+type MaxAggregator struct{}
+
+// Name returns MethodMax.
+func (MaxAggregator) Name() string {
+	return MethodMax
+}
+
+// Aggregate returns the price of the most expensive quote.
+func (MaxAggregator) Aggregate(quotes []domain.ShipmentQuote) *big.Rat {
+	return big.NewRat(int64(quotes[len(quotes)-1].Price), 1)
+}
+
+// NewAggregator returns the built-in domain.Aggregator with the given name, e.g., "median". It returns
+// domain.ErrInvalidAggregation if there is none.
+func NewAggregator(method string) (domain.Aggregator, error) {
+	aggregator, exists := builtinAggregators()[strings.ToLower(strings.TrimSpace(method))]
+	if !exists {
+		return nil, fmt.Errorf("%w: %q", domain.ErrInvalidAggregation, method)
+	}
+
+	return aggregator, nil
+}
+
+// builtinAggregators returns the built-in aggregators keyed by name.
+func builtinAggregators() map[string]domain.Aggregator {
+	aggregators := make(map[string]domain.Aggregator)
+	for _, aggregator := range []domain.Aggregator{
+		MeanAggregator{},
+		MedianAggregator{},
+		TrimmedMeanAggregator{Percent: defaultTrimPercent},
+		RecencyWeightedMeanAggregator{},
+		MinAggregator{},
+		MaxAggregator{},
+	} {
+		aggregators[aggregator.Name()] = aggregator
+	}
+
+	return aggregators
+}
+
+// meanPrice returns the exact mean price of the quotes.
+func meanPrice(quotes []domain.ShipmentQuote) *big.Rat {
+	total := 0
+	for _, quote := range quotes {
+		total += quote.Price
+	}
+
+	return big.NewRat(int64(total), int64(len(quotes)))
+}
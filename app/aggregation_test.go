@@ -0,0 +1,126 @@
+package app
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"quoteship/domain"
+)
+
+func TestAggregators(t *testing.T) {
+	date := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	quotes := func(prices ...int) []domain.ShipmentQuote {
+		quotes := make([]domain.ShipmentQuote, 0, len(prices))
+		for i, price := range prices {
+			quotes = append(quotes, domain.ShipmentQuote{Company: i + 1, Price: price, Date: date})
+		}
+		return quotes
+	}
+
+	tests := []struct {
+		name         string
+		aggregator   domain.Aggregator
+		quotes       []domain.ShipmentQuote
+		expectedRate *big.Rat
+	}{
+		{
+			name:         "mean",
+			aggregator:   MeanAggregator{},
+			quotes:       quotes(100, 200, 400),
+			expectedRate: big.NewRat(700, 3),
+		},
+		{
+			name:         "median - odd number of quotes",
+			aggregator:   MedianAggregator{},
+			quotes:       quotes(100, 200, 900),
+			expectedRate: big.NewRat(200, 1),
+		},
+		{
+			name:         "median - even number of quotes",
+			aggregator:   MedianAggregator{},
+			quotes:       quotes(100, 200, 301, 900),
+			expectedRate: big.NewRat(501, 2),
+		},
+		{
+			name:         "trimmed mean - trims each end",
+			aggregator:   TrimmedMeanAggregator{Percent: 20},
+			quotes:       quotes(1, 100, 200, 300, 5000),
+			expectedRate: big.NewRat(200, 1),
+		},
+		{
+			name:         "trimmed mean - too few quotes to trim",
+			aggregator:   TrimmedMeanAggregator{Percent: 10},
+			quotes:       quotes(1, 100, 200, 300, 5000),
+			expectedRate: big.NewRat(5601, 5),
+		},
+		{
+			name:       "recency weighted mean",
+			aggregator: RecencyWeightedMeanAggregator{},
+			quotes: []domain.ShipmentQuote{
+				{Company: 1, Price: 100, Date: date},
+				{Company: 2, Price: 400, Date: date.AddDate(0, 0, -1)}, // Weighs 1/2
+				{Company: 3, Price: 700, Date: date.AddDate(0, 0, -3)}, // Weighs 1/4
+			},
+			expectedRate: big.NewRat(1900, 7), // (100 + 400/2 + 700/4) / (1 + 1/2 + 1/4)
+		},
+		{
+			name:         "recency weighted mean - same dates",
+			aggregator:   RecencyWeightedMeanAggregator{},
+			quotes:       quotes(100, 200, 400),
+			expectedRate: big.NewRat(700, 3),
+		},
+		{
+			name:         "min",
+			aggregator:   MinAggregator{},
+			quotes:       quotes(100, 200, 400),
+			expectedRate: big.NewRat(100, 1),
+		},
+		{
+			name:         "max",
+			aggregator:   MaxAggregator{},
+			quotes:       quotes(100, 200, 400),
+			expectedRate: big.NewRat(400, 1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate := tt.aggregator.Aggregate(tt.quotes)
+			if rate.Cmp(tt.expectedRate) != 0 {
+				t.Errorf("expected rate %v, got %v", tt.expectedRate, rate)
+			}
+		})
+	}
+}
+
+func TestNewAggregator(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		expectedError error
+	}{
+		{name: "mean", method: MethodMean},
+		{name: "median", method: MethodMedian},
+		{name: "trimmed mean", method: MethodTrimmedMean},
+		{name: "recency weighted mean", method: MethodRecencyWeightedMean},
+		{name: "min", method: MethodMin},
+		{name: "max", method: MethodMax},
+		{name: "surrounding spaces and uppercase", method: " Median "},
+		{name: "unknown method", method: "mode", expectedError: domain.ErrInvalidAggregation},
+		{name: "empty method", method: "", expectedError: domain.ErrInvalidAggregation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aggregator, err := NewAggregator(tt.method)
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if err == nil && aggregator.Name() == "" {
+				t.Errorf("expected a named aggregator, got %+v", aggregator)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"quoteship/domain"
+)
+
+const (
+	FilterNone = "none" // FilterNone disables the outlier filter, every quote is aggregated.
+	FilterIQR  = "iqr"  // FilterIQR is the name of the IQRFilter.
+	FilterMAD  = "mad"  // FilterMAD is the name of the MADFilter.
+
+	DefaultIQRMultiplier = 1.5 // DefaultIQRMultiplier is the number of interquartile ranges beyond the quartiles a price must be to be an outlier.
+	DefaultMADThreshold  = 3.5 // DefaultMADThreshold is the modified z-score beyond which a price is an outlier.
+
+	minOutlierSample = 4      // minOutlierSample is the smallest number of quotes a distribution must have to find outliers in it.
+	madScale         = 0.6745 // madScale scales the median absolute deviation to the standard deviation of a normal distribution.
+)
+
+// IQRFilter excludes the prices beyond the fences of the interquartile range (IQR) of their distribution, the prices
+// lower than Q1 - Multiplier × IQR or greater than Q3 + Multiplier × IQR. The quartiles are interpolated linearly.
+type IQRFilter struct {
+	Multiplier float64 // Multiplier is the number of interquartile ranges beyond the quartiles a price must be to be an outlier, e.g., 1.5.
+}
+
+// Name returns FilterIQR.
+func (IQRFilter) Name() string {
+	return FilterIQR
+}
+
+// Filter returns the quotes within the fences and the excluded ones.
+func (f IQRFilter) Filter(quotes []domain.ShipmentQuote) ([]domain.ShipmentQuote, []domain.ExcludedQuote) {
+	if len(quotes) < minOutlierSample {
+		return quotes, nil
+	}
+
+	prices := sortedPrices(quotes)
+	q1, q3 := quantile(prices, 0.25), quantile(prices, 0.75)
+	lower, upper := q1-f.Multiplier*(q3-q1), q3+f.Multiplier*(q3-q1)
+
+	return partitionQuotes(quotes, func(price float64) string {
+		switch {
+		case price < lower:
+			return fmt.Sprintf("price %s is below the lower fence %s of the interquartile range", formatUnits(price), formatUnits(lower))
+		case price > upper:
+			return fmt.Sprintf("price %s is above the upper fence %s of the interquartile range", formatUnits(price), formatUnits(upper))
+		default:
+			return ""
+		}
+	})
+}
+
+// MADFilter excludes the prices whose modified z-score, their distance to the median of their distribution in median
+// absolute deviations (MAD) scaled by 0.6745, is greater than Threshold. A distribution whose MAD is zero, where most
+// prices are equal, has no outliers.
+type MADFilter struct {
+	Threshold float64 // Threshold is the modified z-score beyond which a price is an outlier, e.g., 3.5.
+}
+
+// Name returns FilterMAD.
+func (MADFilter) Name() string {
+	return FilterMAD
+}
+
+// Filter returns the quotes within the threshold and the excluded ones.
+func (f MADFilter) Filter(quotes []domain.ShipmentQuote) ([]domain.ShipmentQuote, []domain.ExcludedQuote) {
+	if len(quotes) < minOutlierSample {
+		return quotes, nil
+	}
+
+	prices := sortedPrices(quotes)
+	median := quantile(prices, 0.5)
+
+	deviations := make([]float64, 0, len(prices))
+	for _, price := range prices {
+		deviations = append(deviations, math.Abs(price-median))
+	}
+	sort.Float64s(deviations)
+
+	mad := quantile(deviations, 0.5)
+	if mad == 0 {
+		return quotes, nil
+	}
+
+	return partitionQuotes(quotes, func(price float64) string {
+		if score := madScale * (price - median) / mad; math.Abs(score) > f.Threshold {
+			return fmt.Sprintf("price %s has a modified z-score of %.2f from the median %s", formatUnits(price), score, formatUnits(median))
+		}
+		return ""
+	})
+}
+
+// NewOutlierFilter returns the built-in domain.OutlierFilter with the given name, e.g., "iqr", configured with the
+// threshold. A zero threshold uses the default of the filter, DefaultIQRMultiplier or DefaultMADThreshold. It returns a
+// nil filter for FilterNone, and domain.ErrInvalidOutlierFilter for an unknown name or a negative threshold.
+func NewOutlierFilter(name string, threshold float64) (domain.OutlierFilter, error) {
+	if threshold < 0 || math.IsNaN(threshold) || math.IsInf(threshold, 0) {
+		return nil, fmt.Errorf("%w: invalid threshold %v", domain.ErrInvalidOutlierFilter, threshold)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case FilterNone:
+		return nil, nil
+	case FilterIQR:
+		if threshold == 0 {
+			threshold = DefaultIQRMultiplier
+		}
+		return IQRFilter{Multiplier: threshold}, nil
+	case FilterMAD:
+		if threshold == 0 {
+			threshold = DefaultMADThreshold
+		}
+		return MADFilter{Threshold: threshold}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", domain.ErrInvalidOutlierFilter, name)
+	}
+}
+
+// partitionQuotes splits the quotes into the kept ones and the excluded ones, a quote is excluded when reason returns a
+// non-empty reason for its price. The quotes keep their order.
+func partitionQuotes(quotes []domain.ShipmentQuote, reason func(price float64) string) ([]domain.ShipmentQuote, []domain.ExcludedQuote) {
+	kept := make([]domain.ShipmentQuote, 0, len(quotes))
+	var excluded []domain.ExcludedQuote
+	for _, quote := range quotes {
+		if why := reason(float64(quote.Price)); why != "" {
+			excluded = append(excluded, domain.ExcludedQuote{ShipmentQuote: quote, Reason: why})
+			continue
+		}
+		kept = append(kept, quote)
+	}
+
+	return kept, excluded
+}
+
+// sortedPrices returns the prices of the quotes in ascending order.
+func sortedPrices(quotes []domain.ShipmentQuote) []float64 {
+	prices := make([]float64, 0, len(quotes))
+	for _, quote := range quotes {
+		prices = append(prices, float64(quote.Price))
+	}
+	sort.Float64s(prices)
+
+	return prices
+}
+
+// quantile returns the q quantile of the sorted values, interpolated linearly between the closest ranks.
+func quantile(sorted []float64, q float64) float64 {
+	position := q * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	if lower == len(sorted)-1 {
+		return sorted[lower]
+	}
+
+	return sorted[lower] + (position-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// formatUnits formats an amount in minor units as a decimal in whole units, e.g., "123.45".
+func formatUnits(amount float64) string {
+	return strconv.FormatFloat(amount/domain.MinorUnitsPerUnit, 'f', 2, 64)
+}
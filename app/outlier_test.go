@@ -0,0 +1,124 @@
+package app
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"quoteship/domain"
+)
+
+func TestOutlierFilters(t *testing.T) {
+	date := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	quotes := func(prices ...int) []domain.ShipmentQuote {
+		quotes := make([]domain.ShipmentQuote, 0, len(prices))
+		for i, price := range prices {
+			quotes = append(quotes, domain.ShipmentQuote{Company: i + 1, Price: price, Date: date})
+		}
+		return quotes
+	}
+
+	tests := []struct {
+		name             string
+		filter           domain.OutlierFilter
+		quotes           []domain.ShipmentQuote
+		expectedKept     []int
+		expectedExcluded []domain.ExcludedQuote
+	}{
+		{
+			name:         "iqr - price below the lower fence",
+			filter:       IQRFilter{Multiplier: DefaultIQRMultiplier},
+			quotes:       quotes(100, 85000, 90000, 95000, 100000),
+			expectedKept: []int{2, 3, 4, 5},
+			expectedExcluded: []domain.ExcludedQuote{
+				{ShipmentQuote: quotes(100)[0], Reason: "price 1.00 is below the lower fence 700.00 of the interquartile range"},
+			},
+		},
+		{
+			name:         "iqr - price above the upper fence",
+			filter:       IQRFilter{Multiplier: DefaultIQRMultiplier},
+			quotes:       quotes(85000, 90000, 95000, 100000, 900000),
+			expectedKept: []int{1, 2, 3, 4},
+			expectedExcluded: []domain.ExcludedQuote{
+				{ShipmentQuote: domain.ShipmentQuote{Company: 5, Price: 900000, Date: date}, Reason: "price 9000.00 is above the upper fence 1150.00 of the interquartile range"},
+			},
+		},
+		{
+			name:         "iqr - too few quotes",
+			filter:       IQRFilter{Multiplier: DefaultIQRMultiplier},
+			quotes:       quotes(100, 85000, 90000),
+			expectedKept: []int{1, 2, 3},
+		},
+		{
+			name:         "mad - price far from the median",
+			filter:       MADFilter{Threshold: DefaultMADThreshold},
+			quotes:       quotes(100, 85000, 90000, 95000, 100000),
+			expectedKept: []int{2, 3, 4, 5},
+			expectedExcluded: []domain.ExcludedQuote{
+				{ShipmentQuote: quotes(100)[0], Reason: "price 1.00 has a modified z-score of -12.13 from the median 900.00"},
+			},
+		},
+		{
+			name:         "mad - zero median absolute deviation",
+			filter:       MADFilter{Threshold: DefaultMADThreshold},
+			quotes:       quotes(100, 100, 100, 100, 5000),
+			expectedKept: []int{1, 2, 3, 4, 5},
+		},
+		{
+			name:         "mad - lower threshold",
+			filter:       MADFilter{Threshold: 1.5},
+			quotes:       quotes(85000, 90000, 95000, 100000, 110000),
+			expectedKept: []int{1, 2, 3, 4},
+			expectedExcluded: []domain.ExcludedQuote{
+				{ShipmentQuote: domain.ShipmentQuote{Company: 5, Price: 110000, Date: date}, Reason: "price 1100.00 has a modified z-score of 2.02 from the median 950.00"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, excluded := tt.filter.Filter(tt.quotes)
+
+			companies := make([]int, 0, len(kept))
+			for _, quote := range kept {
+				companies = append(companies, quote.Company)
+			}
+			if !reflect.DeepEqual(companies, tt.expectedKept) {
+				t.Errorf("expected kept companies %v, got %v", tt.expectedKept, companies)
+			}
+			if !reflect.DeepEqual(excluded, tt.expectedExcluded) {
+				t.Errorf("expected excluded quotes %+v, got %+v", tt.expectedExcluded, excluded)
+			}
+		})
+	}
+}
+
+func TestNewOutlierFilter(t *testing.T) {
+	tests := []struct {
+		name           string
+		filter         string
+		threshold      float64
+		expectedFilter domain.OutlierFilter
+		expectedError  error
+	}{
+		{name: "none", filter: FilterNone},
+		{name: "iqr with the default multiplier", filter: FilterIQR, expectedFilter: IQRFilter{Multiplier: DefaultIQRMultiplier}},
+		{name: "iqr with a multiplier", filter: FilterIQR, threshold: 3, expectedFilter: IQRFilter{Multiplier: 3}},
+		{name: "mad with the default threshold", filter: " MAD ", expectedFilter: MADFilter{Threshold: DefaultMADThreshold}},
+		{name: "unknown filter", filter: "zscore", expectedError: domain.ErrInvalidOutlierFilter},
+		{name: "negative threshold", filter: FilterIQR, threshold: -1, expectedError: domain.ErrInvalidOutlierFilter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewOutlierFilter(tt.filter, tt.threshold)
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if !reflect.DeepEqual(filter, tt.expectedFilter) {
+				t.Errorf("expected filter %+v, got %+v", tt.expectedFilter, filter)
+			}
+		})
+	}
+}
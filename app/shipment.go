@@ -1,56 +1,353 @@
 package app
 
 import (
+	"fmt"
 	"log/slog"
+	"math/big"
+	"sort"
 	"strings"
+	"time"
 
 	"quoteship/domain"
 )
 
+const (
+	DefaultTop = 10 // DefaultTop is the number of lowest-priced offers aggregated for every lane when none is configured.
+)
+
 // ShipmentService handles business logic for managing and retrieving shipment data.
 type ShipmentService struct {
-	r domain.ShipmentRepository // r is the repository that provides access to shipment data.
+	r           domain.ShipmentRepository    // r is the repository that provides access to shipment data.
+	fx          domain.ExchangeRates         // fx converts prices between currencies, when nil only the domain.DefaultCurrency is supported.
+	rounding    domain.RoundingMode          // rounding rounds the converted prices and the expected rates to whole minor units.
+	aggregator  domain.Aggregator            // aggregator calculates the expected rates of the queries without a method.
+	aggregators map[string]domain.Aggregator // aggregators are the aggregators the queries can select by name.
+	top         int                          // top is the number of lowest-priced offers considered for the queries without a top.
+	topByOrigin map[string]int               // topByOrigin overrides top for the lanes of some origins, e.g., thin markets.
+	filter      domain.OutlierFilter         // filter excludes the outliers before the quotes are aggregated, when nil every quote is aggregated.
+	ports       domain.PortRegistry          // ports lists the ports the quotes can be submitted for, when nil every port is accepted.
+	companies   domain.CompanyRegistry       // companies lists the companies that can submit quotes, when nil every company is accepted.
+}
+
+// ServiceOption configures an optional behaviour of the ShipmentService.
+type ServiceOption func(*ShipmentService)
+
+// WithExchangeRates makes the service accept quotes and return expected rates in every currency of the exchange rates.
+// The quotes are normalized to the base currency of the exchange rates, with the rates in effect when they are
+// submitted.
+func WithExchangeRates(rates domain.ExchangeRates) ServiceOption {
+	return func(s *ShipmentService) {
+		s.fx = rates
+	}
+}
+
+// WithRoundingMode sets the rounding mode of the converted prices and of the expected rates, domain.RoundHalfEven by
+// default.
+func WithRoundingMode(mode domain.RoundingMode) ServiceOption {
+	return func(s *ShipmentService) {
+		s.rounding = mode
+	}
+}
+
+// WithAggregator makes the aggregator calculate the expected rates of the queries without a method, instead of the
+// MeanAggregator. The aggregator can also be selected by its name, next to the built-in ones. A nil aggregator is
+// ignored.
+func WithAggregator(aggregator domain.Aggregator) ServiceOption {
+	return func(s *ShipmentService) {
+		if aggregator == nil {
+			return
+		}
+		s.aggregator = aggregator
+		s.aggregators[aggregator.Name()] = aggregator
+	}
+}
+
+// WithPortRegistry makes the service reject the quotes whose origin or destination is not an active port of the
+// registry. Without registry, every port is accepted.
+func WithPortRegistry(registry domain.PortRegistry) ServiceOption {
+	return func(s *ShipmentService) {
+		s.ports = registry
+	}
+}
+
+// WithCompanyRegistry makes the service reject the quotes of the companies that are not active in the registry, the
+// rejections are recorded in the audit trail of the company. Without registry, every company is accepted.
+func WithCompanyRegistry(registry domain.CompanyRegistry) ServiceOption {
+	return func(s *ShipmentService) {
+		s.companies = registry
+	}
+}
+
+// WithOutlierFilter makes the filter exclude the outliers of every lane and equipment type before the lowest-priced
+// offers are selected and aggregated. A nil filter aggregates every quote, which is the default.
+func WithOutlierFilter(filter domain.OutlierFilter) ServiceOption {
+	return func(s *ShipmentService) {
+		s.filter = filter
+	}
+}
+
+// WithTop sets the number of lowest-priced offers considered for the queries without a top, DefaultTop by default.
+// topByOrigin overrides it for the lanes of the given origins, e.g., {"CNGGZ": 5} for a thin market. Non-positive
+// values are ignored.
+func WithTop(top int, topByOrigin map[string]int) ServiceOption {
+	return func(s *ShipmentService) {
+		if top > 0 {
+			s.top = top
+		}
+		for origin, originTop := range topByOrigin {
+			if originTop > 0 {
+				s.topByOrigin[origin] = originTop
+			}
+		}
+	}
 }
 
 // GetLatestExpectedRates calculates the expected rates for shipments grouped by origin.
 // It considers the `top` lowest-priced offers for each origin and returns the expected rates.
-// Note that the fetched most recent offers are automatically updated every 1000 offer submissions.
+// Only the quotes of the wildcard lane of every origin, the quotes submitted without a destination, are considered, and
+// only the ones priced for the default equipment type. The rates are in minor units of the base currency.
+// Note that the rates reflect the latest batch of offers published by the publication policy of the repository.
 func (s ShipmentService) GetLatestExpectedRates(top int) (map[string]int, error) {
 	if top <= 0 {
-		return nil, domain.ErrInvalidTopValue // Return an error if the top
+		return nil, domain.ErrInvalidTopValue // Return an error if the top value is invalid, a zero top has no default here
 	}
 
-	// Get the latest sorted shipments by origin from the repository.
-	shipmentsByOrigin := s.r.GetLatestSortedShipmentsByOrigin()
+	expectedRates, err := s.GetExpectedRates(domain.RateQuery{Top: top, Equipment: domain.DefaultEquipment})
+	if err != nil {
+		return nil, err
+	}
 
-	// Return an error if no expected rates are available
-	if len(shipmentsByOrigin) == 0 {
-		return nil, domain.ErrNoExpectedRates
+	// Key the wildcard lane rates by their origin
+	ratesByOrigin := make(map[string]int, len(expectedRates))
+	for _, expectedRate := range expectedRates {
+		ratesByOrigin[expectedRate.Origin] = expectedRate.Rate
 	}
 
-	// Calculate the expected rates for each origin based on the top (lowest) origin shipments
-	expectedRates := make(map[string]int)
-	for _, originShipments := range shipmentsByOrigin {
-		// Skip if origin shipments are empty to avoid division by zero
-		if len(originShipments.Quotes) == 0 || strings.TrimSpace(originShipments.Origin) == "" {
-			continue
+	return ratesByOrigin, nil
+}
+
+// GetExpectedRates calculates the expected rates described by the query. It aggregates the `top` lowest-priced offers
+// of every lane and equipment type, or the default number of offers of the origin of the lane when query.Top is zero,
+// with the aggregator named by query.Method, or the default aggregator of the service. Quotes priced for different
+// equipment types are never aggregated together. The offers are taken from the latest published batch or, when the
+// query has an AsOf date, from the quotes that were in effect on that date.
+//
+// The rates are calculated in the base currency and converted to query.Currency, if provided. The average of the quotes
+// is kept exact through the conversion and rounded to minor units only once, with the rounding mode of the service.
+//
+// Without query.Lanes, a rate is calculated for the wildcard lane of every origin only. With query.Lanes, a rate is
+// calculated for every lane, and the wildcard quotes of an origin also count for its specific lanes, for the companies
+// that did not quote the specific lane themselves.
+//
+// With an outlier filter, the outliers of every lane and equipment type are excluded before the offers are selected.
+func (s ShipmentService) GetExpectedRates(query domain.RateQuery) ([]domain.ExpectedRate, error) {
+	if err := s.validateQuery(query); err != nil {
+		return nil, err
+	}
+
+	aggregator := s.aggregator
+	if query.Method != "" {
+		aggregator = s.aggregators[query.Method]
+	}
+
+	shipmentsByLane, publishedAt := s.queryShipments(query)
+
+	top := func(origin string) int {
+		if query.Top > 0 {
+			return query.Top
+		}
+		if originTop, exists := s.topByOrigin[origin]; exists {
+			return originTop
+		}
+		return s.top
+	}
+
+	expectedRates, err := calculateExpectedRates(shipmentsByLane, top, queryEquipmentTypes(query), aggregator, s.filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the exchange rate from the base currency to the requested one
+	var exchangeRate *big.Rat
+	if query.Currency != "" && query.Currency != s.baseCurrency() {
+		if exchangeRate, err = s.exchangeRate(s.baseCurrency(), query.Currency); err != nil {
+			return nil, err
+		}
+	}
+
+	// Convert the exact rates and statistics, and round the rates
+	for i := range expectedRates {
+		if exchangeRate != nil {
+			expectedRates[i].Exact.Mul(expectedRates[i].Exact, exchangeRate)
+			convertStatistics(expectedRates[i].RateStatistics, exchangeRate)
+		}
+		expectedRates[i].Rate = s.rounding.Round(expectedRates[i].Exact)
+		expectedRates[i].PublishedAt = publishedAt
+	}
+
+	return expectedRates, nil
+}
+
+// GetExcludedQuotes retrieves the quotes the outlier filter excludes from the expected rates described by the query,
+// in the order of the lanes and then of the equipment types. Only the lanes, equipment types and date of the query
+// matter. The excluded quotes are empty without an outlier filter.
+func (s ShipmentService) GetExcludedQuotes(query domain.RateQuery) ([]domain.ExcludedQuote, error) {
+	if err := s.validateQuery(query); err != nil {
+		return nil, err
+	}
+
+	excludedQuotes := make([]domain.ExcludedQuote, 0)
+	if s.filter == nil {
+		return excludedQuotes, nil
+	}
+
+	shipmentsByLane, _ := s.queryShipments(query)
+	for _, laneShipments := range shipmentsByLane {
+		for _, equipment := range queryEquipmentTypes(query) {
+			_, excluded := filterOutliers(s.filter, laneShipments.Lane(), equipmentQuotes(laneShipments.Quotes, equipment))
+			excludedQuotes = append(excludedQuotes, excluded...)
 		}
+	}
+
+	return excludedQuotes, nil
+}
+
+// validateQuery returns an error if the query is invalid.
+func (s ShipmentService) validateQuery(query domain.RateQuery) error {
+	switch {
+	case query.Top < 0:
+		return domain.ErrInvalidTopValue // Return an error if the top value is invalid.
+	case query.Equipment != "" && !query.Equipment.Valid():
+		return domain.ErrInvalidEquipment // Return an error if the equipment type is unknown.
+	case query.Currency != "" && !query.Currency.Valid():
+		return domain.ErrInvalidCurrency // Return an error if the currency is not an ISO 4217 code.
+	case query.Method != "" && s.aggregators[query.Method] == nil:
+		return domain.ErrInvalidAggregation // Return an error if the aggregation method is unknown.
+	}
+
+	return nil
+}
+
+// queryShipments returns the sorted shipments of the lanes of the query, from the latest published batch together
+// with its publication time or, when the query has an AsOf date, the ones in effect on that date.
+func (s ShipmentService) queryShipments(query domain.RateQuery) ([]domain.OriginShipments, time.Time) {
+	// Get the latest sorted shipments, or the ones in effect on the requested date, by lane from the repository.
+	var shipmentsByLane []domain.OriginShipments
+	var publishedAt time.Time
+	if query.AsOf.IsZero() {
+		batch := s.r.GetLatestBatch()
+		shipmentsByLane, publishedAt = batch.ShipmentsByLane, batch.PublishedAt
+	} else {
+		shipmentsByLane = s.r.GetSortedShipmentsByOriginAsOf(query.AsOf)
+	}
+
+	if query.Lanes {
+		return withWildcardQuotes(shipmentsByLane), publishedAt
+	}
 
-		// Use the actual length of the slice, or a maximum of 10
-		unitsCount := len(originShipments.Quotes)
-		if unitsCount > top {
-			unitsCount = top
+	return wildcardLanes(shipmentsByLane), publishedAt
+}
+
+// queryEquipmentTypes returns the equipment type of the query, or every equipment type when the query has none.
+func queryEquipmentTypes(query domain.RateQuery) []domain.Equipment {
+	if query.Equipment != "" {
+		return []domain.Equipment{query.Equipment}
+	}
+
+	return domain.EquipmentTypes
+}
+
+// wildcardLanes returns the shipments of the wildcard lanes only.
+func wildcardLanes(shipmentsByLane []domain.OriginShipments) []domain.OriginShipments {
+	wildcards := make([]domain.OriginShipments, 0, len(shipmentsByLane))
+	for _, laneShipments := range shipmentsByLane {
+		if laneShipments.Lane().Destination == domain.WildcardDestination {
+			wildcards = append(wildcards, laneShipments)
 		}
+	}
+
+	return wildcards
+}
 
-		// Safely calculate the total price of the top origin shipments
-		totalPrice := 0
-		for _, originShipmentQuote := range originShipments.Quotes[:unitsCount] {
-			totalPrice += originShipmentQuote.Price
+// withWildcardQuotes returns the shipments of every lane, where the quotes of each specific lane also include the
+// wildcard quotes of its origin from the companies without a quote of their own for the lane and equipment type. The
+// quotes stay sorted by price, the provided shipments are not modified.
+func withWildcardQuotes(shipmentsByLane []domain.OriginShipments) []domain.OriginShipments {
+	// Find the wildcard quotes of every origin
+	wildcardQuotes := make(map[string][]domain.ShipmentQuote)
+	for _, laneShipments := range shipmentsByLane {
+		if laneShipments.Lane().Destination == domain.WildcardDestination {
+			wildcardQuotes[laneShipments.Origin] = laneShipments.Quotes
 		}
+	}
+
+	merged := make([]domain.OriginShipments, 0, len(shipmentsByLane))
+	for _, laneShipments := range shipmentsByLane {
+		lane := laneShipments.Lane()
+		quotes := laneShipments.Quotes
+
+		if lane.Destination != domain.WildcardDestination && len(wildcardQuotes[lane.Origin]) > 0 {
+			// Skip the wildcard quotes of the companies that quoted the lane themselves for the same equipment type
+			type companyEquipment struct {
+				company   int
+				equipment domain.Equipment
+			}
+			companies := make(map[companyEquipment]struct{}, len(laneShipments.Quotes))
+			for _, quote := range laneShipments.Quotes {
+				companies[companyEquipment{quote.Company, quote.EquipmentType()}] = struct{}{}
+			}
 
-		// Ensure unitsCount is not zero before calculating the average
-		if unitsCount > 0 {
-			expectedRates[originShipments.Origin] = totalPrice / unitsCount
+			quotes = append(make([]domain.ShipmentQuote, 0, len(laneShipments.Quotes)+len(wildcardQuotes[lane.Origin])), laneShipments.Quotes...)
+			for _, quote := range wildcardQuotes[lane.Origin] {
+				if _, exists := companies[companyEquipment{quote.Company, quote.EquipmentType()}]; !exists {
+					quotes = append(quotes, quote)
+				}
+			}
+
+			// Only the price matters for the expected rate, the order of equal prices is kept
+			sort.SliceStable(quotes, func(i, j int) bool { return quotes[i].Price < quotes[j].Price })
+		}
+
+		merged = append(merged, domain.OriginShipments{Origin: lane.Origin, Destination: lane.Destination, Quotes: quotes})
+	}
+
+	return merged
+}
+
+// calculateExpectedRates calculates the expected rate of each lane and equipment type by aggregating its first quotes,
+// as many as the top of its origin, after the outliers are excluded with the filter, if any. The quotes of every lane
+// must be sorted by price. Only the Exact rates and the statistics of the quotes, except their publication time, are
+// set, they are returned in the order of the lanes and then of the provided equipment types.
+func calculateExpectedRates(shipmentsByLane []domain.OriginShipments, top func(origin string) int, equipmentTypes []domain.Equipment, aggregator domain.Aggregator, filter domain.OutlierFilter) ([]domain.ExpectedRate, error) {
+	// Return an error if no expected rates are available
+	if len(shipmentsByLane) == 0 {
+		return nil, domain.ErrNoExpectedRates
+	}
+
+	// Calculate the expected rates for each lane and equipment type based on the top (lowest) lane shipments
+	expectedRates := make([]domain.ExpectedRate, 0, len(shipmentsByLane))
+	for _, laneShipments := range shipmentsByLane {
+		// Skip the lanes without quotes or origin, they have no expected rate
+		if len(laneShipments.Quotes) == 0 || strings.TrimSpace(laneShipments.Origin) == "" {
+			continue
+		}
+
+		originTop := top(laneShipments.Origin)
+		for _, equipment := range equipmentTypes {
+			// Select the top lane shipments of the equipment type that are not outliers, the quotes stay sorted
+			quotes, _ := filterOutliers(filter, laneShipments.Lane(), equipmentQuotes(laneShipments.Quotes, equipment))
+			topQuotes := quotes[:min(originTop, len(quotes))]
+
+			// Ensure there are quotes before aggregating them
+			if len(topQuotes) > 0 {
+				expectedRates = append(expectedRates, domain.ExpectedRate{
+					Lane:           laneShipments.Lane(),
+					Equipment:      equipment,
+					Exact:          aggregator.Aggregate(topQuotes),
+					Quotes:         len(topQuotes),
+					RateStatistics: rateStatistics(topQuotes),
+				})
+			}
 		}
 	}
 
@@ -62,25 +359,193 @@ func (s ShipmentService) GetLatestExpectedRates(top int) (map[string]int, error)
 	return expectedRates, nil
 }
 
-// SubmitShipment submits a new shipment unit to the repository.
+// equipmentQuotes returns the quotes priced for the equipment type, they stay sorted.
+func equipmentQuotes(quotes []domain.ShipmentQuote, equipment domain.Equipment) []domain.ShipmentQuote {
+	selected := make([]domain.ShipmentQuote, 0, len(quotes))
+	for _, quote := range quotes {
+		if quote.EquipmentType() == equipment {
+			selected = append(selected, quote)
+		}
+	}
+
+	return selected
+}
+
+// filterOutliers returns the quotes of the lane the filter keeps, and the excluded ones. Every quote is kept without a
+// filter.
+func filterOutliers(filter domain.OutlierFilter, lane domain.Lane, quotes []domain.ShipmentQuote) ([]domain.ShipmentQuote, []domain.ExcludedQuote) {
+	if filter == nil {
+		return quotes, nil
+	}
+
+	kept, excluded := filter.Filter(quotes)
+	for i := range excluded {
+		excluded[i].Lane = lane
+	}
+
+	return kept, excluded
+}
+
+// SubmitShipment submits a new shipment unit to the repository. A shipment unit with a Quoted price is stored with its
+// price converted to the base currency and rounded to minor units, a Quoted price without currency is in the base
+// currency.
 func (s ShipmentService) SubmitShipment(shipment *domain.ShipmentUnit) error {
 	if shipment == nil {
 		slog.Warn("failed to submit shipment", "error", domain.ErrNilShipmentUnit)
 		return domain.ErrNilShipmentUnit // Return an error if the shipment is nil.
 	}
 
+	normalized, err := s.normalizeShipment(*shipment)
+	if err != nil {
+		return err
+	}
+
+	return s.r.AddOrUpdate(normalized) // Store the shipment in the repository.
+}
+
+// SubmitShipments submits the shipment units to the repository at once, normalized like SubmitShipment. It returns one
+// error per shipment unit, in the order of the shipment units, nil when the shipment unit was accepted.
+func (s ShipmentService) SubmitShipments(shipments []domain.ShipmentUnit) []error {
+	errs := make([]error, len(shipments))
+
+	// Only the valid shipment units reach the repository, their indices map the repository errors back
+	normalized := make([]domain.ShipmentUnit, 0, len(shipments))
+	indices := make([]int, 0, len(shipments))
+	for i, shipment := range shipments {
+		shipment, err := s.normalizeShipment(shipment)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		normalized = append(normalized, shipment)
+		indices = append(indices, i)
+	}
+
+	if len(normalized) == 0 {
+		return errs
+	}
+
+	for j, err := range s.r.AddOrUpdateAll(normalized) {
+		errs[indices[j]] = err
+	}
+
+	return errs
+}
+
+// normalizeShipment returns a copy of the shipment unit with its Quoted price converted to the base currency, or the
+// reason the shipment unit is invalid. The quotes are ranked by their price in the base currency.
+func (s ShipmentService) normalizeShipment(shipment domain.ShipmentUnit) (domain.ShipmentUnit, error) {
+	if shipment.Quoted != (domain.Money{}) {
+		if shipment.Quoted.Currency == "" {
+			shipment.Quoted.Currency = s.baseCurrency()
+		}
+		if !shipment.Quoted.Currency.Valid() {
+			return shipment, domain.ErrInvalidCurrency // Return an error if the currency is not an ISO 4217 code.
+		}
+
+		exchangeRate, err := s.exchangeRate(shipment.Quoted.Currency, s.baseCurrency())
+		if err != nil {
+			return shipment, err // Return an error if the currency is not supported.
+		}
+		shipment.Price = s.rounding.Round(new(big.Rat).Mul(big.NewRat(int64(shipment.Quoted.Amount), 1), exchangeRate))
+	}
+
 	switch {
 	case strings.TrimSpace(shipment.Origin) == "":
-		return domain.ErrInvalidOriginPort // Return an error if the origin port is empty.
+		return shipment, domain.ErrInvalidOriginPort // Return an error if the origin port is empty.
+	case shipment.Destination == shipment.Origin:
+		return shipment, domain.ErrInvalidDestinationPort // Return an error if the shipment ends where it starts.
 	case shipment.Price <= 0:
-		return domain.ErrInvalidPrice // Return an error if the price is invalid.
+		return shipment, domain.ErrInvalidPrice // Return an error if the price is invalid.
 	case shipment.Date.IsZero():
-		return domain.ErrInvalidDate // Return an error if the date is invalid.
+		return shipment, domain.ErrInvalidDate // Return an error if the date is invalid.
 	case shipment.Company <= 0:
-		return domain.ErrInvalidCompany // Return an error if the company is invalid.
+		return shipment, domain.ErrInvalidCompany // Return an error if the company is invalid.
+	case !shipment.ValidUntil.IsZero() && !shipment.ValidUntil.After(shipment.Date):
+		return shipment, domain.ErrInvalidValidity // Return an error if the quote expires before it starts.
+	case !shipment.EquipmentType().Valid():
+		return shipment, domain.ErrInvalidEquipment // Return an error if the equipment type is unknown.
+	}
+
+	// The ports must be active in the registry, the wildcard destination is not a port
+	if err := s.activePort(shipment.Origin); err != nil {
+		return shipment, fmt.Errorf("%w: %w", domain.ErrInvalidOriginPort, err)
+	}
+	if shipment.Destination != "" {
+		if err := s.activePort(shipment.Destination); err != nil {
+			return shipment, fmt.Errorf("%w: %w", domain.ErrInvalidDestinationPort, err)
+		}
 	}
 
-	return s.r.AddOrUpdate(*shipment) // Store the shipment in the repository.
+	// The company must be active in the registry
+	if err := s.activeCompany(shipment.Company); err != nil {
+		return shipment, fmt.Errorf("%w: %w", domain.ErrInvalidCompany, err)
+	}
+
+	return shipment, nil
+}
+
+// activePort returns domain.ErrUnknownPort if the port registry does not list the port, domain.ErrInactivePort if the
+// port is disabled, or nil. Every port is active without registry.
+func (s ShipmentService) activePort(code string) error {
+	if s.ports == nil {
+		return nil
+	}
+
+	port, exists := s.ports.Port(code)
+	switch {
+	case !exists:
+		return fmt.Errorf("%w: %q", domain.ErrUnknownPort, code)
+	case !port.Active:
+		return fmt.Errorf("%w: %q", domain.ErrInactivePort, code)
+	}
+
+	return nil
+}
+
+// activeCompany returns domain.ErrUnknownCompany if the company registry does not list the company,
+// domain.ErrSuspendedCompany if the company is suspended, or nil. Every company is active without registry.
+func (s ShipmentService) activeCompany(id int) error {
+	if s.companies == nil {
+		return nil
+	}
+
+	company, exists := s.companies.Company(id)
+	switch {
+	case !exists:
+		return fmt.Errorf("%w: %d", domain.ErrUnknownCompany, id)
+	case company.Status != domain.CompanyActive:
+		return fmt.Errorf("%w: %d", domain.ErrSuspendedCompany, id)
+	}
+
+	return nil
+}
+
+// baseCurrency returns the currency the quotes are normalized to.
+func (s ShipmentService) baseCurrency() domain.Currency {
+	if s.fx == nil {
+		return domain.DefaultCurrency
+	}
+
+	return s.fx.Base()
+}
+
+// exchangeRate returns the exact amount of the to currency worth one unit of the from currency, with the exchange rates
+// of the service.
+func (s ShipmentService) exchangeRate(from, to domain.Currency) (*big.Rat, error) {
+	if s.fx != nil {
+		return s.fx.Rate(from, to)
+	}
+
+	// Without exchange rates, only the default currency is supported
+	if from != domain.DefaultCurrency {
+		return nil, domain.ErrUnsupportedCurrency
+	}
+	if to != domain.DefaultCurrency {
+		return nil, domain.ErrUnsupportedCurrency
+	}
+
+	return big.NewRat(1, 1), nil
 }
 
 // IncrementShipmentUnitsCount increments the internal counter for received shipment units.
@@ -88,12 +553,74 @@ func (s ShipmentService) IncrementShipmentUnitsCount() {
 	s.r.IncrementShipmentUnitsCount() // Calls the repository method to increment the shipment count.
 }
 
-// CreateShipmentService creates a new instance of ShipmentService with the provided repository.
-func CreateShipmentService(repository domain.ShipmentRepository) (*ShipmentService, error) {
+// RecordRejectedShipment records a shipment unit that was rejected before submission, together with the reason, in the
+// audit trail of its origin and company.
+func (s ShipmentService) RecordRejectedShipment(shipment *domain.ShipmentUnit, reason error) error {
+	if shipment == nil {
+		return domain.ErrNilShipmentUnit // Return an error if the shipment is nil.
+	}
+
+	return s.r.RecordRejectedShipment(*shipment, reason) // Append the rejection to the audit trail in the repository.
+}
+
+// GetLatestQuotes retrieves the quotes of the latest published batch, the ones the expected rates are calculated from,
+// grouped by lane in the order the lanes were first submitted and sorted by price. The prices are in minor units of the
+// base currency. The returned shipments are shared with the batch and must not be modified.
+func (s ShipmentService) GetLatestQuotes() ([]domain.OriginShipments, error) {
+	return s.r.GetLatestSortedShipmentsByOrigin(), nil
+}
+
+// GetQuoteHistory retrieves every accepted and rejected submission of the company for the origin, in the order they
+// were received.
+func (s ShipmentService) GetQuoteHistory(origin string, company int) ([]domain.AuditEntry, error) {
+	switch {
+	case strings.TrimSpace(origin) == "":
+		return nil, domain.ErrInvalidOriginPort // Return an error if the origin port is empty.
+	case company <= 0:
+		return nil, domain.ErrInvalidCompany // Return an error if the company is invalid.
+	}
+
+	history := s.r.GetQuoteHistory(origin, company)
+
+	// Return an error if the company never submitted a quote for the origin
+	if len(history) == 0 {
+		return nil, domain.ErrNoQuoteHistory
+	}
+
+	return history, nil
+}
+
+// DeleteCompanyQuotes deletes every quote of the company for the origin, current or not, and returns the number of
+// deleted quotes. The audit trail of the company keeps its submissions together with the deletion.
+func (s ShipmentService) DeleteCompanyQuotes(origin string, company int) (int, error) {
+	switch {
+	case strings.TrimSpace(origin) == "":
+		return 0, domain.ErrInvalidOriginPort // Return an error if the origin port is empty.
+	case company <= 0:
+		return 0, domain.ErrInvalidCompany // Return an error if the company is invalid.
+	}
+
+	return s.r.DeleteCompanyQuotes(origin, company) // Delete the quotes in the repository.
+}
+
+// CreateShipmentService creates a new instance of ShipmentService with the provided repository and options.
+func CreateShipmentService(repository domain.ShipmentRepository, options ...ServiceOption) (*ShipmentService, error) {
 	if repository == nil {
 		slog.Error("failed to create shipment service", "error", domain.ErrNilRepository)
 		return nil, domain.ErrNilRepository // Return an error if the repository is nil.
 	}
 
-	return &ShipmentService{r: repository}, nil // Return a new instance of ShipmentService.
+	service := &ShipmentService{
+		r:           repository,
+		rounding:    domain.DefaultRoundingMode,
+		aggregator:  MeanAggregator{},
+		aggregators: builtinAggregators(),
+		top:         DefaultTop,
+		topByOrigin: make(map[string]int),
+	}
+	for _, option := range options {
+		option(service)
+	}
+
+	return service, nil // Return a new instance of ShipmentService.
 }
@@ -3,12 +3,17 @@ package app
 import (
 	"context"
 	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"quoteship/domain"
 	"quoteship/persistence"
+	"quoteship/ports"
 )
 
 func TestShipmentService_GetLatestExpectedRates(t *testing.T) {
@@ -32,6 +37,19 @@ func TestShipmentService_GetLatestExpectedRates(t *testing.T) {
 			expectedError: domain.ErrInvalidTopValue,
 			expectedRates: nil,
 		},
+		{
+			name: "invalid input - zero top",
+			repository: func(ctx context.Context, i int) (*persistence.ShipmentRepository, error) {
+				repository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+				if err != nil {
+					return nil, err
+				}
+				return repository, nil
+			},
+			input:         0,
+			expectedError: domain.ErrInvalidTopValue,
+			expectedRates: nil,
+		},
 		{
 			name: "received no expected rates from repository",
 			repository: func(ctx context.Context, i int) (*persistence.ShipmentRepository, error) {
@@ -133,6 +151,610 @@ func TestShipmentService_GetLatestExpectedRates(t *testing.T) {
 	}
 }
 
+func TestShipmentService_GetExpectedRates(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	repository, err := persistence.NewShipmentOfferRepository(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	for _, shipment := range []domain.ShipmentUnit{
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 100, Date: date}},
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 2, Price: 200, Date: date}},
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 500, Date: date.AddDate(0, 1, 0)}},
+		{Origin: "NYC", Destination: "ROT", ShipmentQuote: domain.ShipmentQuote{Company: 2, Price: 150, Date: date.AddDate(0, 1, 0)}},
+		{Origin: "NYC", Destination: "ROT", ShipmentQuote: domain.ShipmentQuote{Company: 3, Price: 400, Date: date.AddDate(0, 1, 0)}},
+		{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 300, Date: date.AddDate(0, 1, 0)}},
+		{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 900, Date: date.AddDate(0, 1, 0), Equipment: domain.Equipment40HC}},
+	} {
+		if err = repository.AddOrUpdate(shipment); err != nil {
+			t.Fatalf("failed to add shipment unit: %v", err)
+		}
+	}
+
+	service, err := CreateShipmentService(repository)
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		query         domain.RateQuery
+		expectedError error
+		expectedRates []domain.ExpectedRate
+	}{
+		{
+			name:          "invalid input - negative top",
+			query:         domain.RateQuery{Top: -1, AsOf: date},
+			expectedError: domain.ErrInvalidTopValue,
+		},
+		{
+			name:          "invalid input - unknown equipment type",
+			query:         domain.RateQuery{Top: 10, AsOf: date, Equipment: "45HC"},
+			expectedError: domain.ErrInvalidEquipment,
+		},
+		{
+			name:          "no published batch without a date",
+			query:         domain.RateQuery{Top: 10},
+			expectedError: domain.ErrNoExpectedRates,
+		},
+		{
+			name:          "no quotes in effect",
+			query:         domain.RateQuery{Top: 10, AsOf: date.AddDate(0, 0, -1)},
+			expectedError: domain.ErrNoExpectedRates,
+		},
+		{
+			name:  "valid input - before the updates",
+			query: domain.RateQuery{Top: 10, AsOf: date.AddDate(0, 0, 15)},
+			expectedRates: []domain.ExpectedRate{
+				{Lane: domain.NewLane("NYC", ""), Equipment: domain.Equipment20DV, Rate: 150, Exact: big.NewRat(150, 1), Quotes: 2},
+			},
+		},
+		{
+			name:  "valid input - after the updates",
+			query: domain.RateQuery{Top: 10, AsOf: date.AddDate(0, 1, 0)},
+			expectedRates: []domain.ExpectedRate{
+				{Lane: domain.NewLane("NYC", ""), Equipment: domain.Equipment20DV, Rate: 350, Exact: big.NewRat(350, 1), Quotes: 2},
+				{Lane: domain.NewLane("LAX", ""), Equipment: domain.Equipment20DV, Rate: 300, Exact: big.NewRat(300, 1), Quotes: 1},
+				{Lane: domain.NewLane("LAX", ""), Equipment: domain.Equipment40HC, Rate: 900, Exact: big.NewRat(900, 1), Quotes: 1},
+			},
+		},
+		{
+			name:  "valid input - top cheapest",
+			query: domain.RateQuery{Top: 1, AsOf: date.AddDate(0, 1, 0)},
+			expectedRates: []domain.ExpectedRate{
+				{Lane: domain.NewLane("NYC", ""), Equipment: domain.Equipment20DV, Rate: 200, Exact: big.NewRat(200, 1), Quotes: 1},
+				{Lane: domain.NewLane("LAX", ""), Equipment: domain.Equipment20DV, Rate: 300, Exact: big.NewRat(300, 1), Quotes: 1},
+				{Lane: domain.NewLane("LAX", ""), Equipment: domain.Equipment40HC, Rate: 900, Exact: big.NewRat(900, 1), Quotes: 1},
+			},
+		},
+		{
+			name:  "valid input - single equipment type",
+			query: domain.RateQuery{Top: 10, AsOf: date.AddDate(0, 1, 0), Equipment: domain.Equipment40HC},
+			expectedRates: []domain.ExpectedRate{
+				{Lane: domain.NewLane("LAX", ""), Equipment: domain.Equipment40HC, Rate: 900, Exact: big.NewRat(900, 1), Quotes: 1},
+			},
+		},
+		{
+			name:  "valid input - lanes with the wildcard quotes of other companies",
+			query: domain.RateQuery{Top: 10, AsOf: date.AddDate(0, 1, 0), Lanes: true},
+			expectedRates: []domain.ExpectedRate{
+				{Lane: domain.NewLane("NYC", ""), Equipment: domain.Equipment20DV, Rate: 350, Exact: big.NewRat(350, 1), Quotes: 2},
+				{Lane: domain.NewLane("NYC", "ROT"), Equipment: domain.Equipment20DV, Rate: 350, Exact: big.NewRat(350, 1), Quotes: 3}, // 150 and 400 quoted for the lane, 500 for every destination
+				{Lane: domain.NewLane("LAX", ""), Equipment: domain.Equipment20DV, Rate: 300, Exact: big.NewRat(300, 1), Quotes: 1},
+				{Lane: domain.NewLane("LAX", ""), Equipment: domain.Equipment40HC, Rate: 900, Exact: big.NewRat(900, 1), Quotes: 1},
+			},
+		},
+		{
+			name:          "invalid input - unknown aggregation method",
+			query:         domain.RateQuery{Top: 10, AsOf: date, Method: "mode"},
+			expectedError: domain.ErrInvalidAggregation,
+		},
+		{
+			name:  "valid input - median",
+			query: domain.RateQuery{Top: 10, AsOf: date.AddDate(0, 1, 0), Lanes: true, Method: MethodMedian},
+			expectedRates: []domain.ExpectedRate{
+				{Lane: domain.NewLane("NYC", ""), Equipment: domain.Equipment20DV, Rate: 350, Exact: big.NewRat(350, 1), Quotes: 2},
+				{Lane: domain.NewLane("NYC", "ROT"), Equipment: domain.Equipment20DV, Rate: 400, Exact: big.NewRat(400, 1), Quotes: 3}, // 150, 400 and 500
+				{Lane: domain.NewLane("LAX", ""), Equipment: domain.Equipment20DV, Rate: 300, Exact: big.NewRat(300, 1), Quotes: 1},
+				{Lane: domain.NewLane("LAX", ""), Equipment: domain.Equipment40HC, Rate: 900, Exact: big.NewRat(900, 1), Quotes: 1},
+			},
+		},
+		{
+			name:  "valid input - max",
+			query: domain.RateQuery{Top: 10, AsOf: date.AddDate(0, 1, 0), Method: MethodMax},
+			expectedRates: []domain.ExpectedRate{
+				{Lane: domain.NewLane("NYC", ""), Equipment: domain.Equipment20DV, Rate: 500, Exact: big.NewRat(500, 1), Quotes: 2},
+				{Lane: domain.NewLane("LAX", ""), Equipment: domain.Equipment20DV, Rate: 300, Exact: big.NewRat(300, 1), Quotes: 1},
+				{Lane: domain.NewLane("LAX", ""), Equipment: domain.Equipment40HC, Rate: 900, Exact: big.NewRat(900, 1), Quotes: 1},
+			},
+		},
+		{
+			name:  "valid input - lanes top cheapest",
+			query: domain.RateQuery{Top: 2, AsOf: date.AddDate(0, 1, 0), Lanes: true},
+			expectedRates: []domain.ExpectedRate{
+				{Lane: domain.NewLane("NYC", ""), Equipment: domain.Equipment20DV, Rate: 350, Exact: big.NewRat(350, 1), Quotes: 2},
+				{Lane: domain.NewLane("NYC", "ROT"), Equipment: domain.Equipment20DV, Rate: 275, Exact: big.NewRat(275, 1), Quotes: 2},
+				{Lane: domain.NewLane("LAX", ""), Equipment: domain.Equipment20DV, Rate: 300, Exact: big.NewRat(300, 1), Quotes: 1},
+				{Lane: domain.NewLane("LAX", ""), Equipment: domain.Equipment40HC, Rate: 900, Exact: big.NewRat(900, 1), Quotes: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates, err := service.GetExpectedRates(tt.query)
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+
+			if !equalExpectedRates(rates, tt.expectedRates) {
+				t.Errorf("expected rates %v, got %v", tt.expectedRates, rates)
+			}
+		})
+	}
+}
+
+func TestShipmentService_rounding(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	repository, err := persistence.NewShipmentOfferRepository(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	for i, price := range []int{10050, 10051, 10151, 10152} {
+		origin := "NYC"
+		if i >= 2 {
+			origin = "LAX"
+		}
+		shipment := domain.ShipmentUnit{Origin: origin, ShipmentQuote: domain.ShipmentQuote{Company: i + 1, Price: price, Date: date}}
+		if err = repository.AddOrUpdate(shipment); err != nil {
+			t.Fatalf("failed to add shipment unit: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		mode          domain.RoundingMode
+		expectedRates map[string]int
+	}{
+		{name: "half-even", mode: domain.RoundHalfEven, expectedRates: map[string]int{"NYC": 10050, "LAX": 10152}}, // 10050.5 and 10151.5
+		{name: "half-up", mode: domain.RoundHalfUp, expectedRates: map[string]int{"NYC": 10051, "LAX": 10152}},
+		{name: "down", mode: domain.RoundDown, expectedRates: map[string]int{"NYC": 10050, "LAX": 10151}},
+		{name: "up", mode: domain.RoundUp, expectedRates: map[string]int{"NYC": 10051, "LAX": 10152}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, err := CreateShipmentService(repository, WithRoundingMode(tt.mode))
+			if err != nil {
+				t.Fatalf("failed to create shipment service: %v", err)
+			}
+
+			rates, err := service.GetExpectedRates(domain.RateQuery{Top: 10, AsOf: date})
+			if err != nil {
+				t.Fatalf("failed to get expected rates: %v", err)
+			}
+
+			for _, rate := range rates {
+				if rate.Rate != tt.expectedRates[rate.Origin] {
+					t.Errorf("expected rate %d for %s, got %d (exactly %v)", tt.expectedRates[rate.Origin], rate.Origin, rate.Rate, rate.Exact)
+				}
+			}
+		})
+	}
+}
+
+func TestShipmentService_WithAggregator(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	repository, err := persistence.NewShipmentOfferRepository(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	for i, price := range []int{100, 200, 900} {
+		shipment := domain.ShipmentUnit{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: i + 1, Price: price, Date: date}}
+		if err = repository.AddOrUpdate(shipment); err != nil {
+			t.Fatalf("failed to add shipment unit: %v", err)
+		}
+	}
+
+	service, err := CreateShipmentService(repository, WithAggregator(MinAggregator{}))
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		expectedRate int
+	}{
+		{name: "default aggregator", expectedRate: 100},
+		{name: "selected aggregator", method: MethodMedian, expectedRate: 200},
+		{name: "default aggregator selected by name", method: MethodMin, expectedRate: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates, err := service.GetExpectedRates(domain.RateQuery{Top: 10, AsOf: date, Method: tt.method})
+			if err != nil {
+				t.Fatalf("failed to get expected rates: %v", err)
+			}
+
+			if len(rates) != 1 || rates[0].Rate != tt.expectedRate {
+				t.Errorf("expected rate %d, got %+v", tt.expectedRate, rates)
+			}
+		})
+	}
+
+	// A nil aggregator is ignored, the mean stays the default
+	service, err = CreateShipmentService(repository, WithAggregator(nil))
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+	if rates, err := service.GetExpectedRates(domain.RateQuery{Top: 10, AsOf: date}); err != nil || len(rates) != 1 || rates[0].Rate != 400 {
+		t.Errorf("expected rate 400, got %+v and %v", rates, err)
+	}
+}
+
+func TestShipmentService_WithTop(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	repository, err := persistence.NewShipmentOfferRepository(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	for i, price := range []int{100, 200, 300, 400} {
+		for _, origin := range []string{"NYC", "LAX"} {
+			shipment := domain.ShipmentUnit{Origin: origin, ShipmentQuote: domain.ShipmentQuote{Company: i + 1, Price: price, Date: date}}
+			if err = repository.AddOrUpdate(shipment); err != nil {
+				t.Fatalf("failed to add shipment unit: %v", err)
+			}
+		}
+	}
+
+	service, err := CreateShipmentService(repository, WithTop(3, map[string]int{"LAX": 1, "SFO": 0}))
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		top            int
+		expectedRates  map[string]int
+		expectedQuotes map[string]int
+	}{
+		{
+			name:           "default top of every origin",
+			expectedRates:  map[string]int{"NYC": 200, "LAX": 100},
+			expectedQuotes: map[string]int{"NYC": 3, "LAX": 1},
+		},
+		{
+			name:           "top of the query",
+			top:            2,
+			expectedRates:  map[string]int{"NYC": 150, "LAX": 150},
+			expectedQuotes: map[string]int{"NYC": 2, "LAX": 2},
+		},
+		{
+			name:           "fewer quotes than the top",
+			top:            25,
+			expectedRates:  map[string]int{"NYC": 250, "LAX": 250},
+			expectedQuotes: map[string]int{"NYC": 4, "LAX": 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates, err := service.GetExpectedRates(domain.RateQuery{Top: tt.top, AsOf: date})
+			if err != nil {
+				t.Fatalf("failed to get expected rates: %v", err)
+			}
+
+			for _, rate := range rates {
+				if rate.Rate != tt.expectedRates[rate.Origin] || rate.Quotes != tt.expectedQuotes[rate.Origin] {
+					t.Errorf("expected rate %d of %d quotes for %s, got %d of %d", tt.expectedRates[rate.Origin], tt.expectedQuotes[rate.Origin], rate.Origin, rate.Rate, rate.Quotes)
+				}
+			}
+		})
+	}
+}
+
+func TestShipmentService_WithPortRegistry(t *testing.T) {
+	registry, err := ports.Load(strings.NewReader(`code,name,country,timezone,active
+CNSGH,Shanghai,CN,Asia/Shanghai,true
+CNTXG,Tianjin Xingang,CN,Asia/Shanghai,false
+NLRTM,Rotterdam,NL,Europe/Amsterdam,true
+`))
+	if err != nil {
+		t.Fatalf("failed to load ports: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		origin        string
+		destination   string
+		expectedError error
+	}{
+		{
+			name:   "active origin - wildcard destination",
+			origin: "CNSGH",
+		},
+		{
+			name:        "active origin and destination",
+			origin:      "CNSGH",
+			destination: "NLRTM",
+		},
+		{
+			name:          "unknown origin",
+			origin:        "NYC",
+			expectedError: domain.ErrUnknownPort,
+		},
+		{
+			name:          "inactive origin",
+			origin:        "CNTXG",
+			expectedError: domain.ErrInactivePort,
+		},
+		{
+			name:          "unknown destination",
+			origin:        "CNSGH",
+			destination:   "DEHAM",
+			expectedError: domain.ErrInvalidDestinationPort,
+		},
+		{
+			name:          "inactive destination",
+			origin:        "NLRTM",
+			destination:   "CNTXG",
+			expectedError: domain.ErrInactivePort,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+			if err != nil {
+				t.Fatalf("failed to create shipment repository: %v", err)
+			}
+			service, err := CreateShipmentService(repository, WithPortRegistry(registry))
+			if err != nil {
+				t.Fatalf("failed to create shipment service: %v", err)
+			}
+
+			err = service.SubmitShipment(&domain.ShipmentUnit{Origin: tt.origin, Destination: tt.destination, ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 100, Date: time.Now()}})
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestShipmentService_WithCompanyRegistry(t *testing.T) {
+	registry, err := persistence.OpenCompanyRegistry(filepath.Join(t.TempDir(), "companies.json"))
+	if err != nil {
+		t.Fatalf("failed to open company registry: %v", err)
+	}
+	for _, company := range []domain.Company{
+		{ID: 1, Name: "Blue Whale Lines"},
+		{ID: 2, Name: "Red Sea Cargo", Status: domain.CompanySuspended},
+	} {
+		if _, err := registry.Register(company); err != nil {
+			t.Fatalf("failed to register company: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		company       int
+		expectedError error
+	}{
+		{
+			name:    "active company",
+			company: 1,
+		},
+		{
+			name:          "suspended company",
+			company:       2,
+			expectedError: domain.ErrSuspendedCompany,
+		},
+		{
+			name:          "unknown company",
+			company:       3,
+			expectedError: domain.ErrUnknownCompany,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+			if err != nil {
+				t.Fatalf("failed to create shipment repository: %v", err)
+			}
+			service, err := CreateShipmentService(repository, WithCompanyRegistry(registry))
+			if err != nil {
+				t.Fatalf("failed to create shipment service: %v", err)
+			}
+
+			err = service.SubmitShipment(&domain.ShipmentUnit{Origin: "CNSGH", ShipmentQuote: domain.ShipmentQuote{Company: tt.company, Price: 100, Date: time.Now()}})
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if err != nil && !errors.Is(err, domain.ErrInvalidCompany) {
+				t.Errorf("expected error %v, got %v", domain.ErrInvalidCompany, err)
+			}
+		})
+	}
+}
+
+func TestShipmentService_WithOutlierFilter(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	repository, err := persistence.NewShipmentOfferRepository(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	for i, price := range []int{100, 85000, 90000, 95000, 100000} {
+		shipment := domain.ShipmentUnit{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: i + 1, Price: price, Date: date}}
+		if err = repository.AddOrUpdate(shipment); err != nil {
+			t.Fatalf("failed to add shipment unit: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name             string
+		filter           domain.OutlierFilter
+		expectedRate     int
+		expectedQuotes   int
+		expectedExcluded []domain.ExcludedQuote
+	}{
+		{
+			name:             "without filter",
+			expectedRate:     74020, // The fat-fingered price of 1.00 drags the rate down
+			expectedQuotes:   5,
+			expectedExcluded: []domain.ExcludedQuote{},
+		},
+		{
+			name:           "with filter",
+			filter:         IQRFilter{Multiplier: DefaultIQRMultiplier},
+			expectedRate:   92500,
+			expectedQuotes: 4,
+			expectedExcluded: []domain.ExcludedQuote{
+				{
+					Lane:          domain.NewLane("NYC", ""),
+					ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 100, Date: date},
+					Reason:        "price 1.00 is below the lower fence 700.00 of the interquartile range",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, err := CreateShipmentService(repository, WithOutlierFilter(tt.filter))
+			if err != nil {
+				t.Fatalf("failed to create shipment service: %v", err)
+			}
+
+			query := domain.RateQuery{AsOf: date, Equipment: domain.DefaultEquipment}
+			rates, err := service.GetExpectedRates(query)
+			if err != nil || len(rates) != 1 {
+				t.Fatalf("expected a single rate, got %+v, %v", rates, err)
+			}
+			if rates[0].Rate != tt.expectedRate || rates[0].Quotes != tt.expectedQuotes {
+				t.Errorf("expected rate %d of %d quotes, got %d of %d", tt.expectedRate, tt.expectedQuotes, rates[0].Rate, rates[0].Quotes)
+			}
+
+			excluded, err := service.GetExcludedQuotes(query)
+			if err != nil {
+				t.Fatalf("failed to get excluded quotes: %v", err)
+			}
+			if !reflect.DeepEqual(excluded, tt.expectedExcluded) {
+				t.Errorf("expected excluded quotes %+v, got %+v", tt.expectedExcluded, excluded)
+			}
+		})
+	}
+}
+
+func TestShipmentService_statistics(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	path := filepath.Join(t.TempDir(), "fx.json")
+	if err := os.WriteFile(path, []byte(`{"base": "USD", "rates": {"EUR": 0.5}}`), 0o600); err != nil {
+		t.Fatalf("failed to write exchange rates file: %v", err)
+	}
+	exchangeRates, err := persistence.NewFXRateTable(context.Background(), path, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create exchange rates table: %v", err)
+	}
+
+	repository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	before := time.Now()
+	for i, price := range []int{100, 300} {
+		shipment := domain.ShipmentUnit{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: i + 1, Price: price, Date: date.AddDate(0, 0, i)}}
+		if err = repository.AddOrUpdate(shipment); err != nil {
+			t.Fatalf("failed to add shipment unit: %v", err)
+		}
+		repository.IncrementShipmentUnitsCount()
+	}
+	after := time.Now()
+
+	service, err := CreateShipmentService(repository, WithExchangeRates(exchangeRates))
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	tests := []struct {
+		name              string
+		query             domain.RateQuery
+		expectedMin       *big.Rat
+		expectedMax       *big.Rat
+		expectedStdDev    *big.Rat
+		expectedPublished bool
+	}{
+		{
+			name:              "latest batch",
+			query:             domain.RateQuery{Equipment: domain.DefaultEquipment},
+			expectedMin:       big.NewRat(100, 1),
+			expectedMax:       big.NewRat(300, 1),
+			expectedStdDev:    big.NewRat(100, 1),
+			expectedPublished: true,
+		},
+		{
+			name:              "converted to another currency",
+			query:             domain.RateQuery{Equipment: domain.DefaultEquipment, Currency: "EUR"},
+			expectedMin:       big.NewRat(50, 1),
+			expectedMax:       big.NewRat(150, 1),
+			expectedStdDev:    big.NewRat(50, 1),
+			expectedPublished: true,
+		},
+		{
+			name:           "as of a date - no batch",
+			query:          domain.RateQuery{Equipment: domain.DefaultEquipment, AsOf: date.AddDate(0, 0, 1)},
+			expectedMin:    big.NewRat(100, 1),
+			expectedMax:    big.NewRat(300, 1),
+			expectedStdDev: big.NewRat(100, 1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates, err := service.GetExpectedRates(tt.query)
+			if err != nil || len(rates) != 1 {
+				t.Fatalf("expected a single rate, got %+v, %v", rates, err)
+			}
+
+			rate := rates[0]
+			if rate.Min.Cmp(tt.expectedMin) != 0 || rate.Max.Cmp(tt.expectedMax) != 0 || rate.StdDev.Cmp(tt.expectedStdDev) != 0 {
+				t.Errorf("expected min %v, max %v and standard deviation %v, got %v, %v and %v", tt.expectedMin, tt.expectedMax, tt.expectedStdDev, rate.Min, rate.Max, rate.StdDev)
+			}
+			if !rate.Oldest.Equal(date) || !rate.Newest.Equal(date.AddDate(0, 0, 1)) {
+				t.Errorf("expected quotes from %v to %v, got %v to %v", date, date.AddDate(0, 0, 1), rate.Oldest, rate.Newest)
+			}
+
+			published := !rate.PublishedAt.IsZero()
+			if published != tt.expectedPublished || (published && (rate.PublishedAt.Before(before) || rate.PublishedAt.After(after))) {
+				t.Errorf("expected published %t between %v and %v, got %v", tt.expectedPublished, before, after, rate.PublishedAt)
+			}
+		})
+	}
+}
+
+// equalExpectedRates reports whether the expected rates are equal, comparing their exact rates by value.
+func equalExpectedRates(a, b []domain.ExpectedRate) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].Lane != b[i].Lane || a[i].Equipment != b[i].Equipment || a[i].Rate != b[i].Rate || a[i].Quotes != b[i].Quotes || a[i].Exact.Cmp(b[i].Exact) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
 func TestShipmentService_SubmitShipment(t *testing.T) {
 	shipmentUnit := &domain.ShipmentUnit{
 		Origin: "NYC",
@@ -217,6 +839,50 @@ func TestShipmentService_SubmitShipment(t *testing.T) {
 			},
 			expectedError: domain.ErrInvalidCompany,
 		},
+		{
+			name: "invalid shipment - destination is the origin",
+			repository: func(ctx context.Context, i int) (*persistence.ShipmentRepository, error) {
+				return &persistence.ShipmentRepository{}, nil
+			},
+			input: &domain.ShipmentUnit{
+				Origin:        shipmentUnit.Origin,
+				Destination:   shipmentUnit.Origin,
+				ShipmentQuote: shipmentUnit.ShipmentQuote,
+			},
+			expectedError: domain.ErrInvalidDestinationPort,
+		},
+		{
+			name: "invalid shipment - unknown equipment type",
+			repository: func(ctx context.Context, i int) (*persistence.ShipmentRepository, error) {
+				return &persistence.ShipmentRepository{}, nil
+			},
+			input: &domain.ShipmentUnit{
+				Origin: shipmentUnit.Origin,
+				ShipmentQuote: domain.ShipmentQuote{
+					Company:   shipmentUnit.Company,
+					Price:     shipmentUnit.Price,
+					Date:      shipmentUnit.Date,
+					Equipment: "45HC",
+				},
+			},
+			expectedError: domain.ErrInvalidEquipment,
+		},
+		{
+			name: "invalid shipment - expires before it starts",
+			repository: func(ctx context.Context, i int) (*persistence.ShipmentRepository, error) {
+				return &persistence.ShipmentRepository{}, nil
+			},
+			input: &domain.ShipmentUnit{
+				Origin: shipmentUnit.Origin,
+				ShipmentQuote: domain.ShipmentQuote{
+					Company:    shipmentUnit.Company,
+					Price:      shipmentUnit.Price,
+					Date:       shipmentUnit.Date,
+					ValidUntil: shipmentUnit.Date.Add(-time.Hour),
+				},
+			},
+			expectedError: domain.ErrInvalidValidity,
+		},
 		{
 			name: "valid shipment",
 			repository: func(ctx context.Context, i int) (*persistence.ShipmentRepository, error) {
@@ -246,6 +912,281 @@ func TestShipmentService_SubmitShipment(t *testing.T) {
 	}
 }
 
+func TestShipmentService_SubmitShipments(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	repository, err := persistence.NewShipmentOfferRepository(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	service, err := CreateShipmentService(repository)
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	shipments := []domain.ShipmentUnit{
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 1, Quoted: domain.Money{Amount: 100}, Date: date}},
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 2, Quoted: domain.Money{Amount: 100, Currency: "EUR"}, Date: date}},
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 3, Quoted: domain.Money{Amount: 300}}},
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 1, Quoted: domain.Money{Amount: 200}, Date: date}},
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 4, Quoted: domain.Money{Amount: 300}, Date: date, Equipment: domain.Equipment40HC}},
+	}
+	expectedErrors := []error{nil, domain.ErrUnsupportedCurrency, domain.ErrInvalidDate, domain.ErrDuplicateQuote, nil}
+
+	errs := service.SubmitShipments(shipments)
+	if len(errs) != len(expectedErrors) {
+		t.Fatalf("expected %d errors, got %d", len(expectedErrors), len(errs))
+	}
+	for i, err := range errs {
+		if !errors.Is(err, expectedErrors[i]) {
+			t.Errorf("expected error %v for shipment %d, got %v", expectedErrors[i], i, err)
+		}
+	}
+
+	// Only the accepted shipments are stored, with their price in the base currency
+	rates, err := service.GetExpectedRates(domain.RateQuery{Top: 10, AsOf: date})
+	if err != nil {
+		t.Fatalf("failed to get expected rates: %v", err)
+	}
+	if len(rates) != 2 || rates[0].Rate != 100 || rates[1].Rate != 300 {
+		t.Errorf("expected rates 100 and 300, got %+v", rates)
+	}
+
+	if errs = service.SubmitShipments(nil); len(errs) != 0 {
+		t.Errorf("expected no errors, got %v", errs)
+	}
+}
+
+func TestShipmentService_currencies(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	path := filepath.Join(t.TempDir(), "fx.json")
+	if err := os.WriteFile(path, []byte(`{"base": "USD", "rates": {"CNY": 7, "EUR": 0.5}}`), 0o600); err != nil {
+		t.Fatalf("failed to write exchange rates file: %v", err)
+	}
+	exchangeRates, err := persistence.NewFXRateTable(context.Background(), path, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create exchange rates table: %v", err)
+	}
+
+	repository, err := persistence.NewShipmentOfferRepository(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	service, err := CreateShipmentService(repository, WithExchangeRates(exchangeRates))
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+	serviceWithoutRates, err := CreateShipmentService(repository)
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	submitTests := []struct {
+		name          string
+		service       *ShipmentService
+		quoted        domain.Money
+		expectedError error
+	}{
+		{name: "base currency", service: service, quoted: domain.Money{Amount: 100, Currency: "USD"}},
+		{name: "base currency without currency", service: service, quoted: domain.Money{Amount: 300}},
+		{name: "other currency", service: service, quoted: domain.Money{Amount: 1400, Currency: "CNY"}},
+		{name: "invalid currency", service: service, quoted: domain.Money{Amount: 100, Currency: "usd"}, expectedError: domain.ErrInvalidCurrency},
+		{name: "unsupported currency", service: service, quoted: domain.Money{Amount: 100, Currency: "GBP"}, expectedError: domain.ErrUnsupportedCurrency},
+		{name: "price rounded to zero", service: service, quoted: domain.Money{Amount: 1, Currency: "CNY"}, expectedError: domain.ErrInvalidPrice},
+		{name: "no exchange rates", service: serviceWithoutRates, quoted: domain.Money{Amount: 100, Currency: "EUR"}, expectedError: domain.ErrUnsupportedCurrency},
+	}
+
+	for i, tt := range submitTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.service.SubmitShipment(&domain.ShipmentUnit{
+				Origin:        "NYC",
+				ShipmentQuote: domain.ShipmentQuote{Company: i + 1, Quoted: tt.quoted, Date: date},
+			})
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+		})
+	}
+
+	rateTests := []struct {
+		name          string
+		service       *ShipmentService
+		currency      domain.Currency
+		expectedRate  int
+		expectedError error
+	}{
+		{name: "base currency", service: service, expectedRate: 200}, // The average of 100, 300 and 1400 / 7
+		{name: "other currency", service: service, currency: "EUR", expectedRate: 100},
+		{name: "invalid currency", service: service, currency: "Euro", expectedError: domain.ErrInvalidCurrency},
+		{name: "unsupported currency", service: service, currency: "GBP", expectedError: domain.ErrUnsupportedCurrency},
+		{name: "no exchange rates", service: serviceWithoutRates, currency: "EUR", expectedError: domain.ErrUnsupportedCurrency},
+	}
+
+	for _, tt := range rateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates, err := tt.service.GetExpectedRates(domain.RateQuery{Top: 10, AsOf: date, Currency: tt.currency})
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+
+			if err == nil && (len(rates) != 1 || rates[0].Rate != tt.expectedRate) {
+				t.Errorf("expected rate %d, got %+v", tt.expectedRate, rates)
+			}
+		})
+	}
+}
+
+func TestShipmentService_GetQuoteHistory(t *testing.T) {
+	repository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	service, err := CreateShipmentService(repository)
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	accepted := &domain.ShipmentUnit{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 100, Date: time.Now()}}
+	if err = service.SubmitShipment(accepted); err != nil {
+		t.Fatalf("failed to submit shipment: %v", err)
+	}
+	rejected := &domain.ShipmentUnit{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: -1}}
+	if err = service.RecordRejectedShipment(rejected, domain.ErrInvalidPrice); err != nil {
+		t.Fatalf("failed to record rejected shipment: %v", err)
+	}
+
+	tests := []struct {
+		name             string
+		origin           string
+		company          int
+		expectedError    error
+		expectedStatuses []domain.AuditStatus
+	}{
+		{
+			name:          "invalid input - empty origin port",
+			company:       1,
+			expectedError: domain.ErrInvalidOriginPort,
+		},
+		{
+			name:          "invalid input - invalid company",
+			origin:        "NYC",
+			expectedError: domain.ErrInvalidCompany,
+		},
+		{
+			name:          "no history",
+			origin:        "NYC",
+			company:       2,
+			expectedError: domain.ErrNoQuoteHistory,
+		},
+		{
+			name:             "valid input",
+			origin:           "NYC",
+			company:          1,
+			expectedStatuses: []domain.AuditStatus{domain.AuditAccepted, domain.AuditRejected},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history, err := service.GetQuoteHistory(tt.origin, tt.company)
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+
+			var statuses []domain.AuditStatus
+			for _, entry := range history {
+				statuses = append(statuses, entry.Status)
+			}
+			if !reflect.DeepEqual(statuses, tt.expectedStatuses) {
+				t.Errorf("expected statuses %v, got %v", tt.expectedStatuses, statuses)
+			}
+		})
+	}
+}
+
+func TestShipmentService_DeleteCompanyQuotes(t *testing.T) {
+	repository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	service, err := CreateShipmentService(repository)
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	for _, shipment := range []*domain.ShipmentUnit{
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 100, Date: time.Now()}},
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 2, Price: 300, Date: time.Now()}},
+	} {
+		if err = service.SubmitShipment(shipment); err != nil {
+			t.Fatalf("failed to submit shipment: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name            string
+		origin          string
+		company         int
+		expectedDeleted int
+		expectedError   error
+		expectedRates   map[string]int
+	}{
+		{
+			name:          "invalid input - empty origin port",
+			company:       1,
+			expectedError: domain.ErrInvalidOriginPort,
+		},
+		{
+			name:          "invalid input - invalid company",
+			origin:        "NYC",
+			expectedError: domain.ErrInvalidCompany,
+		},
+		{
+			name:          "no quotes",
+			origin:        "NYC",
+			company:       3,
+			expectedError: domain.ErrNoCompanyQuotes,
+		},
+		{
+			name:            "valid input",
+			origin:          "NYC",
+			company:         1,
+			expectedDeleted: 1,
+			expectedRates:   map[string]int{"NYC": 300},
+		},
+		{
+			name:          "already deleted",
+			origin:        "NYC",
+			company:       1,
+			expectedError: domain.ErrNoCompanyQuotes,
+			expectedRates: map[string]int{"NYC": 300},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleted, err := service.DeleteCompanyQuotes(tt.origin, tt.company)
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if deleted != tt.expectedDeleted {
+				t.Errorf("expected %d deleted quotes, got %d", tt.expectedDeleted, deleted)
+			}
+
+			if tt.expectedRates != nil {
+				rates, err := service.GetLatestExpectedRates(10)
+				if err != nil {
+					t.Fatalf("failed to get expected rates: %v", err)
+				}
+				if !reflect.DeepEqual(rates, tt.expectedRates) {
+					t.Errorf("expected rates %v, got %v", tt.expectedRates, rates)
+				}
+			}
+		})
+	}
+}
+
 func TestCreateShipmentService(t *testing.T) {
 	tests := []struct {
 		name                    string
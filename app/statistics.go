@@ -0,0 +1,46 @@
+package app
+
+import (
+	"math"
+	"math/big"
+
+	"quoteship/domain"
+)
+
+// rateStatistics describes the quotes an expected rate is calculated from. The quotes must be sorted by price, cheapest
+// first, and never be empty. The batch publication time is not known from the quotes and is left to the caller.
+func rateStatistics(quotes []domain.ShipmentQuote) domain.RateStatistics {
+	statistics := domain.RateStatistics{
+		Min:    big.NewRat(int64(quotes[0].Price), 1),
+		Max:    big.NewRat(int64(quotes[len(quotes)-1].Price), 1),
+		Oldest: quotes[0].Date,
+		Newest: quotes[0].Date,
+	}
+
+	// The variance is the mean of the squared prices minus the squared mean, it is exact until the square root
+	mean, meanOfSquares := meanPrice(quotes), new(big.Rat)
+	for _, quote := range quotes {
+		price := big.NewRat(int64(quote.Price), 1)
+		meanOfSquares.Add(meanOfSquares, price.Mul(price, price))
+
+		if quote.Date.Before(statistics.Oldest) {
+			statistics.Oldest = quote.Date
+		}
+		if quote.Date.After(statistics.Newest) {
+			statistics.Newest = quote.Date
+		}
+	}
+	meanOfSquares.Quo(meanOfSquares, big.NewRat(int64(len(quotes)), 1))
+	variance, _ := meanOfSquares.Sub(meanOfSquares, mean.Mul(mean, mean)).Float64()
+
+	statistics.StdDev = new(big.Rat).SetFloat64(math.Sqrt(variance))
+
+	return statistics
+}
+
+// convertStatistics multiplies the prices of the statistics by the exchange rate in place, the dates are kept.
+func convertStatistics(statistics domain.RateStatistics, exchangeRate *big.Rat) {
+	for _, price := range []*big.Rat{statistics.Min, statistics.Max, statistics.StdDev} {
+		price.Mul(price, exchangeRate)
+	}
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"quoteship/domain"
+)
+
+func TestRateStatistics(t *testing.T) {
+	date := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name               string
+		quotes             []domain.ShipmentQuote
+		expectedStatistics domain.RateStatistics
+	}{
+		{
+			name:   "single quote",
+			quotes: []domain.ShipmentQuote{{Company: 1, Price: 100, Date: date}},
+			expectedStatistics: domain.RateStatistics{
+				Min:    big.NewRat(100, 1),
+				Max:    big.NewRat(100, 1),
+				StdDev: big.NewRat(0, 1),
+				Oldest: date,
+				Newest: date,
+			},
+		},
+		{
+			name: "several quotes",
+			quotes: []domain.ShipmentQuote{
+				{Company: 1, Price: 200, Date: date},
+				{Company: 2, Price: 400, Date: date.AddDate(0, 0, 3)},
+				{Company: 3, Price: 400, Date: date.AddDate(0, 0, -2)},
+				{Company: 4, Price: 400, Date: date},
+				{Company: 5, Price: 500, Date: date},
+				{Company: 6, Price: 500, Date: date},
+				{Company: 7, Price: 700, Date: date},
+				{Company: 8, Price: 900, Date: date},
+			},
+			expectedStatistics: domain.RateStatistics{
+				Min:    big.NewRat(200, 1),
+				Max:    big.NewRat(900, 1),
+				StdDev: big.NewRat(200, 1), // The square root of the variance 40000
+				Oldest: date.AddDate(0, 0, -2),
+				Newest: date.AddDate(0, 0, 3),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statistics := rateStatistics(tt.quotes)
+			switch {
+			case statistics.Min.Cmp(tt.expectedStatistics.Min) != 0:
+				t.Errorf("expected min %v, got %v", tt.expectedStatistics.Min, statistics.Min)
+			case statistics.Max.Cmp(tt.expectedStatistics.Max) != 0:
+				t.Errorf("expected max %v, got %v", tt.expectedStatistics.Max, statistics.Max)
+			case statistics.StdDev.Cmp(tt.expectedStatistics.StdDev) != 0:
+				t.Errorf("expected standard deviation %v, got %v", tt.expectedStatistics.StdDev, statistics.StdDev)
+			case !statistics.Oldest.Equal(tt.expectedStatistics.Oldest) || !statistics.Newest.Equal(tt.expectedStatistics.Newest):
+				t.Errorf("expected dates from %v to %v, got %v to %v", tt.expectedStatistics.Oldest, tt.expectedStatistics.Newest, statistics.Oldest, statistics.Newest)
+			}
+		})
+	}
+}
+
+func TestConvertStatistics(t *testing.T) {
+	statistics := domain.RateStatistics{Min: big.NewRat(100, 1), Max: big.NewRat(300, 1), StdDev: big.NewRat(50, 1)}
+
+	convertStatistics(statistics, big.NewRat(1, 2))
+
+	if statistics.Min.Cmp(big.NewRat(50, 1)) != 0 || statistics.Max.Cmp(big.NewRat(150, 1)) != 0 || statistics.StdDev.Cmp(big.NewRat(25, 1)) != 0 {
+		t.Errorf("expected the prices to be halved, got %v, %v and %v", statistics.Min, statistics.Max, statistics.StdDev)
+	}
+}
@@ -2,18 +2,24 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"flag"
+	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"quoteship/app"
+	"quoteship/domain"
 	"quoteship/persistence"
+	"quoteship/ports"
 	"quoteship/presentation"
 )
 
@@ -21,12 +27,56 @@ import (
 const (
 	defaultAddr            = ":3142"           // Define default http address
 	defaultUpdateThreshold = "1000"            //Values to send before each price index retrieval (default 1000)
+	defaultPublishMode     = "count"           // Define default batch publication mode
+	defaultPublishInterval = "5s"              // Define default batch publication interval, used by the interval and hybrid modes
+	defaultQuoteMaxAge     = "0"               // Define default quote max age, zero keeps quotes until their validity ends
+	defaultSweepInterval   = "1m"              // Define default interval between two sweeps of expired quotes
+	defaultWALSyncPolicy   = "always"          // Define default write-ahead log sync policy
+	defaultWALSyncInterval = "1s"              // Define default write-ahead log sync interval, used by the interval sync policy
+	defaultSnapshotEvery   = "5m"              // Define default interval between two repository snapshots
+	defaultSnapshotRetain  = "2"               // Define default number of snapshots kept on disk
+	defaultFXReloadEvery   = "30s"             // Define default interval between two checks of the exchange rates file
+	defaultSigningWindow   = "5m"              // Define default maximum age of a signed submission
+	defaultRoundingMode    = "half-even"       // Define default rounding mode of the prices and expected rates
+	defaultRateMethod      = app.DefaultMethod // Define default aggregation method of the expected rates
+	defaultRatesTop        = "10"              // Define default number of lowest-priced offers aggregated per origin
+	defaultOutlierFilter   = app.FilterNone    // Define default outlier filter of the quotes, none aggregates every quote
+	defaultOutlierLimit    = "0"               // Define default outlier threshold, zero uses the default of the outlier filter
+	defaultStrictValidate  = "false"           // Define default handling of invalid offers, false discards them with 200 OK
 	readTimeout            = 5 * time.Second   // Define http server read timeout
 	writeTimeout           = 10 * time.Second  // Define http server write timeout
 	idleTimeout            = 120 * time.Second // Define http server idle timeout
 	shutdownTimeout        = 10 * time.Second  // Define http server shutdown timeout
+	importTimeout          = time.Minute       // Define timeout of a tariff import sent to the running server
 )
 
+// config holds the application configuration loaded from the environment.
+type config struct {
+	addr            string                        // addr is the http server address.
+	updateThreshold int                           // updateThreshold is the number of submissions after which a new batch is published.
+	publishMode     string                        // publishMode is the batch publication mode, one of "count", "interval" or "hybrid".
+	publishInterval time.Duration                 // publishInterval is the batch publication interval of the interval and hybrid modes.
+	quoteMaxAge     time.Duration                 // quoteMaxAge is the age after which a quote expires, zero disables it.
+	sweepInterval   time.Duration                 // sweepInterval is the interval between two sweeps of expired quotes.
+	wal             *persistence.WALConfig        // wal is the write-ahead log configuration, nil when the repository is in-memory only.
+	snapshotDir     string                        // snapshotDir is the directory of the repository snapshots, empty when snapshots are disabled.
+	snapshotEvery   time.Duration                 // snapshotEvery is the interval between two repository snapshots.
+	snapshotRetain  int                           // snapshotRetain is the number of snapshots kept on disk.
+	fxRatesFile     string                        // fxRatesFile is the path of the exchange rates file, empty when only the default currency is supported.
+	fxReloadEvery   time.Duration                 // fxReloadEvery is the interval between two checks of the exchange rates file.
+	roundingMode    domain.RoundingMode           // roundingMode is the rounding mode of the converted prices and of the expected rates.
+	aggregator      domain.Aggregator             // aggregator calculates the expected rates of the requests without an aggregation method.
+	top             int                           // top is the number of lowest-priced offers aggregated per origin for the requests without a top.
+	topByOrigin     map[string]int                // topByOrigin overrides top for the given origins.
+	outlierFilter   domain.OutlierFilter          // outlierFilter excludes the outliers before the quotes are aggregated, nil when every quote is aggregated.
+	strictValidate  bool                          // strictValidate rejects invalid offers with problem details for the requests without a handling preference.
+	ports           *ports.Registry               // ports is the registry of the ports the offers are validated against, shared by the service and the handlers.
+	companies       domain.CompanyRegistry        // companies is the registry of the companies that can submit offers, nil when every company is accepted.
+	apiKeys         domain.APIKeyStore            // apiKeys authenticates the API keys of the requests, nil when every request is accepted.
+	signatures      *presentation.RequestVerifier // signatures verifies the signed submissions, nil when the signature headers are ignored.
+	limiter         *presentation.RateLimiter     // limiter limits the requests per client IP address, API key and company, nil when every request is accepted.
+}
+
 func main() {
 	// Fetch the server address from an environment variable or use the default value
 	addr := getEnv("HTTP_SERVER_ADDR", defaultAddr)
@@ -40,32 +90,247 @@ func main() {
 		cleanExit(1)
 	}
 
+	publishInterval, err := time.ParseDuration(getEnv("PUBLICATION_INTERVAL", defaultPublishInterval))
+	if err != nil {
+		slog.Error("failed to parse publication interval", "error", err.Error())
+		cleanExit(1)
+	}
+
+	quoteMaxAge, err := time.ParseDuration(getEnv("QUOTE_MAX_AGE", defaultQuoteMaxAge))
+	if err != nil {
+		slog.Error("failed to parse quote max age", "error", err.Error())
+		cleanExit(1)
+	}
+
+	sweepInterval, err := time.ParseDuration(getEnv("QUOTE_SWEEP_INTERVAL", defaultSweepInterval))
+	if err != nil {
+		slog.Error("failed to parse quote sweep interval", "error", err.Error())
+		cleanExit(1)
+	}
+
+	cfg := config{
+		addr:            addr,
+		updateThreshold: updateThresholdInt,
+		publishMode:     getEnv("PUBLICATION_MODE", defaultPublishMode),
+		publishInterval: publishInterval,
+		quoteMaxAge:     quoteMaxAge,
+		sweepInterval:   sweepInterval,
+	}
+
+	// Enable the write-ahead log only when a directory is configured
+	if walDir := getEnv("WAL_DIR", ""); walDir != "" {
+		syncPolicy, err := persistence.ParseSyncPolicy(getEnv("WAL_SYNC_POLICY", defaultWALSyncPolicy))
+		if err != nil {
+			slog.Error("failed to parse write-ahead log sync policy", "error", err.Error())
+			cleanExit(1)
+		}
+
+		syncInterval, err := time.ParseDuration(getEnv("WAL_SYNC_INTERVAL", defaultWALSyncInterval))
+		if err != nil {
+			slog.Error("failed to parse write-ahead log sync interval", "error", err.Error())
+			cleanExit(1)
+		}
+
+		cfg.wal = &persistence.WALConfig{Dir: walDir, SyncPolicy: syncPolicy, SyncInterval: syncInterval}
+	}
+
+	// Enable snapshots only when a directory is configured
+	if snapshotDir := getEnv("SNAPSHOT_DIR", ""); snapshotDir != "" {
+		cfg.snapshotDir = snapshotDir
+
+		cfg.snapshotEvery, err = time.ParseDuration(getEnv("SNAPSHOT_INTERVAL", defaultSnapshotEvery))
+		if err != nil {
+			slog.Error("failed to parse snapshot interval", "error", err.Error())
+			cleanExit(1)
+		}
+
+		cfg.snapshotRetain, err = strconv.Atoi(getEnv("SNAPSHOT_RETAIN", defaultSnapshotRetain))
+		if err != nil {
+			slog.Error("failed to convert snapshot retain to integer", "error", err.Error())
+			cleanExit(1)
+		}
+	}
+
+	// Enable multiple currencies only when an exchange rates file is configured
+	if fxRatesFile := getEnv("FX_RATES_FILE", ""); fxRatesFile != "" {
+		cfg.fxRatesFile = fxRatesFile
+
+		cfg.fxReloadEvery, err = time.ParseDuration(getEnv("FX_RELOAD_INTERVAL", defaultFXReloadEvery))
+		if err != nil {
+			slog.Error("failed to parse exchange rates reload interval", "error", err.Error())
+			cleanExit(1)
+		}
+	}
+
+	cfg.roundingMode, err = domain.ParseRoundingMode(getEnv("ROUNDING_MODE", defaultRoundingMode))
+	if err != nil {
+		slog.Error("failed to parse rounding mode", "error", err.Error())
+		cleanExit(1)
+	}
+
+	cfg.aggregator, err = app.NewAggregator(getEnv("RATE_METHOD", defaultRateMethod))
+	if err != nil {
+		slog.Error("failed to parse rate aggregation method", "error", err.Error())
+		cleanExit(1)
+	}
+
+	cfg.top, err = parseTop(getEnv("RATES_TOP", defaultRatesTop))
+	if err != nil {
+		slog.Error("failed to parse rates top", "error", err.Error())
+		cleanExit(1)
+	}
+
+	cfg.topByOrigin, err = parseTopByOrigin(getEnv("TOP_PER_ORIGIN", ""))
+	if err != nil {
+		slog.Error("failed to parse rates top per origin", "error", err.Error())
+		cleanExit(1)
+	}
+
+	outlierThreshold, err := strconv.ParseFloat(getEnv("OUTLIER_THRESHOLD", defaultOutlierLimit), 64)
+	if err != nil {
+		slog.Error("failed to parse outlier threshold", "error", err.Error())
+		cleanExit(1)
+	}
+
+	cfg.outlierFilter, err = app.NewOutlierFilter(getEnv("OUTLIER_FILTER", defaultOutlierFilter), outlierThreshold)
+	if err != nil {
+		slog.Error("failed to parse outlier filter", "error", err.Error())
+		cleanExit(1)
+	}
+
+	cfg.strictValidate, err = strconv.ParseBool(getEnv("STRICT_VALIDATION", defaultStrictValidate))
+	if err != nil {
+		slog.Error("failed to parse strict validation", "error", err.Error())
+		cleanExit(1)
+	}
+
+	// Load the port registry from the configured ports file, or the bundled one
+	if portsFile := getEnv("PORTS_FILE", ""); portsFile != "" {
+		cfg.ports, err = ports.LoadFile(portsFile)
+		if err != nil {
+			slog.Error("failed to load ports file", "error", err.Error())
+			cleanExit(1)
+		}
+		slog.Info("port registry", slog.String("file", portsFile), slog.Int("ports", len(cfg.ports.Ports())))
+	} else {
+		cfg.ports = ports.Bundled()
+		slog.Info("port registry", slog.String("file", "bundled"), slog.Int("ports", len(cfg.ports.Ports())))
+	}
+
+	// Enable the company registry only when a companies file is configured
+	if companiesFile := getEnv("COMPANIES_FILE", ""); companiesFile != "" {
+		companies, err := persistence.OpenCompanyRegistry(companiesFile)
+		if err != nil {
+			slog.Error("failed to open company registry", "error", err.Error())
+			cleanExit(1)
+		}
+		slog.Info("company registry", slog.String("file", companiesFile), slog.Int("companies", len(companies.Companies())))
+		cfg.companies = companies
+	}
+
+	// Authenticate the requests only when an API keys file is configured
+	if apiKeysFile := getEnv("API_KEYS_FILE", ""); apiKeysFile != "" {
+		apiKeys, err := persistence.OpenAPIKeyStore(apiKeysFile)
+		if err != nil {
+			slog.Error("failed to open API keys file", "error", err.Error())
+			cleanExit(1)
+		}
+		slog.Info("API key authentication", slog.String("file", apiKeysFile))
+		cfg.apiKeys = apiKeys
+	} else {
+		slog.Warn("API_KEYS_FILE is not configured, every request is accepted without API key")
+	}
+
+	// Verify the signed submissions only when a signing secrets file is configured
+	if signingSecretsFile := getEnv("SIGNING_SECRETS_FILE", ""); signingSecretsFile != "" {
+		secrets, err := persistence.OpenSigningSecrets(signingSecretsFile)
+		if err != nil {
+			slog.Error("failed to open signing secrets file", "error", err.Error())
+			cleanExit(1)
+		}
+
+		window, err := time.ParseDuration(getEnv("SIGNING_WINDOW", defaultSigningWindow))
+		if err != nil {
+			slog.Error("failed to parse signing window", "error", err.Error())
+			cleanExit(1)
+		}
+
+		cfg.signatures, err = presentation.NewRequestVerifier(secrets, window)
+		if err != nil {
+			slog.Error("failed to create request verifier", "error", err.Error())
+			cleanExit(1)
+		}
+		slog.Info("request signing", slog.String("file", signingSecretsFile), slog.Duration("window", window))
+	}
+
+	// Limit the requests only when a rate limit is configured, per client IP address, API key or company
+	var limits presentation.RateLimits
+	for env, limit := range map[string]*presentation.RateLimit{
+		"RATE_LIMIT_PER_IP":      &limits.PerIP,
+		"RATE_LIMIT_PER_KEY":     &limits.PerKey,
+		"RATE_LIMIT_PER_COMPANY": &limits.PerCompany,
+	} {
+		*limit, err = presentation.ParseRateLimit(getEnv(env, ""))
+		if err != nil {
+			slog.Error("failed to parse rate limit", "env", env, "error", err.Error())
+			cleanExit(1)
+		}
+	}
+	if limits != (presentation.RateLimits{}) {
+		maxBuckets, err := strconv.Atoi(getEnv("RATE_LIMIT_MAX_BUCKETS", strconv.Itoa(presentation.DefaultMaxRateLimitBuckets)))
+		if err != nil {
+			slog.Error("failed to convert rate limit buckets to integer", "error", err.Error())
+			cleanExit(1)
+		}
+
+		cfg.limiter, err = presentation.NewRateLimiter(limits, maxBuckets)
+		if err != nil {
+			slog.Error("failed to create rate limiter", "error", err.Error())
+			cleanExit(1)
+		}
+		slog.Info("rate limits", slog.Any("per_ip", limits.PerIP), slog.Any("per_key", limits.PerKey), slog.Any("per_company", limits.PerCompany), slog.Int("max_buckets", maxBuckets))
+	}
+
 	// Create a context that listens for SIGINT or SIGTERM signals for graceful shutdown
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop() // Ensure resources associated with the signal context are released
 
-	if err := run(ctx, addr, updateThresholdInt); err != nil {
+	// Import a CSV tariff instead of serving requests, e.g., "quoteship import -delimiter semicolon tariff.csv"
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(ctx, cfg.addr, os.Args[2:]); err != nil {
+			slog.Error("failed to import the tariff", "error", err.Error())
+			cleanExit(1)
+		}
+		return
+	}
+
+	// Generate an API key instead of serving requests, e.g.,
+	// "quoteship apikey -name blue-whale-ci -role submitter -company 1"
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		if err := runAPIKey(os.Args[2:]); err != nil {
+			slog.Error("failed to generate the API key", "error", err.Error())
+			cleanExit(1)
+		}
+		return
+	}
+
+	if err := run(ctx, cfg); err != nil {
 		slog.Error("failed to run the application", "error", err.Error())
 		// Call a function to cleanly exit
 		cleanExit(1)
 	}
 }
 
-func run(ctx context.Context, addr string, updateThreshold int) error {
+func run(ctx context.Context, cfg config) error {
+	addr := cfg.addr
+
 	slog.Info("Starting application...")
 	slog.Info("http server address", slog.String("addr", addr))
-	slog.Info("update threshold value", slog.Int("threshold", updateThreshold))
+	slog.Info("update threshold value", slog.Int("threshold", cfg.updateThreshold))
 
-	// Initialize the shipment repository
-	shipmentRepository, err := persistence.NewShipmentOfferRepository(ctx, updateThreshold)
+	shipmentService, closeService, err := newShipmentService(ctx, cfg)
+	defer closeService() // Flush the write-ahead log once the server no longer accepts submissions
 	if err != nil {
-		slog.Error("failed to create shipment repository", "error", err.Error())
-		return err
-	}
-	// Initialize the shipment service
-	shipmentService, err := app.CreateShipmentService(shipmentRepository)
-	if err != nil {
-		slog.Error("failed to create shipment service", "error", err.Error())
 		return err
 	}
 
@@ -73,7 +338,7 @@ func run(ctx context.Context, addr string, updateThreshold int) error {
 	mux := http.NewServeMux()
 
 	// Shipment handler is created within the routes registration function
-	presentation.RegisterRoutes(mux, shipmentService)
+	presentation.RegisterRoutes(mux, shipmentService, presentation.WithRoundingMode(cfg.roundingMode), presentation.WithStrictValidation(cfg.strictValidate), presentation.WithPortRegistry(cfg.ports), presentation.WithCompanyRegistry(cfg.companies), presentation.WithAPIKeys(cfg.apiKeys), presentation.WithRequestSigning(cfg.signatures), presentation.WithRateLimiter(cfg.limiter))
 
 	// Configure the HTTP server with timeouts and base context
 	httpServer := &http.Server{
@@ -124,12 +389,234 @@ func run(ctx context.Context, addr string, updateThreshold int) error {
 	return nil
 }
 
+// newShipmentService creates the shipment repository and service described by the configuration. The returned function
+// closes the write-ahead log, it must be called once no more shipments are submitted, even when an error is returned.
+func newShipmentService(ctx context.Context, cfg config) (*app.ShipmentService, func(), error) {
+	closeService := func() {}
+
+	slog.Info("publication mode", slog.String("mode", cfg.publishMode), slog.Duration("interval", cfg.publishInterval))
+
+	// Decide when the batch used to calculate the expected rates is published
+	publicationPolicy, err := persistence.NewPublicationPolicy(cfg.publishMode, cfg.updateThreshold, cfg.publishInterval)
+	if err != nil {
+		slog.Error("failed to create publication policy", "error", err.Error())
+		return nil, closeService, err
+	}
+
+	slog.Info("quote expiry", slog.Duration("max_age", cfg.quoteMaxAge), slog.Duration("sweep_interval", cfg.sweepInterval))
+
+	repositoryOptions := []persistence.RepositoryOption{
+		persistence.WithPublicationPolicy(publicationPolicy),
+		persistence.WithQuoteExpiry(cfg.quoteMaxAge, cfg.sweepInterval),
+	}
+
+	// Open the write-ahead log, the repository replays it on creation
+	if cfg.wal != nil {
+		slog.Info("write-ahead log", slog.String("dir", cfg.wal.Dir), slog.String("sync_policy", cfg.wal.SyncPolicy.String()))
+
+		wal, err := persistence.OpenWriteAheadLog(*cfg.wal)
+		if err != nil {
+			slog.Error("failed to open write-ahead log", "error", err.Error())
+			return nil, closeService, err
+		}
+		closeService = func() {
+			if err := wal.Close(); err != nil {
+				slog.Error("failed to close write-ahead log", "error", err.Error())
+			}
+		}
+
+		repositoryOptions = append(repositoryOptions, persistence.WithWriteAheadLog(wal))
+	}
+
+	// Load the newest snapshot on start and take new ones periodically
+	if cfg.snapshotDir != "" {
+		slog.Info("snapshots", slog.String("dir", cfg.snapshotDir), slog.Duration("interval", cfg.snapshotEvery), slog.Int("retain", cfg.snapshotRetain))
+
+		snapshots, err := persistence.NewSnapshotStore(cfg.snapshotDir, cfg.snapshotRetain)
+		if err != nil {
+			slog.Error("failed to create snapshot store", "error", err.Error())
+			return nil, closeService, err
+		}
+
+		repositoryOptions = append(repositoryOptions, persistence.WithSnapshots(snapshots, cfg.snapshotEvery))
+	}
+
+	// Initialize the shipment repository
+	shipmentRepository, err := persistence.NewShipmentOfferRepository(ctx, cfg.updateThreshold, repositoryOptions...)
+	if err != nil {
+		slog.Error("failed to create shipment repository", "error", err.Error())
+		return nil, closeService, err
+	}
+	slog.Info("expected rates", slog.String("rounding_mode", cfg.roundingMode.String()), slog.String("method", cfg.aggregator.Name()), slog.Int("top", cfg.top), slog.Any("top_per_origin", cfg.topByOrigin))
+
+	serviceOptions := []app.ServiceOption{
+		app.WithRoundingMode(cfg.roundingMode),
+		app.WithAggregator(cfg.aggregator),
+		app.WithTop(cfg.top, cfg.topByOrigin),
+		app.WithOutlierFilter(cfg.outlierFilter),
+		app.WithPortRegistry(cfg.ports),
+		app.WithCompanyRegistry(cfg.companies),
+	}
+	if cfg.outlierFilter != nil {
+		slog.Info("outlier filter", slog.String("filter", cfg.outlierFilter.Name()))
+	}
+
+	// Load the exchange rates, the file is reloaded whenever it changes
+	if cfg.fxRatesFile != "" {
+		exchangeRates, err := persistence.NewFXRateTable(ctx, cfg.fxRatesFile, cfg.fxReloadEvery)
+		if err != nil {
+			slog.Error("failed to load exchange rates", "error", err.Error())
+			return nil, closeService, err
+		}
+		slog.Info("exchange rates", slog.String("file", cfg.fxRatesFile), slog.String("base", string(exchangeRates.Base())), slog.Duration("reload_interval", cfg.fxReloadEvery))
+
+		serviceOptions = append(serviceOptions, app.WithExchangeRates(exchangeRates))
+	}
+
+	// Initialize the shipment service
+	shipmentService, err := app.CreateShipmentService(shipmentRepository, serviceOptions...)
+	if err != nil {
+		slog.Error("failed to create shipment service", "error", err.Error())
+		return nil, closeService, err
+	}
+
+	return shipmentService, closeService, nil
+}
+
+// runImport posts the CSV tariff named by the arguments to the import endpoint of the running service, so the
+// imported quotes are stored by the service itself, and logs the line of every rejected row. The service is reached
+// at HTTP_SERVER_ADDR unless a server URL is given, with the API key of API_KEY. It returns an error if a row was
+// rejected.
+func runImport(ctx context.Context, addr string, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	server := flags.String("server", serverURL(addr), "URL of the running service the tariff is imported into")
+	delimiter := flags.String("delimiter", string(presentation.DefaultDelimiter), `field delimiter of the tariff, a single character, "tab" or "semicolon"`)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: import [-server http://localhost:3142] [-delimiter ,] tariff.csv")
+	}
+
+	comma, err := presentation.ParseDelimiter(*delimiter)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	client := &http.Client{Timeout: importTimeout}
+	accepted, rowErrors, err := presentation.ImportTariff(ctx, client, *server, getEnv("API_KEY", ""), file, comma)
+	if err != nil {
+		return err
+	}
+
+	for _, rowError := range rowErrors {
+		slog.Warn("rejected tariff row", slog.Int("line", rowError.Line), slog.String("reason", rowError.Reason))
+	}
+	slog.Info("imported tariff", slog.String("file", flags.Arg(0)), slog.String("server", *server), slog.Int("accepted", accepted), slog.Int("rejected", len(rowErrors)))
+
+	if len(rowErrors) > 0 {
+		return fmt.Errorf("%d of the %d rows of the tariff were rejected", len(rowErrors), accepted+len(rowErrors))
+	}
+
+	return nil
+}
+
+// serverURL returns the URL of the service listening on the http server address, on localhost when the address has no
+// host, e.g., "http://localhost:3142" for ":3142".
+func serverURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr
+	}
+	if host == "" {
+		host = "localhost"
+	}
+
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// runAPIKey generates a new API key for the holder described by the arguments. It prints the key, to hand over to its
+// holder, followed by the entry to add to the API keys file, which only stores the hash of the key.
+func runAPIKey(args []string) error {
+	flags := flag.NewFlagSet("apikey", flag.ContinueOnError)
+	name := flags.String("name", "", "name of the key holder, logged with the requests it makes")
+	role := flags.String("role", string(domain.RoleConsumer), `role of the key, "consumer", "submitter" or "admin"`)
+	company := flags.Int("company", 0, "identifier of the company of a submitter key")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" || flags.NArg() != 0 {
+		return errors.New("usage: apikey -name blue-whale-ci [-role submitter -company 1]")
+	}
+	if !domain.Role(*role).Valid() {
+		return fmt.Errorf("invalid role %q", *role)
+	}
+
+	key, err := persistence.GenerateAPIKey()
+	if err != nil {
+		return err
+	}
+
+	entry, err := json.Marshal(map[string]any{"name": *name, "sha256": persistence.HashAPIKey(key), "role": *role, "company": *company})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(key)
+	fmt.Println(string(entry))
+
+	return nil
+}
+
 // cleanExit is used to exit the application while ensuring deferred functions are executed
 func cleanExit(code int) {
 	// Allow deferred functions to run before exiting
 	defer os.Exit(code)
 }
 
+// parseTop parses a number of lowest-priced offers aggregated per origin, within the bounds accepted by the requests.
+func parseTop(value string) (int, error) {
+	top, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, err
+	}
+	if top < presentation.MinTop || top > presentation.MaxTop {
+		return 0, fmt.Errorf("%w: %d is not between %d and %d", domain.ErrInvalidTopValue, top, presentation.MinTop, presentation.MaxTop)
+	}
+
+	return top, nil
+}
+
+// parseTopByOrigin parses the numbers of lowest-priced offers aggregated per origin, e.g., "CNGGZ=5,SGSIN=15".
+func parseTopByOrigin(value string) (map[string]int, error) {
+	topByOrigin := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		origin, top, found := strings.Cut(entry, "=")
+		origin = strings.TrimSpace(origin)
+		if !found || origin == "" {
+			return nil, fmt.Errorf("%w: %q is not an origin=top pair", domain.ErrInvalidTopValue, entry)
+		}
+
+		originTop, err := parseTop(top)
+		if err != nil {
+			return nil, fmt.Errorf("origin %q: %w", origin, err)
+		}
+		topByOrigin[origin] = originTop
+	}
+
+	return topByOrigin, nil
+}
+
 // getEnv is a helper function to fetch an environment variable or return a default value
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -2,50 +2,430 @@ package domain
 
 import (
 	"errors"
+	"fmt"
+	"math/big"
+	"strings"
 	"time"
 )
 
 var (
-	ErrInvalidTopValue   = errors.New("invalid top value provided")
-	ErrNoExpectedRates   = errors.New("no expected rates available")
-	ErrNilShipmentUnit   = errors.New("nil shipment unit provided")
-	ErrInvalidOriginPort = errors.New("invalid origin port provided")
-	ErrInvalidPrice      = errors.New("invalid price provided")
-	ErrInvalidDate       = errors.New("invalid date provided")
-	ErrInvalidCompany    = errors.New("invalid company provided")
-	ErrNoValidRates      = errors.New("no valid rates calculated")
-	ErrNilRepository     = errors.New("nil repository provided")
-)
-
-// OriginShipments represents a list of ShipmentQuote for a specific Origin.
+	ErrInvalidTopValue        = errors.New("invalid top value provided")
+	ErrNoExpectedRates        = errors.New("no expected rates available")
+	ErrNilShipmentUnit        = errors.New("nil shipment unit provided")
+	ErrInvalidOriginPort      = errors.New("invalid origin port provided")
+	ErrInvalidDestinationPort = errors.New("invalid destination port provided")
+	ErrInvalidPrice           = errors.New("invalid price provided")
+	ErrInvalidDate            = errors.New("invalid date provided")
+	ErrInvalidCompany         = errors.New("invalid company provided")
+	ErrInvalidValidity        = errors.New("invalid validity window provided")
+	ErrInvalidEquipment       = errors.New("invalid equipment type provided")
+	ErrInvalidCurrency        = errors.New("invalid currency provided")
+	ErrUnsupportedCurrency    = errors.New("unsupported currency provided")
+	ErrInvalidRoundingMode    = errors.New("invalid rounding mode provided")
+	ErrInvalidAggregation     = errors.New("invalid aggregation method provided")
+	ErrInvalidOutlierFilter   = errors.New("invalid outlier filter provided")
+	ErrDuplicateQuote         = errors.New("quote with the same date already provided")
+	ErrNoQuoteHistory         = errors.New("no quote history available")
+	ErrNoCompanyQuotes        = errors.New("no quotes of the company available")
+	ErrNoValidRates           = errors.New("no valid rates calculated")
+	ErrNilRepository          = errors.New("nil repository provided")
+	ErrUnknownPort            = errors.New("unknown port provided")
+	ErrInactivePort           = errors.New("inactive port provided")
+	ErrUnknownCompany         = errors.New("unknown company provided")
+	ErrSuspendedCompany       = errors.New("suspended company provided")
+	ErrInvalidCompanyStatus   = errors.New("invalid company status provided")
+	ErrInvalidAPIKey          = errors.New("invalid API key provided")
+)
+
+const (
+	WildcardDestination = "*" // WildcardDestination is the destination of the lane of quotes that apply to any destination of their origin.
+)
+
+// Equipment is the container type a quote is priced for, e.g., a 40' high-cube.
+type Equipment string
+
+const (
+	Equipment20DV Equipment = "20DV" // Equipment20DV is a 20' dry container.
+	Equipment40DV Equipment = "40DV" // Equipment40DV is a 40' dry container.
+	Equipment40HC Equipment = "40HC" // Equipment40HC is a 40' high-cube container.
+	Equipment40RF Equipment = "40RF" // Equipment40RF is a 40' reefer container.
+
+	DefaultEquipment = Equipment20DV // DefaultEquipment is the equipment type of the quotes submitted without one.
+)
+
+// EquipmentTypes lists the known equipment types, in the order the expected rates are calculated.
+var EquipmentTypes = []Equipment{Equipment20DV, Equipment40DV, Equipment40HC, Equipment40RF}
+
+// Valid reports whether the equipment is one of the known EquipmentTypes.
+func (e Equipment) Valid() bool {
+	for _, equipment := range EquipmentTypes {
+		if e == equipment {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Lane identifies an origin to destination shipping lane, e.g., CNSGH to NLRTM.
+type Lane struct {
+	Origin      string // Origin is the located port where the shipment starts (e.g., "CNSGH").
+	Destination string // Destination is the located port where the shipment ends (e.g., "NLRTM"), empty for the wildcard lane.
+}
+
+// NewLane creates the Lane of the origin and destination, an empty destination is the WildcardDestination.
+func NewLane(origin, destination string) Lane {
+	if destination == "" {
+		destination = WildcardDestination
+	}
+
+	return Lane{Origin: origin, Destination: destination}
+}
+
+// OriginShipments represents a list of ShipmentQuote for a specific Origin and Destination lane.
 type OriginShipments struct {
-	Origin string          // Origin is the located port where the shipment starts (e.g., "CNSGH").
-	Quotes []ShipmentQuote // Quotes is a list of ShipmentQuote for the specified origin.
+	Origin      string          // Origin is the located port where the shipment starts (e.g., "CNSGH").
+	Destination string          // Destination is the located port where the shipment ends (e.g., "NLRTM"), empty for the wildcard lane.
+	Quotes      []ShipmentQuote // Quotes is a list of ShipmentQuote for the specified lane.
+}
+
+// Lane returns the lane of the shipments.
+func (s OriginShipments) Lane() Lane {
+	return NewLane(s.Origin, s.Destination)
 }
 
-// ShipmentUnit represents a single shipment details in a form that is origin based.
+// ShipmentBatch is a published batch of shipments, the expected rates are calculated from the latest one.
+type ShipmentBatch struct {
+	ShipmentsByLane []OriginShipments // ShipmentsByLane are the shipments of the batch grouped by lane and sorted by price.
+	PublishedAt     time.Time         // PublishedAt is the time the batch was published, the zero value when no batch was published yet.
+}
+
+// ShipmentUnit represents a single shipment details in a form that is lane based.
 type ShipmentUnit struct {
 	Origin        string // Origin is the located port where the shipment starts (e.g., "CNSGH").
-	ShipmentQuote        // ShipmentQuote contains the details of a shipment quote (company, price, date).
+	Destination   string // Destination is the located port where the shipment ends (e.g., "NLRTM"), empty when the quote applies to any destination.
+	ShipmentQuote        // ShipmentQuote contains the details of a shipment quote (company, price, date, validity).
+}
+
+// Lane returns the lane of the shipment unit, a shipment unit without destination belongs to the wildcard lane of its
+// origin.
+func (s ShipmentUnit) Lane() Lane {
+	return NewLane(s.Origin, s.Destination)
+}
+
+// Currency is the ISO 4217 code of a currency, e.g., "USD".
+type Currency string
+
+const (
+	DefaultCurrency Currency = "USD" // DefaultCurrency is the base currency when no exchange rates are configured.
+)
+
+// Valid reports whether the currency is formatted as an ISO 4217 code, three uppercase letters. It does not tell
+// whether the currency is supported by the ExchangeRates.
+func (c Currency) Valid() bool {
+	if len(c) != 3 {
+		return false
+	}
+
+	for _, char := range c {
+		if char < 'A' || char > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+const (
+	MinorUnitsPerUnit = 100 // MinorUnitsPerUnit is the number of minor units in a whole unit of every currency (e.g., cents in a dollar), amounts have two decimal places.
+)
+
+// Money is an amount in a currency.
+type Money struct {
+	Amount   int      // Amount is the amount in minor units of the currency (e.g., 12345 for 123.45).
+	Currency Currency // Currency is the currency of the amount.
+}
+
+// RoundingMode defines how an exact amount is rounded to a whole number of minor units.
+type RoundingMode int
+
+const (
+	RoundHalfEven RoundingMode = iota // RoundHalfEven rounds to the nearest minor unit, and halves to the even one (banker's rounding).
+	RoundHalfUp                       // RoundHalfUp rounds to the nearest minor unit, and halves away from zero.
+	RoundDown                         // RoundDown truncates towards zero.
+	RoundUp                           // RoundUp rounds away from zero.
+
+	DefaultRoundingMode = RoundHalfEven // DefaultRoundingMode is the rounding mode used when none is configured.
+)
+
+// String returns the textual representation of the RoundingMode.
+func (m RoundingMode) String() string {
+	switch m {
+	case RoundHalfEven:
+		return "half-even"
+	case RoundHalfUp:
+		return "half-up"
+	case RoundDown:
+		return "down"
+	case RoundUp:
+		return "up"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseRoundingMode parses a textual rounding mode ("half-even", "half-up", "down" or "up") into a RoundingMode.
+func ParseRoundingMode(mode string) (RoundingMode, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "half-even":
+		return RoundHalfEven, nil
+	case "half-up":
+		return RoundHalfUp, nil
+	case "down":
+		return RoundDown, nil
+	case "up":
+		return RoundUp, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidRoundingMode, mode)
+	}
+}
+
+// Round rounds the exact value to an integer with the rounding mode, e.g., to a whole number of minor units.
+func (m RoundingMode) Round(value *big.Rat) int {
+	quotient, remainder := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int)) // Truncated towards zero
+	if remainder.Sign() == 0 {
+		return int(quotient.Int64())
+	}
+
+	// Compare the discarded fraction with one half, remainder / denominator against 1 / 2
+	away := false
+	half := new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(value.Denom())
+	switch m {
+	case RoundHalfEven:
+		away = half > 0 || (half == 0 && quotient.Bit(0) == 1)
+	case RoundHalfUp:
+		away = half >= 0
+	case RoundUp:
+		away = true
+	}
+
+	if away {
+		quotient.Add(quotient, big.NewInt(int64(value.Sign())))
+	}
+
+	return int(quotient.Int64())
+}
+
+// ExchangeRates converts money between the supported currencies.
+type ExchangeRates interface {
+	Base() Currency                           // Base returns the currency the quotes are normalized to for ranking.
+	Rate(from, to Currency) (*big.Rat, error) // Rate returns the exact amount of the to currency worth one unit of the from currency, it returns ErrUnsupportedCurrency if either currency has no exchange rate.
 }
 
 // ShipmentQuote holds the details of a single shipping quote.
 type ShipmentQuote struct {
-	Company int       // Company is the name of the company that provided the quote.
-	Price   int       // Price is the cost of the shipment.
-	Date    time.Time // Date is the date when the shipment will start.
+	Company    int       // Company is the identifier of the company that provided the quote, its name is listed by a CompanyRegistry.
+	Price      int       // Price is the cost of the shipment in minor units of the base currency, the quotes are ranked by it.
+	Quoted     Money     // Quoted is the cost of the shipment as submitted, in its own currency. It is empty for quotes submitted in the base currency without one.
+	Date       time.Time // Date is the date when the shipment will start.
+	ValidUntil time.Time // ValidUntil is the time the quote expires at, the zero value means the quote does not expire.
+	Equipment  Equipment // Equipment is the container type the quote is priced for, empty for quotes stored before equipment types existed.
+}
+
+// EquipmentType returns the equipment type of the quote, a quote without equipment is priced for the DefaultEquipment.
+func (q ShipmentQuote) EquipmentType() Equipment {
+	if q.Equipment == "" {
+		return DefaultEquipment
+	}
+
+	return q.Equipment
+}
+
+// AuditStatus is the outcome of a submission recorded in the audit trail, or the deletion of the quotes of a company.
+type AuditStatus string
+
+const (
+	AuditAccepted AuditStatus = "accepted" // AuditAccepted marks a submission that was stored.
+	AuditRejected AuditStatus = "rejected" // AuditRejected marks a submission that was discarded.
+	AuditDeleted  AuditStatus = "deleted"  // AuditDeleted marks the deletion of every quote of the company for a lane.
+)
+
+// AuditEntry is a single submission of a company for an origin, as recorded in the append-only audit trail.
+type AuditEntry struct {
+	ShipmentUnit             // ShipmentUnit is the submitted shipment, rejected submissions only hold the fields that could be parsed and deletions only the lane and the company.
+	ReceivedAt   time.Time   // ReceivedAt is the time the submission was received.
+	Status       AuditStatus // Status tells whether the submission was accepted or rejected.
+	Reason       string      // Reason explains why the submission was rejected, it is empty for accepted submissions.
+}
+
+// RateQuery describes which expected rates to calculate.
+type RateQuery struct {
+	Top       int       // Top is the number of lowest-priced offers considered for every lane, zero uses the default of the service for the origin of the lane.
+	AsOf      time.Time // AsOf is the date the quotes must be in effect on, the zero value uses the latest published batch.
+	Lanes     bool      // Lanes calculates a rate for every lane, instead of only for the wildcard lane of every origin.
+	Equipment Equipment // Equipment restricts the rates to a single equipment type, the empty value calculates a rate for every equipment type.
+	Currency  Currency  // Currency is the currency of the rates, the empty value uses the base currency.
+	Method    string    // Method is the name of the Aggregator that calculates the rates, the empty value uses the default of the service.
+}
+
+// Aggregator calculates the expected rate of a lane and equipment type from its top quotes, e.g., as their mean or
+// median.
+type Aggregator interface {
+	Name() string                              // Name returns the name the aggregator is selected by, e.g., "median".
+	Aggregate(quotes []ShipmentQuote) *big.Rat // Aggregate returns the exact expected price, in minor units of the base currency, of the quotes. The quotes are never empty and are sorted by price, cheapest first.
+}
+
+// OutlierFilter excludes the quotes whose price is an outlier of their distribution, e.g., a price of 1 entered by
+// mistake, before they are aggregated. The distribution is made of every quote of a lane and equipment type.
+type OutlierFilter interface {
+	Name() string                                                     // Name returns the name of the filter, e.g., "iqr".
+	Filter(quotes []ShipmentQuote) ([]ShipmentQuote, []ExcludedQuote) // Filter returns the quotes that are not outliers, in their order, and the excluded ones with the reason of their exclusion. The quotes are sorted by price, cheapest first. The Lane of the excluded quotes is left to the caller.
+}
+
+// ExcludedQuote is a quote excluded from the expected rates by an OutlierFilter.
+type ExcludedQuote struct {
+	Lane                 // Lane is the lane of the quote.
+	ShipmentQuote        // ShipmentQuote is the excluded quote.
+	Reason        string // Reason explains why the quote is an outlier, e.g., "price 1.00 is below the lower fence 850.00 of the interquartile range".
+}
+
+// ExpectedRate is the expected rate of a lane for an equipment type.
+type ExpectedRate struct {
+	Lane                     // Lane is the lane the rate applies to.
+	Equipment      Equipment // Equipment is the equipment type the rate applies to.
+	Rate           int       // Rate is the expected price of a shipment on the lane, in minor units of the currency of the query. It is Exact rounded with the rounding mode of the service.
+	Exact          *big.Rat  // Exact is the exact expected price, in minor units of the currency of the query, before rounding.
+	Quotes         int       // Quotes is the number of quotes the rate is calculated from, fewer than the top when the lane has fewer quotes.
+	RateStatistics           // RateStatistics describes the quotes the rate is calculated from.
+}
+
+// RateStatistics describes the quotes an expected rate is calculated from. The prices are exact, in minor units of the
+// currency of the query.
+type RateStatistics struct {
+	Min         *big.Rat  // Min is the price of the cheapest quote.
+	Max         *big.Rat  // Max is the price of the most expensive quote.
+	StdDev      *big.Rat  // StdDev is the population standard deviation of the prices, accurate to float64 precision as it is a square root.
+	Oldest      time.Time // Oldest is the earliest date of the quotes.
+	Newest      time.Time // Newest is the latest date of the quotes.
+	PublishedAt time.Time // PublishedAt is the time the batch of the quotes was published, the zero value for the rates of an AsOf query.
+}
+
+// Port is a located port quotes can be submitted for, as listed by a PortRegistry.
+type Port struct {
+	Code     string // Code is the UN/LOCODE of the port (e.g., "CNSGH"), the country code followed by three letters or digits.
+	Name     string // Name is the name of the port (e.g., "Shanghai").
+	Country  string // Country is the ISO 3166-1 alpha-2 code of the country of the port (e.g., "CN").
+	Timezone string // Timezone is the IANA time zone of the port (e.g., "Asia/Shanghai").
+	Active   bool   // Active tells whether quotes can be submitted for the port, as origin or destination.
+}
+
+// PortRegistry provides the ports quotes can be submitted for. The ports can be enabled and disabled at runtime.
+type PortRegistry interface {
+	Port(code string) (Port, bool)                    // Port returns the port with the UN/LOCODE, and whether the registry lists it.
+	Ports() []Port                                    // Ports returns every port of the registry, sorted by code.
+	SetActive(code string, active bool) (Port, error) // SetActive enables or disables the port with the UN/LOCODE and returns it, or ErrUnknownPort if the registry does not list it.
+}
+
+// CompanyStatus is the status of a company of a CompanyRegistry, it tells whether the company can submit quotes.
+type CompanyStatus string
+
+const (
+	CompanyActive    CompanyStatus = "active"    // CompanyActive is the status of the companies that can submit quotes.
+	CompanySuspended CompanyStatus = "suspended" // CompanySuspended is the status of the companies whose quotes are rejected, the quotes they already submitted are kept.
+)
+
+// Valid reports whether the status is CompanyActive or CompanySuspended.
+func (s CompanyStatus) Valid() bool {
+	return s == CompanyActive || s == CompanySuspended
+}
+
+// Company is a company that submits quotes, as listed by a CompanyRegistry.
+type Company struct {
+	ID          int           // ID is the identifier of the company, the Company of its quotes.
+	Name        string        // Name is the display name of the company (e.g., "Blue Whale Lines").
+	Status      CompanyStatus // Status tells whether the company can submit quotes.
+	OnboardedAt time.Time     // OnboardedAt is the date the company was onboarded, at midnight UTC.
+}
+
+// CompanyRegistry provides the companies that can submit quotes. The companies can be registered and suspended at
+// runtime.
+type CompanyRegistry interface {
+	Company(id int) (Company, bool)                          // Company returns the company with the identifier, and whether the registry lists it.
+	Companies() []Company                                    // Companies returns every company of the registry, sorted by identifier.
+	Register(company Company) (Company, error)               // Register adds the company to the registry, or replaces the company with the same identifier, and returns it.
+	SetStatus(id int, status CompanyStatus) (Company, error) // SetStatus activates or suspends the company with the identifier and returns it, or ErrUnknownCompany if the registry does not list it.
+}
+
+// Role is the role of an API key, it tells which requests the key can make. Every role can make the requests of the
+// roles before it: RoleConsumer, RoleSubmitter and RoleAdmin.
+type Role string
+
+const (
+	RoleConsumer  Role = "consumer"  // RoleConsumer is the role of the read-only keys, they can retrieve the expected rates and the quotes.
+	RoleSubmitter Role = "submitter" // RoleSubmitter is the role of the keys of a company, they can also submit and delete the quotes of the company.
+	RoleAdmin     Role = "admin"     // RoleAdmin is the role of the operators, they can also submit the quotes of every company and use the admin endpoints.
+)
+
+// roleRanks orders the roles, a role can make the requests of the roles with a lower rank.
+var roleRanks = map[Role]int{RoleConsumer: 1, RoleSubmitter: 2, RoleAdmin: 3}
+
+// Valid reports whether the role is RoleConsumer, RoleSubmitter or RoleAdmin.
+func (r Role) Valid() bool {
+	_, exists := roleRanks[r]
+
+	return exists
+}
+
+// Allows reports whether the role can make the requests of the required role.
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[required]
+}
+
+// Principal is the holder of an API key, as authenticated by an APIKeyStore.
+type Principal struct {
+	Name    string // Name identifies the API key in the logs (e.g., "blue-whale-ci"), the key itself is never logged.
+	Role    Role   // Role tells which requests the key can make.
+	Company int    // Company is the identifier of the company a RoleSubmitter key submits quotes for, zero for the other roles.
+}
+
+// CanSubmitFor reports whether the principal can submit and delete the quotes of the company.
+func (p Principal) CanSubmitFor(company int) bool {
+	return p.Role == RoleAdmin || (p.Role == RoleSubmitter && p.Company == company)
+}
+
+// APIKeyStore authenticates the API keys of the requests.
+type APIKeyStore interface {
+	Authenticate(key string) (Principal, error) // Authenticate returns the holder of the API key, or ErrInvalidAPIKey if the store does not list it.
+}
+
+// SigningSecretStore provides the secrets the companies share with the service to sign their submissions.
+type SigningSecretStore interface {
+	Secret(company int) ([]byte, bool) // Secret returns the shared secret of the company, or false if the company does not sign its submissions.
 }
 
 // ShipmentService defines the operations related to managing and retrieving shipment data.
 type ShipmentService interface {
-	GetLatestExpectedRates(top int) (map[string]int, error) // GetLatestExpectedRates retrieves the expected rates for the top lowest-priced offers, grouped by origin. The top parameter specifies the number of offers to consider.
-	SubmitShipment(shipment *ShipmentUnit) error            // SubmitShipment submits a new ShipmentUnit offer to the system.
-	IncrementShipmentUnitsCount()                           // IncrementShipmentUnitsCount increments the internal counter for received shipment units.
+	GetLatestExpectedRates(top int) (map[string]int, error)            // GetLatestExpectedRates retrieves the expected rates for the top lowest-priced offers, for the wildcard lane of every origin and the DefaultEquipment. The top parameter specifies the number of offers to consider.
+	GetExpectedRates(query RateQuery) ([]ExpectedRate, error)          // GetExpectedRates retrieves the expected rates described by the query, one per origin or one per lane, and per equipment type.
+	GetExcludedQuotes(query RateQuery) ([]ExcludedQuote, error)        // GetExcludedQuotes retrieves the quotes excluded as outliers from the expected rates described by the query.
+	SubmitShipment(shipment *ShipmentUnit) error                       // SubmitShipment submits a new ShipmentUnit offer to the system.
+	SubmitShipments(shipments []ShipmentUnit) []error                  // SubmitShipments submits the ShipmentUnit offers at once, it returns one error per shipment, nil when it was accepted.
+	IncrementShipmentUnitsCount()                                      // IncrementShipmentUnitsCount increments the internal counter for received shipment units.
+	RecordRejectedShipment(shipment *ShipmentUnit, reason error) error // RecordRejectedShipment records a shipment offer that was rejected before submission in the audit trail of its company.
+	GetQuoteHistory(origin string, company int) ([]AuditEntry, error)  // GetQuoteHistory retrieves every accepted and rejected submission of the company for the origin, in the order they were received.
+	GetLatestQuotes() ([]OriginShipments, error)                       // GetLatestQuotes retrieves the quotes of the latest published batch, grouped by lane and sorted by price.
+	DeleteCompanyQuotes(origin string, company int) (int, error)       // DeleteCompanyQuotes deletes every quote of the company for the origin and returns the number of deleted quotes.
 }
 
 // ShipmentRepository defines the data layer operations for managing shipment units.
 type ShipmentRepository interface {
-	AddOrUpdate(shipment ShipmentUnit) error             // AddOrUpdate adds or updates a new ShipmentUnit offer to the repository, if it is outdated or already exists then it will not be updated.
-	GetLatestSortedShipmentsByOrigin() []OriginShipments // GetLatestSortedShipmentsByOrigin retrieves the latest batched shipment units grouped by origin port and sorted by price.
-	IncrementShipmentUnitsCount()                        // IncrementShipmentUnitsCount tracks the number of received shipment units by incrementing an internal counter.
+	AddOrUpdate(shipment ShipmentUnit) error                          // AddOrUpdate adds or updates a new ShipmentUnit offer to the repository, if it is outdated or already exists then it will not be updated.
+	AddOrUpdateAll(shipments []ShipmentUnit) []error                  // AddOrUpdateAll adds or updates the ShipmentUnit offers under a single lock acquisition, it returns one error per shipment, nil when it was stored.
+	GetLatestSortedShipmentsByOrigin() []OriginShipments              // GetLatestSortedShipmentsByOrigin retrieves the latest batched shipment units grouped by lane and sorted by price.
+	GetLatestBatch() ShipmentBatch                                    // GetLatestBatch retrieves the latest batch, the shipment units of GetLatestSortedShipmentsByOrigin together with their publication time.
+	GetSortedShipmentsByOriginAsOf(date time.Time) []OriginShipments  // GetSortedShipmentsByOriginAsOf retrieves the shipment quote of every company that was in effect on the given date, grouped by lane and sorted by price.
+	IncrementShipmentUnitsCount()                                     // IncrementShipmentUnitsCount tracks the number of received shipment units by incrementing an internal counter.
+	RecordRejectedShipment(shipment ShipmentUnit, reason error) error // RecordRejectedShipment appends a shipment unit rejected before reaching the repository to the audit trail of its origin and company.
+	GetQuoteHistory(origin string, company int) []AuditEntry          // GetQuoteHistory retrieves the audit trail of the company for the origin, in the order the submissions were received.
+	DeleteCompanyQuotes(origin string, company int) (int, error)      // DeleteCompanyQuotes deletes the current quotes and the quote history of the company for every lane of the origin, the audit trail is kept.
 }
@@ -0,0 +1,132 @@
+package persistence
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"quoteship/domain"
+)
+
+const (
+	apiKeyPrefix = "qs_" // apiKeyPrefix starts every generated API key, so the keys can be recognized, e.g., by secret scanners.
+	apiKeyBytes  = 32    // apiKeyBytes is the number of random bytes of a generated API key.
+)
+
+var (
+	ErrEmptyAPIKeysPath = errors.New("API keys file path cannot be empty")
+	ErrInvalidAPIKeys   = errors.New("invalid API keys file")
+)
+
+// apiKeysFile is the content of the API keys file, e.g.,
+// {"keys": [{"name": "blue-whale-ci", "sha256": "9f86d0...", "role": "submitter", "company": 1}]}.
+type apiKeysFile struct {
+	Keys []apiKeyRecord `json:"keys"` // Keys lists the API keys.
+}
+
+// apiKeyRecord is a single API key of the API keys file, only the hash of the key is stored.
+type apiKeyRecord struct {
+	Name    string `json:"name"`              // Name identifies the key in the logs.
+	SHA256  string `json:"sha256"`            // SHA256 is the hex encoded SHA-256 hash of the key.
+	Role    string `json:"role"`              // Role is "consumer", "submitter" or "admin".
+	Company int    `json:"company,omitempty"` // Company is the identifier of the company of a submitter key.
+}
+
+// APIKeyStore is a domain.APIKeyStore loaded from a local JSON file that stores the SHA-256 hash of every key, so the
+// file does not disclose the keys. The API keys are random strings generated with GenerateAPIKey, long enough for an
+// unsalted hash to be safe.
+type APIKeyStore struct {
+	principals map[[sha256.Size]byte]domain.Principal // principals maps the hash of every key to its holder, it is never modified once loaded.
+}
+
+// OpenAPIKeyStore loads the API keys file at path. Every key must have a name, a role and a unique hash, and the keys
+// of the submitter role the identifier of their company.
+func OpenAPIKeyStore(path string) (*APIKeyStore, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, ErrEmptyAPIKeysPath
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read API keys file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var file apiKeysFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAPIKeys, err)
+	}
+
+	store := &APIKeyStore{principals: make(map[[sha256.Size]byte]domain.Principal, len(file.Keys))}
+	for _, record := range file.Keys {
+		hash, principal, err := parseAPIKey(record)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidAPIKeys, err)
+		}
+		if _, exists := store.principals[hash]; exists {
+			return nil, fmt.Errorf("%w: duplicate hash of key %q", ErrInvalidAPIKeys, principal.Name)
+		}
+		store.principals[hash] = principal
+	}
+
+	return store, nil
+}
+
+// Authenticate returns the holder of the API key, or domain.ErrInvalidAPIKey if the store does not list it.
+func (s *APIKeyStore) Authenticate(key string) (domain.Principal, error) {
+	principal, exists := s.principals[sha256.Sum256([]byte(key))]
+	if !exists {
+		return domain.Principal{}, domain.ErrInvalidAPIKey
+	}
+
+	return principal, nil
+}
+
+// GenerateAPIKey returns a new random API key, e.g., "qs_3f1c...", to hand over to its holder. Only its hash is stored
+// in the API keys file.
+func GenerateAPIKey() (string, error) {
+	random := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("generate API key: %w", err)
+	}
+
+	return apiKeyPrefix + hex.EncodeToString(random), nil
+}
+
+// HashAPIKey returns the hex encoded SHA-256 hash of the API key, as stored in the API keys file.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// parseAPIKey validates an API key of the API keys file and returns its hash and holder.
+func parseAPIKey(record apiKeyRecord) ([sha256.Size]byte, domain.Principal, error) {
+	var hash [sha256.Size]byte
+	principal := domain.Principal{Name: strings.TrimSpace(record.Name), Role: domain.Role(record.Role), Company: record.Company}
+
+	decoded, err := hex.DecodeString(record.SHA256)
+	switch {
+	case principal.Name == "":
+		return hash, principal, errors.New("name of key is required")
+	case err != nil || len(decoded) != sha256.Size:
+		return hash, principal, fmt.Errorf("hash of key %q is not a hex encoded SHA-256 hash", principal.Name)
+	case !principal.Role.Valid():
+		return hash, principal, fmt.Errorf("invalid role %q of key %q", record.Role, principal.Name)
+	case principal.Role == domain.RoleSubmitter && principal.Company <= 0:
+		return hash, principal, fmt.Errorf("company of submitter key %q is required", principal.Name)
+	case principal.Role != domain.RoleSubmitter && principal.Company != 0:
+		return hash, principal, fmt.Errorf("company of %s key %q is not allowed", principal.Role, principal.Name)
+	}
+	copy(hash[:], decoded)
+
+	return hash, principal, nil
+}
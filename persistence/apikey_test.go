@@ -0,0 +1,158 @@
+package persistence
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"quoteship/domain"
+)
+
+func TestOpenAPIKeyStore(t *testing.T) {
+	hash := HashAPIKey("qs_submitter")
+
+	tests := []struct {
+		name          string
+		content       string
+		path          func(path string) string
+		expectedError error
+	}{
+		{
+			name:          "invalid path - empty",
+			path:          func(string) string { return " " },
+			expectedError: ErrEmptyAPIKeysPath,
+		},
+		{
+			name:          "missing file",
+			path:          func(path string) string { return path + ".missing" },
+			expectedError: os.ErrNotExist,
+		},
+		{
+			name:          "invalid file - malformed JSON",
+			content:       `{"keys": [`,
+			expectedError: ErrInvalidAPIKeys,
+		},
+		{
+			name:          "invalid file - plain key",
+			content:       `{"keys": [{"name": "ci", "key": "qs_submitter", "role": "submitter", "company": 1}]}`,
+			expectedError: ErrInvalidAPIKeys,
+		},
+		{
+			name:          "invalid file - missing name",
+			content:       `{"keys": [{"name": "", "sha256": "` + hash + `", "role": "submitter", "company": 1}]}`,
+			expectedError: ErrInvalidAPIKeys,
+		},
+		{
+			name:          "invalid file - invalid hash",
+			content:       `{"keys": [{"name": "ci", "sha256": "` + hash[:32] + `", "role": "submitter", "company": 1}]}`,
+			expectedError: ErrInvalidAPIKeys,
+		},
+		{
+			name:          "invalid file - invalid role",
+			content:       `{"keys": [{"name": "ci", "sha256": "` + hash + `", "role": "owner", "company": 1}]}`,
+			expectedError: ErrInvalidAPIKeys,
+		},
+		{
+			name:          "invalid file - submitter without company",
+			content:       `{"keys": [{"name": "ci", "sha256": "` + hash + `", "role": "submitter"}]}`,
+			expectedError: ErrInvalidAPIKeys,
+		},
+		{
+			name:          "invalid file - consumer with company",
+			content:       `{"keys": [{"name": "dashboard", "sha256": "` + hash + `", "role": "consumer", "company": 1}]}`,
+			expectedError: ErrInvalidAPIKeys,
+		},
+		{
+			name:          "invalid file - duplicate hash",
+			content:       `{"keys": [{"name": "ci", "sha256": "` + hash + `", "role": "submitter", "company": 1}, {"name": "dashboard", "sha256": "` + strings.ToUpper(hash) + `", "role": "consumer"}]}`,
+			expectedError: ErrInvalidAPIKeys,
+		},
+		{
+			name:    "valid file",
+			content: `{"keys": [{"name": "ci", "sha256": "` + hash + `", "role": "submitter", "company": 1}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.json")
+			if tt.path != nil {
+				path = tt.path(path)
+			} else if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write API keys file: %v", err)
+			}
+
+			_, err := OpenAPIKeyStore(path)
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestAPIKeyStore_Authenticate(t *testing.T) {
+	submitterKey, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("failed to generate API key: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	content := `{"keys": [
+		{"name": "blue-whale-ci", "sha256": "` + HashAPIKey(submitterKey) + `", "role": "submitter", "company": 1},
+		{"name": "dashboard", "sha256": "` + HashAPIKey("qs_consumer") + `", "role": "consumer"}
+	]}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write API keys file: %v", err)
+	}
+	store, err := OpenAPIKeyStore(path)
+	if err != nil {
+		t.Fatalf("failed to open API key store: %v", err)
+	}
+
+	tests := []struct {
+		name              string
+		key               string
+		expectedError     error
+		expectedPrincipal domain.Principal
+	}{
+		{
+			name:              "submitter key",
+			key:               submitterKey,
+			expectedPrincipal: domain.Principal{Name: "blue-whale-ci", Role: domain.RoleSubmitter, Company: 1},
+		},
+		{
+			name:              "consumer key",
+			key:               "qs_consumer",
+			expectedPrincipal: domain.Principal{Name: "dashboard", Role: domain.RoleConsumer},
+		},
+		{
+			name:          "unknown key",
+			key:           "qs_unknown",
+			expectedError: domain.ErrInvalidAPIKey,
+		},
+		{
+			name:          "hash instead of the key",
+			key:           HashAPIKey("qs_consumer"),
+			expectedError: domain.ErrInvalidAPIKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := store.Authenticate(tt.key)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if principal != tt.expectedPrincipal {
+				t.Errorf("expected principal %+v, got %+v", tt.expectedPrincipal, principal)
+			}
+		})
+	}
+
+	// Every generated key is different
+	if otherKey, _ := GenerateAPIKey(); otherKey == submitterKey || !strings.HasPrefix(otherKey, apiKeyPrefix) {
+		t.Errorf("expected a new key with prefix %q, got %q", apiKeyPrefix, otherKey)
+	}
+}
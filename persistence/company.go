@@ -0,0 +1,257 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"quoteship/domain"
+)
+
+const (
+	companyDateFormat = "2006-01-02" // companyDateFormat is the format of the onboarding dates of the companies file.
+)
+
+var (
+	ErrEmptyCompaniesPath = errors.New("companies file path cannot be empty")
+	ErrInvalidCompanies   = errors.New("invalid companies file")
+)
+
+// companiesFile is the content of the companies file, e.g.,
+// {"companies": [{"id": 1, "name": "Blue Whale Lines", "status": "active", "onboardedAt": "2023-01-15"}]}.
+type companiesFile struct {
+	Companies []companyRecord `json:"companies"` // Companies lists the companies, sorted by identifier.
+}
+
+// companyRecord is a single company of the companies file.
+type companyRecord struct {
+	ID          int    `json:"id"`          // ID is the identifier of the company.
+	Name        string `json:"name"`        // Name is the display name of the company.
+	Status      string `json:"status"`      // Status is "active" or "suspended".
+	OnboardedAt string `json:"onboardedAt"` // OnboardedAt is the date the company was onboarded, in the format "YYYY-MM-DD".
+}
+
+// CompanyRegistry is a domain.CompanyRegistry persisted to a local JSON file. Every registration and status change is
+// written to the file before it takes effect, so the companies survive a restart.
+type CompanyRegistry struct {
+	path      string                 // path is the path of the companies file.
+	companies map[int]domain.Company // companies maps every identifier to its company.
+	mu        sync.RWMutex           // mu synchronizes access to companies and to the companies file.
+	now       func() time.Time       // now returns the current time used as onboarding date, it is replaced in tests.
+}
+
+// OpenCompanyRegistry loads the companies file at path. A missing file is an empty registry, the file is created when
+// the first company is registered.
+func OpenCompanyRegistry(path string) (*CompanyRegistry, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, ErrEmptyCompaniesPath
+	}
+
+	registry := &CompanyRegistry{path: path, companies: make(map[int]domain.Company), now: time.Now}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return registry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read companies file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var file companiesFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCompanies, err)
+	}
+
+	for _, record := range file.Companies {
+		company, err := parseCompany(record)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCompanies, err)
+		}
+		if _, exists := registry.companies[company.ID]; exists {
+			return nil, fmt.Errorf("%w: duplicate company %d", ErrInvalidCompanies, company.ID)
+		}
+		registry.companies[company.ID] = company
+	}
+
+	return registry, nil
+}
+
+// Company returns the company with the identifier, and whether the registry lists it.
+func (r *CompanyRegistry) Company(id int) (domain.Company, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	company, exists := r.companies[id]
+
+	return company, exists
+}
+
+// Companies returns every company of the registry, sorted by identifier.
+func (r *CompanyRegistry) Companies() []domain.Company {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.sortedCompanies()
+}
+
+// Register adds the company to the registry, or replaces the company with the same identifier, and returns it. A
+// company without status is active, and a company without onboarding date is onboarded today. It returns
+// domain.ErrInvalidCompany if the company has no positive identifier or no name.
+func (r *CompanyRegistry) Register(company domain.Company) (domain.Company, error) {
+	company.Name = strings.TrimSpace(company.Name)
+	if company.Status == "" {
+		company.Status = domain.CompanyActive
+	}
+	if company.OnboardedAt.IsZero() {
+		company.OnboardedAt = r.now()
+	}
+	company.OnboardedAt = truncateToDay(company.OnboardedAt)
+
+	switch {
+	case company.ID <= 0:
+		return domain.Company{}, fmt.Errorf("%w: identifier %d", domain.ErrInvalidCompany, company.ID)
+	case company.Name == "":
+		return domain.Company{}, fmt.Errorf("%w: name of %d is required", domain.ErrInvalidCompany, company.ID)
+	case !company.Status.Valid():
+		return domain.Company{}, fmt.Errorf("%w: %q", domain.ErrInvalidCompanyStatus, company.Status)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.update(company); err != nil {
+		return domain.Company{}, err
+	}
+
+	return company, nil
+}
+
+// SetStatus activates or suspends the company with the identifier and returns it, or domain.ErrUnknownCompany if the
+// registry does not list it. The quotes already submitted by a suspended company are kept.
+func (r *CompanyRegistry) SetStatus(id int, status domain.CompanyStatus) (domain.Company, error) {
+	if !status.Valid() {
+		return domain.Company{}, fmt.Errorf("%w: %q", domain.ErrInvalidCompanyStatus, status)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	company, exists := r.companies[id]
+	if !exists {
+		return domain.Company{}, fmt.Errorf("%w: %d", domain.ErrUnknownCompany, id)
+	}
+	company.Status = status
+
+	if err := r.update(company); err != nil {
+		return domain.Company{}, err
+	}
+
+	return company, nil
+}
+
+// update stores the company and writes the companies file, the company is only kept if the file is written. The caller
+// must hold the write lock.
+func (r *CompanyRegistry) update(company domain.Company) error {
+	previous, existed := r.companies[company.ID]
+	r.companies[company.ID] = company
+
+	if err := r.save(); err != nil {
+		if existed {
+			r.companies[company.ID] = previous
+		} else {
+			delete(r.companies, company.ID)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// save writes every company to the companies file. The file is written to a temporary path, synced and renamed, so a
+// crash never leaves a half-written file behind. The caller must hold the lock.
+func (r *CompanyRegistry) save() error {
+	file := companiesFile{Companies: make([]companyRecord, 0, len(r.companies))}
+	for _, company := range r.sortedCompanies() {
+		file.Companies = append(file.Companies, companyRecord{
+			ID:          company.ID,
+			Name:        company.Name,
+			Status:      string(company.Status),
+			OnboardedAt: company.OnboardedAt.Format(companyDateFormat),
+		})
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode companies file: %w", err)
+	}
+
+	tmpPath := r.path + ".tmp"
+	tmpFile, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, walFilePermissions)
+	if err != nil {
+		return fmt.Errorf("create companies file: %w", err)
+	}
+	if _, err = tmpFile.Write(append(data, '\n')); err != nil {
+		_ = tmpFile.Close()
+		return fmt.Errorf("write companies file: %w", err)
+	}
+	if err = tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		return fmt.Errorf("sync companies file: %w", err)
+	}
+	if err = tmpFile.Close(); err != nil {
+		return fmt.Errorf("close companies file: %w", err)
+	}
+	if err = os.Rename(tmpPath, r.path); err != nil {
+		return fmt.Errorf("rename companies file: %w", err)
+	}
+
+	return nil
+}
+
+// sortedCompanies returns every company, sorted by identifier. The caller must hold the lock.
+func (r *CompanyRegistry) sortedCompanies() []domain.Company {
+	companies := make([]domain.Company, 0, len(r.companies))
+	for _, company := range r.companies {
+		companies = append(companies, company)
+	}
+	sort.Slice(companies, func(i, j int) bool { return companies[i].ID < companies[j].ID })
+
+	return companies
+}
+
+// parseCompany validates a company of the companies file. Every company must have a positive identifier, a name, a
+// status and an onboarding date.
+func parseCompany(record companyRecord) (domain.Company, error) {
+	company := domain.Company{ID: record.ID, Name: strings.TrimSpace(record.Name), Status: domain.CompanyStatus(record.Status)}
+
+	onboardedAt, err := time.Parse(companyDateFormat, record.OnboardedAt)
+	switch {
+	case company.ID <= 0:
+		return company, fmt.Errorf("invalid identifier %d", company.ID)
+	case company.Name == "":
+		return company, fmt.Errorf("name of company %d is required", company.ID)
+	case !company.Status.Valid():
+		return company, fmt.Errorf("invalid status %q of company %d", record.Status, company.ID)
+	case err != nil:
+		return company, fmt.Errorf("invalid onboarding date %q of company %d", record.OnboardedAt, company.ID)
+	}
+	company.OnboardedAt = onboardedAt
+
+	return company, nil
+}
+
+// truncateToDay returns midnight UTC of the day of the time.
+func truncateToDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,237 @@
+package persistence
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"quoteship/domain"
+)
+
+func TestOpenCompanyRegistry(t *testing.T) {
+	tests := []struct {
+		name              string
+		content           string
+		path              func(path string) string
+		expectedError     error
+		expectedCompanies []domain.Company
+	}{
+		{
+			name:          "invalid path - empty",
+			path:          func(string) string { return " " },
+			expectedError: ErrEmptyCompaniesPath,
+		},
+		{
+			name:              "missing file",
+			path:              func(path string) string { return path + ".missing" },
+			expectedCompanies: []domain.Company{},
+		},
+		{
+			name:          "invalid file - malformed JSON",
+			content:       `{"companies": [`,
+			expectedError: ErrInvalidCompanies,
+		},
+		{
+			name:          "invalid file - unknown field",
+			content:       `{"companies": [{"id": 1, "name": "Blue Whale Lines", "status": "active", "onboardedAt": "2023-01-15", "country": "NL"}]}`,
+			expectedError: ErrInvalidCompanies,
+		},
+		{
+			name:          "invalid file - invalid identifier",
+			content:       `{"companies": [{"id": 0, "name": "Blue Whale Lines", "status": "active", "onboardedAt": "2023-01-15"}]}`,
+			expectedError: ErrInvalidCompanies,
+		},
+		{
+			name:          "invalid file - missing name",
+			content:       `{"companies": [{"id": 1, "name": " ", "status": "active", "onboardedAt": "2023-01-15"}]}`,
+			expectedError: ErrInvalidCompanies,
+		},
+		{
+			name:          "invalid file - invalid status",
+			content:       `{"companies": [{"id": 1, "name": "Blue Whale Lines", "status": "banned", "onboardedAt": "2023-01-15"}]}`,
+			expectedError: ErrInvalidCompanies,
+		},
+		{
+			name:          "invalid file - invalid onboarding date",
+			content:       `{"companies": [{"id": 1, "name": "Blue Whale Lines", "status": "active", "onboardedAt": "15-01-2023"}]}`,
+			expectedError: ErrInvalidCompanies,
+		},
+		{
+			name:          "invalid file - duplicate company",
+			content:       `{"companies": [{"id": 1, "name": "Blue Whale Lines", "status": "active", "onboardedAt": "2023-01-15"}, {"id": 1, "name": "Red Sea Cargo", "status": "active", "onboardedAt": "2023-01-15"}]}`,
+			expectedError: ErrInvalidCompanies,
+		},
+		{
+			name:    "valid file",
+			content: `{"companies": [{"id": 7, "name": "Red Sea Cargo", "status": "suspended", "onboardedAt": "2023-03-01"}, {"id": 1, "name": "Blue Whale Lines", "status": "active", "onboardedAt": "2023-01-15"}]}`,
+			expectedCompanies: []domain.Company{
+				{ID: 1, Name: "Blue Whale Lines", Status: domain.CompanyActive, OnboardedAt: time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC)},
+				{ID: 7, Name: "Red Sea Cargo", Status: domain.CompanySuspended, OnboardedAt: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "companies.json")
+			if tt.path != nil {
+				path = tt.path(path)
+			} else if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write companies file: %v", err)
+			}
+
+			registry, err := OpenCompanyRegistry(path)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if companies := registry.Companies(); !reflect.DeepEqual(companies, tt.expectedCompanies) {
+				t.Errorf("expected companies %+v, got %+v", tt.expectedCompanies, companies)
+			}
+		})
+	}
+}
+
+func TestCompanyRegistry_Register(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "companies.json")
+	registry, err := OpenCompanyRegistry(path)
+	if err != nil {
+		t.Fatalf("failed to open company registry: %v", err)
+	}
+	registry.now = func() time.Time { return time.Date(2024, 5, 10, 15, 30, 0, 0, time.UTC) }
+
+	// The steps run in order, each one sees the companies registered by the previous ones
+	tests := []struct {
+		name            string
+		company         domain.Company
+		expectedError   error
+		expectedCompany domain.Company
+	}{
+		{
+			name:            "new company onboarded today",
+			company:         domain.Company{ID: 1, Name: " Blue Whale Lines "},
+			expectedCompany: domain.Company{ID: 1, Name: "Blue Whale Lines", Status: domain.CompanyActive, OnboardedAt: time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:            "new suspended company",
+			company:         domain.Company{ID: 7, Name: "Red Sea Cargo", Status: domain.CompanySuspended, OnboardedAt: time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)},
+			expectedCompany: domain.Company{ID: 7, Name: "Red Sea Cargo", Status: domain.CompanySuspended, OnboardedAt: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:            "company renamed",
+			company:         domain.Company{ID: 1, Name: "Blue Whale Container Lines", OnboardedAt: time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)},
+			expectedCompany: domain.Company{ID: 1, Name: "Blue Whale Container Lines", Status: domain.CompanyActive, OnboardedAt: time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:          "invalid identifier",
+			company:       domain.Company{ID: -1, Name: "Blue Whale Lines"},
+			expectedError: domain.ErrInvalidCompany,
+		},
+		{
+			name:          "missing name",
+			company:       domain.Company{ID: 2},
+			expectedError: domain.ErrInvalidCompany,
+		},
+		{
+			name:          "invalid status",
+			company:       domain.Company{ID: 2, Name: "Blue Whale Lines", Status: "banned"},
+			expectedError: domain.ErrInvalidCompanyStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			company, err := registry.Register(tt.company)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if company != tt.expectedCompany {
+				t.Errorf("expected company %+v, got %+v", tt.expectedCompany, company)
+			}
+		})
+	}
+
+	// The registered companies are loaded from the file
+	reopened, err := OpenCompanyRegistry(path)
+	if err != nil {
+		t.Fatalf("failed to reopen company registry: %v", err)
+	}
+	if !reflect.DeepEqual(reopened.Companies(), registry.Companies()) {
+		t.Errorf("expected companies %+v, got %+v", registry.Companies(), reopened.Companies())
+	}
+}
+
+func TestCompanyRegistry_SetStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "companies.json")
+	if err := os.WriteFile(path, []byte(`{"companies": [{"id": 1, "name": "Blue Whale Lines", "status": "active", "onboardedAt": "2023-01-15"}]}`), 0o600); err != nil {
+		t.Fatalf("failed to write companies file: %v", err)
+	}
+	registry, err := OpenCompanyRegistry(path)
+	if err != nil {
+		t.Fatalf("failed to open company registry: %v", err)
+	}
+
+	onboardedAt := time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		id              int
+		status          domain.CompanyStatus
+		expectedError   error
+		expectedCompany domain.Company
+	}{
+		{
+			name:            "suspend company",
+			id:              1,
+			status:          domain.CompanySuspended,
+			expectedCompany: domain.Company{ID: 1, Name: "Blue Whale Lines", Status: domain.CompanySuspended, OnboardedAt: onboardedAt},
+		},
+		{
+			name:          "unknown company",
+			id:            2,
+			status:        domain.CompanySuspended,
+			expectedError: domain.ErrUnknownCompany,
+		},
+		{
+			name:          "invalid status",
+			id:            1,
+			status:        "banned",
+			expectedError: domain.ErrInvalidCompanyStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			company, err := registry.SetStatus(tt.id, tt.status)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if company != tt.expectedCompany {
+				t.Errorf("expected company %+v, got %+v", tt.expectedCompany, company)
+			}
+		})
+	}
+
+	// The suspension is kept in the file
+	reopened, err := OpenCompanyRegistry(path)
+	if err != nil {
+		t.Fatalf("failed to reopen company registry: %v", err)
+	}
+	if company, _ := reopened.Company(1); company.Status != domain.CompanySuspended {
+		t.Errorf("expected suspended company, got %+v", company)
+	}
+
+	// A change that cannot be written is not kept
+	registry.path = filepath.Join(t.TempDir(), "missing", "companies.json")
+	if _, err := registry.SetStatus(1, domain.CompanyActive); err == nil {
+		t.Fatalf("expected error writing companies file")
+	}
+	if company, _ := registry.Company(1); company.Status != domain.CompanySuspended {
+		t.Errorf("expected company to stay suspended, got %+v", company)
+	}
+}
@@ -0,0 +1,153 @@
+package persistence
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"quoteship/domain"
+)
+
+const (
+	defaultSweepInterval = 1 * time.Minute // defaultSweepInterval is the interval between two sweeps of expired quotes when none is configured.
+)
+
+// WithQuoteExpiry makes the repository expire the quotes whose Date is older than maxAge, on top of the quotes whose
+// ValidUntil has passed, and sweep the expired quotes every sweepInterval. A zero maxAge keeps quotes without a
+// ValidUntil forever, and a zero sweepInterval keeps the default interval of one minute.
+func WithQuoteExpiry(maxAge, sweepInterval time.Duration) RepositoryOption {
+	return func(r *ShipmentRepository) {
+		r.maxAge = maxAge
+		if sweepInterval > 0 {
+			r.sweepInterval = sweepInterval
+		}
+	}
+}
+
+// expired reports whether the quote is expired at now, either because its ValidUntil has been reached or because its
+// Date is older than maxAge. A zero maxAge disables the age check.
+func expired(quote domain.ShipmentQuote, now time.Time, maxAge time.Duration) bool {
+	switch {
+	case !quote.ValidUntil.IsZero() && !now.Before(quote.ValidUntil):
+		return true
+	case maxAge > 0 && now.Sub(quote.Date) > maxAge:
+		return true
+	default:
+		return false
+	}
+}
+
+// sweepExpired evicts the expired quotes from every lane shard and, if the latest batch contains any of them,
+// republishes it without the expired quotes. It returns the number of quotes evicted from the shards.
+//
+// The republished batch is the latest batch minus the expired quotes rather than a new copy of the shards, so the
+// submissions received since the last publication are still published by the publication policy only. A sweep that
+// evicted any quote is logged to the write-ahead log, so the replay evicts the same quotes before the submissions that
+// followed it.
+func (r *ShipmentRepository) sweepExpired() int {
+	now := r.now()
+
+	r.countMu.Lock()         // Lock the count first, it serializes batch publication
+	defer r.countMu.Unlock() // Unlock the count when the function returns
+
+	// Never sweep the state left behind by cleanup
+	if r.ctx.Err() != nil {
+		return 0
+	}
+
+	evicted, republished := r.evictExpired(now, r.maxAge)
+	if evicted > 0 || republished {
+		r.persistSweep(now)
+	}
+
+	if evicted > 0 {
+		slog.Info("evicted expired quotes", slog.Int("quotes", evicted))
+	}
+
+	return evicted
+}
+
+// evictExpired evicts the quotes expired at now from every lane shard and from the latest batch, without publishing a
+// new batch. It returns the number of quotes evicted from the shards and whether the latest batch was republished, the
+// caller must hold r.countMu.
+func (r *ShipmentRepository) evictExpired(now time.Time, maxAge time.Duration) (int, bool) {
+	r.mu.RLock() // Lock the mutex so no shard is added while sweeping
+	var evicted int
+	for _, lane := range r.lanes {
+		shard := r.shards[lane]
+
+		shard.mu.Lock()
+		evicted += shard.evictExpired(now, maxAge)
+		shard.mu.Unlock()
+	}
+	r.mu.RUnlock()
+
+	// Evicting the expired quotes of the latest batch does not publish a new one
+	published := r.loadPublishedBatch()
+	batch, changed := unexpiredBatch(published.ShipmentsByLane, now, maxAge)
+	if changed {
+		r.storeBatch(batch, published.PublishedAt)
+	}
+
+	return evicted, changed
+}
+
+// applySweep evicts the quotes that were expired at sweptAt with the max age of the sweep, it is used to replay the
+// write-ahead log.
+func (r *ShipmentRepository) applySweep(sweptAt time.Time, maxAge time.Duration) {
+	r.countMu.Lock()
+	defer r.countMu.Unlock()
+
+	r.evictExpired(sweptAt, maxAge)
+}
+
+// persistSweep appends the sweep of the quotes expired at sweptAt to the write-ahead log. The caller must hold
+// r.countMu.
+func (r *ShipmentRepository) persistSweep(sweptAt time.Time) {
+	if r.wal == nil {
+		return
+	}
+
+	if err := r.wal.Append(newSweepRecord(sweptAt, r.maxAge)); err != nil {
+		slog.Error("failed to append sweep to write-ahead log", "error", err)
+	}
+}
+
+// unexpiredBatch returns a copy of the batch without the quotes expired at now, and whether any quote was removed. The
+// batch itself is left untouched, it may still be read by other goroutines. Lanes left without quotes are dropped.
+func unexpiredBatch(batch []domain.OriginShipments, now time.Time, maxAge time.Duration) ([]domain.OriginShipments, bool) {
+	var changed bool
+	unexpired := make([]domain.OriginShipments, 0, len(batch))
+	for _, originShipments := range batch {
+		quotes := make([]domain.ShipmentQuote, 0, len(originShipments.Quotes))
+		for _, quote := range originShipments.Quotes {
+			if expired(quote, now, maxAge) {
+				changed = true
+				continue
+			}
+			quotes = append(quotes, quote)
+		}
+
+		if len(quotes) > 0 {
+			unexpired = append(unexpired, domain.OriginShipments{Origin: originShipments.Origin, Destination: originShipments.Destination, Quotes: quotes})
+		}
+	}
+
+	return unexpired, changed
+}
+
+// sweepLoop sweeps the expired quotes every sweepInterval until ctx, the context the repository was created with, is
+// cancelled.
+func (r *ShipmentRepository) sweepLoop(ctx context.Context) {
+	ticker := time.NewTicker(r.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.sweepExpired()
+		}
+	}
+}
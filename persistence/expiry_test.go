@@ -0,0 +1,180 @@
+package persistence
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"quoteship/domain"
+)
+
+func TestExpired(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		quote    domain.ShipmentQuote
+		maxAge   time.Duration
+		expected bool
+	}{
+		{
+			name:     "no validity and no max age",
+			quote:    domain.ShipmentQuote{Date: now.AddDate(-10, 0, 0)},
+			expected: false,
+		},
+		{
+			name:     "validity not reached",
+			quote:    domain.ShipmentQuote{Date: now.AddDate(0, -1, 0), ValidUntil: now.Add(time.Second)},
+			expected: false,
+		},
+		{
+			name:     "validity reached",
+			quote:    domain.ShipmentQuote{Date: now.AddDate(0, -1, 0), ValidUntil: now},
+			expected: true,
+		},
+		{
+			name:     "younger than max age",
+			quote:    domain.ShipmentQuote{Date: now.AddDate(0, -1, 0)},
+			maxAge:   365 * 24 * time.Hour,
+			expected: false,
+		},
+		{
+			name:     "older than max age",
+			quote:    domain.ShipmentQuote{Date: now.AddDate(-2, 0, 0)},
+			maxAge:   365 * 24 * time.Hour,
+			expected: true,
+		},
+		{
+			name:     "older than max age with validity not reached",
+			quote:    domain.ShipmentQuote{Date: now.AddDate(-2, 0, 0), ValidUntil: now.AddDate(1, 0, 0)},
+			maxAge:   365 * 24 * time.Hour,
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := expired(tt.quote, now, tt.maxAge); actual != tt.expected {
+				t.Errorf("expected expired %t, got %t", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestShipmentRepository_sweepExpired(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fresh := domain.ShipmentQuote{Company: 1, Price: 300, Date: now.AddDate(0, -1, 0)}
+	validityReached := domain.ShipmentQuote{Company: 2, Price: 100, Date: now.AddDate(0, -1, 0), ValidUntil: now.AddDate(0, 0, -1)}
+	tooOld := domain.ShipmentQuote{Company: 3, Price: 200, Date: now.AddDate(-2, 0, 0)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repository, err := NewShipmentOfferRepository(ctx, 4, WithQuoteExpiry(365*24*time.Hour, time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	repository.now = func() time.Time { return now }
+
+	shipments := []domain.ShipmentUnit{
+		{Origin: "LAX", ShipmentQuote: fresh},
+		{Origin: "LAX", ShipmentQuote: validityReached},
+		{Origin: "NYC", ShipmentQuote: tooOld},
+		{Origin: "LAX", ShipmentQuote: tooOld},
+	}
+	for _, shipment := range shipments {
+		if err = repository.AddOrUpdate(shipment); err != nil {
+			t.Fatalf("failed to add shipment: %v", err)
+		}
+	}
+
+	published := repository.GetLatestSortedShipmentsByOrigin()
+
+	if evicted := repository.sweepExpired(); evicted != 3 {
+		t.Errorf("expected 3 evicted quotes, got %d", evicted)
+	}
+
+	expected := []domain.OriginShipments{{Origin: "LAX", Quotes: []domain.ShipmentQuote{fresh}}}
+	if actual := repository.GetLatestSortedShipmentsByOrigin(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected republished batch %+v, got %+v", expected, actual)
+	}
+	if actual := repository.copyShipments(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected stored shipments %+v, got %+v", expected, actual)
+	}
+	if publishedAt := repository.GetLatestBatch().PublishedAt; !publishedAt.Equal(now) {
+		t.Errorf("expected the batch to keep its publication time %v, got %v", now, publishedAt)
+	}
+
+	// The batch published before the sweep is immutable and keeps the expired quotes
+	if len(published) != 2 || len(published[0].Quotes) != 3 {
+		t.Errorf("expected the previous batch to be left untouched, got %+v", published)
+	}
+
+	// An evicted company can submit an older quote again
+	if err = repository.AddOrUpdate(domain.ShipmentUnit{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 3, Price: 250, Date: now.AddDate(0, -2, 0)}}); err != nil {
+		t.Fatalf("failed to add shipment: %v", err)
+	}
+	if actual := repository.copyShipments(); len(actual) != 2 {
+		t.Errorf("expected the resubmitted quote to be stored, got %+v", actual)
+	}
+}
+
+func TestShipmentRepository_sweepExpired_replay(t *testing.T) {
+	walDir := t.TempDir()
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// open creates a repository backed by the write-ahead log stored in the test directory
+	open := func(t *testing.T) (*ShipmentRepository, *WriteAheadLog) {
+		t.Helper()
+
+		wal, err := OpenWriteAheadLog(WALConfig{Dir: walDir, SyncPolicy: SyncAlways})
+		if err != nil {
+			t.Fatalf("failed to open write-ahead log: %v", err)
+		}
+		repository, err := NewShipmentOfferRepository(context.Background(), 3, WithWriteAheadLog(wal), WithQuoteExpiry(0, time.Hour))
+		if err != nil {
+			t.Fatalf("failed to create repository: %v", err)
+		}
+		return repository, wal
+	}
+
+	original, wal := open(t)
+	original.now = func() time.Time { return now }
+
+	// The third submission publishes a batch with the quote that expires next
+	shipments := []domain.ShipmentUnit{
+		{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 300, Date: now.AddDate(0, -1, 0)}},
+		{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 2, Price: 100, Date: now.AddDate(0, -1, 0), ValidUntil: now.AddDate(0, 0, 1)}},
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 3, Price: 200, Date: now.AddDate(0, -1, 0)}},
+	}
+	for _, shipment := range shipments {
+		if err := original.AddOrUpdate(shipment); err != nil {
+			t.Fatalf("failed to add shipment: %v", err)
+		}
+	}
+
+	now = now.AddDate(0, 0, 2)
+	if evicted := original.sweepExpired(); evicted != 1 {
+		t.Fatalf("expected 1 evicted quote, got %d", evicted)
+	}
+
+	// The evicted company submits an older quote, which is only stored because its expired quote was evicted
+	if err := original.AddOrUpdate(domain.ShipmentUnit{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 2, Price: 150, Date: now.AddDate(0, -2, 0)}}); err != nil {
+		t.Fatalf("failed to add shipment: %v", err)
+	}
+	if err := wal.Close(); err != nil {
+		t.Fatalf("failed to close write-ahead log: %v", err)
+	}
+
+	restored, wal := open(t)
+	defer wal.Close()
+
+	if !reflect.DeepEqual(restored.copyShipments(), original.copyShipments()) {
+		t.Errorf("expected shipments %+v, got %+v", original.copyShipments(), restored.copyShipments())
+	}
+	if !reflect.DeepEqual(restored.GetLatestBatch(), original.GetLatestBatch()) {
+		t.Errorf("expected latest batch %+v, got %+v", original.GetLatestBatch(), restored.GetLatestBatch())
+	}
+}
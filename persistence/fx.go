@@ -0,0 +1,171 @@
+package persistence
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"quoteship/domain"
+)
+
+const (
+	defaultFXReloadInterval = 30 * time.Second // defaultFXReloadInterval is the interval between two checks of the exchange rates file when none is configured.
+)
+
+var (
+	ErrEmptyFXRatesPath = errors.New("exchange rates file path cannot be empty")
+	ErrInvalidFXRates   = errors.New("invalid exchange rates")
+)
+
+// fxRatesFile is the content of the exchange rates file, e.g., {"base": "USD", "rates": {"CNY": 7.1, "EUR": 0.92}}.
+type fxRatesFile struct {
+	Base  string                 `json:"base"`  // Base is the currency the quotes are normalized to.
+	Rates map[string]json.Number `json:"rates"` // Rates maps every other supported currency to the amount of it worth one unit of the base currency, decoded as exact decimals.
+}
+
+// fxRates is a validated, immutable exchange rate table.
+type fxRates struct {
+	base  domain.Currency              // base is the currency the quotes are normalized to.
+	rates map[domain.Currency]*big.Rat // rates maps every supported currency, the base included, to the exact amount of it worth one unit of the base currency.
+}
+
+// FXRateTable provides exact exchange rates between currencies from a local JSON file. The file is checked every reload
+// interval and reloaded when it changed, lookups never wait for a reload. A file that cannot be loaded on reload is
+// logged and the previous exchange rates are kept.
+type FXRateTable struct {
+	path    string                  // path is the path of the exchange rates file.
+	rates   atomic.Pointer[fxRates] // rates points to the exchange rates currently in use, it is swapped on reload.
+	modTime time.Time               // modTime is the modification time of the loaded file, it is only accessed by the reload goroutine.
+	size    int64                   // size is the size of the loaded file, it is only accessed by the reload goroutine.
+}
+
+// NewFXRateTable loads the exchange rates file at path and reloads it every reloadInterval until the context is done. A
+// zero reloadInterval keeps the default interval of 30 seconds.
+func NewFXRateTable(ctx context.Context, path string, reloadInterval time.Duration) (*FXRateTable, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, ErrEmptyFXRatesPath
+	}
+	if reloadInterval <= 0 {
+		reloadInterval = defaultFXReloadInterval
+	}
+
+	table := &FXRateTable{path: path}
+	if _, err := table.reload(); err != nil {
+		return nil, err
+	}
+
+	go table.reloadLoop(ctx, reloadInterval)
+
+	return table, nil
+}
+
+// Base returns the currency the quotes are normalized to.
+func (t *FXRateTable) Base() domain.Currency {
+	return t.rates.Load().base
+}
+
+// Rate returns the exact amount of the to currency worth one unit of the from currency, the caller rounds the converted
+// amounts. It returns domain.ErrUnsupportedCurrency if either currency is not in the exchange rates.
+func (t *FXRateTable) Rate(from, to domain.Currency) (*big.Rat, error) {
+	rates := t.rates.Load()
+
+	fromRate, fromExists := rates.rates[from]
+	toRate, toExists := rates.rates[to]
+	switch {
+	case !fromExists:
+		return nil, fmt.Errorf("%w: %q", domain.ErrUnsupportedCurrency, from)
+	case !toExists:
+		return nil, fmt.Errorf("%w: %q", domain.ErrUnsupportedCurrency, to)
+	}
+
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
+
+// reload loads the exchange rates file if it changed since the last load, and returns whether it was loaded.
+func (t *FXRateTable) reload() (bool, error) {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		return false, fmt.Errorf("stat exchange rates file: %w", err)
+	}
+	if t.rates.Load() != nil && info.ModTime().Equal(t.modTime) && info.Size() == t.size {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return false, fmt.Errorf("read exchange rates file: %w", err)
+	}
+
+	rates, err := parseFXRates(data)
+	if err != nil {
+		return false, err
+	}
+
+	t.rates.Store(rates)
+	t.modTime, t.size = info.ModTime(), info.Size()
+
+	return true, nil
+}
+
+// reloadLoop reloads the exchange rates file every interval until the context is done.
+func (t *FXRateTable) reloadLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := t.reload()
+			switch {
+			case err != nil:
+				slog.Warn("keeping previous exchange rates", "path", t.path, "error", err)
+			case reloaded:
+				slog.Info("reloaded exchange rates", "path", t.path, "base", t.Base())
+			}
+		}
+	}
+}
+
+// parseFXRates decodes and validates the content of an exchange rates file. The base currency and every rated currency
+// must be ISO 4217 codes, and every rate must be a positive decimal.
+func parseFXRates(data []byte) (*fxRates, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var file fxRatesFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFXRates, err)
+	}
+
+	base := domain.Currency(file.Base)
+	if !base.Valid() {
+		return nil, fmt.Errorf("%w: invalid base currency %q", ErrInvalidFXRates, file.Base)
+	}
+
+	rates := &fxRates{base: base, rates: map[domain.Currency]*big.Rat{base: big.NewRat(1, 1)}}
+	for code, number := range file.Rates {
+		currency := domain.Currency(code)
+		rate, ok := new(big.Rat).SetString(number.String())
+		switch {
+		case !currency.Valid():
+			return nil, fmt.Errorf("%w: invalid currency %q", ErrInvalidFXRates, code)
+		case !ok || rate.Sign() <= 0:
+			return nil, fmt.Errorf("%w: invalid rate %v for %q", ErrInvalidFXRates, number, code)
+		case currency == base && rate.Cmp(big.NewRat(1, 1)) != 0:
+			return nil, fmt.Errorf("%w: base currency rate must be 1", ErrInvalidFXRates)
+		}
+		rates.rates[currency] = rate
+	}
+
+	return rates, nil
+}
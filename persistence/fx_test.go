@@ -0,0 +1,201 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"quoteship/domain"
+)
+
+func TestNewFXRateTable(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		path          func(dir string) string
+		expectedError error
+	}{
+		{
+			name:          "invalid path - empty",
+			path:          func(string) string { return "" },
+			expectedError: ErrEmptyFXRatesPath,
+		},
+		{
+			name:          "invalid file - malformed JSON",
+			content:       `{"base": "USD",`,
+			expectedError: ErrInvalidFXRates,
+		},
+		{
+			name:          "invalid file - unknown field",
+			content:       `{"base": "USD", "rate": {"EUR": 0.9}}`,
+			expectedError: ErrInvalidFXRates,
+		},
+		{
+			name:          "invalid file - invalid base currency",
+			content:       `{"base": "usd", "rates": {"EUR": 0.9}}`,
+			expectedError: ErrInvalidFXRates,
+		},
+		{
+			name:          "invalid file - invalid currency",
+			content:       `{"base": "USD", "rates": {"EURO": 0.9}}`,
+			expectedError: ErrInvalidFXRates,
+		},
+		{
+			name:          "invalid file - negative rate",
+			content:       `{"base": "USD", "rates": {"EUR": -0.9}}`,
+			expectedError: ErrInvalidFXRates,
+		},
+		{
+			name:          "invalid file - base currency rate",
+			content:       `{"base": "USD", "rates": {"USD": 2}}`,
+			expectedError: ErrInvalidFXRates,
+		},
+		{
+			name:          "invalid file - rate that is not a number",
+			content:       `{"base": "USD", "rates": {"EUR": "ninety"}}`,
+			expectedError: ErrInvalidFXRates,
+		},
+		{
+			name:    "valid file",
+			content: `{"base": "USD", "rates": {"EUR": 0.9, "CNY": 7.2}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fx.json")
+			if tt.path != nil {
+				path = tt.path(path)
+			} else if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write exchange rates file: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			table, err := NewFXRateTable(ctx, path, time.Hour)
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if err == nil && table.Base() != "USD" {
+				t.Errorf("expected base currency USD, got %s", table.Base())
+			}
+		})
+	}
+}
+
+func TestFXRateTable_Rate(t *testing.T) {
+	table := &FXRateTable{}
+	rates, err := parseFXRates([]byte(`{"base": "USD", "rates": {"EUR": 0.9, "CNY": 7.2}}`))
+	if err != nil {
+		t.Fatalf("failed to parse exchange rates: %v", err)
+	}
+	table.rates.Store(rates)
+
+	tests := []struct {
+		name          string
+		from          domain.Currency
+		to            domain.Currency
+		expectedRate  *big.Rat
+		expectedError error
+	}{
+		{
+			name:         "same currency",
+			from:         "EUR",
+			to:           "EUR",
+			expectedRate: big.NewRat(1, 1),
+		},
+		{
+			name:         "to the base currency",
+			from:         "CNY",
+			to:           "USD",
+			expectedRate: big.NewRat(5, 36), // 1 / 7.2
+		},
+		{
+			name:         "from the base currency",
+			from:         "USD",
+			to:           "EUR",
+			expectedRate: big.NewRat(9, 10),
+		},
+		{
+			name:         "between two currencies is exact",
+			from:         "CNY",
+			to:           "EUR",
+			expectedRate: big.NewRat(1, 8), // 0.9 / 7.2
+		},
+		{
+			name:          "unsupported source currency",
+			from:          "GBP",
+			to:            "USD",
+			expectedError: domain.ErrUnsupportedCurrency,
+		},
+		{
+			name:          "unsupported target currency",
+			from:          "USD",
+			to:            "GBP",
+			expectedError: domain.ErrUnsupportedCurrency,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, err := table.Rate(tt.from, tt.to)
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+
+			if (rate == nil) != (tt.expectedRate == nil) || (rate != nil && rate.Cmp(tt.expectedRate) != 0) {
+				t.Errorf("expected rate %v, got %v", tt.expectedRate, rate)
+			}
+		})
+	}
+}
+
+func TestFXRateTable_reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fx.json")
+	if err := os.WriteFile(path, []byte(`{"base": "USD", "rates": {"EUR": 0.5}}`), 0o600); err != nil {
+		t.Fatalf("failed to write exchange rates file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	table, err := NewFXRateTable(ctx, path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create exchange rates table: %v", err)
+	}
+
+	// An invalid file keeps the previous exchange rates
+	if err = os.WriteFile(path, []byte(`{"base": "USD", "rates": {"EUR": 0}}`), 0o600); err != nil {
+		t.Fatalf("failed to write exchange rates file: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if rate, err := table.Rate("USD", "EUR"); err != nil || rate.Cmp(big.NewRat(1, 2)) != 0 {
+		t.Errorf("expected the previous exchange rates to be kept, got %v, %v", rate, err)
+	}
+
+	// A valid file replaces the exchange rates
+	if err = os.WriteFile(path, []byte(`{"base": "USD", "rates": {"EUR": 0.25, "GBP": 0.75}}`), 0o600); err != nil {
+		t.Fatalf("failed to write exchange rates file: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		rate, err := table.Rate("USD", "EUR")
+		if err == nil && rate.Cmp(big.NewRat(1, 4)) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the exchange rates to be reloaded, got %v, %v", rate, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err = table.Rate("GBP", "USD"); err != nil {
+		t.Errorf("expected the reloaded currency to be supported, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	PublicationModeCount    = "count"    // PublicationModeCount publishes a batch every threshold submissions.
+	PublicationModeInterval = "interval" // PublicationModeInterval publishes a batch every interval.
+	PublicationModeHybrid   = "hybrid"   // PublicationModeHybrid publishes a batch every threshold submissions or every interval, whichever comes first.
+)
+
+var (
+	ErrInvalidPublicationMode     = errors.New("invalid publication mode")
+	ErrInvalidPublicationInterval = errors.New("publication interval must be greater than 0")
+)
+
+// PublicationPolicy decides when the repository publishes a new batch of shipment offers to readers.
+type PublicationPolicy interface {
+	ShouldPublish(count int) bool // ShouldPublish reports whether a batch must be published after count submissions were received since the last publication.
+	Interval() time.Duration      // Interval returns the period of time-based publication, zero disables it.
+}
+
+// CountPolicy publishes a new batch every Threshold submissions.
+type CountPolicy struct {
+	Threshold int // Threshold is the number of submissions received before publishing a batch.
+}
+
+// ShouldPublish reports whether count is a multiple of the threshold.
+func (p CountPolicy) ShouldPublish(count int) bool {
+	return count%p.Threshold == 0
+}
+
+// Interval returns zero, the count policy is not time-based.
+func (p CountPolicy) Interval() time.Duration {
+	return 0
+}
+
+// IntervalPolicy publishes a new batch every Every, as long as a submission was received since the last publication.
+type IntervalPolicy struct {
+	Every time.Duration // Every is the period between two publications.
+}
+
+// ShouldPublish returns false, the interval policy does not publish on submissions.
+func (p IntervalPolicy) ShouldPublish(int) bool {
+	return false
+}
+
+// Interval returns the period between two publications.
+func (p IntervalPolicy) Interval() time.Duration {
+	return p.Every
+}
+
+// HybridPolicy publishes a new batch every Threshold submissions or every Every, whichever comes first. Both triggers
+// restart the count, so a timed publication postpones the next count-based one.
+type HybridPolicy struct {
+	Threshold int           // Threshold is the number of submissions received before publishing a batch.
+	Every     time.Duration // Every is the period between two publications.
+}
+
+// ShouldPublish reports whether count is a multiple of the threshold.
+func (p HybridPolicy) ShouldPublish(count int) bool {
+	return count%p.Threshold == 0
+}
+
+// Interval returns the period between two publications.
+func (p HybridPolicy) Interval() time.Duration {
+	return p.Every
+}
+
+// NewPublicationPolicy creates the PublicationPolicy of the given mode ("count", "interval" or "hybrid"). The threshold
+// is used by the count and hybrid modes, and the interval by the interval and hybrid modes.
+func NewPublicationPolicy(mode string, threshold int, interval time.Duration) (PublicationPolicy, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+
+	switch {
+	case mode != PublicationModeCount && mode != PublicationModeInterval && mode != PublicationModeHybrid:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPublicationMode, mode)
+	case mode != PublicationModeInterval && threshold <= 0:
+		return nil, ErrThresholdCounter
+	case mode != PublicationModeCount && interval <= 0:
+		return nil, ErrInvalidPublicationInterval
+	}
+
+	switch mode {
+	case PublicationModeInterval:
+		return IntervalPolicy{Every: interval}, nil
+	case PublicationModeHybrid:
+		return HybridPolicy{Threshold: threshold, Every: interval}, nil
+	default:
+		return CountPolicy{Threshold: threshold}, nil
+	}
+}
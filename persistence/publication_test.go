@@ -0,0 +1,128 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"quoteship/domain"
+)
+
+func TestNewPublicationPolicy(t *testing.T) {
+	tests := []struct {
+		name           string
+		mode           string
+		threshold      int
+		interval       time.Duration
+		expectedPolicy PublicationPolicy
+		expectedError  error
+	}{
+		{
+			name:           "count mode",
+			mode:           "count",
+			threshold:      10,
+			expectedPolicy: CountPolicy{Threshold: 10},
+		},
+		{
+			name:           "interval mode",
+			mode:           "Interval",
+			interval:       5 * time.Second,
+			expectedPolicy: IntervalPolicy{Every: 5 * time.Second},
+		},
+		{
+			name:           "hybrid mode",
+			mode:           "hybrid",
+			threshold:      10,
+			interval:       5 * time.Second,
+			expectedPolicy: HybridPolicy{Threshold: 10, Every: 5 * time.Second},
+		},
+		{
+			name:          "invalid mode",
+			mode:          "sometimes",
+			threshold:     10,
+			expectedError: ErrInvalidPublicationMode,
+		},
+		{
+			name:          "invalid threshold for count mode",
+			mode:          "count",
+			expectedError: ErrThresholdCounter,
+		},
+		{
+			name:          "invalid interval for hybrid mode",
+			mode:          "hybrid",
+			threshold:     10,
+			expectedError: ErrInvalidPublicationInterval,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewPublicationPolicy(tt.mode, tt.threshold, tt.interval)
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+
+			if !reflect.DeepEqual(policy, tt.expectedPolicy) {
+				t.Errorf("expected policy %+v, got %+v", tt.expectedPolicy, policy)
+			}
+		})
+	}
+}
+
+func TestShipmentRepository_publicationPolicy(t *testing.T) {
+	shipment := domain.ShipmentUnit{
+		Origin:        "LAX",
+		ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 100, Date: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		name            string
+		policy          PublicationPolicy
+		expectPublished bool
+	}{
+		{
+			name:            "count policy waits for the threshold",
+			policy:          CountPolicy{Threshold: 1000},
+			expectPublished: false,
+		},
+		{
+			name:            "interval policy publishes after the interval",
+			policy:          IntervalPolicy{Every: 10 * time.Millisecond},
+			expectPublished: true,
+		},
+		{
+			name:            "hybrid policy publishes after the interval before the threshold",
+			policy:          HybridPolicy{Threshold: 1000, Every: 10 * time.Millisecond},
+			expectPublished: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			repository, err := NewShipmentOfferRepository(ctx, 1000, WithPublicationPolicy(tt.policy))
+			if err != nil {
+				t.Fatalf("failed to create repository: %v", err)
+			}
+
+			if err = repository.AddOrUpdate(shipment); err != nil {
+				t.Fatalf("failed to add shipment: %v", err)
+			}
+
+			// Wait for a few intervals, the timed publication happens in the background
+			deadline := time.Now().Add(500 * time.Millisecond)
+			for len(repository.GetLatestSortedShipmentsByOrigin()) == 0 && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			published := len(repository.GetLatestSortedShipmentsByOrigin()) > 0
+			if published != tt.expectPublished {
+				t.Errorf("expected published %t, got %t", tt.expectPublished, published)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package persistence
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"quoteship/domain"
+)
+
+// quoteKey identifies the quotes of a company for an equipment type, a company has a single current quote per key.
+type quoteKey struct {
+	company   int              // company is the identifier of the company that provided the quote.
+	equipment domain.Equipment // equipment is the equipment type the quote is priced for.
+}
+
+// newQuoteKey returns the quoteKey of the quote.
+func newQuoteKey(quote domain.ShipmentQuote) quoteKey {
+	return quoteKey{company: quote.Company, equipment: quote.EquipmentType()}
+}
+
+// laneShard stores the quotes of a single origin to destination lane. Every shard has its own lock, so the quotes of a
+// lane are read without waiting for the submissions of other lanes.
+type laneShard struct {
+	lane      domain.Lane                         // lane is the lane of the shard (e.g., CNSGH to NLRTM).
+	quotes    []domain.ShipmentQuote              // quotes is the current quote of every company and equipment type, sorted by price, date, company and equipment type.
+	companies map[quoteKey]domain.ShipmentQuote   // companies maps each company and equipment type to its current quote, used to locate it in quotes.
+	history   map[quoteKey][]domain.ShipmentQuote // history stores every quote of each company and equipment type, one per Date and sorted by Date, it is used to answer as-of queries.
+	audit     map[int][]domain.AuditEntry         // audit stores every accepted and rejected submission of each company, in the order they were received.
+	mu        sync.Mutex                          // mu synchronizes access to quotes, companies, history and audit.
+}
+
+// newLaneShard creates an empty laneShard for the given lane.
+func newLaneShard(lane domain.Lane) *laneShard {
+	return &laneShard{
+		lane:      lane,
+		quotes:    []domain.ShipmentQuote{},
+		companies: make(map[quoteKey]domain.ShipmentQuote),
+		history:   make(map[quoteKey][]domain.ShipmentQuote),
+		audit:     make(map[int][]domain.AuditEntry),
+	}
+}
+
+// submit applies the submitted shipment with upsert and appends the outcome to the audit trail of its company. A quote
+// with the same Date and equipment type as one the company already submitted is rejected as a duplicate. The caller
+// must hold s.mu.
+func (s *laneShard) submit(shipment domain.ShipmentUnit, receivedAt time.Time) domain.AuditEntry {
+	entry := domain.AuditEntry{ShipmentUnit: shipment, ReceivedAt: receivedAt, Status: domain.AuditAccepted}
+	if s.duplicate(shipment.ShipmentQuote) {
+		entry.Status = domain.AuditRejected
+		entry.Reason = domain.ErrDuplicateQuote.Error()
+	} else {
+		s.upsert(shipment.ShipmentQuote)
+	}
+
+	s.audit[shipment.Company] = append(s.audit[shipment.Company], entry)
+
+	return entry
+}
+
+// reject appends a submission rejected before reaching the repository to the audit trail of its company, the caller
+// must hold s.mu.
+func (s *laneShard) reject(shipment domain.ShipmentUnit, receivedAt time.Time, reason string) domain.AuditEntry {
+	entry := domain.AuditEntry{ShipmentUnit: shipment, ReceivedAt: receivedAt, Status: domain.AuditRejected, Reason: reason}
+	s.audit[shipment.Company] = append(s.audit[shipment.Company], entry)
+
+	return entry
+}
+
+// copyAudit returns a copy of the audit trail of the company, the caller must hold s.mu.
+func (s *laneShard) copyAudit(company int) []domain.AuditEntry {
+	return append([]domain.AuditEntry(nil), s.audit[company]...)
+}
+
+// copyAuditTrail returns a copy of the audit trail of every company, sorted by company and then in the order the
+// submissions were received. The caller must hold s.mu.
+func (s *laneShard) copyAuditTrail() []domain.AuditEntry {
+	companies := make([]int, 0, len(s.audit))
+	for company := range s.audit {
+		companies = append(companies, company)
+	}
+	sort.Ints(companies)
+
+	var entries []domain.AuditEntry
+	for _, company := range companies {
+		entries = append(entries, s.audit[company]...)
+	}
+
+	return entries
+}
+
+// upsert records the quote in the company's history and stores it as the current quote if its company has no quote for
+// the lane and equipment type yet, or replaces the company's current quote if the new one is more recent. It returns
+// whether the current quotes changed, the caller must hold s.mu.
+func (s *laneShard) upsert(quote domain.ShipmentQuote) bool {
+	// A company has a single quote per Date and equipment type, the first one submitted is kept
+	if !s.record(quote) {
+		return false
+	}
+
+	key := newQuoteKey(quote)
+	current, exists := s.companies[key]
+	if exists {
+		// Keep the current quote if the new one is not more recent
+		if !quote.Date.After(current.Date) {
+			return false
+		}
+		s.remove(current)
+	}
+
+	s.insert(quote)
+	s.companies[key] = quote
+
+	return true
+}
+
+// duplicate reports whether the company already has a quote with the same Date and equipment type in its history, the
+// caller must hold s.mu.
+func (s *laneShard) duplicate(quote domain.ShipmentQuote) bool {
+	history := s.history[newQuoteKey(quote)]
+
+	// Find the first quote that does not start before the new one
+	index := sort.Search(len(history), func(i int) bool {
+		return !history[i].Date.Before(quote.Date)
+	})
+
+	return index < len(history) && history[index].Date.Equal(quote.Date)
+}
+
+// record adds the quote to the history of its company and equipment type at its position by Date. It returns false,
+// leaving the history untouched, if the company already has a quote with the same Date and equipment type. The caller
+// must hold s.mu.
+func (s *laneShard) record(quote domain.ShipmentQuote) bool {
+	key := newQuoteKey(quote)
+	history := s.history[key]
+
+	// Find the first quote that does not start before the new one
+	index := sort.Search(len(history), func(i int) bool {
+		return !history[i].Date.Before(quote.Date)
+	})
+	if index < len(history) && history[index].Date.Equal(quote.Date) {
+		return false
+	}
+
+	history = append(history, domain.ShipmentQuote{})
+	copy(history[index+1:], history[index:])
+	history[index] = quote
+	s.history[key] = history
+
+	return true
+}
+
+// quotesAsOf returns the quote of every company and equipment type that was in effect on date, sorted like the current
+// quotes. The quote in effect is the company's most recent quote that started on or before date, unless it was already
+// expired on that date, see expired. The caller must hold s.mu.
+func (s *laneShard) quotesAsOf(date time.Time, maxAge time.Duration) []domain.ShipmentQuote {
+	quotes := make([]domain.ShipmentQuote, 0, len(s.history))
+	for _, history := range s.history {
+		// Find the first quote that starts after the date, the one before it is the quote in effect
+		index := sort.Search(len(history), func(i int) bool {
+			return history[i].Date.After(date)
+		})
+		if index == 0 || expired(history[index-1], date, maxAge) {
+			continue
+		}
+		quotes = append(quotes, history[index-1])
+	}
+
+	sort.Slice(quotes, func(i, j int) bool { return quoteLess(quotes[i], quotes[j]) })
+
+	return quotes
+}
+
+// copyHistory returns a copy of the history of every company and equipment type, sorted by company, equipment type and
+// then by Date. The caller must hold s.mu.
+func (s *laneShard) copyHistory() []domain.ShipmentQuote {
+	keys := make([]quoteKey, 0, len(s.history))
+	for key := range s.history {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].company != keys[j].company {
+			return keys[i].company < keys[j].company
+		}
+		return keys[i].equipment < keys[j].equipment
+	})
+
+	var quotes []domain.ShipmentQuote
+	for _, key := range keys {
+		quotes = append(quotes, s.history[key]...)
+	}
+
+	return quotes
+}
+
+// insert adds the quote at its sorted position, the caller must hold s.mu.
+func (s *laneShard) insert(quote domain.ShipmentQuote) {
+	// Find the first quote that sorts after the new one
+	index := sort.Search(len(s.quotes), func(i int) bool {
+		return quoteLess(quote, s.quotes[i])
+	})
+
+	s.quotes = append(s.quotes, domain.ShipmentQuote{})
+	copy(s.quotes[index+1:], s.quotes[index:])
+	s.quotes[index] = quote
+}
+
+// remove deletes the quote from its sorted position, the caller must hold s.mu.
+func (s *laneShard) remove(quote domain.ShipmentQuote) {
+	// Find the first quote that does not sort before the removed one, which is the removed quote itself
+	index := sort.Search(len(s.quotes), func(i int) bool {
+		return !quoteLess(s.quotes[i], quote)
+	})
+
+	if index < len(s.quotes) && newQuoteKey(s.quotes[index]) == newQuoteKey(quote) {
+		s.quotes = append(s.quotes[:index], s.quotes[index+1:]...)
+	}
+}
+
+// companyQuotes returns the number of quotes of the company in the history of every equipment type, the caller must
+// hold s.mu.
+func (s *laneShard) companyQuotes(company int) int {
+	var quotes int
+	for key, history := range s.history {
+		if key.company == company {
+			quotes += len(history)
+		}
+	}
+
+	return quotes
+}
+
+// deleteCompany removes the current quotes and the history of the company for every equipment type, and appends the
+// deletion to the audit trail of the company. The caller must hold s.mu.
+func (s *laneShard) deleteCompany(company int, receivedAt time.Time) {
+	for key := range s.history {
+		if key.company != company {
+			continue
+		}
+		if current, exists := s.companies[key]; exists {
+			s.remove(current)
+			delete(s.companies, key)
+		}
+		delete(s.history, key)
+	}
+
+	// The wildcard lane keeps an empty destination, like the shipment units submitted without one
+	shipment := domain.ShipmentUnit{Origin: s.lane.Origin, Destination: s.lane.Destination, ShipmentQuote: domain.ShipmentQuote{Company: company}}
+	if s.lane.Destination == domain.WildcardDestination {
+		shipment.Destination = ""
+	}
+	s.audit[company] = append(s.audit[company], domain.AuditEntry{ShipmentUnit: shipment, ReceivedAt: receivedAt, Status: domain.AuditDeleted})
+}
+
+// evictExpired removes the quotes that are expired at now, see expired, and returns how many were removed. The history
+// of the removed quotes outlives their expiry, so the as-of queries still return them for the dates they were in effect
+// on, see quotesAsOf. The caller must hold s.mu.
+func (s *laneShard) evictExpired(now time.Time, maxAge time.Duration) int {
+	kept := s.quotes[:0]
+	for _, quote := range s.quotes {
+		if expired(quote, now, maxAge) {
+			delete(s.companies, newQuoteKey(quote))
+			continue
+		}
+		kept = append(kept, quote)
+	}
+
+	evicted := len(s.quotes) - len(kept)
+	clear(s.quotes[len(kept):]) // Drop the references held past the end of the kept quotes
+	s.quotes = kept
+
+	return evicted
+}
+
+// copyQuotes returns a copy of the sorted quotes, the caller must hold s.mu.
+func (s *laneShard) copyQuotes() []domain.ShipmentQuote {
+	return append([]domain.ShipmentQuote(nil), s.quotes...)
+}
+
+// laneShipments returns the quotes of the lane as domain.OriginShipments. The wildcard lane keeps an empty destination,
+// like the shipment units submitted without one.
+func laneShipments(lane domain.Lane, quotes []domain.ShipmentQuote) domain.OriginShipments {
+	shipments := domain.OriginShipments{Origin: lane.Origin, Destination: lane.Destination, Quotes: quotes}
+	if lane.Destination == domain.WildcardDestination {
+		shipments.Destination = ""
+	}
+
+	return shipments
+}
+
+// quoteLess reports whether quote a sorts before quote b. Quotes are sorted by price, cheapest first, then by date,
+// most recent first, then by company and finally by equipment type.
+func quoteLess(a, b domain.ShipmentQuote) bool {
+	switch {
+	case a.Price != b.Price:
+		return a.Price < b.Price
+	case !a.Date.Equal(b.Date):
+		return a.Date.After(b.Date)
+	case a.Company != b.Company:
+		return a.Company < b.Company
+	default:
+		return a.EquipmentType() < b.EquipmentType()
+	}
+}
@@ -0,0 +1,225 @@
+package persistence
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"quoteship/domain"
+)
+
+func TestLaneShard_upsert(t *testing.T) {
+	date := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	existingQuotes := []domain.ShipmentQuote{
+		{Company: 1, Price: 100, Date: date},
+		{Company: 2, Price: 200, Date: date},
+		{Company: 3, Price: 300, Date: date},
+	}
+
+	tests := []struct {
+		name            string
+		quoteInput      domain.ShipmentQuote
+		expectedUpdated bool
+		expectedQuotes  []domain.ShipmentQuote
+	}{
+		{
+			name:            "valid upsert - added",
+			quoteInput:      domain.ShipmentQuote{Company: 4, Price: 250, Date: date},
+			expectedUpdated: true,
+			expectedQuotes: []domain.ShipmentQuote{
+				{Company: 1, Price: 100, Date: date},
+				{Company: 2, Price: 200, Date: date},
+				{Company: 4, Price: 250, Date: date},
+				{Company: 3, Price: 300, Date: date},
+			},
+		},
+		{
+			name:            "valid upsert - added with equal price sorts most recent first",
+			quoteInput:      domain.ShipmentQuote{Company: 4, Price: 200, Date: date.AddDate(0, 0, 1)},
+			expectedUpdated: true,
+			expectedQuotes: []domain.ShipmentQuote{
+				{Company: 1, Price: 100, Date: date},
+				{Company: 4, Price: 200, Date: date.AddDate(0, 0, 1)},
+				{Company: 2, Price: 200, Date: date},
+				{Company: 3, Price: 300, Date: date},
+			},
+		},
+		{
+			name:            "valid upsert - added with equal price and date sorts by company",
+			quoteInput:      domain.ShipmentQuote{Company: 4, Price: 200, Date: date},
+			expectedUpdated: true,
+			expectedQuotes: []domain.ShipmentQuote{
+				{Company: 1, Price: 100, Date: date},
+				{Company: 2, Price: 200, Date: date},
+				{Company: 4, Price: 200, Date: date},
+				{Company: 3, Price: 300, Date: date},
+			},
+		},
+		{
+			name:            "valid upsert - updated",
+			quoteInput:      domain.ShipmentQuote{Company: 3, Price: 50, Date: date.AddDate(1, 0, 0)},
+			expectedUpdated: true,
+			expectedQuotes: []domain.ShipmentQuote{
+				{Company: 3, Price: 50, Date: date.AddDate(1, 0, 0)},
+				{Company: 1, Price: 100, Date: date},
+				{Company: 2, Price: 200, Date: date},
+			},
+		},
+		{
+			name:            "valid upsert - added for another equipment type",
+			quoteInput:      domain.ShipmentQuote{Company: 1, Price: 150, Date: date, Equipment: domain.Equipment40HC},
+			expectedUpdated: true,
+			expectedQuotes: []domain.ShipmentQuote{
+				{Company: 1, Price: 100, Date: date},
+				{Company: 1, Price: 150, Date: date, Equipment: domain.Equipment40HC},
+				{Company: 2, Price: 200, Date: date},
+				{Company: 3, Price: 300, Date: date},
+			},
+		},
+		{
+			name:            "valid upsert - not updated with the default equipment type",
+			quoteInput:      domain.ShipmentQuote{Company: 1, Price: 500, Date: date, Equipment: domain.DefaultEquipment},
+			expectedUpdated: false,
+			expectedQuotes:  existingQuotes,
+		},
+		{
+			name:            "valid upsert - not updated with the same date",
+			quoteInput:      domain.ShipmentQuote{Company: 1, Price: 500, Date: date},
+			expectedUpdated: false,
+			expectedQuotes:  existingQuotes,
+		},
+		{
+			name:            "valid upsert - not updated with an older date",
+			quoteInput:      domain.ShipmentQuote{Company: 1, Price: 500, Date: date.AddDate(-1, 0, 0)},
+			expectedUpdated: false,
+			expectedQuotes:  existingQuotes,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shard := newLaneShard(domain.NewLane("LAX", ""))
+			for _, quote := range existingQuotes {
+				shard.upsert(quote)
+			}
+
+			updated := shard.upsert(tt.quoteInput)
+			if updated != tt.expectedUpdated {
+				t.Errorf("expected updated %t, got %t", tt.expectedUpdated, updated)
+			}
+
+			if !reflect.DeepEqual(shard.quotes, tt.expectedQuotes) {
+				t.Errorf("expected quotes %+v, got %+v", tt.expectedQuotes, shard.quotes)
+			}
+			if len(shard.companies) != len(tt.expectedQuotes) {
+				t.Errorf("expected %d companies, got %d", len(tt.expectedQuotes), len(shard.companies))
+			}
+		})
+	}
+}
+
+func TestLaneShard_quotesAsOf(t *testing.T) {
+	date := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	shard := newLaneShard(domain.NewLane("LAX", ""))
+	for _, quote := range []domain.ShipmentQuote{
+		{Company: 1, Price: 300, Date: date},
+		{Company: 1, Price: 100, Date: date.AddDate(0, 1, 0)},
+		{Company: 2, Price: 200, Date: date.AddDate(0, 0, 10), ValidUntil: date.AddDate(0, 0, 20)},
+		{Company: 3, Price: 50, Date: date.AddDate(0, 2, 0)},
+		{Company: 1, Price: 400, Date: date.AddDate(0, 0, 15)}, // Submitted late, it is only recorded in the history
+	} {
+		shard.upsert(quote)
+	}
+
+	tests := []struct {
+		name           string
+		date           time.Time
+		maxAge         time.Duration
+		expectedQuotes []domain.ShipmentQuote
+	}{
+		{
+			name:           "before any quote",
+			date:           date.AddDate(0, 0, -1),
+			expectedQuotes: []domain.ShipmentQuote{},
+		},
+		{
+			name:           "on the date of the first quote",
+			date:           date,
+			expectedQuotes: []domain.ShipmentQuote{{Company: 1, Price: 300, Date: date}},
+		},
+		{
+			name: "within a validity window",
+			date: date.AddDate(0, 0, 12),
+			expectedQuotes: []domain.ShipmentQuote{
+				{Company: 2, Price: 200, Date: date.AddDate(0, 0, 10), ValidUntil: date.AddDate(0, 0, 20)},
+				{Company: 1, Price: 300, Date: date},
+			},
+		},
+		{
+			name: "after a late submission and a validity window",
+			date: date.AddDate(0, 0, 25),
+			expectedQuotes: []domain.ShipmentQuote{
+				{Company: 1, Price: 400, Date: date.AddDate(0, 0, 15)},
+			},
+		},
+		{
+			name: "after every quote",
+			date: date.AddDate(1, 0, 0),
+			expectedQuotes: []domain.ShipmentQuote{
+				{Company: 3, Price: 50, Date: date.AddDate(0, 2, 0)},
+				{Company: 1, Price: 100, Date: date.AddDate(0, 1, 0)},
+			},
+		},
+		{
+			name:   "after every quote with a max age",
+			date:   date.AddDate(0, 3, 0),
+			maxAge: 45 * 24 * time.Hour,
+			expectedQuotes: []domain.ShipmentQuote{
+				{Company: 3, Price: 50, Date: date.AddDate(0, 2, 0)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if quotes := shard.quotesAsOf(tt.date, tt.maxAge); !reflect.DeepEqual(quotes, tt.expectedQuotes) {
+				t.Errorf("expected quotes %+v, got %+v", tt.expectedQuotes, quotes)
+			}
+		})
+	}
+}
+
+func TestLaneShard_submit(t *testing.T) {
+	date := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	receivedAt := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	shard := newLaneShard(domain.NewLane("LAX", ""))
+	shipments := []domain.ShipmentUnit{
+		{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 100, Date: date}},
+		{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 200, Date: date}},
+		{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 300, Date: date.AddDate(0, 0, -1)}},
+	}
+	for _, shipment := range shipments {
+		shard.submit(shipment, receivedAt)
+	}
+	shard.reject(domain.ShipmentUnit{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1}}, receivedAt, domain.ErrInvalidPrice.Error())
+
+	expectedAudit := []domain.AuditEntry{
+		{ShipmentUnit: shipments[0], ReceivedAt: receivedAt, Status: domain.AuditAccepted},
+		{ShipmentUnit: shipments[1], ReceivedAt: receivedAt, Status: domain.AuditRejected, Reason: domain.ErrDuplicateQuote.Error()},
+		{ShipmentUnit: shipments[2], ReceivedAt: receivedAt, Status: domain.AuditAccepted},
+		{ShipmentUnit: domain.ShipmentUnit{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1}}, ReceivedAt: receivedAt, Status: domain.AuditRejected, Reason: domain.ErrInvalidPrice.Error()},
+	}
+	if audit := shard.copyAudit(1); !reflect.DeepEqual(audit, expectedAudit) {
+		t.Errorf("expected audit trail %+v, got %+v", expectedAudit, audit)
+	}
+
+	// The duplicate is not stored, the older quote is only stored in the history
+	if !reflect.DeepEqual(shard.quotes, []domain.ShipmentQuote{shipments[0].ShipmentQuote}) {
+		t.Errorf("expected quotes %+v, got %+v", []domain.ShipmentQuote{shipments[0].ShipmentQuote}, shard.quotes)
+	}
+	if history := shard.history[quoteKey{company: 1, equipment: domain.DefaultEquipment}]; len(history) != 2 {
+		t.Errorf("expected 2 quotes in the history, got %d", len(history))
+	}
+}
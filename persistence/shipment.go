@@ -3,10 +3,13 @@ package persistence
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"quoteship/domain"
 )
@@ -17,14 +20,54 @@ var (
 	ErrOperationCancelled = errors.New("operation cancelled")
 )
 
-// ShipmentRepository manages shipmentInput offers with thread-safe operations.
+// ShipmentRepository manages shipmentInput offers with thread-safe operations. The offers are stored in one shard per
+// lane, every shard having its own lock, so submissions for different lanes do not wait for each other.
 type ShipmentRepository struct {
-	shipmentsByOrigin   []domain.OriginShipments // shipmentsByOrigin is a slice of domain.OriginShipments, used to store shipmentInput offers by origin.
-	latestShipmentBatch []domain.OriginShipments // latestShipmentBatch is a slice of domain.OriginShipments that stores the latest batch of shipmentInput offers, this batch is updated every thresholdCount.
-	shipmentCount       int                      // shipmentCount is a counter that keeps track of the number of shipmentInput offers received, we use this to determine when to update the latestShipmentBatch.
-	thresholdCount      int                      // thresholdCount is the number of shipmentInput offers to receive before updating the latestShipmentBatch, it acts like a recency threshold.
-	mu                  sync.RWMutex             // mu is a read-write mutex that is used to synchronize access to shipmentInput data operations.
-	ctx                 context.Context          // ctx is the context used to cancel operations when the context is cancelled.
+	shards              map[domain.Lane]*laneShard           // shards stores the shipmentInput offers of every lane, keyed by lane.
+	lanes               []domain.Lane                        // lanes lists the lanes in the order they were first submitted, it keeps the batch order stable.
+	mu                  sync.RWMutex                         // mu is a read-write mutex that guards shards and lanes, the offers of a shard are guarded by the shard's own lock.
+	latestShipmentBatch atomic.Pointer[domain.ShipmentBatch] // latestShipmentBatch points to an immutable deep copy of the latest batch of shipmentInput offers, it is swapped every thresholdCount and read without locking.
+	shipmentCount       int                                  // shipmentCount is a counter that keeps track of the number of shipmentInput offers received, we use this to determine when to update the latestShipmentBatch.
+	thresholdCount      int                                  // thresholdCount is the number of shipmentInput offers to receive before updating the latestShipmentBatch, it acts like a recency threshold.
+	policy              PublicationPolicy                    // policy decides when a new latestShipmentBatch is published, it defaults to a CountPolicy of thresholdCount.
+	countMu             sync.Mutex                           // countMu guards shipmentCount and serializes batch publication, it is always acquired before any shard lock.
+	ctx                 context.Context                      // ctx is the context used to cancel operations when the context is cancelled.
+	wal                 *WriteAheadLog                       // wal is the optional write-ahead log that persists every accepted operation, nil when the repository is in-memory only.
+	snapshots           *SnapshotStore                       // snapshots is the optional store of periodic snapshots used to compact the write-ahead log.
+	snapshotInterval    time.Duration                        // snapshotInterval is the interval between two periodic snapshots, zero disables periodic snapshots.
+	snapshotMu          sync.Mutex                           // snapshotMu serializes snapshot creation.
+	maxAge              time.Duration                        // maxAge is the age after which a quote expires even without a ValidUntil, zero disables it.
+	sweepInterval       time.Duration                        // sweepInterval is the interval between two sweeps of the expired quotes.
+	now                 func() time.Time                     // now returns the current time used to expire quotes, it is replaced in tests.
+}
+
+// RepositoryOption configures optional behaviour of a ShipmentRepository.
+type RepositoryOption func(*ShipmentRepository)
+
+// WithWriteAheadLog makes the repository append every accepted operation to the provided WriteAheadLog and replay the
+// log when the repository is created, so the stored shipments survive restarts and crashes.
+func WithWriteAheadLog(wal *WriteAheadLog) RepositoryOption {
+	return func(r *ShipmentRepository) {
+		r.wal = wal
+	}
+}
+
+// WithSnapshots makes the repository load its state from the newest valid snapshot of the store on creation, and take a
+// new snapshot every interval. After each snapshot the write-ahead log segments no retained snapshot needs are removed.
+func WithSnapshots(store *SnapshotStore, interval time.Duration) RepositoryOption {
+	return func(r *ShipmentRepository) {
+		r.snapshots = store
+		r.snapshotInterval = interval
+	}
+}
+
+// WithPublicationPolicy replaces the default count-based publication of the latest batch with the provided policy.
+func WithPublicationPolicy(policy PublicationPolicy) RepositoryOption {
+	return func(r *ShipmentRepository) {
+		if policy != nil {
+			r.policy = policy
+		}
+	}
 }
 
 // AddOrUpdate adds or updates a new domain.ShipmentUnit offer to the repository. If the offer is outdated or already exists,
@@ -43,130 +86,543 @@ func (r *ShipmentRepository) AddOrUpdate(shipment domain.ShipmentUnit) error {
 		// Proceed with normal processing
 	}
 
-	r.mu.Lock()         // Lock the mutex to prevent concurrent access
-	defer r.mu.Unlock() // Unlock the mutex when the function returns
+	r.countMu.Lock()         // Lock the count before the shard like AddOrUpdateAll, so a snapshot never captures the shipment uncounted
+	defer r.countMu.Unlock() // Unlock the count when the function returns
 
-	var wg sync.WaitGroup       // WaitGroup to wait for all goroutines to finish
-	var muOrigin sync.Mutex     // Protects `updated`
-	var updated bool            // Tracks if the shipmentInput was updated
-	done := make(chan struct{}) // Signals early termination
-
-	// Iterate over the shipmentsByOrigin to find the shipmentInput origin, we split the work into goroutines for each origin
-	// to speed up the process.
-	for i := range r.shipmentsByOrigin {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-
-			select {
-			case <-done:
-				// Exit early if another goroutine has already updated
-				return
-			default:
-			}
+	receivedAt := r.now()
+	shard := r.shard(shipment.Lane())
+
+	shard.mu.Lock() // Lock the lane shard, readers of other lanes proceed in parallel
+
+	// Persist the shipment before applying it, so an acknowledged submission survives a crash. The record is appended
+	// under the shard lock, so the log keeps the order in which the submissions of a lane were applied.
+	if r.wal != nil {
+		if err = r.wal.Append(newShipmentRecord(shipment, receivedAt)); err != nil {
+			shard.mu.Unlock()
+			slog.Error("failed to append shipment to write-ahead log", "error", err)
+			return err
+		}
+	}
+
+	shard.submit(shipment, receivedAt) // Duplicates are recorded as rejected in the audit trail
+	shard.mu.Unlock()
 
-			if r.shipmentsByOrigin[i].Origin == shipment.Origin {
-				muOrigin.Lock() // Protect shared variable
-				if !updated {
-					if r.upsertShipment(&r.shipmentsByOrigin[i], &shipment) {
-						updated = true
-						close(done) // Signal other goroutines to stop
-					}
-				}
-				muOrigin.Unlock()
+	// Count the shipment and publish a new batch at the time it was received if the threshold count is reached
+	if r.countShipments(1, receivedAt) {
+		r.persistPublication(receivedAt)
+	}
+
+	return nil
+}
+
+// AddOrUpdateAll adds or updates the domain.ShipmentUnit offers like AddOrUpdate, under a single acquisition of the
+// count lock and of the lock of every lane shard of the batch. The batch is persisted as a single write-ahead log
+// record, so it is either replayed whole or not at all. It returns one error per shipment, in the order of the
+// shipments: nil when the shipment was stored, domain.ErrDuplicateQuote when it was rejected as a duplicate, or the
+// reason it was not applied.
+func (r *ShipmentRepository) AddOrUpdateAll(shipments []domain.ShipmentUnit) []error {
+	errs := make([]error, len(shipments))
+
+	// Only the valid shipments are applied, their indices map the outcomes back
+	valid := make([]domain.ShipmentUnit, 0, len(shipments))
+	indices := make([]int, 0, len(shipments))
+	for i, shipment := range shipments {
+		if errs[i] = validateShipment(shipment); errs[i] == nil {
+			valid = append(valid, shipment)
+			indices = append(indices, i)
+		}
+	}
+
+	// Check if the operation is cancelled.
+	select {
+	case <-r.ctx.Done():
+		for _, i := range indices {
+			errs[i] = ErrOperationCancelled
+		}
+		return errs
+	default:
+		// Proceed with normal processing
+	}
+
+	if len(valid) == 0 {
+		return errs
+	}
+
+	r.countMu.Lock()         // Lock the count once for the batch, it is always acquired before any shard lock
+	defer r.countMu.Unlock() // Unlock the count when the function returns
+
+	receivedAt := r.now()
+	shards := r.lockShards(valid)
+
+	// Persist the batch before applying it, like AddOrUpdate, while every shard of the batch is locked so the log keeps
+	// the order in which the submissions of a lane were applied
+	if r.wal != nil {
+		if err := r.wal.Append(newBatchRecord(valid, receivedAt)); err != nil {
+			unlockShards(shards)
+			slog.Error("failed to append shipment batch to write-ahead log", "error", err)
+			for _, i := range indices {
+				errs[i] = err
 			}
-		}(i)
+			return errs
+		}
 	}
 
-	wg.Wait() // Wait for all goroutines to finish
+	for j, err := range submitAll(shards, valid, receivedAt) {
+		errs[indices[j]] = err
+	}
+	unlockShards(shards)
 
-	// Append the new shipmentInput if it wasn't updated
-	if !updated {
-		r.shipmentsByOrigin = append(r.shipmentsByOrigin, domain.OriginShipments{
-			Origin: shipment.Origin,
-			Quotes: []domain.ShipmentQuote{
-				{
-					Company: shipment.Company,
-					Price:   shipment.Price,
-					Date:    shipment.Date,
-				},
-			},
-		})
+	if r.countShipments(len(valid), receivedAt) {
+		r.persistPublication(receivedAt)
 	}
 
+	return errs
+}
+
+// applyBatch stores the shipments in their lane shards and counts them like AddOrUpdateAll, it is used to replay the
+// write-ahead log. The batches the count published are replayed from their own publication records.
+func (r *ShipmentRepository) applyBatch(shipments []domain.ShipmentUnit, receivedAt time.Time) {
+	r.countMu.Lock()
+	defer r.countMu.Unlock()
+
+	shards := r.lockShards(shipments)
+	submitAll(shards, shipments, receivedAt)
+	unlockShards(shards)
+
+	r.shipmentCount += len(shipments)
+}
+
+// lockShards locks the shard of every lane of the shipments, creating the missing ones, and returns them keyed by lane.
+// The shards are locked in the order their lanes were first submitted, like captureState does, so two batches never
+// wait for each other.
+func (r *ShipmentRepository) lockShards(shipments []domain.ShipmentUnit) map[domain.Lane]*laneShard {
+	shards := make(map[domain.Lane]*laneShard)
+	for _, shipment := range shipments {
+		if lane := shipment.Lane(); shards[lane] == nil {
+			shards[lane] = r.shard(lane)
+		}
+	}
+
+	r.mu.RLock()
+	lanes := r.lanes // The lanes are only appended, so the prefix read here never changes
+	r.mu.RUnlock()
+
+	for _, lane := range lanes {
+		if shard, exists := shards[lane]; exists {
+			shard.mu.Lock()
+		}
+	}
+
+	return shards
+}
+
+// unlockShards unlocks the shards locked by lockShards.
+func unlockShards(shards map[domain.Lane]*laneShard) {
+	for _, shard := range shards {
+		shard.mu.Unlock()
+	}
+}
+
+// submitAll applies the shipments to their locked lane shards in order, and returns one error per shipment:
+// domain.ErrDuplicateQuote when it was rejected as a duplicate, nil otherwise.
+func submitAll(shards map[domain.Lane]*laneShard, shipments []domain.ShipmentUnit, receivedAt time.Time) []error {
+	errs := make([]error, len(shipments))
+	for i, shipment := range shipments {
+		if entry := shards[shipment.Lane()].submit(shipment, receivedAt); entry.Status == domain.AuditRejected {
+			errs[i] = domain.ErrDuplicateQuote
+		}
+	}
+
+	return errs
+}
+
+// applyShipment stores the shipment in its lane shard and counts it like AddOrUpdate, it is used to replay the
+// write-ahead log. The batches the count published are replayed from their own publication records.
+func (r *ShipmentRepository) applyShipment(shipment domain.ShipmentUnit, receivedAt time.Time) {
+	r.countMu.Lock()
+	defer r.countMu.Unlock()
+
+	shard := r.shard(shipment.Lane())
+
+	shard.mu.Lock()
+	shard.submit(shipment, receivedAt)
+	shard.mu.Unlock()
+
 	r.shipmentCount++
+}
+
+// applyPublication publishes the batch at the time it was originally published at, it is used to replay the
+// write-ahead log.
+func (r *ShipmentRepository) applyPublication(publishedAt time.Time) {
+	r.countMu.Lock()
+	defer r.countMu.Unlock()
+
+	r.publishBatch(publishedAt)
+	r.shipmentCount = 0
+}
 
-	// Check if the shipmentInput count has reached the threshold count
-	r.manageBatch()
+// applyRejection appends the rejected shipment to the audit trail of its origin and company, it is used to replay the
+// write-ahead log.
+func (r *ShipmentRepository) applyRejection(shipment domain.ShipmentUnit, receivedAt time.Time, reason string) {
+	shard, exists := r.existingShard(shipment.Lane())
+	if !exists {
+		return
+	}
+
+	shard.mu.Lock()
+	shard.reject(shipment, receivedAt, reason)
+	shard.mu.Unlock()
+}
+
+// RecordRejectedShipment appends a shipment unit that was rejected before reaching the repository, e.g. by the request
+// validation, to the audit trail of its origin and company. The shipment only needs an origin and a company, the
+// other fields hold whatever could be parsed. Rejections do not count towards the publication of a batch. The rejection
+// is only recorded for a lane that already has quotes, so invalid offers never create lanes.
+func (r *ShipmentRepository) RecordRejectedShipment(shipment domain.ShipmentUnit, reason error) error {
+	switch {
+	case strings.TrimSpace(shipment.Origin) == "":
+		return domain.ErrInvalidOriginPort
+	case shipment.Company <= 0:
+		return domain.ErrInvalidCompany
+	}
+
+	// Check if the operation is cancelled.
+	select {
+	case <-r.ctx.Done():
+		return ErrOperationCancelled
+	default:
+		// Proceed with normal processing
+	}
+
+	var message string
+	if reason != nil {
+		message = reason.Error()
+	}
+
+	shard, exists := r.existingShard(shipment.Lane())
+	if !exists {
+		slog.Debug("rejection of a lane without quotes not recorded", "origin", shipment.Origin, "destination", shipment.Destination, "company", shipment.Company)
+		return nil
+	}
+
+	receivedAt := r.now()
+	shard.mu.Lock()         // Lock the lane shard, the audit trail is kept per shard
+	defer shard.mu.Unlock() // Unlock the lane shard when the function returns
+
+	// Persist the rejection before recording it, like the accepted submissions
+	if r.wal != nil {
+		if err := r.wal.Append(newRejectionRecord(shipment, receivedAt, message)); err != nil {
+			slog.Error("failed to append rejection to write-ahead log", "error", err)
+			return err
+		}
+	}
+
+	shard.reject(shipment, receivedAt, message)
 
 	return nil
 }
 
-// upsertShipment updates an existing shipmentInput if found, or adds it if the company does not own a shipmentInput quote for the
-// inserted origin. Takes as arguments a slice of shipments (originShipmentsInput) and a shipmentInput unit to update.
-func (r *ShipmentRepository) upsertShipment(originShipments *domain.OriginShipments, shipment *domain.ShipmentUnit) bool {
-	// Check if the shipmentInput company already exists in the originShipmentsInput, if so update the shipmentInput if the new shipmentInput is more recent or has the same date but a lower price.
-	for i, shipmentQuote := range originShipments.Quotes {
-		if shipmentQuote.Company == shipment.Company {
-			if shipment.Date.After(shipmentQuote.Date) {
-				originShipments.Quotes[i] = domain.ShipmentQuote{
-					Company: shipment.Company,
-					Price:   shipment.Price,
-					Date:    shipment.Date,
-				}
-
-				// remove origin shipment quote
-				originShipments.Quotes = append(originShipments.Quotes[:i], originShipments.Quotes[i+1:]...)
-
-				// find the correct index to insert the new shipment quote
-				index := sort.Search(len(originShipments.Quotes), func(i int) bool {
-					// First condition: sort by price, if equal sort by date
-					if originShipments.Quotes[i].Price == shipment.Price {
-						// Secondary condition: sort by company if the price and date are equal
-						if originShipments.Quotes[i].Date.Equal(shipment.Date) {
-							return originShipments.Quotes[i].Company > shipment.Company // return true if the company is greater
-						}
-						return originShipments.Quotes[i].Date.Before(shipment.Date) // return true if the date is before
-					}
-					return originShipments.Quotes[i].Price > shipment.Price // return true if the price is greater
-				})
-
-				// Insert the new shipment quote at the correct index
-				originShipments.Quotes = append(originShipments.Quotes[:index], append([]domain.ShipmentQuote{shipment.ShipmentQuote}, originShipments.Quotes[index:]...)...)
-			}
-			return true
+// GetQuoteHistory retrieves the audit trail of the company for the origin: every accepted and rejected submission for
+// any lane of the origin, in the order they were received. It returns nil if the company never submitted a quote for
+// the origin.
+func (r *ShipmentRepository) GetQuoteHistory(origin string, company int) []domain.AuditEntry {
+	// Check if the operation is cancelled
+	select {
+	case <-r.ctx.Done():
+		return nil
+	default:
+	}
+
+	r.mu.RLock()         // Lock the mutex so no shard is added while reading
+	defer r.mu.RUnlock() // Unlock the mutex when the function returns
+
+	var history []domain.AuditEntry
+	for _, lane := range r.lanes {
+		if lane.Origin != origin {
+			continue
 		}
+		shard := r.shards[lane]
+
+		shard.mu.Lock()
+		history = append(history, shard.copyAudit(company)...)
+		shard.mu.Unlock()
 	}
 
-	// if company does not exist in the originShipmentsInput, find the correct index to insert the new shipment quote
-	// Sort the quotes by price, date, and company
-	index := sort.Search(len(originShipments.Quotes), func(i int) bool {
-		// First condition: sort by price, if equal sort by date
-		if originShipments.Quotes[i].Price == shipment.Price {
-			// Secondary condition: sort by company if the price and date are equal
-			if originShipments.Quotes[i].Date.Equal(shipment.Date) {
-				return originShipments.Quotes[i].Company > shipment.Company // return true if the company is greater
-			}
-			return originShipments.Quotes[i].Date.Before(shipment.Date) // return true if the date is before
+	// Interleave the submissions of the different lanes, the order within a lane is kept for equal times
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].ReceivedAt.Before(history[j].ReceivedAt)
+	})
+
+	return history
+}
+
+// DeleteCompanyQuotes deletes the current quotes and the quote history of the company for every lane of the origin, and
+// publishes a new batch right away so the deleted quotes no longer count towards the expected rates. The deletion is
+// appended to the audit trail of every lane it removed quotes from, the earlier submissions are kept. It returns the
+// number of deleted quotes, including the ones no longer in effect, or domain.ErrNoCompanyQuotes if the company has no
+// quote for the origin.
+func (r *ShipmentRepository) DeleteCompanyQuotes(origin string, company int) (int, error) {
+	switch {
+	case strings.TrimSpace(origin) == "":
+		return 0, domain.ErrInvalidOriginPort
+	case company <= 0:
+		return 0, domain.ErrInvalidCompany
+	}
+
+	// Check if the operation is cancelled.
+	select {
+	case <-r.ctx.Done():
+		return 0, ErrOperationCancelled
+	default:
+		// Proceed with normal processing
+	}
+
+	r.countMu.Lock()         // Lock the count first, it serializes batch publication
+	defer r.countMu.Unlock() // Unlock the count when the function returns
+
+	receivedAt := r.now()
+	deleted, err := r.deleteCompany(origin, company, receivedAt, func() error {
+		// Persist the deletion before applying it, like the submissions
+		if r.wal == nil {
+			return nil
 		}
-		return originShipments.Quotes[i].Price > shipment.Price // return true if the price is greater
+		if err := r.wal.Append(newDeletionRecord(origin, company, receivedAt)); err != nil {
+			slog.Error("failed to append deletion to write-ahead log", "error", err)
+			return err
+		}
+		return nil
 	})
+	if err != nil {
+		return 0, err
+	}
+
+	r.publishBatch(receivedAt)
+
+	slog.Info("deleted company quotes", slog.String("origin", origin), slog.Int("company", company), slog.Int("quotes", deleted))
+
+	return deleted, nil
+}
+
+// applyDeletion deletes the quotes of the company for the origin and publishes a new batch like DeleteCompanyQuotes, it
+// is used to replay the write-ahead log.
+func (r *ShipmentRepository) applyDeletion(origin string, company int, receivedAt time.Time) {
+	r.countMu.Lock()
+	defer r.countMu.Unlock()
+
+	if _, err := r.deleteCompany(origin, company, receivedAt, func() error { return nil }); err == nil {
+		r.publishBatch(receivedAt)
+	}
+}
+
+// deleteCompany locks the shard of every lane of the origin, in the order the lanes were first submitted, and deletes
+// the quotes of the company once persist succeeded. It returns the number of deleted quotes, domain.ErrNoCompanyQuotes
+// without calling persist if there is none, or the error of persist. The caller must hold r.countMu.
+func (r *ShipmentRepository) deleteCompany(origin string, company int, receivedAt time.Time, persist func() error) (int, error) {
+	r.mu.RLock()         // Lock the mutex so no shard is added while deleting
+	defer r.mu.RUnlock() // Unlock the mutex when the function returns
+
+	var shards []*laneShard
+	for _, lane := range r.lanes {
+		if lane.Origin != origin {
+			continue
+		}
+		shard := r.shards[lane]
+
+		shard.mu.Lock()
+		defer shard.mu.Unlock()
+		shards = append(shards, shard)
+	}
+
+	var deleted int
+	for _, shard := range shards {
+		deleted += shard.companyQuotes(company)
+	}
+	if deleted == 0 {
+		return 0, domain.ErrNoCompanyQuotes
+	}
+
+	if err := persist(); err != nil {
+		return 0, err
+	}
 
-	// add the new shipment quote at the correct index
-	originShipments.Quotes = append(originShipments.Quotes[:index], append([]domain.ShipmentQuote{shipment.ShipmentQuote}, originShipments.Quotes[index:]...)...)
+	for _, shard := range shards {
+		if shard.companyQuotes(company) > 0 {
+			shard.deleteCompany(company, receivedAt)
+		}
+	}
 
-	return true
+	return deleted, nil
 }
 
-// manageBatch updates the shipmentInput batch and resets the shipmentInput count if the threshold count is reached.
-func (r *ShipmentRepository) manageBatch() {
-	if r.shipmentCount%r.thresholdCount == 0 {
-		r.latestShipmentBatch = r.shipmentsByOrigin
-		r.shipmentCount = 0
+// shard returns the shard of the lane, creating it if this is the first submission for the lane.
+func (r *ShipmentRepository) shard(lane domain.Lane) *laneShard {
+	r.mu.RLock()
+	shard, exists := r.shards[lane]
+	r.mu.RUnlock()
+	if exists {
+		return shard
 	}
+
+	r.mu.Lock()         // Lock the mutex to add the new shard
+	defer r.mu.Unlock() // Unlock the mutex when the function returns
+
+	// Another submission may have created the shard in the meantime
+	if shard, exists = r.shards[lane]; exists {
+		return shard
+	}
+
+	shard = newLaneShard(lane)
+	r.shards[lane] = shard
+	r.lanes = append(r.lanes, lane)
+
+	return shard
 }
 
-// GetLatestSortedShipmentsByOrigin retrieves the latest shipments, sorted by price.
+// existingShard returns the shard of the lane, or false if no submission created it yet.
+func (r *ShipmentRepository) existingShard(lane domain.Lane) (*laneShard, bool) {
+	r.mu.RLock()         // Lock the mutex for reading
+	defer r.mu.RUnlock() // Unlock the mutex when the function returns
+
+	shard, exists := r.shards[lane]
+
+	return shard, exists
+}
+
+// countShipments increments the shipmentInput count once per shipment received at receivedAt and publishes a single
+// batch if the threshold count is reached along the way. It reports whether a batch was published, the caller must hold
+// r.countMu.
+func (r *ShipmentRepository) countShipments(count int, receivedAt time.Time) bool {
+	publish := false
+	for range count {
+		r.shipmentCount++
+		if r.policy.ShouldPublish(r.shipmentCount) {
+			publish = true
+			r.shipmentCount = 0
+		}
+	}
+
+	if publish {
+		r.publishBatch(receivedAt)
+	}
+
+	return publish
+}
+
+// persistPublication appends the publication of a batch at publishedAt to the write-ahead log, so the replay publishes
+// the same batch at the same time whatever the count it rebuilt. The caller must hold r.countMu.
+func (r *ShipmentRepository) persistPublication(publishedAt time.Time) {
+	if r.wal == nil {
+		return
+	}
+
+	if err := r.wal.Append(newPublicationRecord(publishedAt)); err != nil {
+		slog.Error("failed to append publication to write-ahead log", "error", err)
+	}
+}
+
+// publicationLoop publishes a new batch every interval of the publication policy, as long as a submission was received
+// since the last publication, until the repository context is cancelled.
+func (r *ShipmentRepository) publicationLoop() {
+	ticker := time.NewTicker(r.policy.Interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			r.countMu.Lock()
+			if r.shipmentCount > 0 && r.ctx.Err() == nil {
+				publishedAt := r.now()
+				r.publishBatch(publishedAt)
+				r.persistPublication(publishedAt)
+				r.shipmentCount = 0
+			}
+			r.countMu.Unlock()
+		}
+	}
+}
+
+// publishBatch publishes a deep copy of the stored shipments as the latest batch at publishedAt, the caller must hold
+// r.countMu. The copy is never modified afterward, so readers holding a previously published batch are not affected by
+// later submissions.
+func (r *ShipmentRepository) publishBatch(publishedAt time.Time) {
+	r.storeBatch(r.copyShipments(), publishedAt)
+}
+
+// storeBatch swaps the latest batch with the provided shipments, published at publishedAt.
+func (r *ShipmentRepository) storeBatch(shipmentsByLane []domain.OriginShipments, publishedAt time.Time) {
+	r.latestShipmentBatch.Store(&domain.ShipmentBatch{ShipmentsByLane: shipmentsByLane, PublishedAt: publishedAt})
+}
+
+// copyShipments returns a deep copy of the stored shipments grouped by lane, in the order the lanes were first
+// submitted. Each shard is locked only while its own quotes are copied.
+func (r *ShipmentRepository) copyShipments() []domain.OriginShipments {
+	r.mu.RLock()         // Lock the mutex so no shard is added while copying
+	defer r.mu.RUnlock() // Unlock the mutex when the function returns
+
+	shipmentsByOrigin := make([]domain.OriginShipments, 0, len(r.lanes))
+	for _, lane := range r.lanes {
+		shard := r.shards[lane]
+
+		shard.mu.Lock()
+		quotes := shard.copyQuotes()
+		shard.mu.Unlock()
+
+		// Skip the shards created by a submission that has not been applied yet
+		if len(quotes) == 0 {
+			continue
+		}
+		shipmentsByOrigin = append(shipmentsByOrigin, laneShipments(lane, quotes))
+	}
+
+	return shipmentsByOrigin
+}
+
+// loadShipments stores the provided shipments in the repository shards, it is used to restore a snapshot. The quotes of
+// a company may include its history, the most recent one becomes the current quote.
+func (r *ShipmentRepository) loadShipments(shipmentsByOrigin []domain.OriginShipments) {
+	for _, originShipments := range shipmentsByOrigin {
+		shard := r.shard(originShipments.Lane())
+
+		shard.mu.Lock()
+		for _, quote := range originShipments.Quotes {
+			shard.upsert(quote)
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// loadAuditTrail appends the provided audit entries to the audit trail of their lane and company, it is used to restore
+// a snapshot.
+func (r *ShipmentRepository) loadAuditTrail(entries []domain.AuditEntry) {
+	for _, entry := range entries {
+		shard := r.shard(entry.Lane())
+
+		shard.mu.Lock()
+		shard.audit[entry.Company] = append(shard.audit[entry.Company], entry)
+		shard.mu.Unlock()
+	}
+}
+
+// loadBatch returns the shipments of the latest published batch, it is safe to call without holding r.mu.
+func (r *ShipmentRepository) loadBatch() []domain.OriginShipments {
+	return r.loadPublishedBatch().ShipmentsByLane
+}
+
+// loadPublishedBatch returns the latest published batch with its publication time, it is safe to call without holding
+// r.mu.
+func (r *ShipmentRepository) loadPublishedBatch() domain.ShipmentBatch {
+	batch := r.latestShipmentBatch.Load()
+	if batch == nil {
+		return domain.ShipmentBatch{}
+	}
+
+	return *batch
+}
+
+// GetLatestSortedShipmentsByOrigin retrieves the latest shipments, sorted by price. The returned batch is an immutable
+// snapshot shared between readers, it is read without locking and must not be modified by the caller.
 func (r *ShipmentRepository) GetLatestSortedShipmentsByOrigin() []domain.OriginShipments {
 	// Check if the operation is cancelled
 	select {
@@ -175,50 +631,309 @@ func (r *ShipmentRepository) GetLatestSortedShipmentsByOrigin() []domain.OriginS
 	default:
 	}
 
-	r.mu.RLock()         // Lock the mutex for reading
+	return r.loadBatch()
+}
+
+// GetLatestBatch retrieves the latest shipments, sorted by price, together with the time they were published. Like
+// GetLatestSortedShipmentsByOrigin, the shipments are shared between readers and must not be modified by the caller.
+func (r *ShipmentRepository) GetLatestBatch() domain.ShipmentBatch {
+	// Check if the operation is cancelled
+	select {
+	case <-r.ctx.Done():
+		return domain.ShipmentBatch{}
+	default:
+	}
+
+	return r.loadPublishedBatch()
+}
+
+// GetSortedShipmentsByOriginAsOf retrieves the quote of every company that was in effect on the given date, grouped by
+// lane and sorted by price. Unlike the latest batch, the quotes are read from the stored quote history, so every
+// accepted submission is taken into account regardless of the publication policy.
+func (r *ShipmentRepository) GetSortedShipmentsByOriginAsOf(date time.Time) []domain.OriginShipments {
+	// Check if the operation is cancelled
+	select {
+	case <-r.ctx.Done():
+		return nil
+	default:
+	}
+
+	r.mu.RLock()         // Lock the mutex so no shard is added while reading
 	defer r.mu.RUnlock() // Unlock the mutex when the function returns
 
-	return r.latestShipmentBatch
+	shipmentsByOrigin := make([]domain.OriginShipments, 0, len(r.lanes))
+	for _, lane := range r.lanes {
+		shard := r.shards[lane]
+
+		shard.mu.Lock()
+		quotes := shard.quotesAsOf(date, r.maxAge)
+		shard.mu.Unlock()
+
+		if len(quotes) > 0 {
+			shipmentsByOrigin = append(shipmentsByOrigin, laneShipments(lane, quotes))
+		}
+	}
+
+	return shipmentsByOrigin
 }
 
 // IncrementShipmentUnitsCount increments the shipmentInput count.
 func (r *ShipmentRepository) IncrementShipmentUnitsCount() {
-	r.mu.Lock()         // Lock the mutex for writing
-	defer r.mu.Unlock() // Unlock the mutex when the function returns
+	r.countMu.Lock()         // Lock the mutex for writing
+	defer r.countMu.Unlock() // Unlock the mutex when the function returns
 
+	// The increment is only counted in memory and persisted by the next snapshot, so invalid submissions never wait for
+	// the write-ahead log
 	r.shipmentCount++
 }
 
+// CreateSnapshot writes the current repository state to the snapshot store and compacts the write-ahead log. The state
+// is captured while every shard is locked, together with a log rotation, so the snapshot and the remaining log
+// segments never overlap; the snapshot itself is written without holding any lock.
+func (r *ShipmentRepository) CreateSnapshot() error {
+	if r.snapshots == nil {
+		return nil
+	}
+
+	r.snapshotMu.Lock()         // Lock the mutex so only one snapshot is created at a time
+	defer r.snapshotMu.Unlock() // Unlock the mutex when the function returns
+
+	walSegment, state, err := r.captureState()
+	if err != nil {
+		return err
+	}
+
+	created, err := r.snapshots.save(walSegment, state)
+	if err != nil {
+		slog.Error("failed to save snapshot", "error", err)
+		return err
+	}
+
+	// Remove the snapshots and log segments that are no longer needed
+	oldestSegment, found, err := r.snapshots.prune()
+	if err != nil {
+		slog.Error("failed to prune snapshots", "error", err)
+		return err
+	}
+	if found && r.wal != nil {
+		if err = r.wal.TruncateBefore(oldestSegment); err != nil {
+			slog.Error("failed to truncate write-ahead log", "error", err)
+			return err
+		}
+	}
+
+	slog.Info("created snapshot", slog.Uint64("sequence", created.sequence), slog.Uint64("wal_segment", walSegment))
+
+	return nil
+}
+
+// captureState rotates the write-ahead log and copies the repository state while no submission can be applied, and
+// returns the first log segment that is not covered by the state.
+func (r *ShipmentRepository) captureState() (uint64, snapshotState, error) {
+	r.countMu.Lock()         // Lock the count first, like every submission does
+	defer r.countMu.Unlock() // Unlock the count when the function returns
+	r.mu.RLock()             // Lock the mutex so no shard is added
+	defer r.mu.RUnlock()     // Unlock the mutex when the function returns
+
+	// Lock every shard so the state and the log rotation are consistent
+	for _, lane := range r.lanes {
+		r.shards[lane].mu.Lock()
+	}
+	defer func() {
+		for _, lane := range r.lanes {
+			r.shards[lane].mu.Unlock()
+		}
+	}()
+
+	// Never snapshot the state left behind by cleanup
+	if r.ctx.Err() != nil {
+		return 0, snapshotState{}, ErrOperationCancelled
+	}
+
+	// Start a new log segment, every record in older segments is covered by the snapshot
+	var walSegment uint64
+	if r.wal != nil {
+		var err error
+		if walSegment, err = r.wal.Rotate(); err != nil {
+			slog.Error("failed to rotate write-ahead log", "error", err)
+			return 0, snapshotState{}, err
+		}
+	}
+
+	// Store the whole quote history, the current quotes are rebuilt from it when the snapshot is loaded
+	shipmentsByOrigin := make([]domain.OriginShipments, 0, len(r.lanes))
+	var auditTrail []domain.AuditEntry
+	for _, lane := range r.lanes {
+		if quotes := r.shards[lane].copyHistory(); len(quotes) > 0 {
+			shipmentsByOrigin = append(shipmentsByOrigin, laneShipments(lane, quotes))
+		}
+		auditTrail = append(auditTrail, r.shards[lane].copyAuditTrail()...)
+	}
+
+	batch := r.loadPublishedBatch() // The published batch is immutable, no copy is needed
+	return walSegment, snapshotState{
+		ShipmentsByOrigin:      shipmentsByOrigin,
+		AuditTrail:             auditTrail,
+		LatestShipmentBatch:    batch.ShipmentsByLane,
+		LatestBatchPublishedAt: batch.PublishedAt,
+		ShipmentCount:          r.shipmentCount,
+		ThresholdCount:         r.thresholdCount,
+	}, nil
+}
+
+// restore loads the newest valid snapshot into the repository and returns the first write-ahead log segment that must
+// be replayed on top of it. Without a valid snapshot the whole log is replayed.
+func (r *ShipmentRepository) restore() uint64 {
+	loaded, err := r.snapshots.loadLatest()
+	if err != nil {
+		slog.Warn("starting without snapshot", "error", err)
+		return 0
+	}
+
+	if loaded.state.ThresholdCount != r.thresholdCount {
+		slog.Warn("snapshot threshold count differs from the configured one", slog.Int("snapshot", loaded.state.ThresholdCount), slog.Int("configured", r.thresholdCount))
+	}
+
+	r.loadShipments(loaded.state.ShipmentsByOrigin)
+	r.loadAuditTrail(loaded.state.AuditTrail)
+	r.shipmentCount = loaded.state.ShipmentCount
+
+	// Keep the empty, non-nil batch a new repository starts with
+	batch := loaded.state.LatestShipmentBatch
+	if batch == nil {
+		batch = []domain.OriginShipments{}
+	}
+	r.storeBatch(batch, loaded.state.LatestBatchPublishedAt)
+
+	slog.Info("loaded snapshot", slog.Uint64("sequence", loaded.sequence), slog.Uint64("wal_segment", loaded.walSegment))
+
+	return loaded.walSegment
+}
+
+// snapshotLoop creates a snapshot every snapshotInterval until the repository context is cancelled.
+func (r *ShipmentRepository) snapshotLoop() {
+	ticker := time.NewTicker(r.snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			// Errors are logged by CreateSnapshot, the next tick retries
+			_ = r.CreateSnapshot()
+		}
+	}
+}
+
+// replay rebuilds the repository state by applying every record stored in the write-ahead log from fromSegment, in
+// order.
+func (r *ShipmentRepository) replay(fromSegment uint64) error {
+	var replayed int
+	err := r.wal.Replay(fromSegment, func(record walRecord) error {
+		if record.Version != walRecordVersion {
+			return fmt.Errorf("%w: unsupported version %d", ErrCorruptWALRecord, record.Version)
+		}
+
+		switch record.Type {
+		case walRecordShipment:
+			if record.Shipment == nil {
+				return fmt.Errorf("%w: shipment record without shipment", ErrCorruptWALRecord)
+			}
+			r.applyShipment(record.Shipment.shipmentUnit(), record.Shipment.ReceivedAt)
+		case walRecordRejection:
+			if record.Shipment == nil {
+				return fmt.Errorf("%w: rejection record without shipment", ErrCorruptWALRecord)
+			}
+			r.applyRejection(record.Shipment.shipmentUnit(), record.Shipment.ReceivedAt, record.Reason)
+		case walRecordBatch:
+			if len(record.Shipments) == 0 {
+				return fmt.Errorf("%w: batch record without shipments", ErrCorruptWALRecord)
+			}
+			shipments := make([]domain.ShipmentUnit, 0, len(record.Shipments))
+			for _, shipment := range record.Shipments {
+				shipments = append(shipments, shipment.shipmentUnit())
+			}
+			r.applyBatch(shipments, record.Shipments[0].ReceivedAt)
+		case walRecordDeletion:
+			if record.Shipment == nil {
+				return fmt.Errorf("%w: deletion record without shipment", ErrCorruptWALRecord)
+			}
+			r.applyDeletion(record.Shipment.Origin, record.Shipment.Company, record.Shipment.ReceivedAt)
+		case walRecordPublication:
+			if record.Published == nil {
+				return fmt.Errorf("%w: publication record without time", ErrCorruptWALRecord)
+			}
+			r.applyPublication(*record.Published)
+		case walRecordSweep:
+			if record.Swept == nil {
+				return fmt.Errorf("%w: sweep record without time", ErrCorruptWALRecord)
+			}
+			r.applySweep(*record.Swept, record.MaxAge)
+		default:
+			return fmt.Errorf("%w: unknown record type %q", ErrCorruptWALRecord, record.Type)
+		}
+		replayed++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("replayed write-ahead log", slog.Int("records", replayed))
+
+	return nil
+}
+
 // validateShipment validates the domain.ShipmentUnit argument fields.
 func validateShipment(shipment domain.ShipmentUnit) error {
 	switch {
 	case strings.TrimSpace(shipment.Origin) == "":
 		return domain.ErrInvalidOriginPort
+	case shipment.Destination == shipment.Origin:
+		return domain.ErrInvalidDestinationPort
 	case shipment.Price <= 0:
 		return domain.ErrInvalidPrice
 	case shipment.Date.IsZero():
 		return domain.ErrInvalidDate
 	case shipment.Company <= 0:
 		return domain.ErrInvalidCompany
+	case !shipment.ValidUntil.IsZero() && !shipment.ValidUntil.After(shipment.Date):
+		return domain.ErrInvalidValidity
+	case !shipment.EquipmentType().Valid():
+		return domain.ErrInvalidEquipment
 	}
 	return nil
 }
 
 // cleanup clears all the stored data.
 func (r *ShipmentRepository) cleanup() {
-	r.mu.Lock()         // Lock the mutex for writing
-	defer r.mu.Unlock() // Unlock the mutex when the function returns
-
-	r.shipmentsByOrigin = nil
-	r.latestShipmentBatch = nil
+	r.countMu.Lock()         // Lock the count first, like every submission does
+	defer r.countMu.Unlock() // Unlock the count when the function returns
+	r.mu.Lock()              // Lock the mutex for writing
+	defer r.mu.Unlock()      // Unlock the mutex when the function returns
+
+	r.shards = make(map[domain.Lane]*laneShard)
+	r.lanes = nil
+	r.latestShipmentBatch.Store(nil)
 	r.shipmentCount = 0
+
+	// Close the write-ahead log, the persisted data is kept on disk for the next start
+	if r.wal != nil {
+		if err := r.wal.Close(); err != nil {
+			slog.Error("failed to close write-ahead log", "error", err)
+		}
+	}
+
 	slog.Warn("repository data has been cleared")
 }
 
-// NewShipmentOfferRepository initializes a new ShipmentRepository. It takes a context and a thresholdCount as arguments.
-// The context is used to cancel operations when the context is cancelled, and the thresholdCount is the number of shipmentInput offers to
-// receive before updating the latestShipmentBatch. The latestShipmentBatch is meant to be sent for calculating the estimates prices.
-func NewShipmentOfferRepository(ctx context.Context, thresholdCount int) (*ShipmentRepository, error) {
+// NewShipmentOfferRepository initializes a new ShipmentRepository. It takes a context and a thresholdCount as
+// arguments. The context is used to cancel operations when the context is cancelled, and the thresholdCount is the
+// number of shipmentInput offers to receive before updating the latestShipmentBatch. The latestShipmentBatch is meant
+// to be sent for calculating the estimates prices. When a write-ahead log is provided through WithWriteAheadLog, its
+// records are replayed before the repository is returned.
+func NewShipmentOfferRepository(ctx context.Context, thresholdCount int, opts ...RepositoryOption) (*ShipmentRepository, error) {
 	switch {
 	case ctx == nil:
 		slog.Error("failed to create repository", "error", ErrNilContext.Error())
@@ -230,17 +945,49 @@ func NewShipmentOfferRepository(ctx context.Context, thresholdCount int) (*Shipm
 
 	// Initialize a new ShipmentRepository
 	repo := &ShipmentRepository{
-		shipmentsByOrigin:   []domain.OriginShipments{},
-		latestShipmentBatch: []domain.OriginShipments{},
-		thresholdCount:      thresholdCount,
-		ctx:                 ctx,
+		shards:         make(map[domain.Lane]*laneShard),
+		thresholdCount: thresholdCount,
+		policy:         CountPolicy{Threshold: thresholdCount},
+		ctx:            ctx,
+		sweepInterval:  defaultSweepInterval,
+		now:            time.Now,
+	}
+	repo.storeBatch([]domain.OriginShipments{}, time.Time{})
+
+	for _, opt := range opts {
+		opt(repo)
 	}
 
+	// Rebuild the stored shipments and the latest batch from the newest snapshot and the write-ahead log
+	var fromSegment uint64
+	if repo.snapshots != nil {
+		fromSegment = repo.restore()
+	}
+	if repo.wal != nil {
+		if err := repo.replay(fromSegment); err != nil {
+			slog.Error("failed to replay write-ahead log", "error", err.Error())
+			return nil, err
+		}
+	}
+
+	// Evict the quotes that expired while the repository was not running
+	repo.sweepExpired()
+
 	// Cleanup on context cancellation
 	go func() {
 		<-ctx.Done()
 		repo.cleanup() // Clear the repository data
 	}()
 
+	if repo.snapshots != nil && repo.snapshotInterval > 0 {
+		go repo.snapshotLoop()
+	}
+
+	if repo.policy.Interval() > 0 {
+		go repo.publicationLoop()
+	}
+
+	go repo.sweepLoop(ctx)
+
 	return repo, nil
 }
@@ -0,0 +1,155 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"quoteship/domain"
+)
+
+// legacyShipmentRepository is the repository implementation that preceded the per-origin shards: a single lock for
+// every submission and one goroutine per origin to find the submitted origin. It is kept as the benchmark baseline.
+type legacyShipmentRepository struct {
+	shipmentsByOrigin   []domain.OriginShipments // shipmentsByOrigin stores the shipment offers by origin.
+	latestShipmentBatch []domain.OriginShipments // latestShipmentBatch stores the latest published batch.
+	shipmentCount       int                      // shipmentCount is the number of offers received since the last batch.
+	thresholdCount      int                      // thresholdCount is the number of offers received before publishing a batch.
+	mu                  sync.RWMutex             // mu synchronizes every operation.
+}
+
+// AddOrUpdate adds or updates the offer using the legacy global lock and goroutine-per-origin scan.
+func (r *legacyShipmentRepository) AddOrUpdate(shipment domain.ShipmentUnit) error {
+	if err := validateShipment(shipment); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var wg sync.WaitGroup
+	var muOrigin sync.Mutex
+	var updated bool
+	done := make(chan struct{})
+
+	for i := range r.shipmentsByOrigin {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			if r.shipmentsByOrigin[i].Origin == shipment.Origin {
+				muOrigin.Lock()
+				if !updated {
+					r.upsertShipment(&r.shipmentsByOrigin[i], &shipment)
+					updated = true
+					close(done)
+				}
+				muOrigin.Unlock()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if !updated {
+		r.shipmentsByOrigin = append(r.shipmentsByOrigin, domain.OriginShipments{
+			Origin: shipment.Origin,
+			Quotes: []domain.ShipmentQuote{shipment.ShipmentQuote},
+		})
+	}
+
+	r.shipmentCount++
+	if r.shipmentCount%r.thresholdCount == 0 {
+		r.latestShipmentBatch = r.shipmentsByOrigin
+		r.shipmentCount = 0
+	}
+
+	return nil
+}
+
+// upsertShipment replaces the company's quote if the new one is more recent, or inserts it at its sorted position.
+func (r *legacyShipmentRepository) upsertShipment(originShipments *domain.OriginShipments, shipment *domain.ShipmentUnit) {
+	for i, shipmentQuote := range originShipments.Quotes {
+		if shipmentQuote.Company == shipment.Company {
+			if !shipment.Date.After(shipmentQuote.Date) {
+				return
+			}
+			originShipments.Quotes = append(originShipments.Quotes[:i], originShipments.Quotes[i+1:]...)
+			break
+		}
+	}
+
+	index := sort.Search(len(originShipments.Quotes), func(i int) bool {
+		return quoteLess(shipment.ShipmentQuote, originShipments.Quotes[i])
+	})
+	originShipments.Quotes = append(originShipments.Quotes[:index], append([]domain.ShipmentQuote{shipment.ShipmentQuote}, originShipments.Quotes[index:]...)...)
+}
+
+// shipmentAdder is the operation benchmarked on both repository implementations.
+type shipmentAdder interface {
+	AddOrUpdate(shipment domain.ShipmentUnit) error
+}
+
+func BenchmarkShipmentRepository_AddOrUpdate(b *testing.B) {
+	implementations := []struct {
+		name       string
+		repository func(b *testing.B) shipmentAdder
+	}{
+		{
+			name: "legacy",
+			repository: func(*testing.B) shipmentAdder {
+				return &legacyShipmentRepository{thresholdCount: 1000}
+			},
+		},
+		{
+			name: "sharded",
+			repository: func(b *testing.B) shipmentAdder {
+				repository, err := NewShipmentOfferRepository(context.Background(), 1000)
+				if err != nil {
+					b.Fatalf("failed to create repository: %v", err)
+				}
+				return repository
+			},
+		},
+	}
+
+	date := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, origins := range []int{5, 100} {
+		for _, implementation := range implementations {
+			b.Run(fmt.Sprintf("%s/origins=%d", implementation.name, origins), func(b *testing.B) {
+				repository := implementation.repository(b)
+				var sequence atomic.Int64
+
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						i := int(sequence.Add(1))
+						err := repository.AddOrUpdate(domain.ShipmentUnit{
+							Origin: fmt.Sprintf("P%04d", i%origins),
+							ShipmentQuote: domain.ShipmentQuote{
+								Company: i%999 + 1,
+								Price:   i%99999 + 1,
+								Date:    date.Add(time.Duration(i) * time.Minute),
+							},
+						})
+						if err != nil {
+							b.Errorf("failed to add shipment: %v", err)
+							return
+						}
+					}
+				})
+			})
+		}
+	}
+}
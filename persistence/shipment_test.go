@@ -3,7 +3,11 @@ package persistence
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
+	"reflect"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -122,22 +126,11 @@ func TestShipmentRepository_AddOrUpdate(t *testing.T) {
 				testingShipmentInput.Origin = ""
 				return testingShipmentInput
 			},
-			expectedError: domain.ErrInvalidOriginPort,
-			repository: func(ctx context.Context, thresholdCount int) (*ShipmentRepository, error) {
-				repository, err := NewShipmentOfferRepository(ctx, thresholdCount)
-				if err != nil {
-					return nil, err
-				}
-				repository.shipmentsByOrigin = testingOriginShipments
-				repository.latestShipmentBatch = testingOriginShipments
-				repository.shipmentCount = len(testingOriginShipments)
-				return repository, nil
-			},
+			expectedError:                 domain.ErrInvalidOriginPort,
+			repository:                    newTestingRepository,
 			repositoryContextInput:        context.Background(),
 			repositoryThresholdCountInput: len(testingOriginShipments),
-			expectedShipments: func() []domain.OriginShipments {
-				return testingOriginShipments
-			},
+			expectedShipments:             sortedTestingOriginShipments,
 		},
 		{
 			name: "invalid shipmentInput - invalid price",
@@ -146,20 +139,11 @@ func TestShipmentRepository_AddOrUpdate(t *testing.T) {
 				testingShipmentInput.Price = -1
 				return testingShipmentInput
 			},
-			expectedError: domain.ErrInvalidPrice,
-			repository: func(ctx context.Context, thresholdCount int) (*ShipmentRepository, error) {
-				repository, err := NewShipmentOfferRepository(ctx, thresholdCount)
-				if err != nil {
-					return nil, err
-				}
-				repository.shipmentsByOrigin = testingOriginShipments
-				repository.latestShipmentBatch = testingOriginShipments
-				repository.shipmentCount = len(testingOriginShipments)
-				return repository, nil
-			},
+			expectedError:                 domain.ErrInvalidPrice,
+			repository:                    newTestingRepository,
 			repositoryContextInput:        context.Background(),
 			repositoryThresholdCountInput: len(testingOriginShipments),
-			expectedShipments:             func() []domain.OriginShipments { return testingOriginShipments },
+			expectedShipments:             sortedTestingOriginShipments,
 		},
 		{
 			name: "invalid shipmentInput - invalid date",
@@ -168,22 +152,11 @@ func TestShipmentRepository_AddOrUpdate(t *testing.T) {
 				testingShipmentInput.Date = time.Time{}
 				return testingShipmentInput
 			},
-			expectedError: domain.ErrInvalidDate,
-			repository: func(ctx context.Context, thresholdCount int) (*ShipmentRepository, error) {
-				repository, err := NewShipmentOfferRepository(ctx, thresholdCount)
-				if err != nil {
-					return nil, err
-				}
-				repository.shipmentsByOrigin = testingOriginShipments
-				repository.latestShipmentBatch = testingOriginShipments
-				repository.shipmentCount = len(testingOriginShipments)
-				return repository, nil
-			},
+			expectedError:                 domain.ErrInvalidDate,
+			repository:                    newTestingRepository,
 			repositoryContextInput:        context.Background(),
 			repositoryThresholdCountInput: len(testingOriginShipments),
-			expectedShipments: func() []domain.OriginShipments {
-				return testingOriginShipments
-			},
+			expectedShipments:             sortedTestingOriginShipments,
 		},
 		{
 			name: "invalid shipmentInput - invalid company",
@@ -192,51 +165,58 @@ func TestShipmentRepository_AddOrUpdate(t *testing.T) {
 				testingShipmentInput.Company = 0
 				return testingShipmentInput
 			},
-			expectedError: domain.ErrInvalidCompany,
-			repository: func(ctx context.Context, thresholdCount int) (*ShipmentRepository, error) {
-				repository, err := NewShipmentOfferRepository(ctx, thresholdCount)
-				if err != nil {
-					return nil, err
-				}
-				repository.shipmentsByOrigin = testingOriginShipments
-				repository.latestShipmentBatch = testingOriginShipments
-				repository.shipmentCount = len(testingOriginShipments)
-				return repository, nil
-			},
+			expectedError:                 domain.ErrInvalidCompany,
+			repository:                    newTestingRepository,
 			repositoryContextInput:        context.Background(),
 			repositoryThresholdCountInput: len(testingOriginShipments),
-			expectedShipments: func() []domain.OriginShipments {
-				return testingOriginShipments
+			expectedShipments:             sortedTestingOriginShipments,
+		},
+		{
+			name: "invalid shipmentInput - destination is the origin",
+			shipmentInput: func() domain.ShipmentUnit {
+				testingShipmentInput := testingShipmentUnit
+				testingShipmentInput.Destination = testingShipmentInput.Origin
+				return testingShipmentInput
 			},
+			expectedError:                 domain.ErrInvalidDestinationPort,
+			repository:                    newTestingRepository,
+			repositoryContextInput:        context.Background(),
+			repositoryThresholdCountInput: len(testingOriginShipments),
+			expectedShipments:             sortedTestingOriginShipments,
 		},
 		{
-			name: "valid shipmentInput - added",
+			name: "invalid shipmentInput - cancelled context",
 			shipmentInput: func() domain.ShipmentUnit {
 				return testingShipmentUnit
 			},
-			expectedError: nil,
+			expectedError: ErrOperationCancelled,
 			repository: func(ctx context.Context, thresholdCount int) (*ShipmentRepository, error) {
-				repository, err := NewShipmentOfferRepository(ctx, thresholdCount)
+				repository, err := newTestingRepository(context.Background(), thresholdCount)
 				if err != nil {
 					return nil, err
 				}
-				repository.shipmentsByOrigin = testingOriginShipments
-				repository.latestShipmentBatch = testingOriginShipments
-				repository.shipmentCount = len(testingOriginShipments)
+				cancelledCtx, cancel := context.WithCancel(context.Background())
+				cancel()
+				repository.ctx = cancelledCtx
 				return repository, nil
 			},
 			repositoryContextInput:        context.Background(),
 			repositoryThresholdCountInput: len(testingOriginShipments),
+			expectedShipments:             sortedTestingOriginShipments,
+		},
+		{
+			name: "valid shipmentInput - added",
+			shipmentInput: func() domain.ShipmentUnit {
+				return testingShipmentUnit
+			},
+			expectedError:                 nil,
+			repository:                    newTestingRepository,
+			repositoryContextInput:        context.Background(),
+			repositoryThresholdCountInput: len(testingOriginShipments),
 			expectedShipments: func() []domain.OriginShipments {
-				return append(testingOriginShipments, domain.OriginShipments{
+				return append(sortedTestingOriginShipments(), domain.OriginShipments{
 					Origin: testingShipmentUnit.Origin,
-					Quotes: []domain.ShipmentQuote{
-						{
-							Company: testingShipmentUnit.Company,
-							Price:   testingShipmentUnit.Price,
-							Date:    testingShipmentUnit.Date,
-						},
-					},
+					Quotes: []domain.ShipmentQuote{testingShipmentUnit.ShipmentQuote},
 				})
 			},
 		},
@@ -244,34 +224,71 @@ func TestShipmentRepository_AddOrUpdate(t *testing.T) {
 			name: "valid shipmentInput - updated",
 			shipmentInput: func() domain.ShipmentUnit {
 				return domain.ShipmentUnit{
-					Origin: testingOriginShipments[0].Origin,
+					Origin: "LAX",
 					ShipmentQuote: domain.ShipmentQuote{
-						Company: testingOriginShipments[0].Quotes[0].Company,
-						Price:   testingOriginShipments[0].Quotes[0].Price + 1,
+						Company: 1,
+						Price:   50,
 						Date:    time.Date(2044, 1, 1, 0, 0, 0, 0, time.UTC),
 					},
 				}
 			},
-			expectedError: nil,
-			repository: func(ctx context.Context, thresholdCount int) (*ShipmentRepository, error) {
-				repository, err := NewShipmentOfferRepository(ctx, thresholdCount)
-				if err != nil {
-					return nil, err
+			expectedError:                 nil,
+			repository:                    newTestingRepository,
+			repositoryContextInput:        context.Background(),
+			repositoryThresholdCountInput: len(testingOriginShipments),
+			expectedShipments: func() []domain.OriginShipments {
+				updatedTestingOriginShipments := sortedTestingOriginShipments()
+				updatedTestingOriginShipments[0].Quotes = []domain.ShipmentQuote{
+					{Company: 1, Price: 50, Date: time.Date(2044, 1, 1, 0, 0, 0, 0, time.UTC)},
+					{Company: 2, Price: 100, Date: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+				}
+				return updatedTestingOriginShipments
+			},
+		},
+		{
+			name: "valid shipmentInput - added to a destination lane",
+			shipmentInput: func() domain.ShipmentUnit {
+				return domain.ShipmentUnit{
+					Origin:      "LAX",
+					Destination: "ROT",
+					ShipmentQuote: domain.ShipmentQuote{
+						Company: 1,
+						Price:   50,
+						Date:    time.Date(2044, 1, 1, 0, 0, 0, 0, time.UTC),
+					},
 				}
-				repository.shipmentsByOrigin = testingOriginShipments
-				repository.latestShipmentBatch = testingOriginShipments
-				repository.shipmentCount = len(testingOriginShipments)
-				return repository, nil
 			},
+			expectedError:                 nil,
+			repository:                    newTestingRepository,
 			repositoryContextInput:        context.Background(),
 			repositoryThresholdCountInput: len(testingOriginShipments),
 			expectedShipments: func() []domain.OriginShipments {
-				updatedTestingOriginShipments := testingOriginShipments
-				updatedTestingOriginShipments[0].Quotes[0].Price++
-				updatedTestingOriginShipments[0].Quotes[0].Date = time.Date(2044, 1, 1, 0, 0, 0, 0, time.UTC)
-				return updatedTestingOriginShipments
+				// The wildcard lane of the origin keeps its quotes
+				return append(sortedTestingOriginShipments(), domain.OriginShipments{
+					Origin:      "LAX",
+					Destination: "ROT",
+					Quotes:      []domain.ShipmentQuote{{Company: 1, Price: 50, Date: time.Date(2044, 1, 1, 0, 0, 0, 0, time.UTC)}},
+				})
 			},
 		},
+		{
+			name: "valid shipmentInput - outdated",
+			shipmentInput: func() domain.ShipmentUnit {
+				return domain.ShipmentUnit{
+					Origin: "LAX",
+					ShipmentQuote: domain.ShipmentQuote{
+						Company: 1,
+						Price:   50,
+						Date:    time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC),
+					},
+				}
+			},
+			expectedError:                 nil,
+			repository:                    newTestingRepository,
+			repositoryContextInput:        context.Background(),
+			repositoryThresholdCountInput: len(testingOriginShipments),
+			expectedShipments:             sortedTestingOriginShipments,
+		},
 	}
 
 	for _, tt := range tests {
@@ -286,32 +303,8 @@ func TestShipmentRepository_AddOrUpdate(t *testing.T) {
 				t.Errorf("expected error %v, got %v", tt.expectedError, err)
 			}
 
-			if len(tt.expectedShipments()) != len(repo.shipmentsByOrigin) {
-				t.Errorf("expected shipments length %d, got %d", len(tt.expectedShipments()), len(repo.shipmentsByOrigin))
-			}
-
-			for i, expectedOriginShipments := range tt.expectedShipments() {
-				if expectedOriginShipments.Origin != repo.shipmentsByOrigin[i].Origin {
-					t.Errorf("expected origin %s, got %s", expectedOriginShipments.Origin, repo.shipmentsByOrigin[i].Origin)
-				}
-
-				if len(expectedOriginShipments.Quotes) != len(repo.shipmentsByOrigin[i].Quotes) {
-					t.Errorf("expected quotes length %d, got %d", len(expectedOriginShipments.Quotes), len(repo.shipmentsByOrigin[i].Quotes))
-				}
-
-				for j, expectedQuote := range expectedOriginShipments.Quotes {
-					if expectedQuote.Company != repo.shipmentsByOrigin[i].Quotes[j].Company {
-						t.Errorf("expected company %d, got %d", expectedQuote.Company, repo.shipmentsByOrigin[i].Quotes[j].Company)
-					}
-
-					if expectedQuote.Price != repo.shipmentsByOrigin[i].Quotes[j].Price {
-						t.Errorf("expected price %d, got %d", expectedQuote.Price, repo.shipmentsByOrigin[i].Quotes[j].Price)
-					}
-
-					if expectedQuote.Date != repo.shipmentsByOrigin[i].Quotes[j].Date {
-						t.Errorf("expected date %v, got %v", expectedQuote.Date, repo.shipmentsByOrigin[i].Quotes[j].Date)
-					}
-				}
+			if shipments := repo.copyShipments(); !reflect.DeepEqual(shipments, tt.expectedShipments()) {
+				t.Errorf("expected shipments %+v, got %+v", tt.expectedShipments(), shipments)
 			}
 		})
 	}
@@ -325,17 +318,8 @@ func TestShipmentRepository_cleanup(t *testing.T) {
 		repositoryThresholdCountInput int
 	}{
 		{
-			name: "valid cleanup",
-			repository: func(ctx context.Context, thresholdCount int) (*ShipmentRepository, error) {
-				repository, err := NewShipmentOfferRepository(ctx, thresholdCount)
-				if err != nil {
-					return nil, err
-				}
-				repository.shipmentsByOrigin = testingOriginShipments
-				repository.latestShipmentBatch = testingOriginShipments
-				repository.shipmentCount = len(testingOriginShipments)
-				return repository, nil
-			},
+			name:                          "valid cleanup",
+			repository:                    newTestingRepository,
 			repositoryContextInput:        context.Background(),
 			repositoryThresholdCountInput: len(testingOriginShipments),
 		},
@@ -350,12 +334,12 @@ func TestShipmentRepository_cleanup(t *testing.T) {
 
 			repo.cleanup()
 
-			if len(repo.shipmentsByOrigin) != 0 {
-				t.Errorf("expected shipments length 0, got %d", len(repo.shipmentsByOrigin))
+			if len(repo.copyShipments()) != 0 {
+				t.Errorf("expected shipments length 0, got %d", len(repo.copyShipments()))
 			}
 
-			if len(repo.latestShipmentBatch) != 0 {
-				t.Errorf("expected latest shipmentInput batch length 0, got %d", len(repo.latestShipmentBatch))
+			if len(repo.loadBatch()) != 0 {
+				t.Errorf("expected latest shipmentInput batch length 0, got %d", len(repo.loadBatch()))
 			}
 
 			if repo.shipmentCount != 0 {
@@ -371,24 +355,35 @@ func TestShipmentRepository_GetLatestSortedShipmentsByOrigin(t *testing.T) {
 		repository                    func(ctx context.Context, thresholdCount int) (*ShipmentRepository, error)
 		repositoryContextInput        context.Context
 		repositoryThresholdCountInput int
+		shipmentsInput                []domain.ShipmentUnit
 		expectedShipments             func() []domain.OriginShipments
 	}{
 		{
-			name: "valid get latest sorted shipments",
-			repository: func(ctx context.Context, thresholdCount int) (*ShipmentRepository, error) {
-				repository, err := NewShipmentOfferRepository(ctx, thresholdCount)
-				if err != nil {
-					return nil, err
-				}
-				repository.shipmentsByOrigin = testingOriginShipments
-				repository.latestShipmentBatch = testingOriginShipments
-				repository.shipmentCount = len(testingOriginShipments)
-				return repository, nil
-			},
+			name:                          "valid get latest sorted shipments",
+			repository:                    newTestingRepository,
 			repositoryContextInput:        context.Background(),
 			repositoryThresholdCountInput: len(testingOriginShipments),
+			expectedShipments:             sortedTestingOriginShipments,
+		},
+		{
+			name:                          "batch not published before the threshold count",
+			repository:                    newTestingRepository,
+			repositoryContextInput:        context.Background(),
+			repositoryThresholdCountInput: 2,
+			shipmentsInput:                []domain.ShipmentUnit{testingShipmentUnit},
+			expectedShipments:             sortedTestingOriginShipments,
+		},
+		{
+			name:                          "batch published at the threshold count",
+			repository:                    newTestingRepository,
+			repositoryContextInput:        context.Background(),
+			repositoryThresholdCountInput: 1,
+			shipmentsInput:                []domain.ShipmentUnit{testingShipmentUnit},
 			expectedShipments: func() []domain.OriginShipments {
-				return testingOriginShipments
+				return append(sortedTestingOriginShipments(), domain.OriginShipments{
+					Origin: testingShipmentUnit.Origin,
+					Quotes: []domain.ShipmentQuote{testingShipmentUnit.ShipmentQuote},
+				})
 			},
 		},
 	}
@@ -400,191 +395,439 @@ func TestShipmentRepository_GetLatestSortedShipmentsByOrigin(t *testing.T) {
 				t.Fatalf("failed to create repository: %v", err)
 			}
 
-			shipments := repo.GetLatestSortedShipmentsByOrigin()
+			for _, shipment := range tt.shipmentsInput {
+				if err = repo.AddOrUpdate(shipment); err != nil {
+					t.Fatalf("failed to add shipment: %v", err)
+				}
+			}
 
-			if len(shipments) != len(tt.expectedShipments()) {
-				t.Errorf("expected shipments length %d, got %d", len(tt.expectedShipments()), len(shipments))
+			if shipments := repo.GetLatestSortedShipmentsByOrigin(); !reflect.DeepEqual(shipments, tt.expectedShipments()) {
+				t.Errorf("expected shipments %+v, got %+v", tt.expectedShipments(), shipments)
 			}
+		})
+	}
+}
 
-			for i, expectedOriginShipments := range tt.expectedShipments() {
-				if expectedOriginShipments.Origin != shipments[i].Origin {
-					t.Errorf("expected origin %s, got %s", expectedOriginShipments.Origin, shipments[i].Origin)
-				}
+func TestShipmentRepository_GetLatestBatch(t *testing.T) {
+	repository, err := NewShipmentOfferRepository(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
 
-				if len(expectedOriginShipments.Quotes) != len(shipments[i].Quotes) {
-					t.Errorf("expected quotes length %d, got %d", len(expectedOriginShipments.Quotes), len(shipments[i].Quotes))
-				}
+	if batch := repository.GetLatestBatch(); len(batch.ShipmentsByLane) != 0 || !batch.PublishedAt.IsZero() {
+		t.Errorf("expected no published batch, got %+v", batch)
+	}
 
-				for j, expectedQuote := range expectedOriginShipments.Quotes {
-					if expectedQuote.Company != shipments[i].Quotes[j].Company {
-						t.Errorf("expected company %d, got %d", expectedQuote.Company, shipments[i].Quotes[j].Company)
-					}
+	date := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, now := range []time.Time{date.Add(time.Hour), date.Add(2 * time.Hour)} {
+		repository.now = func() time.Time { return now }
+
+		err = repository.AddOrUpdate(domain.ShipmentUnit{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: i + 1, Price: 200, Date: date}})
+		if err != nil {
+			t.Fatalf("failed to add shipment: %v", err)
+		}
+	}
+
+	// The batch is published by the second submission
+	batch := repository.GetLatestBatch()
+	if !batch.PublishedAt.Equal(date.Add(2 * time.Hour)) {
+		t.Errorf("expected the batch to be published at %v, got %v", date.Add(2*time.Hour), batch.PublishedAt)
+	}
+	if !reflect.DeepEqual(batch.ShipmentsByLane, repository.GetLatestSortedShipmentsByOrigin()) {
+		t.Errorf("expected the shipments of the latest batch %+v, got %+v", repository.GetLatestSortedShipmentsByOrigin(), batch.ShipmentsByLane)
+	}
+}
+
+func TestShipmentRepository_AddOrUpdateAll(t *testing.T) {
+	date := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name                   string
+		repositoryContextInput context.Context
+		thresholdCount         int
+		shipmentsInput         []domain.ShipmentUnit
+		expectedErrors         []error
+		expectedShipments      []domain.OriginShipments
+		expectedBatch          []domain.OriginShipments
+		expectedCount          int
+	}{
+		{
+			name:                   "valid, invalid and duplicate shipments",
+			repositoryContextInput: context.Background(),
+			thresholdCount:         10,
+			shipmentsInput: []domain.ShipmentUnit{
+				{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 200, Date: date}},
+				{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 2, Price: 0, Date: date}},
+				{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 150, Date: date}},
+				{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 100, Date: date}},
+			},
+			expectedErrors: []error{nil, domain.ErrInvalidPrice, nil, domain.ErrDuplicateQuote},
+			expectedShipments: []domain.OriginShipments{
+				{Origin: "LAX", Quotes: []domain.ShipmentQuote{{Company: 1, Price: 200, Date: date}}},
+				{Origin: "NYC", Quotes: []domain.ShipmentQuote{{Company: 1, Price: 150, Date: date}}},
+			},
+			expectedCount: 3,
+		},
+		{
+			name:                   "batch published once after every shipment is applied",
+			repositoryContextInput: context.Background(),
+			thresholdCount:         2,
+			shipmentsInput: []domain.ShipmentUnit{
+				{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 200, Date: date}},
+				{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 150, Date: date}},
+				{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 2, Price: 100, Date: date}},
+			},
+			expectedErrors: []error{nil, nil, nil},
+			expectedShipments: []domain.OriginShipments{
+				{Origin: "LAX", Quotes: []domain.ShipmentQuote{{Company: 2, Price: 100, Date: date}, {Company: 1, Price: 200, Date: date}}},
+				{Origin: "NYC", Quotes: []domain.ShipmentQuote{{Company: 1, Price: 150, Date: date}}},
+			},
+			expectedBatch: []domain.OriginShipments{
+				{Origin: "LAX", Quotes: []domain.ShipmentQuote{{Company: 2, Price: 100, Date: date}, {Company: 1, Price: 200, Date: date}}},
+				{Origin: "NYC", Quotes: []domain.ShipmentQuote{{Company: 1, Price: 150, Date: date}}},
+			},
+			expectedCount: 1,
+		},
+		{
+			name:                   "cancelled context",
+			repositoryContextInput: cancelled,
+			thresholdCount:         1,
+			shipmentsInput: []domain.ShipmentUnit{
+				{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 200, Date: date}},
+				{Origin: "", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 200, Date: date}},
+			},
+			expectedErrors:    []error{ErrOperationCancelled, domain.ErrInvalidOriginPort},
+			expectedShipments: []domain.OriginShipments{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository, err := NewShipmentOfferRepository(tt.repositoryContextInput, tt.thresholdCount)
+			if err != nil {
+				t.Fatalf("failed to create repository: %v", err)
+			}
+
+			errs := repository.AddOrUpdateAll(tt.shipmentsInput)
+			if len(errs) != len(tt.expectedErrors) {
+				t.Fatalf("expected %d errors, got %d", len(tt.expectedErrors), len(errs))
+			}
+			for i, err := range errs {
+				if !errors.Is(err, tt.expectedErrors[i]) {
+					t.Errorf("expected error %v for shipment %d, got %v", tt.expectedErrors[i], i, err)
 				}
 			}
+
+			if shipments := repository.copyShipments(); !reflect.DeepEqual(shipments, tt.expectedShipments) {
+				t.Errorf("expected shipments %+v, got %+v", tt.expectedShipments, shipments)
+			}
+			if batch := repository.loadBatch(); len(batch) != len(tt.expectedBatch) || (len(batch) > 0 && !reflect.DeepEqual(batch, tt.expectedBatch)) {
+				t.Errorf("expected latest batch %+v, got %+v", tt.expectedBatch, batch)
+			}
+			// Read the count under its lock, the cleanup of a cancelled repository may still be running
+			repository.countMu.Lock()
+			count := repository.shipmentCount
+			repository.countMu.Unlock()
+			if count != tt.expectedCount {
+				t.Errorf("expected shipment count %d, got %d", tt.expectedCount, count)
+			}
 		})
 	}
 }
 
-func TestShipmentRepository_upsertShipment(t *testing.T) {
+func TestShipmentRepository_DeleteCompanyQuotes(t *testing.T) {
+	date := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	receivedAt := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
 	tests := []struct {
-		name                          string
-		repository                    func(ctx context.Context, thresholdCount int) (*ShipmentRepository, error)
-		repositoryContextInput        context.Context
-		repositoryThresholdCountInput int
-		originShipmentsInput          func() *domain.OriginShipments
-		expectedShipments             func() []domain.OriginShipments
-		shipmentInput                 func() *domain.ShipmentUnit
-		expectedUpdated               bool
+		name              string
+		origin            string
+		company           int
+		expectedDeleted   int
+		expectedError     error
+		expectedShipments []domain.OriginShipments
+		expectedStatuses  []domain.AuditStatus
 	}{
 		{
-			name: "valid upsert - added",
-			repository: func(ctx context.Context, thresholdCount int) (*ShipmentRepository, error) {
-				repository, err := NewShipmentOfferRepository(ctx, thresholdCount)
-				if err != nil {
-					return nil, err
-				}
-				repository.shipmentsByOrigin = testingOriginShipments
-				repository.latestShipmentBatch = testingOriginShipments
-				repository.shipmentCount = len(testingOriginShipments)
-				return repository, nil
-			},
-			repositoryContextInput:        context.Background(),
-			repositoryThresholdCountInput: len(testingOriginShipments),
-			originShipmentsInput: func() *domain.OriginShipments {
-				return &testingOriginShipments[0]
-			},
-			shipmentInput: func() *domain.ShipmentUnit {
-				return &domain.ShipmentUnit{
-					Origin: testingOriginShipments[0].Origin,
-					ShipmentQuote: domain.ShipmentQuote{
-						Company: 666,
-						Price:   333,
-						Date:    time.Now(),
-					},
-				}
-			},
-			expectedUpdated: true,
-			expectedShipments: func() []domain.OriginShipments {
-				updatedTestingOriginShipments := testingOriginShipments
-				updatedTestingOriginShipments[0].Quotes = append(updatedTestingOriginShipments[0].Quotes, domain.ShipmentQuote{
-					Company: 666,
-					Price:   333,
-					Date:    time.Now(),
-				})
-				return updatedTestingOriginShipments
-			},
+			name:          "invalid input - empty origin port",
+			company:       1,
+			expectedError: domain.ErrInvalidOriginPort,
 		},
 		{
-			name: "valid upsert - updated",
-			repository: func(ctx context.Context, thresholdCount int) (*ShipmentRepository, error) {
-				repository, err := NewShipmentOfferRepository(ctx, thresholdCount)
-				if err != nil {
-					return nil, err
-				}
-				repository.shipmentsByOrigin = testingOriginShipments
-				repository.latestShipmentBatch = testingOriginShipments
-				repository.shipmentCount = len(testingOriginShipments)
-				return repository, nil
-			},
-			repositoryContextInput:        context.Background(),
-			repositoryThresholdCountInput: len(testingOriginShipments),
-			originShipmentsInput: func() *domain.OriginShipments {
-				return &testingOriginShipments[0]
-			},
-			shipmentInput: func() *domain.ShipmentUnit {
-				return &domain.ShipmentUnit{
-					Origin: testingOriginShipments[0].Origin,
-					ShipmentQuote: domain.ShipmentQuote{
-						Company: testingOriginShipments[0].Quotes[0].Company,
-						Price:   testingOriginShipments[0].Quotes[0].Price + 1,
-						Date:    time.Date(2044, 1, 1, 0, 0, 0, 0, time.UTC),
-					},
-				}
-			},
-			expectedUpdated: true,
-			expectedShipments: func() []domain.OriginShipments {
-				updatedTestingOriginShipments := testingOriginShipments
-				updatedTestingOriginShipments[0].Quotes[0].Price++
-				updatedTestingOriginShipments[0].Quotes[0].Date = time.Date(2044, 1, 1, 0, 0, 0, 0, time.UTC)
-				return updatedTestingOriginShipments
-			},
+			name:          "invalid input - invalid company",
+			origin:        "LAX",
+			expectedError: domain.ErrInvalidCompany,
 		},
 		{
-			name: "valid upsert - not updated",
-			repository: func(ctx context.Context, thresholdCount int) (*ShipmentRepository, error) {
-				repository, err := NewShipmentOfferRepository(ctx, thresholdCount)
+			name:          "no quotes of the company for the origin",
+			origin:        "NYC",
+			company:       2,
+			expectedError: domain.ErrNoCompanyQuotes,
+		},
+		{
+			name:            "quotes of every lane of the origin deleted",
+			origin:          "LAX",
+			company:         1,
+			expectedDeleted: 3,
+			expectedShipments: []domain.OriginShipments{
+				{Origin: "LAX", Quotes: []domain.ShipmentQuote{{Company: 2, Price: 300, Date: date}}},
+				{Origin: "NYC", Quotes: []domain.ShipmentQuote{{Company: 1, Price: 150, Date: date}}},
+			},
+			expectedStatuses: []domain.AuditStatus{domain.AuditAccepted, domain.AuditAccepted, domain.AuditAccepted, domain.AuditDeleted, domain.AuditDeleted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository, err := NewShipmentOfferRepository(context.Background(), 10)
+			if err != nil {
+				t.Fatalf("failed to create repository: %v", err)
+			}
+			repository.now = func() time.Time { return receivedAt }
+
+			errs := repository.AddOrUpdateAll([]domain.ShipmentUnit{
+				{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 200, Date: date}},
+				{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 100, Date: date.AddDate(0, 1, 0)}},
+				{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 2, Price: 300, Date: date}},
+				{Origin: "LAX", Destination: "ROT", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 400, Date: date}},
+				{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 150, Date: date}},
+			})
+			for _, err := range errs {
 				if err != nil {
-					return nil, err
-				}
-				repository.shipmentsByOrigin = testingOriginShipments
-				repository.latestShipmentBatch = testingOriginShipments
-				repository.shipmentCount = len(testingOriginShipments)
-				return repository, nil
-			},
-			repositoryContextInput:        context.Background(),
-			repositoryThresholdCountInput: len(testingOriginShipments),
-			originShipmentsInput: func() *domain.OriginShipments {
-				return &testingOriginShipments[0]
-			},
-			shipmentInput: func() *domain.ShipmentUnit {
-				return &domain.ShipmentUnit{
-					Origin: testingOriginShipments[0].Origin,
-					ShipmentQuote: domain.ShipmentQuote{
-						Company: testingOriginShipments[0].Quotes[0].Company,
-						Price:   testingOriginShipments[0].Quotes[0].Price,
-						Date:    testingOriginShipments[0].Quotes[0].Date,
-					},
+					t.Fatalf("failed to add shipments: %v", err)
 				}
-			},
-			expectedUpdated: true,
-			expectedShipments: func() []domain.OriginShipments {
-				return testingOriginShipments
-			},
+			}
+
+			repository.now = func() time.Time { return receivedAt.Add(time.Hour) }
+			deleted, err := repository.DeleteCompanyQuotes(tt.origin, tt.company)
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if deleted != tt.expectedDeleted {
+				t.Errorf("expected %d deleted quotes, got %d", tt.expectedDeleted, deleted)
+			}
+			if err != nil {
+				return
+			}
+
+			// The deletion publishes a new batch without the deleted quotes
+			if batch := repository.loadBatch(); !reflect.DeepEqual(batch, tt.expectedShipments) {
+				t.Errorf("expected latest batch %+v, got %+v", tt.expectedShipments, batch)
+			}
+			if asOf := repository.GetSortedShipmentsByOriginAsOf(date.AddDate(1, 0, 0)); !reflect.DeepEqual(asOf, tt.expectedShipments) {
+				t.Errorf("expected shipments as of a year later %+v, got %+v", tt.expectedShipments, asOf)
+			}
+
+			var statuses []domain.AuditStatus
+			for _, entry := range repository.GetQuoteHistory(tt.origin, tt.company) {
+				statuses = append(statuses, entry.Status)
+			}
+			if !reflect.DeepEqual(statuses, tt.expectedStatuses) {
+				t.Errorf("expected statuses %v, got %v", tt.expectedStatuses, statuses)
+			}
+		})
+	}
+}
+
+func TestShipmentRepository_RecordRejectedShipment(t *testing.T) {
+	date := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		shipment         domain.ShipmentUnit
+		expectedError    error
+		expectedStatuses []domain.AuditStatus
+	}{
+		{
+			name:          "invalid input - empty origin port",
+			shipment:      domain.ShipmentUnit{ShipmentQuote: domain.ShipmentQuote{Company: 1}},
+			expectedError: domain.ErrInvalidOriginPort,
+		},
+		{
+			name:          "invalid input - invalid company",
+			shipment:      domain.ShipmentUnit{Origin: "LAX"},
+			expectedError: domain.ErrInvalidCompany,
+		},
+		{
+			name:             "rejection of a lane with quotes",
+			shipment:         domain.ShipmentUnit{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: -1}},
+			expectedStatuses: []domain.AuditStatus{domain.AuditAccepted, domain.AuditRejected},
+		},
+		{
+			name:             "rejection of a lane without quotes",
+			shipment:         domain.ShipmentUnit{Origin: "LAX", Destination: "XXXXX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: -1}},
+			expectedStatuses: []domain.AuditStatus{domain.AuditAccepted},
+		},
+		{
+			name:     "rejection of an origin without quotes",
+			shipment: domain.ShipmentUnit{Origin: "XXXXX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: -1}},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repository, err := tt.repository(tt.repositoryContextInput, tt.repositoryThresholdCountInput)
+			repository, err := NewShipmentOfferRepository(context.Background(), 10)
 			if err != nil {
 				t.Fatalf("failed to create repository: %v", err)
 			}
+			if err = repository.AddOrUpdate(domain.ShipmentUnit{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 200, Date: date}}); err != nil {
+				t.Fatalf("failed to add shipment: %v", err)
+			}
 
-			originShipments := tt.originShipmentsInput()
-			shipment := tt.shipmentInput()
-			updated := repository.upsertShipment(originShipments, shipment)
+			if err = repository.RecordRejectedShipment(tt.shipment, domain.ErrInvalidPrice); !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
 
-			if updated != tt.expectedUpdated {
-				t.Errorf("expected updated %t, got %t", tt.expectedUpdated, updated)
+			// Invalid offers never create lanes
+			if lanes := len(repository.lanes); lanes != 1 {
+				t.Errorf("expected 1 lane, got %d", lanes)
 			}
 
-			if len(tt.expectedShipments()) != len(repository.shipmentsByOrigin) {
-				t.Errorf("expected shipments length %d, got %d", len(tt.expectedShipments()), len(repository.shipmentsByOrigin))
+			var statuses []domain.AuditStatus
+			for _, entry := range repository.GetQuoteHistory(tt.shipment.Origin, tt.shipment.Company) {
+				statuses = append(statuses, entry.Status)
+			}
+			if !reflect.DeepEqual(statuses, tt.expectedStatuses) {
+				t.Errorf("expected statuses %v, got %v", tt.expectedStatuses, statuses)
 			}
+		})
+	}
+}
 
-			for i, expectedOriginShipments := range tt.expectedShipments() {
-				if expectedOriginShipments.Origin != repository.shipmentsByOrigin[i].Origin {
-					t.Errorf("expected origin %s, got %s", expectedOriginShipments.Origin, repository.shipmentsByOrigin[i].Origin)
-				}
+func TestShipmentRepository_publishedBatchIsImmutable(t *testing.T) {
+	repository, err := NewShipmentOfferRepository(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
 
-				if len(expectedOriginShipments.Quotes) != len(repository.shipmentsByOrigin[i].Quotes) {
-					t.Errorf("expected quotes length %d, got %d", len(expectedOriginShipments.Quotes), len(repository.shipmentsByOrigin[i].Quotes))
-				}
+	date := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, shipment := range []domain.ShipmentUnit{
+		{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 200, Date: date}},
+		{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 2, Price: 300, Date: date}},
+	} {
+		if err = repository.AddOrUpdate(shipment); err != nil {
+			t.Fatalf("failed to add shipment: %v", err)
+		}
+	}
 
-				for j, expectedQuote := range expectedOriginShipments.Quotes {
-					if expectedQuote.Company != repository.shipmentsByOrigin[i].Quotes[j].Company {
-						t.Errorf("expected company %d, got %d", expectedQuote.Company, repository.shipmentsByOrigin[i].Quotes[j].Company)
-					}
+	published := repository.GetLatestSortedShipmentsByOrigin()
 
-					if expectedQuote.Price != repository.shipmentsByOrigin[i].Quotes[j].Price {
-						t.Errorf("expected price %d, got %d", expectedQuote.Price, repository.shipmentsByOrigin[i].Quotes[j].Price)
-					}
+	// Update a quote and add a new one to the published origin without reaching the threshold
+	err = repository.AddOrUpdate(domain.ShipmentUnit{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 2, Price: 100, Date: date.AddDate(0, 0, 1)}})
+	if err != nil {
+		t.Fatalf("failed to add shipment: %v", err)
+	}
 
-					if expectedQuote.Date != repository.shipmentsByOrigin[i].Quotes[j].Date {
-						t.Errorf("expected date %v, got %v", expectedQuote.Date, repository.shipmentsByOrigin[i].Quotes[j].Date)
+	expected := []domain.OriginShipments{
+		{
+			Origin: "LAX",
+			Quotes: []domain.ShipmentQuote{{Company: 1, Price: 200, Date: date}, {Company: 2, Price: 300, Date: date}},
+		},
+	}
+	if !reflect.DeepEqual(published, expected) {
+		t.Errorf("expected published batch %+v, got %+v", expected, published)
+	}
+	if latest := repository.GetLatestSortedShipmentsByOrigin(); !reflect.DeepEqual(latest, expected) {
+		t.Errorf("expected latest batch %+v, got %+v", expected, latest)
+	}
+}
+
+func TestShipmentRepository_concurrentPublishAndRead(t *testing.T) {
+	repository, err := NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+
+	const writers, readers, submissions = 4, 4, 200
+	date := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var writersGroup, readersGroup sync.WaitGroup
+	done := make(chan struct{})
+
+	for w := 0; w < writers; w++ {
+		writersGroup.Add(1)
+		go func(w int) {
+			defer writersGroup.Done()
+			for i := 0; i < submissions; i++ {
+				err := repository.AddOrUpdate(domain.ShipmentUnit{
+					Origin:        []string{"LAX", "NYC"}[i%2],
+					ShipmentQuote: domain.ShipmentQuote{Company: i%20 + 1, Price: (i*7+w)%500 + 1, Date: date.AddDate(0, 0, i)},
+				})
+				if err != nil {
+					t.Errorf("failed to add shipment: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	for r := 0; r < readers; r++ {
+		readersGroup.Add(1)
+		go func() {
+			defer readersGroup.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				// Every published batch must stay sorted and unchanged while it is being read
+				batch := repository.GetLatestSortedShipmentsByOrigin()
+				before := fmt.Sprint(batch)
+				for _, originShipments := range batch {
+					if !sort.SliceIsSorted(originShipments.Quotes, func(i, j int) bool {
+						return originShipments.Quotes[i].Price < originShipments.Quotes[j].Price
+					}) {
+						t.Errorf("expected sorted quotes for origin %s, got %+v", originShipments.Origin, originShipments.Quotes)
+						return
 					}
 				}
+				if after := fmt.Sprint(batch); after != before {
+					t.Errorf("published batch changed while reading: %s != %s", before, after)
+					return
+				}
 			}
-		})
+		}()
+	}
+
+	writersGroup.Wait()
+	close(done)
+	readersGroup.Wait()
+}
+
+// newTestingRepository creates a repository that stores testingOriginShipments and has them published as the latest
+// batch.
+func newTestingRepository(ctx context.Context, thresholdCount int) (*ShipmentRepository, error) {
+	repository, err := NewShipmentOfferRepository(ctx, thresholdCount)
+	if err != nil {
+		return nil, err
+	}
+
+	repository.loadShipments(testingOriginShipments)
+	repository.publishBatch(repository.now())
+
+	return repository, nil
+}
+
+// sortedTestingOriginShipments returns a copy of testingOriginShipments with the quotes in the order the repository
+// sorts them.
+func sortedTestingOriginShipments() []domain.OriginShipments {
+	return []domain.OriginShipments{
+		{
+			Origin: "LAX",
+			Quotes: []domain.ShipmentQuote{
+				{Company: 2, Price: 100, Date: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{Company: 1, Price: 200, Date: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			Origin: "NYC",
+			Quotes: []domain.ShipmentQuote{
+				{Company: 1, Price: 150, Date: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
 	}
 }
@@ -0,0 +1,81 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	minSigningSecretLength = 32 // minSigningSecretLength is the minimum length of a shared secret, so it cannot be guessed.
+)
+
+var (
+	ErrEmptySigningSecretsPath = errors.New("signing secrets file path cannot be empty")
+	ErrInvalidSigningSecrets   = errors.New("invalid signing secrets file")
+)
+
+// signingSecretsFile is the content of the signing secrets file, e.g.,
+// {"secrets": [{"company": 1, "secret": "4c8a0f..."}]}.
+type signingSecretsFile struct {
+	Secrets []signingSecretRecord `json:"secrets"` // Secrets lists the shared secrets.
+}
+
+// signingSecretRecord is the shared secret of a single company of the signing secrets file.
+type signingSecretRecord struct {
+	Company int    `json:"company"` // Company is the identifier of the company that signs its submissions.
+	Secret  string `json:"secret"`  // Secret is the secret shared with the company, at least minSigningSecretLength characters.
+}
+
+// SigningSecrets is a domain.SigningSecretStore loaded from a local JSON file. Unlike the API keys, the secrets are
+// stored as they are, since the signatures are verified with them, so the file must only be readable by the service.
+type SigningSecrets struct {
+	secrets map[int][]byte // secrets maps the identifier of every company to its shared secret, it is never modified once loaded.
+}
+
+// OpenSigningSecrets loads the signing secrets file at path. Every company must have a single secret of at least 32
+// characters.
+func OpenSigningSecrets(path string) (*SigningSecrets, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, ErrEmptySigningSecretsPath
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read signing secrets file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var file signingSecretsFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSigningSecrets, err)
+	}
+
+	secrets := &SigningSecrets{secrets: make(map[int][]byte, len(file.Secrets))}
+	for _, record := range file.Secrets {
+		switch {
+		case record.Company <= 0:
+			return nil, fmt.Errorf("%w: invalid company %d", ErrInvalidSigningSecrets, record.Company)
+		case len(record.Secret) < minSigningSecretLength:
+			return nil, fmt.Errorf("%w: secret of company %d is shorter than %d characters", ErrInvalidSigningSecrets, record.Company, minSigningSecretLength)
+		}
+		if _, exists := secrets.secrets[record.Company]; exists {
+			return nil, fmt.Errorf("%w: duplicate secret of company %d", ErrInvalidSigningSecrets, record.Company)
+		}
+		secrets.secrets[record.Company] = []byte(record.Secret)
+	}
+
+	return secrets, nil
+}
+
+// Secret returns the shared secret of the company, or false if the company does not sign its submissions.
+func (s *SigningSecrets) Secret(company int) ([]byte, bool) {
+	secret, exists := s.secrets[company]
+
+	return secret, exists
+}
@@ -0,0 +1,84 @@
+package persistence
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenSigningSecrets(t *testing.T) {
+	secret := "4c8a0f2d9b7e61a35f0c8d2e7b9a1f64"
+
+	tests := []struct {
+		name            string
+		content         string
+		path            func(path string) string
+		expectedError   error
+		expectedSecrets map[int]string
+	}{
+		{
+			name:          "invalid path - empty",
+			path:          func(string) string { return "" },
+			expectedError: ErrEmptySigningSecretsPath,
+		},
+		{
+			name:          "missing file",
+			path:          func(path string) string { return path + ".missing" },
+			expectedError: os.ErrNotExist,
+		},
+		{
+			name:          "invalid file - malformed JSON",
+			content:       `{"secrets": [`,
+			expectedError: ErrInvalidSigningSecrets,
+		},
+		{
+			name:          "invalid file - unknown field",
+			content:       `{"secrets": [{"company": 1, "key": "` + secret + `"}]}`,
+			expectedError: ErrInvalidSigningSecrets,
+		},
+		{
+			name:          "invalid file - invalid company",
+			content:       `{"secrets": [{"company": 0, "secret": "` + secret + `"}]}`,
+			expectedError: ErrInvalidSigningSecrets,
+		},
+		{
+			name:          "invalid file - short secret",
+			content:       `{"secrets": [{"company": 1, "secret": "` + secret[:31] + `"}]}`,
+			expectedError: ErrInvalidSigningSecrets,
+		},
+		{
+			name:          "invalid file - duplicate company",
+			content:       `{"secrets": [{"company": 1, "secret": "` + secret + `"}, {"company": 1, "secret": "` + secret + `"}]}`,
+			expectedError: ErrInvalidSigningSecrets,
+		},
+		{
+			name:            "valid file",
+			content:         `{"secrets": [{"company": 1, "secret": "` + secret + `"}]}`,
+			expectedSecrets: map[int]string{1: secret, 2: ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "secrets.json")
+			if tt.path != nil {
+				path = tt.path(path)
+			} else if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write signing secrets file: %v", err)
+			}
+
+			secrets, err := OpenSigningSecrets(path)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+			}
+
+			for company, expected := range tt.expectedSecrets {
+				secret, exists := secrets.Secret(company)
+				if string(secret) != expected || exists != (expected != "") {
+					t.Errorf("expected secret %q of company %d, got %q (exists %t)", expected, company, secret, exists)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"quoteship/domain"
+)
+
+const (
+	snapshotPrefix     = "snapshot-" // snapshotPrefix is the file name prefix of the snapshot files.
+	snapshotExtension  = ".snap"     // snapshotExtension is the file extension of the snapshot files.
+	snapshotMagic      = "QSSN"      // snapshotMagic identifies a QuoteShip snapshot file.
+	snapshotVersion    = 1           // snapshotVersion is the version of the snapshot format written by this build.
+	snapshotHeaderSize = 18          // snapshotHeaderSize is the size of the magic, version, log segment and payload length fields.
+	defaultRetained    = 2           // defaultRetained is the number of snapshots kept when none is configured.
+)
+
+var (
+	ErrEmptySnapshotDirectory = errors.New("snapshot directory cannot be empty")
+	ErrCorruptSnapshot        = errors.New("corrupt snapshot")
+	ErrNoValidSnapshot        = errors.New("no valid snapshot available")
+	ErrMissingWALSegments     = errors.New("write-ahead log segments required for recovery are missing")
+)
+
+// snapshotState is the repository state serialized in a snapshot, as a snapshotPayload.
+type snapshotState struct {
+	ShipmentsByOrigin      []domain.OriginShipments // ShipmentsByOrigin are the stored shipments grouped by origin, including the quote history of every company.
+	AuditTrail             []domain.AuditEntry      // AuditTrail is the audit trail of every origin and company.
+	LatestShipmentBatch    []domain.OriginShipments // LatestShipmentBatch is the latest published batch.
+	LatestBatchPublishedAt time.Time                // LatestBatchPublishedAt is the time the latest batch was published.
+	ShipmentCount          int                      // ShipmentCount is the number of submissions received since the last published batch.
+	ThresholdCount         int                      // ThresholdCount is the batch threshold the repository was running with.
+}
+
+// snapshotPayload is the on-disk representation of a snapshotState, gob encoded in the snapshot files. It is kept
+// separate from the domain structs, like the walShipment of the write-ahead log records, so the snapshot format does
+// not change whenever the domain evolves.
+type snapshotPayload struct {
+	ShipmentsByLane        []snapshotLane  // ShipmentsByLane are the stored shipments grouped by lane, including the quote history of every company.
+	AuditTrail             []snapshotAudit // AuditTrail is the audit trail of every lane and company.
+	LatestShipmentBatch    []snapshotLane  // LatestShipmentBatch is the latest published batch.
+	LatestBatchPublishedAt time.Time       // LatestBatchPublishedAt is the time the latest batch was published.
+	ShipmentCount          int             // ShipmentCount is the number of submissions received since the last published batch.
+	ThresholdCount         int             // ThresholdCount is the batch threshold the repository was running with.
+}
+
+// snapshotLane is the on-disk representation of a domain.OriginShipments.
+type snapshotLane struct {
+	Origin      string             // Origin is the located port where the shipments start (e.g., "CNSGH").
+	Destination string             // Destination is the located port where the shipments end, empty for the wildcard lane.
+	Quotes      []snapshotShipment // Quotes are the quotes of the lane.
+}
+
+// snapshotShipment is the on-disk representation of a domain.ShipmentQuote.
+type snapshotShipment struct {
+	Company     int       // Company is the identifier of the company that provided the quote.
+	Price       int       // Price is the cost of the shipment in minor units of the base currency.
+	QuotedPrice int       // QuotedPrice is the cost of the shipment as submitted, in minor units of Currency.
+	Currency    string    // Currency is the currency of QuotedPrice.
+	Date        time.Time // Date is the date when the shipment will start.
+	ValidUntil  time.Time // ValidUntil is the time the quote expires at, zero when it only expires with its age.
+	Equipment   string    // Equipment is the container type the quote is priced for.
+}
+
+// snapshotAudit is the on-disk representation of a domain.AuditEntry.
+type snapshotAudit struct {
+	Origin      string           // Origin is the located port of the submitted shipment.
+	Destination string           // Destination is the located port where the submitted shipment ends.
+	Shipment    snapshotShipment // Shipment is the submitted quote.
+	ReceivedAt  time.Time        // ReceivedAt is the time the submission was received.
+	Status      string           // Status tells whether the submission was accepted, rejected or a deletion.
+	Reason      string           // Reason explains why the submission was rejected.
+}
+
+// newSnapshotPayload converts the state into its on-disk representation.
+func newSnapshotPayload(state snapshotState) snapshotPayload {
+	payload := snapshotPayload{
+		ShipmentsByLane:        newSnapshotLanes(state.ShipmentsByOrigin),
+		LatestShipmentBatch:    newSnapshotLanes(state.LatestShipmentBatch),
+		LatestBatchPublishedAt: state.LatestBatchPublishedAt,
+		ShipmentCount:          state.ShipmentCount,
+		ThresholdCount:         state.ThresholdCount,
+	}
+	for _, entry := range state.AuditTrail {
+		payload.AuditTrail = append(payload.AuditTrail, snapshotAudit{
+			Origin:      entry.Origin,
+			Destination: entry.Destination,
+			Shipment:    newSnapshotShipment(entry.ShipmentQuote),
+			ReceivedAt:  entry.ReceivedAt,
+			Status:      string(entry.Status),
+			Reason:      entry.Reason,
+		})
+	}
+
+	return payload
+}
+
+// state converts the payload back into a snapshotState.
+func (p snapshotPayload) state() snapshotState {
+	state := snapshotState{
+		ShipmentsByOrigin:      originShipments(p.ShipmentsByLane),
+		LatestShipmentBatch:    originShipments(p.LatestShipmentBatch),
+		LatestBatchPublishedAt: p.LatestBatchPublishedAt,
+		ShipmentCount:          p.ShipmentCount,
+		ThresholdCount:         p.ThresholdCount,
+	}
+	for _, entry := range p.AuditTrail {
+		state.AuditTrail = append(state.AuditTrail, domain.AuditEntry{
+			ShipmentUnit: domain.ShipmentUnit{
+				Origin:        entry.Origin,
+				Destination:   entry.Destination,
+				ShipmentQuote: entry.Shipment.shipmentQuote(),
+			},
+			ReceivedAt: entry.ReceivedAt,
+			Status:     domain.AuditStatus(entry.Status),
+			Reason:     entry.Reason,
+		})
+	}
+
+	return state
+}
+
+// newSnapshotLanes converts the shipments grouped by lane into their on-disk representation.
+func newSnapshotLanes(shipmentsByLane []domain.OriginShipments) []snapshotLane {
+	var lanes []snapshotLane
+	for _, shipments := range shipmentsByLane {
+		lane := snapshotLane{Origin: shipments.Origin, Destination: shipments.Destination}
+		for _, quote := range shipments.Quotes {
+			lane.Quotes = append(lane.Quotes, newSnapshotShipment(quote))
+		}
+		lanes = append(lanes, lane)
+	}
+
+	return lanes
+}
+
+// originShipments converts the lanes of a payload back into shipments grouped by lane.
+func originShipments(lanes []snapshotLane) []domain.OriginShipments {
+	var shipmentsByLane []domain.OriginShipments
+	for _, lane := range lanes {
+		shipments := domain.OriginShipments{Origin: lane.Origin, Destination: lane.Destination}
+		for _, quote := range lane.Quotes {
+			shipments.Quotes = append(shipments.Quotes, quote.shipmentQuote())
+		}
+		shipmentsByLane = append(shipmentsByLane, shipments)
+	}
+
+	return shipmentsByLane
+}
+
+// newSnapshotShipment converts a domain.ShipmentQuote into its on-disk representation.
+func newSnapshotShipment(quote domain.ShipmentQuote) snapshotShipment {
+	return snapshotShipment{
+		Company:     quote.Company,
+		Price:       quote.Price,
+		QuotedPrice: quote.Quoted.Amount,
+		Currency:    string(quote.Quoted.Currency),
+		Date:        quote.Date,
+		ValidUntil:  quote.ValidUntil,
+		Equipment:   string(quote.Equipment),
+	}
+}
+
+// shipmentQuote converts the snapshotShipment back into a domain.ShipmentQuote.
+func (s snapshotShipment) shipmentQuote() domain.ShipmentQuote {
+	return domain.ShipmentQuote{
+		Company:    s.Company,
+		Price:      s.Price,
+		Quoted:     domain.Money{Amount: s.QuotedPrice, Currency: domain.Currency(s.Currency)},
+		Date:       s.Date,
+		ValidUntil: s.ValidUntil,
+		Equipment:  domain.Equipment(s.Equipment),
+	}
+}
+
+// snapshot is a snapshotState together with the position of the write-ahead log it covers.
+type snapshot struct {
+	sequence   uint64        // sequence is the number of the snapshot, newer snapshots have greater numbers.
+	walSegment uint64        // walSegment is the first write-ahead log segment that is not covered by the snapshot.
+	state      snapshotState // state is the repository state at the time of the snapshot.
+}
+
+// SnapshotStore writes and loads versioned binary snapshots of the repository state. A snapshot file consists of the
+// "QSSN" magic, a 2 byte format version, the 8 byte index of the first write-ahead log segment the snapshot does not
+// cover, a 4 byte payload length, the gob encoded snapshotPayload and a trailing CRC32-C checksum of everything before
+// it.
+type SnapshotStore struct {
+	dir    string // dir is the directory where the snapshots are stored.
+	retain int    // retain is the number of most recent snapshots kept on disk.
+}
+
+// NewSnapshotStore creates a SnapshotStore that keeps the `retain` most recent snapshots in dir. Keeping more than one
+// snapshot allows falling back to an older one when the latest is corrupt.
+func NewSnapshotStore(dir string, retain int) (*SnapshotStore, error) {
+	if strings.TrimSpace(dir) == "" {
+		return nil, ErrEmptySnapshotDirectory
+	}
+	if retain <= 0 {
+		retain = defaultRetained
+	}
+
+	if err := os.MkdirAll(dir, walDirPermissions); err != nil {
+		return nil, fmt.Errorf("create snapshot directory: %w", err)
+	}
+
+	return &SnapshotStore{dir: dir, retain: retain}, nil
+}
+
+// save writes the state to a new snapshot file covering the write-ahead log up to walSegment. The file is written to a
+// temporary path, synced and renamed, so a crash never leaves a half-written snapshot behind.
+func (s *SnapshotStore) save(walSegment uint64, state snapshotState) (snapshot, error) {
+	sequences, err := s.list()
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	var sequence uint64
+	if len(sequences) > 0 {
+		sequence = sequences[len(sequences)-1] + 1
+	}
+
+	data, err := encodeSnapshot(walSegment, state)
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	path := s.path(sequence)
+	tmpPath := path + ".tmp"
+
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, walFilePermissions)
+	if err != nil {
+		return snapshot{}, fmt.Errorf("create snapshot: %w", err)
+	}
+	if _, err = file.Write(data); err != nil {
+		_ = file.Close()
+		return snapshot{}, fmt.Errorf("write snapshot: %w", err)
+	}
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return snapshot{}, fmt.Errorf("sync snapshot: %w", err)
+	}
+	if err = file.Close(); err != nil {
+		return snapshot{}, fmt.Errorf("close snapshot: %w", err)
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return snapshot{}, fmt.Errorf("rename snapshot: %w", err)
+	}
+
+	return snapshot{sequence: sequence, walSegment: walSegment, state: state}, nil
+}
+
+// loadLatest loads the newest valid snapshot. Corrupt snapshots are skipped in favour of older ones, and
+// ErrNoValidSnapshot is returned when none can be loaded.
+func (s *SnapshotStore) loadLatest() (snapshot, error) {
+	sequences, err := s.list()
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	for i := len(sequences) - 1; i >= 0; i-- {
+		loaded, err := s.load(sequences[i])
+		if err != nil {
+			slog.Warn("skipping invalid snapshot", "sequence", sequences[i], "error", err)
+			continue
+		}
+		return loaded, nil
+	}
+
+	return snapshot{}, ErrNoValidSnapshot
+}
+
+// load reads and validates the snapshot with the given sequence number.
+func (s *SnapshotStore) load(sequence uint64) (snapshot, error) {
+	data, err := os.ReadFile(s.path(sequence))
+	if err != nil {
+		return snapshot{}, fmt.Errorf("read snapshot: %w", err)
+	}
+
+	walSegment, state, err := decodeSnapshot(data)
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	return snapshot{sequence: sequence, walSegment: walSegment, state: state}, nil
+}
+
+// prune deletes all but the `retain` most recent snapshots and returns the oldest write-ahead log segment still needed
+// by a retained snapshot, so older segments can be truncated. Corrupt snapshots are still counted as retained, the
+// returned segment is only derived from the valid ones.
+func (s *SnapshotStore) prune() (uint64, bool, error) {
+	sequences, err := s.list()
+	if err != nil {
+		return 0, false, err
+	}
+
+	if len(sequences) > s.retain {
+		for _, sequence := range sequences[:len(sequences)-s.retain] {
+			if err = os.Remove(s.path(sequence)); err != nil {
+				return 0, false, fmt.Errorf("remove snapshot: %w", err)
+			}
+		}
+		sequences = sequences[len(sequences)-s.retain:]
+	}
+
+	// Find the oldest valid retained snapshot, its log segments are needed to fall back to it
+	for _, sequence := range sequences {
+		retained, err := s.load(sequence)
+		if err != nil {
+			continue
+		}
+		return retained.walSegment, true, nil
+	}
+
+	return 0, false, nil
+}
+
+// list returns the sequence numbers of the stored snapshots, in ascending order.
+func (s *SnapshotStore) list() ([]uint64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot directory: %w", err)
+	}
+
+	var sequences []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotExtension) {
+			continue
+		}
+
+		sequence, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotExtension), 10, 64)
+		if err != nil {
+			continue // Ignore files that are not snapshots
+		}
+		sequences = append(sequences, sequence)
+	}
+
+	sort.Slice(sequences, func(i, j int) bool { return sequences[i] < sequences[j] })
+
+	return sequences, nil
+}
+
+// path returns the path of the snapshot with the given sequence number.
+func (s *SnapshotStore) path(sequence uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s%016d%s", snapshotPrefix, sequence, snapshotExtension))
+}
+
+// encodeSnapshot serializes the state and the covered write-ahead log position into the snapshot file format.
+func encodeSnapshot(walSegment uint64, state snapshotState) ([]byte, error) {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(newSnapshotPayload(state)); err != nil {
+		return nil, fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	data := make([]byte, snapshotHeaderSize, snapshotHeaderSize+payload.Len()+4)
+	copy(data[0:4], snapshotMagic)
+	binary.BigEndian.PutUint16(data[4:6], snapshotVersion)
+	binary.BigEndian.PutUint64(data[6:14], walSegment)
+	binary.BigEndian.PutUint32(data[14:18], uint32(payload.Len()))
+	data = append(data, payload.Bytes()...)
+
+	return binary.BigEndian.AppendUint32(data, crc32.Checksum(data, walChecksumTable)), nil
+}
+
+// decodeSnapshot validates and deserializes a snapshot file, returning the covered write-ahead log position and state.
+func decodeSnapshot(data []byte) (uint64, snapshotState, error) {
+	if len(data) < snapshotHeaderSize+4 {
+		return 0, snapshotState{}, fmt.Errorf("%w: truncated file", ErrCorruptSnapshot)
+	}
+
+	body, trailer := data[:len(data)-4], data[len(data)-4:]
+	if crc32.Checksum(body, walChecksumTable) != binary.BigEndian.Uint32(trailer) {
+		return 0, snapshotState{}, fmt.Errorf("%w: checksum mismatch", ErrCorruptSnapshot)
+	}
+
+	version := binary.BigEndian.Uint16(body[4:6])
+	switch {
+	case string(body[0:4]) != snapshotMagic:
+		return 0, snapshotState{}, fmt.Errorf("%w: invalid magic", ErrCorruptSnapshot)
+	case version != snapshotVersion:
+		return 0, snapshotState{}, fmt.Errorf("%w: unsupported version %d", ErrCorruptSnapshot, version)
+	case int(binary.BigEndian.Uint32(body[14:18])) != len(body)-snapshotHeaderSize:
+		return 0, snapshotState{}, fmt.Errorf("%w: invalid payload length", ErrCorruptSnapshot)
+	}
+
+	var payload snapshotPayload
+	if err := gob.NewDecoder(bytes.NewReader(body[snapshotHeaderSize:])).Decode(&payload); err != nil {
+		return 0, snapshotState{}, fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+
+	return binary.BigEndian.Uint64(body[6:14]), payload.state(), nil
+}
@@ -0,0 +1,319 @@
+package persistence
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"quoteship/domain"
+)
+
+func TestNewSnapshotStore(t *testing.T) {
+	tests := []struct {
+		name           string
+		dir            func(t *testing.T) string
+		retain         int
+		expectedRetain int
+		expectedError  error
+	}{
+		{
+			name:          "invalid input - empty directory",
+			dir:           func(*testing.T) string { return " " },
+			expectedError: ErrEmptySnapshotDirectory,
+		},
+		{
+			name:           "valid input - default retain",
+			dir:            func(t *testing.T) string { return t.TempDir() },
+			expectedRetain: defaultRetained,
+		},
+		{
+			name:           "valid input - custom retain",
+			dir:            func(t *testing.T) string { return t.TempDir() },
+			retain:         5,
+			expectedRetain: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewSnapshotStore(tt.dir(t), tt.retain)
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+
+			if err == nil && store.retain != tt.expectedRetain {
+				t.Errorf("expected retain %d, got %d", tt.expectedRetain, store.retain)
+			}
+		})
+	}
+}
+
+func TestDecodeSnapshot(t *testing.T) {
+	shipmentsByOrigin := []domain.OriginShipments{
+		{
+			Origin: "LAX",
+			Quotes: []domain.ShipmentQuote{
+				{Company: 2, Price: 100, Date: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{Company: 1, Price: 200, Date: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			Origin:      "NYC",
+			Destination: "LAX",
+			Quotes: []domain.ShipmentQuote{{
+				Company:    1,
+				Price:      150,
+				Quoted:     domain.Money{Amount: 1050, Currency: "CNY"},
+				Date:       time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+				ValidUntil: time.Date(2000, 2, 1, 0, 0, 0, 0, time.UTC),
+				Equipment:  domain.Equipment40HC,
+			}},
+		},
+	}
+	state := snapshotState{
+		ShipmentsByOrigin: shipmentsByOrigin,
+		AuditTrail: []domain.AuditEntry{
+			{
+				ShipmentUnit: domain.ShipmentUnit{Origin: "LAX", ShipmentQuote: shipmentsByOrigin[0].Quotes[0]},
+				ReceivedAt:   time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC),
+				Status:       domain.AuditAccepted,
+			},
+			{
+				ShipmentUnit: domain.ShipmentUnit{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 3}},
+				ReceivedAt:   time.Date(2000, 1, 3, 0, 0, 0, 0, time.UTC),
+				Status:       domain.AuditRejected,
+				Reason:       domain.ErrInvalidPrice.Error(),
+			},
+		},
+		LatestShipmentBatch:    shipmentsByOrigin[:1],
+		LatestBatchPublishedAt: time.Date(2000, 1, 4, 0, 0, 0, 0, time.UTC),
+		ShipmentCount:          3,
+		ThresholdCount:         10,
+	}
+
+	tests := []struct {
+		name          string
+		data          func(t *testing.T) []byte
+		expectedError error
+	}{
+		{
+			name: "valid snapshot",
+			data: func(t *testing.T) []byte {
+				data, err := encodeSnapshot(7, state)
+				if err != nil {
+					t.Fatalf("failed to encode snapshot: %v", err)
+				}
+				return data
+			},
+		},
+		{
+			name: "invalid snapshot - truncated",
+			data: func(t *testing.T) []byte {
+				data, err := encodeSnapshot(7, state)
+				if err != nil {
+					t.Fatalf("failed to encode snapshot: %v", err)
+				}
+				return data[:len(data)/2]
+			},
+			expectedError: ErrCorruptSnapshot,
+		},
+		{
+			name: "invalid snapshot - flipped byte",
+			data: func(t *testing.T) []byte {
+				data, err := encodeSnapshot(7, state)
+				if err != nil {
+					t.Fatalf("failed to encode snapshot: %v", err)
+				}
+				data[snapshotHeaderSize+1] ^= 0xFF
+				return data
+			},
+			expectedError: ErrCorruptSnapshot,
+		},
+		{
+			name: "invalid snapshot - unsupported version",
+			data: func(t *testing.T) []byte {
+				data, err := encodeSnapshot(7, state)
+				if err != nil {
+					t.Fatalf("failed to encode snapshot: %v", err)
+				}
+				binary.BigEndian.PutUint16(data[4:6], snapshotVersion+1)
+				return binary.BigEndian.AppendUint32(data[:len(data)-4], crc32.Checksum(data[:len(data)-4], walChecksumTable))
+			},
+			expectedError: ErrCorruptSnapshot,
+		},
+		{
+			name:          "invalid snapshot - empty",
+			data:          func(*testing.T) []byte { return nil },
+			expectedError: ErrCorruptSnapshot,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			walSegment, decoded, err := decodeSnapshot(tt.data(t))
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if walSegment != 7 {
+				t.Errorf("expected wal segment 7, got %d", walSegment)
+			}
+			if !reflect.DeepEqual(decoded, state) {
+				t.Errorf("expected state %+v, got %+v", state, decoded)
+			}
+		})
+	}
+}
+
+func TestShipmentRepository_CreateSnapshot(t *testing.T) {
+	walDir, snapshotDir := t.TempDir(), t.TempDir()
+	date := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// open creates a repository backed by the write-ahead log and snapshots stored in the test directories
+	open := func(t *testing.T) (*ShipmentRepository, *WriteAheadLog) {
+		t.Helper()
+
+		wal, err := OpenWriteAheadLog(WALConfig{Dir: walDir, SyncPolicy: SyncAlways})
+		if err != nil {
+			t.Fatalf("failed to open write-ahead log: %v", err)
+		}
+		store, err := NewSnapshotStore(snapshotDir, 2)
+		if err != nil {
+			t.Fatalf("failed to create snapshot store: %v", err)
+		}
+		repository, err := NewShipmentOfferRepository(context.Background(), 2, WithWriteAheadLog(wal), WithSnapshots(store, 0))
+		if err != nil {
+			t.Fatalf("failed to create repository: %v", err)
+		}
+		return repository, wal
+	}
+
+	original, wal := open(t)
+	original.now = func() time.Time { return date }
+	for i := 1; i <= 10; i++ {
+		// Companies submit several quotes per origin, so the snapshots carry a quote history
+		err := original.AddOrUpdate(domain.ShipmentUnit{
+			Origin:        []string{"LAX", "NYC"}[i%2],
+			ShipmentQuote: domain.ShipmentQuote{Company: i%4 + 1, Price: 1000 - i*10, Date: date.AddDate(0, 0, i)},
+		})
+		if err != nil {
+			t.Fatalf("failed to add shipment: %v", err)
+		}
+
+		// Take a snapshot every third submission, leaving records after the last one in the log
+		if i%3 == 0 {
+			if err = original.CreateSnapshot(); err != nil {
+				t.Fatalf("failed to create snapshot: %v", err)
+			}
+		}
+	}
+	if err := wal.Close(); err != nil {
+		t.Fatalf("failed to close write-ahead log: %v", err)
+	}
+
+	// Only the segments needed by the two retained snapshots remain
+	segments, err := listSegments(walDir)
+	if err != nil {
+		t.Fatalf("failed to list segments: %v", err)
+	}
+	if !reflect.DeepEqual(segments, []uint64{2, 3}) {
+		t.Errorf("expected segments [2 3], got %v", segments)
+	}
+
+	tests := []struct {
+		name   string
+		damage func(t *testing.T)
+	}{
+		{
+			name: "restore from latest snapshot",
+		},
+		{
+			name: "fall back to older snapshot when latest is corrupt",
+			damage: func(t *testing.T) {
+				store := SnapshotStore{dir: snapshotDir}
+				sequences, err := store.list()
+				if err != nil {
+					t.Fatalf("failed to list snapshots: %v", err)
+				}
+				flipLastByte(t, store.path(sequences[len(sequences)-1]))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.damage != nil {
+				tt.damage(t)
+			}
+
+			restored, wal := open(t)
+			defer wal.Close()
+
+			if !reflect.DeepEqual(restored.copyShipments(), original.copyShipments()) {
+				t.Errorf("expected shipments %+v, got %+v", original.copyShipments(), restored.copyShipments())
+			}
+			if !reflect.DeepEqual(restored.loadBatch(), original.loadBatch()) {
+				t.Errorf("expected latest batch %+v, got %+v", original.loadBatch(), restored.loadBatch())
+			}
+			asOf := date.AddDate(0, 0, 5)
+			if !reflect.DeepEqual(restored.GetSortedShipmentsByOriginAsOf(asOf), original.GetSortedShipmentsByOriginAsOf(asOf)) {
+				t.Errorf("expected shipments as of %s %+v, got %+v", asOf, original.GetSortedShipmentsByOriginAsOf(asOf), restored.GetSortedShipmentsByOriginAsOf(asOf))
+			}
+			if history := restored.GetQuoteHistory("LAX", 3); len(history) != 3 || !reflect.DeepEqual(history, original.GetQuoteHistory("LAX", 3)) {
+				t.Errorf("expected quote history %+v, got %+v", original.GetQuoteHistory("LAX", 3), history)
+			}
+			if restored.shipmentCount != original.shipmentCount {
+				t.Errorf("expected shipment count %d, got %d", original.shipmentCount, restored.shipmentCount)
+			}
+		})
+	}
+}
+
+func TestShipmentRepository_captureStateDuringSubmissions(t *testing.T) {
+	repository, err := NewShipmentOfferRepository(context.Background(), 1_000_000)
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+
+	const writers, submissions = 4, 200
+	date := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var writersGroup sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		writersGroup.Add(1)
+		go func(w int) {
+			defer writersGroup.Done()
+			for i := 0; i < submissions; i++ {
+				err := repository.AddOrUpdate(domain.ShipmentUnit{
+					Origin:        []string{"LAX", "NYC"}[i%2],
+					ShipmentQuote: domain.ShipmentQuote{Company: w + 1, Price: i + 1, Date: date.AddDate(0, 0, i)},
+				})
+				if err != nil {
+					t.Errorf("failed to add shipment: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	// Every captured submission must be counted, nothing is published before the threshold count
+	for captured := 0; captured < writers*submissions; {
+		_, state, err := repository.captureState()
+		if err != nil {
+			t.Fatalf("failed to capture state: %v", err)
+		}
+		if captured = len(state.AuditTrail); state.ShipmentCount != captured {
+			t.Fatalf("expected shipment count %d, got %d", captured, state.ShipmentCount)
+		}
+	}
+
+	writersGroup.Wait()
+}
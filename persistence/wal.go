@@ -0,0 +1,666 @@
+package persistence
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"quoteship/domain"
+)
+
+const (
+	walSegmentExtension    = ".wal"          // walSegmentExtension is the file extension used by the write-ahead log segments.
+	walRecordHeaderSize    = 8               // walRecordHeaderSize is the size of a record frame header, 4 bytes of payload length followed by 4 bytes of checksum.
+	walRecordVersion       = 1               // walRecordVersion is the version of the record payload written by this build.
+	walMaxRecordSize       = 1 << 20         // walMaxRecordSize is the upper bound of a single record payload, anything bigger is treated as corruption.
+	defaultSegmentSize     = 64 << 20        // defaultSegmentSize is the size after which the active segment is rotated (64 MiB).
+	defaultWALSyncInterval = 1 * time.Second // defaultWALSyncInterval is the fsync interval used by SyncInterval when none is configured.
+	walFilePermissions     = 0o644           // walFilePermissions are the permissions of newly created segment files.
+	walDirPermissions      = 0o755           // walDirPermissions are the permissions of a newly created log directory.
+)
+
+var (
+	ErrEmptyWALDirectory = errors.New("write-ahead log directory cannot be empty")
+	ErrInvalidSyncPolicy = errors.New("invalid write-ahead log sync policy")
+	ErrWALClosed         = errors.New("write-ahead log is closed")
+	ErrCorruptWALRecord  = errors.New("corrupt write-ahead log record")
+
+	walChecksumTable = crc32.MakeTable(crc32.Castagnoli) // walChecksumTable is the CRC32-C table used to checksum record payloads.
+)
+
+// SyncPolicy defines when the write-ahead log flushes appended records to stable storage.
+type SyncPolicy int
+
+const (
+	SyncAlways   SyncPolicy = iota // SyncAlways fsyncs the active segment after every append, no acknowledged record can be lost.
+	SyncInterval                   // SyncInterval fsyncs the active segment periodically, records appended since the last sync may be lost on a crash.
+	SyncNever                      // SyncNever leaves flushing to the operating system.
+)
+
+// String returns the textual representation of the SyncPolicy.
+func (p SyncPolicy) String() string {
+	switch p {
+	case SyncAlways:
+		return "always"
+	case SyncInterval:
+		return "interval"
+	case SyncNever:
+		return "never"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseSyncPolicy parses a textual sync policy ("always", "interval" or "never") into a SyncPolicy.
+func ParseSyncPolicy(policy string) (SyncPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(policy)) {
+	case "always":
+		return SyncAlways, nil
+	case "interval":
+		return SyncInterval, nil
+	case "never":
+		return SyncNever, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSyncPolicy, policy)
+	}
+}
+
+// WALConfig holds the configuration of a WriteAheadLog.
+type WALConfig struct {
+	Dir          string        // Dir is the directory where the log segments are stored.
+	SyncPolicy   SyncPolicy    // SyncPolicy determines when appended records are flushed to stable storage.
+	SyncInterval time.Duration // SyncInterval is the flush interval used by the SyncInterval policy.
+	SegmentSize  int64         // SegmentSize is the size in bytes after which the active segment is rotated.
+}
+
+// walRecordType identifies the repository operation a walRecord replays.
+type walRecordType string
+
+const (
+	walRecordShipment    walRecordType = "shipment"    // walRecordShipment is an accepted AddOrUpdate call.
+	walRecordRejection   walRecordType = "rejection"   // walRecordRejection is a RecordRejectedShipment call.
+	walRecordBatch       walRecordType = "batch"       // walRecordBatch is an AddOrUpdateAll call.
+	walRecordDeletion    walRecordType = "deletion"    // walRecordDeletion is a DeleteCompanyQuotes call.
+	walRecordPublication walRecordType = "publication" // walRecordPublication is the publication of a batch triggered by the publication policy.
+	walRecordSweep       walRecordType = "sweep"       // walRecordSweep is a sweep that evicted expired quotes.
+)
+
+// walRecord is a single entry of the write-ahead log. Records are framed on disk as a 4 byte big-endian payload length,
+// a 4 byte CRC32-C checksum of the payload, and the JSON encoded payload itself.
+type walRecord struct {
+	Version   int            `json:"v"`           // Version is the payload version, records of any other version than walRecordVersion are not replayed.
+	Type      walRecordType  `json:"t"`           // Type is the repository operation the record replays.
+	Shipment  *walShipment   `json:"s,omitempty"` // Shipment holds the submitted shipment unit for walRecordShipment and walRecordRejection records, and the origin and company of walRecordDeletion records.
+	Reason    string         `json:"r,omitempty"` // Reason is the rejection reason of walRecordRejection records.
+	Shipments []*walShipment `json:"b,omitempty"` // Shipments holds the submitted shipment units of walRecordBatch records, in the order they were applied.
+	Published *time.Time     `json:"p,omitempty"` // Published is the time the batch of walRecordPublication records was published at.
+	Swept     *time.Time     `json:"e,omitempty"` // Swept is the time the expired quotes of walRecordSweep records were evicted at.
+	MaxAge    time.Duration  `json:"a,omitempty"` // MaxAge is the quote max age walRecordSweep records were swept with, zero when only ValidUntil expires quotes.
+}
+
+// walShipment is the on-disk representation of a domain.ShipmentUnit. It is kept separate from the domain struct so the
+// log format does not change whenever the domain evolves.
+type walShipment struct {
+	Origin      string    `json:"origin"`      // Origin is the located port where the shipment starts (e.g., "CNSGH").
+	Destination string    `json:"destination"` // Destination is the located port where the shipment ends, or the domain.WildcardDestination.
+	Company     int       `json:"company"`     // Company is the identifier of the company that provided the quote.
+	Price       int       `json:"price"`       // Price is the cost of the shipment in minor units of the base currency.
+	QuotedPrice int       `json:"quotedPrice"` // QuotedPrice is the cost of the shipment as submitted, in minor units of Currency.
+	Currency    string    `json:"currency"`    // Currency is the currency of QuotedPrice.
+	Date        time.Time `json:"date"`        // Date is the date when the shipment will start.
+	ValidUntil  time.Time `json:"validUntil"`  // ValidUntil is the time the quote expires at, zero when it only expires with its age.
+	ReceivedAt  time.Time `json:"receivedAt"`  // ReceivedAt is the time the submission was received.
+	Equipment   string    `json:"equipment"`   // Equipment is the container type the quote is priced for.
+}
+
+// newShipmentRecord creates a walRecord for a domain.ShipmentUnit submitted to the repository at receivedAt.
+func newShipmentRecord(shipment domain.ShipmentUnit, receivedAt time.Time) walRecord {
+	return walRecord{
+		Version:  walRecordVersion,
+		Type:     walRecordShipment,
+		Shipment: newWALShipment(shipment, receivedAt),
+	}
+}
+
+// newRejectionRecord creates a walRecord for a domain.ShipmentUnit rejected at receivedAt for the given reason.
+func newRejectionRecord(shipment domain.ShipmentUnit, receivedAt time.Time, reason string) walRecord {
+	return walRecord{
+		Version:  walRecordVersion,
+		Type:     walRecordRejection,
+		Shipment: newWALShipment(shipment, receivedAt),
+		Reason:   reason,
+	}
+}
+
+// newBatchRecord creates a walRecord for the domain.ShipmentUnit offers submitted together to the repository at
+// receivedAt.
+func newBatchRecord(shipments []domain.ShipmentUnit, receivedAt time.Time) walRecord {
+	record := walRecord{Version: walRecordVersion, Type: walRecordBatch, Shipments: make([]*walShipment, 0, len(shipments))}
+	for _, shipment := range shipments {
+		record.Shipments = append(record.Shipments, newWALShipment(shipment, receivedAt))
+	}
+
+	return record
+}
+
+// newDeletionRecord creates a walRecord for the deletion of the quotes of the company for the origin at receivedAt.
+func newDeletionRecord(origin string, company int, receivedAt time.Time) walRecord {
+	return walRecord{
+		Version:  walRecordVersion,
+		Type:     walRecordDeletion,
+		Shipment: &walShipment{Origin: origin, Company: company, ReceivedAt: receivedAt},
+	}
+}
+
+// newPublicationRecord creates a walRecord for the publication of a batch at publishedAt.
+func newPublicationRecord(publishedAt time.Time) walRecord {
+	return walRecord{Version: walRecordVersion, Type: walRecordPublication, Published: &publishedAt}
+}
+
+// newSweepRecord creates a walRecord for a sweep of the quotes expired at sweptAt with the quote max age.
+func newSweepRecord(sweptAt time.Time, maxAge time.Duration) walRecord {
+	return walRecord{Version: walRecordVersion, Type: walRecordSweep, Swept: &sweptAt, MaxAge: maxAge}
+}
+
+// newWALShipment converts a domain.ShipmentUnit received at receivedAt into its on-disk representation.
+func newWALShipment(shipment domain.ShipmentUnit, receivedAt time.Time) *walShipment {
+	return &walShipment{
+		Origin:      shipment.Origin,
+		Destination: shipment.Destination,
+		Company:     shipment.Company,
+		Price:       shipment.Price,
+		QuotedPrice: shipment.Quoted.Amount,
+		Currency:    string(shipment.Quoted.Currency),
+		Date:        shipment.Date,
+		ValidUntil:  shipment.ValidUntil,
+		ReceivedAt:  receivedAt,
+		Equipment:   string(shipment.Equipment),
+	}
+}
+
+// shipmentUnit converts the walShipment back into a domain.ShipmentUnit.
+func (s *walShipment) shipmentUnit() domain.ShipmentUnit {
+	return domain.ShipmentUnit{
+		Origin:      s.Origin,
+		Destination: s.Destination,
+		ShipmentQuote: domain.ShipmentQuote{
+			Company:    s.Company,
+			Price:      s.Price,
+			Quoted:     domain.Money{Amount: s.QuotedPrice, Currency: domain.Currency(s.Currency)},
+			Date:       s.Date,
+			ValidUntil: s.ValidUntil,
+			Equipment:  domain.Equipment(s.Equipment),
+		},
+	}
+}
+
+// WriteAheadLog is a durable, append-only log of repository operations split into numbered segment files. Every
+// record is checksummed so torn or corrupted writes are detected when the log is replayed.
+type WriteAheadLog struct {
+	config       WALConfig     // config is the configuration the log was opened with.
+	segment      *os.File      // segment is the active segment file records are appended to.
+	segmentIndex uint64        // segmentIndex is the index of the active segment.
+	segmentSize  int64         // segmentSize is the current size in bytes of the active segment.
+	dirty        bool          // dirty reports whether records were appended since the last fsync.
+	closed       bool          // closed reports whether the log has been closed.
+	mu           sync.Mutex    // mu synchronizes appends, syncs and rotations.
+	stop         chan struct{} // stop signals the background sync goroutine to exit.
+	done         chan struct{} // done is closed when the background sync goroutine exits.
+}
+
+// OpenWriteAheadLog opens, or creates, the write-ahead log stored in config.Dir. A torn record at the tail of the last
+// segment, which is what an interrupted write leaves behind, is truncated so new records can be appended after the
+// last intact one.
+func OpenWriteAheadLog(config WALConfig) (*WriteAheadLog, error) {
+	switch {
+	case strings.TrimSpace(config.Dir) == "":
+		return nil, ErrEmptyWALDirectory
+	case config.SyncPolicy < SyncAlways || config.SyncPolicy > SyncNever:
+		return nil, ErrInvalidSyncPolicy
+	}
+
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = defaultSegmentSize
+	}
+	if config.SyncInterval <= 0 {
+		config.SyncInterval = defaultWALSyncInterval
+	}
+
+	if err := os.MkdirAll(config.Dir, walDirPermissions); err != nil {
+		return nil, fmt.Errorf("create write-ahead log directory: %w", err)
+	}
+
+	segments, err := listSegments(config.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	wal := &WriteAheadLog{config: config}
+
+	if len(segments) == 0 {
+		// Start a brand-new log with the first segment
+		if err = wal.openSegment(0); err != nil {
+			return nil, err
+		}
+	} else {
+		// Continue appending to the last segment, after dropping any torn tail record
+		last := segments[len(segments)-1]
+		if err = wal.recoverSegment(last); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.SyncPolicy == SyncInterval {
+		wal.stop = make(chan struct{})
+		wal.done = make(chan struct{})
+		go wal.syncLoop()
+	}
+
+	return wal, nil
+}
+
+// Append writes the records to the active segment and flushes them according to the configured SyncPolicy. The
+// records are written as a single write, so they are either all replayed or, when torn, all dropped.
+func (w *WriteAheadLog) Append(records ...walRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	// Encode the records outside the lock, the encoding does not depend on the log state
+	var buffer []byte
+	for _, record := range records {
+		frame, err := encodeWALRecord(record)
+		if err != nil {
+			return err
+		}
+		buffer = append(buffer, frame...)
+	}
+
+	w.mu.Lock()         // Lock the mutex to serialize appends
+	defer w.mu.Unlock() // Unlock the mutex when the function returns
+
+	if w.closed {
+		return ErrWALClosed
+	}
+
+	// Rotate the active segment if the new records would exceed the configured segment size
+	if w.segmentSize > 0 && w.segmentSize+int64(len(buffer)) > w.config.SegmentSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	written, err := w.segment.Write(buffer)
+	w.segmentSize += int64(written)
+	if err != nil {
+		return fmt.Errorf("append write-ahead log record: %w", err)
+	}
+	w.dirty = true
+
+	if w.config.SyncPolicy == SyncAlways {
+		return w.sync()
+	}
+
+	return nil
+}
+
+// Replay reads every record stored in the segments starting at fromSegment, in order, and passes it to apply. Replay
+// stops at the first corrupted record and returns an error wrapping ErrCorruptWALRecord.
+func (w *WriteAheadLog) Replay(fromSegment uint64, apply func(record walRecord) error) error {
+	w.mu.Lock()         // Lock the mutex so no record is appended while replaying
+	defer w.mu.Unlock() // Unlock the mutex when the function returns
+
+	if w.closed {
+		return ErrWALClosed
+	}
+
+	segments, err := listSegments(w.config.Dir)
+	if err != nil {
+		return err
+	}
+
+	// The segments needed to continue from fromSegment must not have been truncated
+	if len(segments) > 0 && segments[0] > fromSegment {
+		return fmt.Errorf("%w: expected segment %d, oldest is %d", ErrMissingWALSegments, fromSegment, segments[0])
+	}
+
+	for _, index := range segments {
+		if index < fromSegment {
+			continue
+		}
+
+		if err = replaySegment(segmentPath(w.config.Dir, index), apply); err != nil {
+			return fmt.Errorf("replay segment %d: %w", index, err)
+		}
+	}
+
+	return nil
+}
+
+// Rotate seals the active segment and starts a new one, returning the index of the new segment. Every record appended
+// before Rotate returns is stored in a segment older than the returned index.
+func (w *WriteAheadLog) Rotate() (uint64, error) {
+	w.mu.Lock()         // Lock the mutex to prevent concurrent appends while rotating
+	defer w.mu.Unlock() // Unlock the mutex when the function returns
+
+	if w.closed {
+		return 0, ErrWALClosed
+	}
+
+	if err := w.rotate(); err != nil {
+		return 0, err
+	}
+
+	return w.segmentIndex, nil
+}
+
+// TruncateBefore removes every sealed segment older than index, it is used to compact the log once a snapshot covers
+// those segments. The active segment is never removed.
+func (w *WriteAheadLog) TruncateBefore(index uint64) error {
+	w.mu.Lock()         // Lock the mutex so the active segment does not change while truncating
+	defer w.mu.Unlock() // Unlock the mutex when the function returns
+
+	if w.closed {
+		return ErrWALClosed
+	}
+
+	segments, err := listSegments(w.config.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, segment := range segments {
+		if segment >= index || segment >= w.segmentIndex {
+			break
+		}
+
+		if err = os.Remove(segmentPath(w.config.Dir, segment)); err != nil {
+			return fmt.Errorf("remove write-ahead log segment: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Sync flushes the records appended to the active segment to stable storage.
+func (w *WriteAheadLog) Sync() error {
+	w.mu.Lock()         // Lock the mutex to prevent concurrent appends while syncing
+	defer w.mu.Unlock() // Unlock the mutex when the function returns
+
+	if w.closed {
+		return ErrWALClosed
+	}
+
+	return w.sync()
+}
+
+// Close flushes and closes the active segment and stops the background sync goroutine. Closing an already closed log
+// is a no-op.
+func (w *WriteAheadLog) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+
+	syncErr := w.sync()
+	closeErr := w.segment.Close()
+	w.mu.Unlock()
+
+	// Wait for the background sync goroutine outside the lock, it needs the lock to exit cleanly
+	if w.stop != nil {
+		close(w.stop)
+		<-w.done
+	}
+
+	return errors.Join(syncErr, closeErr)
+}
+
+// syncLoop periodically flushes the active segment, it is only started for the SyncInterval policy.
+func (w *WriteAheadLog) syncLoop() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.config.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			if err := w.Sync(); err != nil && !errors.Is(err, ErrWALClosed) {
+				slog.Error("failed to sync write-ahead log", "error", err)
+			}
+		}
+	}
+}
+
+// sync flushes the active segment if it has unsynced records, the caller must hold w.mu.
+func (w *WriteAheadLog) sync() error {
+	if !w.dirty {
+		return nil
+	}
+
+	if err := w.segment.Sync(); err != nil {
+		return fmt.Errorf("sync write-ahead log segment: %w", err)
+	}
+	w.dirty = false
+
+	return nil
+}
+
+// rotate closes the active segment and opens the next one, the caller must hold w.mu.
+func (w *WriteAheadLog) rotate() error {
+	if err := w.sync(); err != nil {
+		return err
+	}
+
+	if err := w.segment.Close(); err != nil {
+		return fmt.Errorf("close write-ahead log segment: %w", err)
+	}
+
+	return w.openSegment(w.segmentIndex + 1)
+}
+
+// openSegment creates and activates a new empty segment with the given index.
+func (w *WriteAheadLog) openSegment(index uint64) error {
+	file, err := os.OpenFile(segmentPath(w.config.Dir, index), os.O_CREATE|os.O_WRONLY|os.O_APPEND, walFilePermissions)
+	if err != nil {
+		return fmt.Errorf("open write-ahead log segment: %w", err)
+	}
+
+	w.segment = file
+	w.segmentIndex = index
+	w.segmentSize = 0
+
+	return nil
+}
+
+// recoverSegment activates an existing segment for appending, truncating it after its last intact record.
+func (w *WriteAheadLog) recoverSegment(index uint64) error {
+	path := segmentPath(w.config.Dir, index)
+
+	validSize, err := validSegmentSize(path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY, walFilePermissions)
+	if err != nil {
+		return fmt.Errorf("open write-ahead log segment: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("stat write-ahead log segment: %w", err)
+	}
+
+	// Drop the torn tail left behind by an interrupted write
+	if info.Size() > validSize {
+		slog.Warn("truncating torn write-ahead log tail", "segment", index, "discarded_bytes", info.Size()-validSize)
+		if err = file.Truncate(validSize); err != nil {
+			_ = file.Close()
+			return fmt.Errorf("truncate write-ahead log segment: %w", err)
+		}
+		if err = file.Sync(); err != nil {
+			_ = file.Close()
+			return fmt.Errorf("sync write-ahead log segment: %w", err)
+		}
+	}
+
+	if _, err = file.Seek(validSize, io.SeekStart); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("seek write-ahead log segment: %w", err)
+	}
+
+	w.segment = file
+	w.segmentIndex = index
+	w.segmentSize = validSize
+
+	return nil
+}
+
+// encodeWALRecord frames a walRecord as length, checksum and JSON payload.
+func encodeWALRecord(record walRecord) ([]byte, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("encode write-ahead log record: %w", err)
+	}
+
+	frame := make([]byte, walRecordHeaderSize, walRecordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, walChecksumTable))
+
+	return append(frame, payload...), nil
+}
+
+// readWALRecord reads the next framed record from reader. It returns io.EOF at a clean end of the segment and an error
+// wrapping ErrCorruptWALRecord for a torn or corrupted record.
+func readWALRecord(reader io.Reader) (walRecord, int64, error) {
+	header := make([]byte, walRecordHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return walRecord{}, 0, io.EOF
+		}
+		return walRecord{}, 0, fmt.Errorf("%w: truncated header", ErrCorruptWALRecord)
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+	if length == 0 || length > walMaxRecordSize {
+		return walRecord{}, 0, fmt.Errorf("%w: invalid length %d", ErrCorruptWALRecord, length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return walRecord{}, 0, fmt.Errorf("%w: truncated payload", ErrCorruptWALRecord)
+	}
+
+	if crc32.Checksum(payload, walChecksumTable) != checksum {
+		return walRecord{}, 0, fmt.Errorf("%w: checksum mismatch", ErrCorruptWALRecord)
+	}
+
+	var record walRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return walRecord{}, 0, fmt.Errorf("%w: %v", ErrCorruptWALRecord, err)
+	}
+
+	return record, int64(walRecordHeaderSize) + int64(length), nil
+}
+
+// replaySegment reads every record of the segment stored at path and passes it to apply.
+func replaySegment(path string, apply func(record walRecord) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open write-ahead log segment: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			slog.Error("error closing write-ahead log segment", "error", closeErr)
+		}
+	}()
+
+	reader := bufio.NewReader(file)
+	for {
+		record, _, err := readWALRecord(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = apply(record); err != nil {
+			return err
+		}
+	}
+}
+
+// validSegmentSize returns the offset right after the last intact record of the segment stored at path.
+func validSegmentSize(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("open write-ahead log segment: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			slog.Error("error closing write-ahead log segment", "error", closeErr)
+		}
+	}()
+
+	var size int64
+	reader := bufio.NewReader(file)
+	for {
+		_, n, err := readWALRecord(reader)
+		if err != nil {
+			// Both a clean end and a torn record end the intact part of the segment
+			return size, nil
+		}
+		size += n
+	}
+}
+
+// listSegments returns the indexes of the segments stored in dir, in ascending order.
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read write-ahead log directory: %w", err)
+	}
+
+	var segments []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, walSegmentExtension) {
+			continue
+		}
+
+		index, err := strconv.ParseUint(strings.TrimSuffix(name, walSegmentExtension), 10, 64)
+		if err != nil {
+			continue // Ignore files that are not segments
+		}
+		segments = append(segments, index)
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+
+	return segments, nil
+}
+
+// segmentPath returns the path of the segment with the given index.
+func segmentPath(dir string, index uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%016d%s", index, walSegmentExtension))
+}
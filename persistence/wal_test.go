@@ -0,0 +1,397 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"quoteship/domain"
+)
+
+func TestParseSyncPolicy(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expectedPolicy SyncPolicy
+		expectedError  error
+	}{
+		{name: "always", input: "always", expectedPolicy: SyncAlways},
+		{name: "interval - mixed case", input: " Interval ", expectedPolicy: SyncInterval},
+		{name: "never", input: "never", expectedPolicy: SyncNever},
+		{name: "invalid policy", input: "sometimes", expectedError: ErrInvalidSyncPolicy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParseSyncPolicy(tt.input)
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+
+			if err == nil && policy != tt.expectedPolicy {
+				t.Errorf("expected policy %v, got %v", tt.expectedPolicy, policy)
+			}
+		})
+	}
+}
+
+func TestOpenWriteAheadLog(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        func(dir string) WALConfig
+		expectedError error
+	}{
+		{
+			name:          "invalid config - empty directory",
+			config:        func(string) WALConfig { return WALConfig{} },
+			expectedError: ErrEmptyWALDirectory,
+		},
+		{
+			name:          "invalid config - unknown sync policy",
+			config:        func(dir string) WALConfig { return WALConfig{Dir: dir, SyncPolicy: SyncPolicy(42)} },
+			expectedError: ErrInvalidSyncPolicy,
+		},
+		{
+			name:   "valid config - sync always",
+			config: func(dir string) WALConfig { return WALConfig{Dir: dir, SyncPolicy: SyncAlways} },
+		},
+		{
+			name: "valid config - sync interval",
+			config: func(dir string) WALConfig {
+				return WALConfig{Dir: dir, SyncPolicy: SyncInterval, SyncInterval: time.Millisecond}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wal, err := OpenWriteAheadLog(tt.config(t.TempDir()))
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+
+			if wal != nil {
+				if err = wal.Close(); err != nil {
+					t.Errorf("failed to close write-ahead log: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteAheadLog_Replay(t *testing.T) {
+	records := []walRecord{
+		newShipmentRecord(testingShipmentUnit, time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)),
+		newShipmentRecord(domain.ShipmentUnit{
+			Origin:        "NYC",
+			ShipmentQuote: domain.ShipmentQuote{Company: 7, Price: 300, Date: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)},
+		}, time.Date(2020, 4, 2, 0, 0, 0, 0, time.UTC)),
+		newRejectionRecord(domain.ShipmentUnit{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 7}}, time.Date(2020, 4, 3, 0, 0, 0, 0, time.UTC), domain.ErrInvalidPrice.Error()),
+		newPublicationRecord(time.Date(2020, 4, 4, 0, 0, 0, 0, time.UTC)),
+	}
+
+	tests := []struct {
+		name            string
+		config          WALConfig
+		damage          func(t *testing.T, dir string)
+		expectedRecords int
+		expectedError   error
+	}{
+		{
+			name:            "single segment",
+			config:          WALConfig{SyncPolicy: SyncAlways},
+			expectedRecords: len(records),
+		},
+		{
+			name:            "rotated segments",
+			config:          WALConfig{SyncPolicy: SyncNever, SegmentSize: 1},
+			expectedRecords: len(records),
+		},
+		{
+			name:   "torn tail is truncated",
+			config: WALConfig{SyncPolicy: SyncAlways},
+			damage: func(t *testing.T, dir string) {
+				appendToFile(t, segmentPath(dir, 0), []byte{0, 0, 0, 42, 1, 2})
+			},
+			expectedRecords: len(records),
+		},
+		{
+			name:   "corrupted record in a sealed segment",
+			config: WALConfig{SyncPolicy: SyncNever, SegmentSize: 1},
+			damage: func(t *testing.T, dir string) {
+				flipLastByte(t, segmentPath(dir, 0))
+			},
+			expectedError: ErrCorruptWALRecord,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.Dir = t.TempDir()
+
+			wal, err := OpenWriteAheadLog(config)
+			if err != nil {
+				t.Fatalf("failed to open write-ahead log: %v", err)
+			}
+			for _, record := range records {
+				if err = wal.Append(record); err != nil {
+					t.Fatalf("failed to append record: %v", err)
+				}
+			}
+			if err = wal.Close(); err != nil {
+				t.Fatalf("failed to close write-ahead log: %v", err)
+			}
+
+			if tt.damage != nil {
+				tt.damage(t, config.Dir)
+			}
+
+			wal, err = OpenWriteAheadLog(config)
+			if err != nil {
+				t.Fatalf("failed to reopen write-ahead log: %v", err)
+			}
+			defer wal.Close()
+
+			var replayed []walRecord
+			err = wal.Replay(0, func(record walRecord) error {
+				replayed = append(replayed, record)
+				return nil
+			})
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if len(replayed) != tt.expectedRecords {
+				t.Fatalf("expected %d records, got %d", tt.expectedRecords, len(replayed))
+			}
+			if !reflect.DeepEqual(replayed, records) {
+				t.Errorf("expected records %+v, got %+v", records, replayed)
+			}
+
+			// The reopened log must accept new records after the replayed ones
+			if err = wal.Append(newPublicationRecord(time.Date(2020, 4, 5, 0, 0, 0, 0, time.UTC))); err != nil {
+				t.Errorf("failed to append after replay: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewShipmentOfferRepository_WriteAheadLog(t *testing.T) {
+	dir := t.TempDir()
+	shipments := []domain.ShipmentUnit{
+		{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 200, Date: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 2, Price: 100, Date: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 150, Date: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 50, Date: time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 3, Price: 180, Date: time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 70, Date: time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)}}, // Duplicate date
+		{Origin: "NYC", Destination: "ROT", ShipmentQuote: domain.ShipmentQuote{Company: 3, Price: 900, Date: time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC), Equipment: domain.Equipment40RF}},
+	}
+
+	wal, err := OpenWriteAheadLog(WALConfig{Dir: dir, SyncPolicy: SyncAlways})
+	if err != nil {
+		t.Fatalf("failed to open write-ahead log: %v", err)
+	}
+	original, err := NewShipmentOfferRepository(context.Background(), 2, WithWriteAheadLog(wal))
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	original.now = func() time.Time { return time.Date(2003, 1, 1, 0, 0, 0, 0, time.UTC) }
+	for i, shipment := range shipments {
+		if err = original.AddOrUpdate(shipment); err != nil {
+			t.Fatalf("failed to add shipment: %v", err)
+		}
+		if i == 1 {
+			original.IncrementShipmentUnitsCount()
+		}
+	}
+	if err = original.RecordRejectedShipment(domain.ShipmentUnit{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1}}, domain.ErrInvalidPrice); err != nil {
+		t.Fatalf("failed to record rejected shipment: %v", err)
+	}
+	batch := []domain.ShipmentUnit{
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 4, Price: 120, Date: time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{Origin: "SIN", ShipmentQuote: domain.ShipmentQuote{Company: 5, Price: 300, Date: time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 4, Price: 110, Date: time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC)}}, // Duplicate date
+	}
+	if errs := original.AddOrUpdateAll(batch); !reflect.DeepEqual(errs, []error{nil, nil, domain.ErrDuplicateQuote}) {
+		t.Fatalf("failed to add shipment batch: %v", errs)
+	}
+	if deleted, err := original.DeleteCompanyQuotes("NYC", 3); err != nil || deleted != 2 {
+		t.Fatalf("failed to delete company quotes: %d, %v", deleted, err)
+	}
+	if err = wal.Close(); err != nil {
+		t.Fatalf("failed to close write-ahead log: %v", err)
+	}
+
+	wal, err = OpenWriteAheadLog(WALConfig{Dir: dir, SyncPolicy: SyncAlways})
+	if err != nil {
+		t.Fatalf("failed to reopen write-ahead log: %v", err)
+	}
+	defer wal.Close()
+	replayed, err := NewShipmentOfferRepository(context.Background(), 2, WithWriteAheadLog(wal))
+	if err != nil {
+		t.Fatalf("failed to replay repository: %v", err)
+	}
+
+	if !reflect.DeepEqual(replayed.copyShipments(), original.copyShipments()) {
+		t.Errorf("expected shipments %+v, got %+v", original.copyShipments(), replayed.copyShipments())
+	}
+	if !reflect.DeepEqual(replayed.loadBatch(), original.loadBatch()) {
+		t.Errorf("expected latest batch %+v, got %+v", original.loadBatch(), replayed.loadBatch())
+	}
+	if published := replayed.GetLatestBatch().PublishedAt; !published.Equal(original.GetLatestBatch().PublishedAt) {
+		t.Errorf("expected latest batch published at %v, got %v", original.GetLatestBatch().PublishedAt, published)
+	}
+	// The increment is only counted in memory, so it is lost without a snapshot
+	if replayed.shipmentCount != original.shipmentCount-1 {
+		t.Errorf("expected shipment count %d, got %d", original.shipmentCount-1, replayed.shipmentCount)
+	}
+	if history := replayed.GetQuoteHistory("LAX", 1); len(history) != 4 || !reflect.DeepEqual(history, original.GetQuoteHistory("LAX", 1)) {
+		t.Errorf("expected quote history %+v, got %+v", original.GetQuoteHistory("LAX", 1), history)
+	}
+	if history := replayed.GetQuoteHistory("NYC", 4); len(history) != 2 || !reflect.DeepEqual(history, original.GetQuoteHistory("NYC", 4)) {
+		t.Errorf("expected quote history %+v, got %+v", original.GetQuoteHistory("NYC", 4), history)
+	}
+	if history := replayed.GetQuoteHistory("NYC", 3); len(history) != 4 || !reflect.DeepEqual(history, original.GetQuoteHistory("NYC", 3)) {
+		t.Errorf("expected quote history %+v, got %+v", original.GetQuoteHistory("NYC", 3), history)
+	}
+}
+
+func TestNewShipmentOfferRepository_replayPublications(t *testing.T) {
+	first := domain.ShipmentUnit{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 200, Date: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}}
+	second := domain.ShipmentUnit{Origin: "LAX", ShipmentQuote: domain.ShipmentQuote{Company: 2, Price: 100, Date: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}}
+	receivedAt := time.Date(2003, 1, 1, 0, 0, 0, 0, time.UTC)
+	publishedAt := time.Date(2003, 1, 1, 0, 5, 0, 0, time.UTC)
+
+	tests := []struct {
+		name                string
+		records             []walRecord
+		expectedQuotes      int
+		expectedPublishedAt time.Time
+		expectedCount       int
+	}{
+		{
+			name:           "unpublished submissions",
+			records:        []walRecord{newShipmentRecord(first, receivedAt), newShipmentRecord(second, receivedAt.Add(time.Second))},
+			expectedQuotes: 0,
+			expectedCount:  2,
+		},
+		{
+			name: "publication triggered by the count",
+			records: []walRecord{
+				newShipmentRecord(first, receivedAt),
+				newShipmentRecord(second, receivedAt.Add(time.Second)),
+				newPublicationRecord(receivedAt.Add(time.Second)),
+			},
+			expectedQuotes:      2,
+			expectedPublishedAt: receivedAt.Add(time.Second),
+		},
+		{
+			name:                "publication triggered by the interval",
+			records:             []walRecord{newShipmentRecord(first, receivedAt), newPublicationRecord(publishedAt)},
+			expectedQuotes:      1,
+			expectedPublishedAt: publishedAt,
+		},
+		{
+			name: "publication triggered by a deletion",
+			records: []walRecord{
+				newShipmentRecord(first, receivedAt),
+				newDeletionRecord("LAX", 1, publishedAt),
+			},
+			expectedQuotes:      0,
+			expectedPublishedAt: publishedAt,
+			expectedCount:       1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			wal, err := OpenWriteAheadLog(WALConfig{Dir: dir, SyncPolicy: SyncAlways})
+			if err != nil {
+				t.Fatalf("failed to open write-ahead log: %v", err)
+			}
+			defer wal.Close()
+			for _, record := range tt.records {
+				if err = wal.Append(record); err != nil {
+					t.Fatalf("failed to append record: %v", err)
+				}
+			}
+
+			repository, err := NewShipmentOfferRepository(context.Background(), 2, WithWriteAheadLog(wal))
+			if err != nil {
+				t.Fatalf("failed to replay repository: %v", err)
+			}
+
+			batch := repository.GetLatestBatch()
+			var quotes int
+			for _, lane := range batch.ShipmentsByLane {
+				quotes += len(lane.Quotes)
+			}
+			if quotes != tt.expectedQuotes {
+				t.Errorf("expected %d published quotes, got %d", tt.expectedQuotes, quotes)
+			}
+			if !batch.PublishedAt.Equal(tt.expectedPublishedAt) {
+				t.Errorf("expected batch published at %v, got %v", tt.expectedPublishedAt, batch.PublishedAt)
+			}
+			if repository.shipmentCount != tt.expectedCount {
+				t.Errorf("expected shipment count %d, got %d", tt.expectedCount, repository.shipmentCount)
+			}
+		})
+	}
+}
+
+func TestNewShipmentOfferRepository_unsupportedRecordVersion(t *testing.T) {
+	wal, err := OpenWriteAheadLog(WALConfig{Dir: t.TempDir(), SyncPolicy: SyncAlways})
+	if err != nil {
+		t.Fatalf("failed to open write-ahead log: %v", err)
+	}
+	defer wal.Close()
+
+	record := newShipmentRecord(testingShipmentUnit, time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC))
+	record.Version = walRecordVersion + 1
+	if err = wal.Append(record); err != nil {
+		t.Fatalf("failed to append record: %v", err)
+	}
+
+	if _, err = NewShipmentOfferRepository(context.Background(), 2, WithWriteAheadLog(wal)); !errors.Is(err, ErrCorruptWALRecord) {
+		t.Errorf("expected error %v, got %v", ErrCorruptWALRecord, err)
+	}
+}
+
+// appendToFile appends data to the file stored at path.
+func appendToFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	if _, err = file.Write(data); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
+
+// flipLastByte corrupts the file stored at path by inverting its last byte.
+func flipLastByte(t *testing.T, path string) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	data[len(data)-1] ^= 0xFF
+
+	if err = os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
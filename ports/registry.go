@@ -0,0 +1,194 @@
+package ports
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // Validate the time zones of the ports without depending on the time zone database of the host
+
+	"quoteship/domain"
+)
+
+var (
+	ErrEmptyPortsPath  = errors.New("ports file path cannot be empty")
+	ErrInvalidPortData = errors.New("invalid port data")
+)
+
+// portColumns are the columns of a ports file, in the order of its header.
+var portColumns = []string{"code", "name", "country", "timezone", "active"}
+
+// bundledPorts is the ports file built into the binary, it lists the ports of the main shipping lanes.
+//
+//go:embed unlocode.csv
+var bundledPorts []byte
+
+// Registry is a domain.PortRegistry loaded from a UN/LOCODE ports file. The ports can be enabled and disabled at
+// runtime, the changes are kept in memory only, so the ports file is loaded as is on the next start.
+type Registry struct {
+	ports map[string]domain.Port // ports maps every UN/LOCODE to its port.
+	mu    sync.RWMutex           // mu synchronizes access to ports.
+}
+
+// Load reads a ports file from the reader. The file is a CSV file with the header "code,name,country,timezone,active"
+// and one port per row, lines starting with "#" are comments. Every code must be a UN/LOCODE of the country, every time
+// zone an IANA time zone, and every code must be listed once.
+func Load(reader io.Reader) (*Registry, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.Comment = '#'
+	csvReader.FieldsPerRecord = len(portColumns)
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPortData, err)
+	}
+	for i, column := range header {
+		if strings.TrimSpace(column) != portColumns[i] {
+			return nil, fmt.Errorf("%w: header must be %q", ErrInvalidPortData, strings.Join(portColumns, ","))
+		}
+	}
+
+	registry := &Registry{ports: make(map[string]domain.Port)}
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPortData, err)
+		}
+		line, _ := csvReader.FieldPos(0)
+
+		port, err := parsePort(record)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidPortData, line, err)
+		}
+		if _, exists := registry.ports[port.Code]; exists {
+			return nil, fmt.Errorf("%w: line %d: duplicate code %q", ErrInvalidPortData, line, port.Code)
+		}
+		registry.ports[port.Code] = port
+	}
+
+	return registry, nil
+}
+
+// LoadFile reads the ports file at path, see Load.
+func LoadFile(path string) (*Registry, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, ErrEmptyPortsPath
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open ports file: %w", err)
+	}
+	defer file.Close()
+
+	return Load(file)
+}
+
+// Bundled returns a new Registry of the ports file built into the binary. Every call returns a registry of its own, so
+// enabling or disabling a port only affects the registry it is done on. It panics if the bundled file is invalid, which
+// the tests of the package rule out.
+func Bundled() *Registry {
+	registry, err := Load(bytes.NewReader(bundledPorts))
+	if err != nil {
+		panic(fmt.Sprintf("ports: bundled ports file: %v", err))
+	}
+
+	return registry
+}
+
+// Port returns the port with the UN/LOCODE, and whether the registry lists it.
+func (r *Registry) Port(code string) (domain.Port, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	port, exists := r.ports[code]
+
+	return port, exists
+}
+
+// Ports returns every port of the registry, sorted by code.
+func (r *Registry) Ports() []domain.Port {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ports := make([]domain.Port, 0, len(r.ports))
+	for _, port := range r.ports {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Code < ports[j].Code })
+
+	return ports
+}
+
+// SetActive enables or disables the port with the UN/LOCODE and returns it, or domain.ErrUnknownPort if the registry
+// does not list it. The quotes already submitted for a disabled port are kept.
+func (r *Registry) SetActive(code string, active bool) (domain.Port, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	port, exists := r.ports[code]
+	if !exists {
+		return domain.Port{}, fmt.Errorf("%w: %q", domain.ErrUnknownPort, code)
+	}
+	port.Active = active
+	r.ports[code] = port
+
+	return port, nil
+}
+
+// parsePort parses a row of a ports file, with the fields in the order of portColumns.
+func parsePort(record []string) (domain.Port, error) {
+	for i := range record {
+		record[i] = strings.TrimSpace(record[i])
+	}
+	port := domain.Port{Code: record[0], Name: record[1], Country: record[2], Timezone: record[3]}
+
+	active, err := strconv.ParseBool(record[4])
+	switch {
+	case !ValidCode(port.Code):
+		return port, fmt.Errorf("code %q is not a UN/LOCODE", port.Code)
+	case port.Country != port.Code[:2]:
+		return port, fmt.Errorf("country %q does not match code %q", port.Country, port.Code)
+	case port.Name == "":
+		return port, fmt.Errorf("name of %q is required", port.Code)
+	case err != nil:
+		return port, fmt.Errorf("active flag %q of %q is not a boolean", record[4], port.Code)
+	}
+	if _, err := time.LoadLocation(port.Timezone); err != nil || port.Timezone == "" || port.Timezone == "Local" {
+		return port, fmt.Errorf("timezone %q of %q is not an IANA time zone", port.Timezone, port.Code)
+	}
+	port.Active = active
+
+	return port, nil
+}
+
+// ValidCode reports whether the code is a UN/LOCODE: two uppercase letters of the country followed by three uppercase
+// letters or digits (e.g., "NLRTM"). It does not tell whether the port is listed by a registry.
+func ValidCode(code string) bool {
+	if len(code) != 5 {
+		return false
+	}
+
+	for i, char := range code {
+		switch {
+		case char >= 'A' && char <= 'Z':
+		case char >= '0' && char <= '9' && i >= 2:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,210 @@
+package ports
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"quoteship/domain"
+)
+
+func TestLoad(t *testing.T) {
+	const header = "code,name,country,timezone,active\n"
+
+	tests := []struct {
+		name          string
+		data          string
+		expectedError error
+		expectedPorts []domain.Port
+	}{
+		{
+			name: "valid ports",
+			data: "# Comment\n" + header + "NLRTM, Rotterdam, NL, Europe/Amsterdam, true\nCNSGH,Shanghai,CN,Asia/Shanghai,false\n",
+			expectedPorts: []domain.Port{
+				{Code: "CNSGH", Name: "Shanghai", Country: "CN", Timezone: "Asia/Shanghai"},
+				{Code: "NLRTM", Name: "Rotterdam", Country: "NL", Timezone: "Europe/Amsterdam", Active: true},
+			},
+		},
+		{
+			name:          "header only",
+			data:          header,
+			expectedPorts: []domain.Port{},
+		},
+		{
+			name:          "empty file",
+			expectedError: ErrInvalidPortData,
+		},
+		{
+			name:          "invalid header",
+			data:          "code,name,country,zone,active\n",
+			expectedError: ErrInvalidPortData,
+		},
+		{
+			name:          "missing column",
+			data:          header + "NLRTM,Rotterdam,NL,Europe/Amsterdam\n",
+			expectedError: ErrInvalidPortData,
+		},
+		{
+			name:          "invalid code",
+			data:          header + "N1RTM,Rotterdam,NL,Europe/Amsterdam,true\n",
+			expectedError: ErrInvalidPortData,
+		},
+		{
+			name:          "country not matching the code",
+			data:          header + "NLRTM,Rotterdam,DE,Europe/Amsterdam,true\n",
+			expectedError: ErrInvalidPortData,
+		},
+		{
+			name:          "missing name",
+			data:          header + "NLRTM,,NL,Europe/Amsterdam,true\n",
+			expectedError: ErrInvalidPortData,
+		},
+		{
+			name:          "invalid timezone",
+			data:          header + "NLRTM,Rotterdam,NL,Europe/Rotterdam,true\n",
+			expectedError: ErrInvalidPortData,
+		},
+		{
+			name:          "missing timezone",
+			data:          header + "NLRTM,Rotterdam,NL,,true\n",
+			expectedError: ErrInvalidPortData,
+		},
+		{
+			name:          "invalid active flag",
+			data:          header + "NLRTM,Rotterdam,NL,Europe/Amsterdam,yes\n",
+			expectedError: ErrInvalidPortData,
+		},
+		{
+			name:          "duplicate code",
+			data:          header + "NLRTM,Rotterdam,NL,Europe/Amsterdam,true\nNLRTM,Rotterdam,NL,Europe/Amsterdam,false\n",
+			expectedError: ErrInvalidPortData,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, err := Load(strings.NewReader(tt.data))
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if ports := registry.Ports(); !reflect.DeepEqual(ports, tt.expectedPorts) {
+				t.Errorf("expected ports %+v, got %+v", tt.expectedPorts, ports)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.csv")
+	if err := os.WriteFile(path, []byte("code,name,country,timezone,active\nNLRTM,Rotterdam,NL,Europe/Amsterdam,true\n"), 0o644); err != nil {
+		t.Fatalf("failed to write ports file: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		expectedError error
+		expectedPorts int
+	}{
+		{
+			name:          "valid file",
+			path:          path,
+			expectedPorts: 1,
+		},
+		{
+			name:          "empty path",
+			path:          " ",
+			expectedError: ErrEmptyPortsPath,
+		},
+		{
+			name:          "missing file",
+			path:          filepath.Join(t.TempDir(), "missing.csv"),
+			expectedError: os.ErrNotExist,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, err := LoadFile(tt.path)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if err == nil && len(registry.Ports()) != tt.expectedPorts {
+				t.Errorf("expected %d ports, got %d", tt.expectedPorts, len(registry.Ports()))
+			}
+		})
+	}
+}
+
+func TestBundled(t *testing.T) {
+	registry := Bundled()
+
+	// The origins accepted before the registry existed are active
+	for _, code := range []string{"CNSGH", "SGSIN", "CNSNZ", "CNNBO", "CNGGZ"} {
+		if port, exists := registry.Port(code); !exists || !port.Active {
+			t.Errorf("expected active port %q, got %+v", code, port)
+		}
+	}
+
+	// Every call returns a registry of its own
+	if _, err := registry.SetActive("CNSGH", false); err != nil {
+		t.Fatalf("failed to disable port: %v", err)
+	}
+	if port, _ := Bundled().Port("CNSGH"); !port.Active {
+		t.Errorf("expected port of a new registry to be active")
+	}
+}
+
+func TestRegistry_SetActive(t *testing.T) {
+	registry, err := Load(strings.NewReader("code,name,country,timezone,active\nNLRTM,Rotterdam,NL,Europe/Amsterdam,true\n"))
+	if err != nil {
+		t.Fatalf("failed to load ports: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		code          string
+		active        bool
+		expectedError error
+		expectedPort  domain.Port
+	}{
+		{
+			name:         "disable port",
+			code:         "NLRTM",
+			expectedPort: domain.Port{Code: "NLRTM", Name: "Rotterdam", Country: "NL", Timezone: "Europe/Amsterdam"},
+		},
+		{
+			name:         "enable port",
+			code:         "NLRTM",
+			active:       true,
+			expectedPort: domain.Port{Code: "NLRTM", Name: "Rotterdam", Country: "NL", Timezone: "Europe/Amsterdam", Active: true},
+		},
+		{
+			name:          "unknown port",
+			code:          "DEHAM",
+			expectedError: domain.ErrUnknownPort,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port, err := registry.SetActive(tt.code, tt.active)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if port != tt.expectedPort {
+				t.Errorf("expected port %+v, got %+v", tt.expectedPort, port)
+			}
+			if stored, _ := registry.Port(tt.code); stored != tt.expectedPort {
+				t.Errorf("expected stored port %+v, got %+v", tt.expectedPort, stored)
+			}
+		})
+	}
+}
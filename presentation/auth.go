@@ -0,0 +1,125 @@
+package presentation
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"quoteship/domain"
+)
+
+const (
+	apiKeyHeader = "X-API-Key" // apiKeyHeader is the request header carrying the API key, next to the "Authorization: Bearer" header.
+	bearerScheme = "Bearer"    // bearerScheme is the authentication scheme of the API keys sent with the Authorization header.
+)
+
+var (
+	ErrUnauthenticated = errors.New("missing or invalid API key")
+	ErrForbidden       = errors.New("API key not allowed to make the request")
+	ErrCompanyMismatch = errors.New("company does not match the API key")
+)
+
+// principalContextKey is the key of the authenticated domain.Principal in the context of a request.
+type principalContextKey struct{}
+
+// WithAPIKeys makes every request, except those for an unknown resource, be authenticated with an API key of the store,
+// sent with the "X-API-Key" or the "Authorization: Bearer" header. The consumer keys can only read, the submitter keys
+// can also submit and delete the quotes of their company, and the admin keys can make every request. Without store,
+// every request is accepted.
+func WithAPIKeys(store domain.APIKeyStore) HandlerOption {
+	return func(h *ShipmentHandler) {
+		h.keys = store
+	}
+}
+
+// authenticate is the authentication middleware of a route, it calls next with the authenticated domain.Principal in
+// the context of the request when the API key of the request has the role, or a role that allows it. It responds with
+// 401 Unauthorized when the API key is missing or unknown, and with 403 Forbidden when its role is not allowed. Every
+// request is passed to next without API key store.
+func (h ShipmentHandler) authenticate(role domain.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if h.keys == nil {
+			next(writer, request)
+			return
+		}
+
+		key := requestAPIKey(request)
+		if key == "" {
+			writer.Header().Set("WWW-Authenticate", bearerScheme)
+			writeJSONResponse(writer, http.StatusUnauthorized, map[string]string{"error": ErrUnauthenticated.Error()})
+			return
+		}
+
+		principal, err := h.keys.Authenticate(key)
+		if err != nil {
+			slog.Warn("invalid API key", "method", request.Method, "path", request.URL.Path, "error", err)
+			writer.Header().Set("WWW-Authenticate", bearerScheme)
+			writeJSONResponse(writer, http.StatusUnauthorized, map[string]string{"error": ErrUnauthenticated.Error()})
+			return
+		}
+
+		if !principal.Role.Allows(role) {
+			slog.Warn("API key not allowed", "key", principal.Name, "role", principal.Role, "method", request.Method, "path", request.URL.Path)
+			writeJSONResponse(writer, http.StatusForbidden, map[string]string{"error": ErrForbidden.Error()})
+			return
+		}
+
+		next(writer, request.WithContext(context.WithValue(request.Context(), principalContextKey{}, principal)))
+	}
+}
+
+// authorizeCompanies reports whether the principal of the request can submit and delete the quotes of every company,
+// and responds with 403 Forbidden otherwise. The requests that were not authenticated are authorized, they are only
+// accepted without API key store.
+func authorizeCompanies(writer http.ResponseWriter, request *http.Request, companies ...int) bool {
+	principal, authenticated := requestPrincipal(request)
+	if !authenticated {
+		return true
+	}
+
+	for _, company := range companies {
+		if !principal.CanSubmitFor(company) {
+			slog.Warn("company does not match the API key", "key", principal.Name, "key_company", principal.Company, "company", company)
+			writeJSONResponse(writer, http.StatusForbidden, map[string]string{"error": ErrCompanyMismatch.Error()})
+			return false
+		}
+	}
+
+	return true
+}
+
+// requestPrincipal returns the domain.Principal the request was authenticated as, or false if it was not authenticated.
+func requestPrincipal(request *http.Request) (domain.Principal, bool) {
+	principal, authenticated := request.Context().Value(principalContextKey{}).(domain.Principal)
+
+	return principal, authenticated
+}
+
+// requestAPIKey returns the API key of the request, from the "X-API-Key" header or else from the "Authorization:
+// Bearer" header, or an empty string if the request has none.
+func requestAPIKey(request *http.Request) string {
+	if key := strings.TrimSpace(request.Header.Get(apiKeyHeader)); key != "" {
+		return key
+	}
+
+	scheme, credentials, found := strings.Cut(request.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, bearerScheme) {
+		return ""
+	}
+
+	return strings.TrimSpace(credentials)
+}
+
+// batchCompanies returns the companies of the decoded shipment offers of a batch.
+func batchCompanies(elements []batchElement) []int {
+	companies := make([]int, 0, len(elements))
+	for _, element := range elements {
+		if element.err == nil {
+			companies = append(companies, element.offer.Company)
+		}
+	}
+
+	return companies
+}
@@ -0,0 +1,201 @@
+package presentation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"quoteship/app"
+	"quoteship/persistence"
+)
+
+func TestShipmentHandler_authenticate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	content := `{"keys": [
+		{"name": "dashboard", "sha256": "` + persistence.HashAPIKey("qs_consumer") + `", "role": "consumer"},
+		{"name": "blue-whale-ci", "sha256": "` + persistence.HashAPIKey("qs_submitter") + `", "role": "submitter", "company": 1},
+		{"name": "operations", "sha256": "` + persistence.HashAPIKey("qs_admin") + `", "role": "admin"}
+	]}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write API keys file: %v", err)
+	}
+	keys, err := persistence.OpenAPIKeyStore(path)
+	if err != nil {
+		t.Fatalf("failed to open API key store: %v", err)
+	}
+	shipmentRepository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	shipmentService, err := app.CreateShipmentService(shipmentRepository)
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, shipmentService, WithAPIKeys(keys))
+
+	unauthenticated := `{"error":"` + ErrUnauthenticated.Error() + `"}` + "\n"
+	forbidden := `{"error":"` + ErrForbidden.Error() + `"}` + "\n"
+	companyMismatch := `{"error":"` + ErrCompanyMismatch.Error() + `"}` + "\n"
+
+	// The steps run in order, each one sees the quotes submitted and deleted by the previous ones
+	tests := []struct {
+		name              string
+		method            string
+		path              string
+		headers           map[string]string
+		body              string
+		expectedStatus    int
+		expectedBody      string
+		expectedChallenge string
+	}{
+		{
+			name:              "missing API key",
+			method:            http.MethodGet,
+			path:              "/v1/origins/CNSGH/quotes",
+			expectedStatus:    http.StatusUnauthorized,
+			expectedBody:      unauthenticated,
+			expectedChallenge: "Bearer",
+		},
+		{
+			name:              "unknown API key",
+			method:            http.MethodGet,
+			path:              "/v1/origins/CNSGH/quotes",
+			headers:           map[string]string{"X-API-Key": "qs_unknown"},
+			expectedStatus:    http.StatusUnauthorized,
+			expectedBody:      unauthenticated,
+			expectedChallenge: "Bearer",
+		},
+		{
+			name:              "other authentication scheme",
+			method:            http.MethodGet,
+			path:              "/v1/origins/CNSGH/quotes",
+			headers:           map[string]string{"Authorization": "Basic qs_consumer"},
+			expectedStatus:    http.StatusUnauthorized,
+			expectedBody:      unauthenticated,
+			expectedChallenge: "Bearer",
+		},
+		{
+			name:           "consumer reads quotes",
+			method:         http.MethodGet,
+			path:           "/v1/origins/CNSGH/quotes",
+			headers:        map[string]string{"X-API-Key": "qs_consumer"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"origin":"CNSGH","total":0,"limit":20,"offset":0,"sort":"price","quotes":[]}` + "\n",
+		},
+		{
+			name:           "consumer cannot submit",
+			method:         http.MethodPost,
+			path:           "/v1/quotes",
+			headers:        map[string]string{"X-API-Key": "qs_consumer", "Content-Type": "application/json"},
+			body:           `{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}`,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   forbidden,
+		},
+		{
+			name:           "submitter cannot submit for another company",
+			method:         http.MethodPost,
+			path:           "/v1/quotes",
+			headers:        map[string]string{"X-API-Key": "qs_submitter", "Content-Type": "application/json"},
+			body:           `{"company": 2, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}`,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   companyMismatch,
+		},
+		{
+			name:           "submitter submits for its company with a bearer token",
+			method:         http.MethodPost,
+			path:           "/",
+			headers:        map[string]string{"Authorization": "Bearer qs_submitter", "Content-Type": "application/json"},
+			body:           `{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "submitter cannot submit a batch with another company",
+			method:         http.MethodPost,
+			path:           "/quotes:batch",
+			headers:        map[string]string{"X-API-Key": "qs_submitter", "Content-Type": "application/json"},
+			body:           `[{"company": 1, "price": 90, "origin": "CNSGH", "date": "2023-01-02"}, {"company": 2, "price": 80, "origin": "CNSGH", "date": "2023-01-02"}]`,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   companyMismatch,
+		},
+		{
+			name:           "submitter cannot import a tariff with another company",
+			method:         http.MethodPost,
+			path:           "/quotes:import",
+			headers:        map[string]string{"X-API-Key": "qs_submitter", "Content-Type": "text/csv"},
+			body:           "company,price,origin,date\n2,80,CNSGH,2023-01-02\n",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   companyMismatch,
+		},
+		{
+			name:           "submitter reads quotes",
+			method:         http.MethodGet,
+			path:           "/v1/origins/CNSGH/quotes",
+			headers:        map[string]string{"X-API-Key": "qs_submitter"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"origin":"CNSGH","total":1,"limit":20,"offset":0,"sort":"price","quotes":[{"destination":"*","equipment":"20DV","company":1,"price":100,"date":"2023-01-01"}]}` + "\n",
+		},
+		{
+			name:           "submitter cannot call the admin routes",
+			method:         http.MethodGet,
+			path:           "/admin/outliers",
+			headers:        map[string]string{"X-API-Key": "qs_submitter"},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   forbidden,
+		},
+		{
+			name:           "submitter cannot delete the quotes of another company",
+			method:         http.MethodDelete,
+			path:           "/v1/origins/CNSGH/companies/2",
+			headers:        map[string]string{"X-API-Key": "qs_submitter"},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   companyMismatch,
+		},
+		{
+			name:           "admin deletes the quotes of any company",
+			method:         http.MethodDelete,
+			path:           "/v1/origins/CNSGH/companies/1",
+			headers:        map[string]string{"X-API-Key": "qs_admin"},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "unknown resource not authenticated",
+			method:         http.MethodGet,
+			path:           "/v1/unknown",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"` + ErrNotFound.Error() + `"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			for header, value := range tt.headers {
+				req.Header.Set(header, value)
+			}
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			// Check the status code
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			// Check the response body
+			if rec.Body.String() != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, rec.Body.String())
+			}
+
+			// Check the authentication challenge
+			if got := rec.Header().Get("WWW-Authenticate"); got != tt.expectedChallenge {
+				t.Errorf("expected WWW-Authenticate header %q, got %q", tt.expectedChallenge, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package presentation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"quoteship/domain"
+)
+
+const (
+	MaxBatchSize = 10000 // MaxBatchSize is the largest number of shipment offers a batch submission can contain.
+
+	ndjsonContentType  = "application/x-ndjson" // ndjsonContentType is the media type of a batch submitted as newline-delimited JSON, one shipment offer per line.
+	maxNDJSONLineBytes = 64 * 1024              // maxNDJSONLineBytes is the largest size of a line of a newline-delimited JSON batch.
+)
+
+var (
+	ErrBatchTooLarge = errors.New("batch too large")
+)
+
+// batchSubmissionResponse is the response payload of the batch submission endpoint.
+type batchSubmissionResponse struct {
+	Accepted int               `json:"accepted"` // Accepted is the number of shipment offers that were stored.
+	Rejected int               `json:"rejected"` // Rejected is the number of shipment offers that were discarded.
+	Results  []batchItemResult `json:"results"`  // Results lists the outcome of every shipment offer, in the order they were submitted.
+}
+
+// batchItemResult is the outcome of a single shipment offer of the batch submission endpoint response payload.
+type batchItemResult struct {
+	Index  int          `json:"index"`            // Index is the position of the shipment offer in the batch, starting at 0. Blank lines of a newline-delimited batch are not counted.
+	Line   int          `json:"line,omitempty"`   // Line is the line the shipment offer starts on in a CSV tariff, starting at 1. It is omitted for JSON batches.
+	Status string       `json:"status"`           // Status is the outcome of the shipment offer, "accepted" or "rejected".
+	Reason string       `json:"reason,omitempty"` // Reason explains why the shipment offer was rejected.
+	Errors []fieldError `json:"errors,omitempty"` // Errors lists every invalid field of a rejected shipment offer, like the strict validation of a single offer.
+}
+
+// batchElement is a shipment offer decoded from a batch, or the reason it could not be decoded.
+type batchElement struct {
+	offer requestedShipmentOffer // offer is the decoded shipment offer.
+	err   error                  // err is the reason the element is not a shipment offer, nil when it was decoded.
+	line  int                    // line is the line the element starts on in a CSV tariff, zero for JSON batches.
+}
+
+// SubmitShipmentOffers is an HTTP handler that submits many shipment offers at once. The body is either a JSON array of
+// shipment offers, with "Content-Type: application/json", or a newline-delimited JSON stream of shipment offers, with
+// "Content-Type: application/x-ndjson". Every shipment offer is validated like the ones submitted one at a time, and
+// the valid ones are stored together. The handler returns 200 OK with the outcome of every shipment offer, accepted or
+// rejected with the reason and the invalid fields, even if some of them were rejected. A body that cannot be read as a
+// batch is rejected with 400 Bad Request, and a batch of more than MaxBatchSize shipment offers with 413 Request Entity
+// Too Large. With request signing, the signature of the batch is verified like the one of a single shipment offer, and
+// the whole batch is rejected if any of its shipment offers cannot be accepted from the company that signed it.
+func (h ShipmentHandler) SubmitShipmentOffers(writer http.ResponseWriter, request *http.Request) {
+	// Defer closing the request body after the function returns
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.Error("error closing request body", "error", err)
+		}
+	}(request.Body)
+
+	// Verify the signature of the request before decoding it
+	signer, verified := h.verifySignature(writer, request)
+	if !verified {
+		return
+	}
+
+	var decode func(io.Reader) ([]batchElement, error)
+	mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		decode = decodeJSONBatch
+	case ndjsonContentType:
+		decode = decodeNDJSONBatch
+	default:
+		slog.Warn("invalid content type", "content-type", request.Header.Get("Content-Type"))
+		writeJSONResponse(writer, http.StatusUnsupportedMediaType, map[string]string{"error": ErrInvalidContentType.Error()})
+		return
+	}
+
+	elements, err := decode(request.Body)
+	if errors.Is(err, ErrBatchTooLarge) {
+		writeJSONResponse(writer, http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		slog.Error("error decoding request payload", "error", err)
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": ErrInvalidRequestPayload.Error()})
+		return
+	}
+
+	// Reject the whole batch if any of its shipment offers is of another company than the one of the API key
+	if !authorizeCompanies(writer, request, batchCompanies(elements)...) {
+		return
+	}
+
+	// Reject the whole batch if it is signed by another company than the one of any of its shipment offers, or if it is
+	// unsigned and any of them is of a company that signs its offers
+	if !h.authorizeSigner(writer, signer, batchCompanies(elements)...) {
+		return
+	}
+
+	writeJSONResponse(writer, http.StatusOK, h.submitBatch(elements))
+}
+
+// submitBatch validates the decoded shipment offers, submits the valid ones to the service layer at once and returns
+// the outcome of every shipment offer. Invalid shipment offers are recorded in the audit trail of their company, like
+// the ones submitted one at a time.
+func (h ShipmentHandler) submitBatch(elements []batchElement) batchSubmissionResponse {
+	response := batchSubmissionResponse{Results: make([]batchItemResult, len(elements))}
+
+	// Only the valid shipment offers are submitted, their indices map the outcomes back
+	shipments := make([]domain.ShipmentUnit, 0, len(elements))
+	indices := make([]int, 0, len(elements))
+	for i, element := range elements {
+		response.Results[i] = batchItemResult{Index: i, Line: element.line, Status: string(domain.AuditAccepted)}
+		if element.err != nil {
+			response.Results[i].Status, response.Results[i].Reason = string(domain.AuditRejected), element.err.Error()
+			continue
+		}
+
+		shipment, err := h.validateAndParseShipment(element.offer)
+		if err != nil {
+			h.rejectShipment(rejectedShipment(element.offer), err)
+			response.Results[i].Status, response.Results[i].Reason = string(domain.AuditRejected), err.Error()
+			response.Results[i].Errors = h.validateShipmentOffer(element.offer)
+			continue
+		}
+
+		shipments = append(shipments, shipment)
+		indices = append(indices, i)
+	}
+
+	if len(shipments) > 0 {
+		for j, err := range h.s.SubmitShipments(shipments) {
+			result := &response.Results[indices[j]]
+			invalidField, rejected := serviceFieldError(shipments[j], err)
+			switch {
+			case err == nil:
+				continue
+			case rejected:
+				// Offers rejected by the service layer are rejected like the invalid ones
+				h.rejectShipment(&shipments[j], invalidField.err)
+				result.Errors = []fieldError{invalidField}
+				err = invalidField.err
+			case errors.Is(err, domain.ErrDuplicateQuote):
+				// Duplicates are already recorded in the audit trail by the repository
+			default:
+				slog.Error("error adding shipment offer", "error", err)
+				err = ErrIntervalServerError
+			}
+			result.Status, result.Reason = string(domain.AuditRejected), err.Error()
+		}
+	}
+
+	for _, result := range response.Results {
+		if result.Status == string(domain.AuditAccepted) {
+			response.Accepted++
+		} else {
+			response.Rejected++
+		}
+	}
+
+	return response
+}
+
+// decodeJSONBatch decodes a JSON array of shipment offers. An element that is valid JSON but not a shipment offer,
+// e.g., a string, is returned with ErrInvalidRequestPayload, while invalid JSON fails the whole batch.
+func decodeJSONBatch(reader io.Reader) ([]batchElement, error) {
+	decoder := json.NewDecoder(reader)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, ErrInvalidRequestPayload
+	}
+
+	var elements []batchElement
+	for decoder.More() {
+		if len(elements) == MaxBatchSize {
+			return nil, ErrBatchTooLarge
+		}
+
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+		elements = append(elements, decodeBatchElement(raw))
+	}
+
+	// The array must be closed and be the only value of the body
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, ErrInvalidRequestPayload
+	}
+
+	return elements, nil
+}
+
+// decodeNDJSONBatch decodes a newline-delimited JSON stream of shipment offers, skipping the blank lines. A line that
+// is not a shipment offer is returned with ErrInvalidRequestPayload, the other lines are still decoded.
+func decodeNDJSONBatch(reader io.Reader) ([]batchElement, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxNDJSONLineBytes)
+
+	var elements []batchElement
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(elements) == MaxBatchSize {
+			return nil, ErrBatchTooLarge
+		}
+		elements = append(elements, decodeBatchElement(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return elements, nil
+}
+
+// decodeBatchElement decodes a single shipment offer of a batch.
+func decodeBatchElement(data []byte) batchElement {
+	var element batchElement
+	if err := json.Unmarshal(data, &element.offer); err != nil {
+		slog.Debug("error decoding batch element", "error", err)
+		element.err = ErrInvalidRequestPayload
+	}
+
+	return element
+}
@@ -0,0 +1,201 @@
+package presentation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"quoteship/app"
+	"quoteship/domain"
+	"quoteship/persistence"
+)
+
+func TestShipmentHandler_SubmitShipmentOffers(t *testing.T) {
+	tests := []struct {
+		name             string
+		contentType      string
+		body             string
+		expectedStatus   int
+		expectedBody     string
+		expectedResponse *batchSubmissionResponse
+		expectedRates    map[string]int
+	}{
+		{
+			name:           "Invalid Content-Type",
+			contentType:    "text/plain",
+			body:           `[]`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", ErrInvalidContentType.Error()),
+		},
+		{
+			name:           "Invalid JSON",
+			contentType:    "application/json",
+			body:           `[{"company": 1,`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", ErrInvalidRequestPayload.Error()),
+		},
+		{
+			name:           "Not a JSON array",
+			contentType:    "application/json",
+			body:           `{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", ErrInvalidRequestPayload.Error()),
+		},
+		{
+			name:           "Trailing data after the JSON array",
+			contentType:    "application/json",
+			body:           `[] []`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", ErrInvalidRequestPayload.Error()),
+		},
+		{
+			name:           "Too many shipment offers",
+			contentType:    "application/json",
+			body:           "[" + strings.Repeat("{},", MaxBatchSize) + "{}]",
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", ErrBatchTooLarge.Error()),
+		},
+		{
+			name:             "Empty JSON array",
+			contentType:      "application/json",
+			body:             `[]`,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: &batchSubmissionResponse{Results: []batchItemResult{}},
+		},
+		{
+			name:        "JSON array",
+			contentType: "application/json; charset=utf-8",
+			body: `[
+				{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-01"},
+				{"company": 2, "price": 0, "origin": "CNSGH", "date": "2023-01-01"},
+				"not a shipment offer",
+				{"company": 1, "price": 150, "origin": "CNSGH", "date": "2023-01-01"},
+				{"company": 3, "price": 300, "origin": "CNSGH", "date": "2023-01-01", "currency": "EUR"},
+				{"company": 2, "price": "200.00", "origin": "CNSGH", "date": "2023-01-01"}
+			]`,
+			expectedStatus: http.StatusOK,
+			expectedResponse: &batchSubmissionResponse{
+				Accepted: 2,
+				Rejected: 4,
+				Results: []batchItemResult{
+					{Index: 0, Status: "accepted"},
+					{Index: 1, Status: "rejected", Reason: domain.ErrInvalidPrice.Error(), Errors: []fieldError{
+						{Field: "price", Code: CodeOutOfRange, Message: "price must be between 1 and 99999"},
+					}},
+					{Index: 2, Status: "rejected", Reason: ErrInvalidRequestPayload.Error()},
+					{Index: 3, Status: "rejected", Reason: domain.ErrDuplicateQuote.Error()},
+					{Index: 4, Status: "rejected", Reason: domain.ErrUnsupportedCurrency.Error(), Errors: []fieldError{
+						{Field: "currency", Code: CodeUnsupported, Message: `currency "EUR" has no exchange rate`},
+					}},
+					{Index: 5, Status: "accepted"},
+				},
+			},
+			expectedRates: map[string]int{"CNSGH": 15000}, // In minor units, the mean of 100 and 200
+		},
+		{
+			name:        "Newline-delimited JSON",
+			contentType: ndjsonContentType,
+			body: `{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}
+
+{"company": 2, "price": 300, "origin": "SGSIN", "date": "2023-01-01"}
+{"company": 3, "price": 300,
+{"company": 4, "price": 100, "origin": "NYC", "date": "2023-01-01"}
+`,
+			expectedStatus: http.StatusOK,
+			expectedResponse: &batchSubmissionResponse{
+				Accepted: 2,
+				Rejected: 2,
+				Results: []batchItemResult{
+					{Index: 0, Status: "accepted"},
+					{Index: 1, Status: "accepted"},
+					{Index: 2, Status: "rejected", Reason: ErrInvalidRequestPayload.Error()},
+					{Index: 3, Status: "rejected", Reason: domain.ErrInvalidOriginPort.Error(), Errors: []fieldError{
+						{Field: "origin", Code: CodeInvalidFormat, Message: "origin must be a UN/LOCODE of five uppercase letters or digits"},
+					}},
+				},
+			},
+			expectedRates: map[string]int{"CNSGH": 10000, "SGSIN": 30000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shipmentRepository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+			if err != nil {
+				t.Fatalf("failed to create shipment repository: %v", err)
+			}
+			shipmentService, err := app.CreateShipmentService(shipmentRepository)
+			if err != nil {
+				t.Fatalf("failed to create shipment service: %v", err)
+			}
+			handler := CreateShipmentHandler(shipmentService)
+
+			req := httptest.NewRequest(http.MethodPost, "/quotes:batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+
+			rec := httptest.NewRecorder()
+			handler.SubmitShipmentOffers(rec, req)
+
+			// Check the status code
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			// Check the response body
+			if tt.expectedResponse == nil {
+				if rec.Body.String() != tt.expectedBody {
+					t.Errorf("expected body %q, got %q", tt.expectedBody, rec.Body.String())
+				}
+				return
+			}
+
+			var response batchSubmissionResponse
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response body: %v", err)
+			}
+			if !reflect.DeepEqual(&response, tt.expectedResponse) {
+				t.Errorf("expected response %+v, got %+v", tt.expectedResponse, response)
+			}
+
+			// Check the accepted shipment offers were stored, every submission publishes a batch
+			if tt.expectedRates != nil {
+				rates, err := shipmentService.GetLatestExpectedRates(10)
+				if err != nil {
+					t.Fatalf("failed to get expected rates: %v", err)
+				}
+				if !reflect.DeepEqual(rates, tt.expectedRates) {
+					t.Errorf("expected rates %v, got %v", tt.expectedRates, rates)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterRoutes_batch(t *testing.T) {
+	shipmentRepository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	shipmentService, err := app.CreateShipmentService(shipmentRepository)
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, shipmentService)
+
+	req := httptest.NewRequest(http.MethodPost, "/quotes:batch", strings.NewReader(`[{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}]`))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if expected := `{"accepted":1,"rejected":0,"results":[{"index":0,"status":"accepted"}]}` + "\n"; rec.Body.String() != expected {
+		t.Errorf("expected body %q, got %q", expected, rec.Body.String())
+	}
+}
@@ -0,0 +1,160 @@
+package presentation
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"quoteship/domain"
+)
+
+var (
+	ErrNoCompanyRegistry = errors.New("company registry not configured")
+)
+
+// companiesResponse is the response payload of the companies endpoint, it lists the companies of the registry.
+type companiesResponse struct {
+	Companies []companyResponse `json:"companies"` // Companies lists the companies, sorted by identifier.
+}
+
+// companyResponse is a single company of the company registry.
+type companyResponse struct {
+	ID          int    `json:"id"`          // ID is the identifier of the company, the company of its quotes.
+	Name        string `json:"name"`        // Name is the display name of the company.
+	Status      string `json:"status"`      // Status is "active" or "suspended".
+	OnboardedAt string `json:"onboardedAt"` // OnboardedAt is the date the company was onboarded, in the format "YYYY-MM-DD".
+}
+
+// requestedCompany is the request payload of the company registration endpoint.
+type requestedCompany struct {
+	Name        string `json:"name"`                  // Name is the display name of the company, it is required.
+	Status      string `json:"status,omitempty"`      // Status is the optional status of the company, "active" when omitted.
+	OnboardedAt string `json:"onboardedAt,omitempty"` // OnboardedAt is the optional onboarding date, in the format "YYYY-MM-DD", today when omitted.
+}
+
+// requestedCompanyUpdate is the request payload of the company update endpoint.
+type requestedCompanyUpdate struct {
+	Status string `json:"status"` // Status is the new status of the company, "active" or "suspended".
+}
+
+// GetCompanies is an HTTP handler that lists the companies of the company registry, sorted by identifier, e.g.,
+// {"companies": [{"id": 1, "name": "Blue Whale Lines", "status": "active", "onboardedAt": "2023-01-15"}]}. The handler
+// returns 404 Not Found when no company registry is configured.
+func (h ShipmentHandler) GetCompanies(writer http.ResponseWriter, _ *http.Request) {
+	if h.companies == nil {
+		writeJSONResponse(writer, http.StatusNotFound, map[string]string{"error": ErrNoCompanyRegistry.Error()})
+		return
+	}
+
+	companies := h.companies.Companies()
+
+	response := companiesResponse{Companies: make([]companyResponse, 0, len(companies))}
+	for _, company := range companies {
+		response.Companies = append(response.Companies, newCompanyResponse(company))
+	}
+
+	writeJSONResponse(writer, http.StatusOK, response)
+}
+
+// RegisterCompany is an HTTP handler that registers the company given by the {company} path value, or replaces it if it
+// is already registered. It expects a JSON payload with the `name` of the company, and optionally its `status` and
+// `onboardedAt` date, e.g., {"name": "Blue Whale Lines", "onboardedAt": "2023-01-15"}, and returns the registered
+// company. The handler returns 404 Not Found when no company registry is configured.
+func (h ShipmentHandler) RegisterCompany(writer http.ResponseWriter, request *http.Request) {
+	id, ok := h.companyRequest(writer, request)
+	if !ok {
+		return
+	}
+
+	var requested requestedCompany
+	if err := json.NewDecoder(request.Body).Decode(&requested); err != nil {
+		slog.Error("error decoding request payload", "error", err)
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": ErrInvalidRequestPayload.Error()})
+		return
+	}
+
+	company := domain.Company{ID: id, Name: requested.Name, Status: domain.CompanyStatus(requested.Status)}
+	if requested.OnboardedAt != "" {
+		onboardedAt, err := time.Parse(dateFormat, requested.OnboardedAt)
+		if err != nil {
+			writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": domain.ErrInvalidDate.Error()})
+			return
+		}
+		company.OnboardedAt = onboardedAt
+	}
+
+	company, err := h.companies.Register(company)
+	writeCompanyResponse(writer, company, err)
+}
+
+// UpdateCompany is an HTTP handler that activates or suspends the company given by the {company} path value. It expects
+// a JSON payload with the new `status`, e.g., {"status": "suspended"}, and returns the updated company. The shipment
+// offers of a suspended company are rejected from then on, the quotes it already submitted are kept. The handler
+// returns 404 Not Found if the registry does not list the company or when no company registry is configured.
+func (h ShipmentHandler) UpdateCompany(writer http.ResponseWriter, request *http.Request) {
+	id, ok := h.companyRequest(writer, request)
+	if !ok {
+		return
+	}
+
+	var update requestedCompanyUpdate
+	if err := json.NewDecoder(request.Body).Decode(&update); err != nil {
+		slog.Error("error decoding request payload", "error", err)
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": ErrInvalidRequestPayload.Error()})
+		return
+	}
+
+	company, err := h.companies.SetStatus(id, domain.CompanyStatus(update.Status))
+	writeCompanyResponse(writer, company, err)
+}
+
+// companyRequest checks the company registry, the content type and the {company} path value of a request changing a
+// company. It returns the identifier of the company, or false once the error response is written.
+func (h ShipmentHandler) companyRequest(writer http.ResponseWriter, request *http.Request) (int, bool) {
+	if h.companies == nil {
+		writeJSONResponse(writer, http.StatusNotFound, map[string]string{"error": ErrNoCompanyRegistry.Error()})
+		return 0, false
+	}
+
+	// Check request headers for Content-Type and validate it is application/json
+	if !strings.HasPrefix(request.Header.Get("Content-Type"), "application/json") {
+		slog.Warn("invalid content type", "content-type", request.Header.Get("Content-Type"))
+		writeJSONResponse(writer, http.StatusUnsupportedMediaType, map[string]string{"error": ErrInvalidContentType.Error()})
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(request.PathValue("company"))
+	if err != nil || id < MinCompanyID || id > MaxCompanyID {
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": domain.ErrInvalidCompany.Error()})
+		return 0, false
+	}
+
+	return id, true
+}
+
+// writeCompanyResponse writes the company changed in the registry, or the error response of the change.
+func writeCompanyResponse(writer http.ResponseWriter, company domain.Company, err error) {
+	switch {
+	case errors.Is(err, domain.ErrUnknownCompany):
+		writeJSONResponse(writer, http.StatusNotFound, map[string]string{"error": domain.ErrUnknownCompany.Error()})
+	case errors.Is(err, domain.ErrInvalidCompany):
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": domain.ErrInvalidCompany.Error()})
+	case errors.Is(err, domain.ErrInvalidCompanyStatus):
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": domain.ErrInvalidCompanyStatus.Error()})
+	case err != nil:
+		slog.Error("error updating company", "error", err)
+		writeJSONResponse(writer, http.StatusInternalServerError, map[string]string{"error": ErrIntervalServerError.Error()})
+	default:
+		slog.Info("company updated", "company", company.ID, "status", company.Status)
+		writeJSONResponse(writer, http.StatusOK, newCompanyResponse(company))
+	}
+}
+
+// newCompanyResponse converts a company of the registry to its response payload.
+func newCompanyResponse(company domain.Company) companyResponse {
+	return companyResponse{ID: company.ID, Name: company.Name, Status: string(company.Status), OnboardedAt: formatDate(company.OnboardedAt)}
+}
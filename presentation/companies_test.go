@@ -0,0 +1,205 @@
+package presentation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"quoteship/app"
+	"quoteship/domain"
+	"quoteship/persistence"
+)
+
+func TestShipmentHandler_companies(t *testing.T) {
+	registry, err := persistence.OpenCompanyRegistry(filepath.Join(t.TempDir(), "companies.json"))
+	if err != nil {
+		t.Fatalf("failed to open company registry: %v", err)
+	}
+	shipmentRepository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	shipmentService, err := app.CreateShipmentService(shipmentRepository, app.WithCompanyRegistry(registry))
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, shipmentService, WithCompanyRegistry(registry), WithStrictValidation(true))
+
+	// The steps run in order, each one sees the companies registered and updated by the previous ones
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "no company registered",
+			method:         http.MethodGet,
+			path:           "/admin/companies",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"companies":[]}` + "\n",
+		},
+		{
+			name:           "register company",
+			method:         http.MethodPut,
+			path:           "/admin/companies/1",
+			contentType:    "application/json",
+			body:           `{"name": "Blue Whale Lines", "onboardedAt": "2023-01-15"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":1,"name":"Blue Whale Lines","status":"active","onboardedAt":"2023-01-15"}` + "\n",
+		},
+		{
+			name:           "offer of the registered company accepted",
+			method:         http.MethodPost,
+			path:           "/v1/quotes",
+			contentType:    "application/json",
+			body:           `{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "offer of an unknown company rejected",
+			method:         http.MethodPost,
+			path:           "/v1/quotes",
+			contentType:    "application/json",
+			body:           `{"company": 2, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"type":"urn:quoteship:problem:invalid-shipment-offer","title":"Invalid shipment offer","status":422,"detail":"The shipment offer was discarded because some of its fields are invalid.","errors":[{"field":"company","code":"invalid_value","message":"company 2 is not registered"}]}` + "\n",
+		},
+		{
+			name:           "quotes listed with the name of the company",
+			method:         http.MethodGet,
+			path:           "/v1/origins/CNSGH/quotes",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"origin":"CNSGH","total":1,"limit":20,"offset":0,"sort":"price","quotes":[{"destination":"*","equipment":"20DV","company":1,"companyName":"Blue Whale Lines","price":100,"date":"2023-01-01"}]}` + "\n",
+		},
+		{
+			name:           "suspend company",
+			method:         http.MethodPatch,
+			path:           "/admin/companies/1",
+			contentType:    "application/json",
+			body:           `{"status": "suspended"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":1,"name":"Blue Whale Lines","status":"suspended","onboardedAt":"2023-01-15"}` + "\n",
+		},
+		{
+			name:           "offer of the suspended company rejected",
+			method:         http.MethodPost,
+			path:           "/v1/quotes",
+			contentType:    "application/json",
+			body:           `{"company": 1, "price": 90, "origin": "CNSGH", "date": "2023-02-01"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"type":"urn:quoteship:problem:invalid-shipment-offer","title":"Invalid shipment offer","status":422,"detail":"The shipment offer was discarded because some of its fields are invalid.","errors":[{"field":"company","code":"suspended","message":"company 1 is suspended"}]}` + "\n",
+		},
+		{
+			name:           "quotes of the suspended company kept",
+			method:         http.MethodGet,
+			path:           "/v1/origins/CNSGH/quotes",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"origin":"CNSGH","total":1,"limit":20,"offset":0,"sort":"price","quotes":[{"destination":"*","equipment":"20DV","company":1,"companyName":"Blue Whale Lines","price":100,"date":"2023-01-01"}]}` + "\n",
+		},
+		{
+			name:           "unknown company",
+			method:         http.MethodPatch,
+			path:           "/admin/companies/2",
+			contentType:    "application/json",
+			body:           `{"status": "active"}`,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"` + domain.ErrUnknownCompany.Error() + `"}` + "\n",
+		},
+		{
+			name:           "invalid status",
+			method:         http.MethodPatch,
+			path:           "/admin/companies/1",
+			contentType:    "application/json",
+			body:           `{"status": "banned"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"` + domain.ErrInvalidCompanyStatus.Error() + `"}` + "\n",
+		},
+		{
+			name:           "invalid company identifier",
+			method:         http.MethodPut,
+			path:           "/admin/companies/1000",
+			contentType:    "application/json",
+			body:           `{"name": "Red Sea Cargo"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"` + domain.ErrInvalidCompany.Error() + `"}` + "\n",
+		},
+		{
+			name:           "missing name",
+			method:         http.MethodPut,
+			path:           "/admin/companies/2",
+			contentType:    "application/json",
+			body:           `{"status": "active"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"` + domain.ErrInvalidCompany.Error() + `"}` + "\n",
+		},
+		{
+			name:           "invalid onboarding date",
+			method:         http.MethodPut,
+			path:           "/admin/companies/2",
+			contentType:    "application/json",
+			body:           `{"name": "Red Sea Cargo", "onboardedAt": "15-01-2023"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"` + domain.ErrInvalidDate.Error() + `"}` + "\n",
+		},
+		{
+			name:           "invalid content type",
+			method:         http.MethodPatch,
+			path:           "/admin/companies/1",
+			contentType:    "text/plain",
+			body:           `{"status": "active"}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedBody:   `{"error":"` + ErrInvalidContentType.Error() + `"}` + "\n",
+		},
+		{
+			name:           "registered companies",
+			method:         http.MethodGet,
+			path:           "/admin/companies",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"companies":[{"id":1,"name":"Blue Whale Lines","status":"suspended","onboardedAt":"2023-01-15"}]}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			// Check the status code
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			// Check the response body
+			if rec.Body.String() != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestShipmentHandler_GetCompanies_withoutRegistry(t *testing.T) {
+	mux := newV1Mux(t, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/companies", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	if expected := `{"error":"` + ErrNoCompanyRegistry.Error() + `"}` + "\n"; rec.Body.String() != expected {
+		t.Errorf("expected body %q, got %q", expected, rec.Body.String())
+	}
+}
@@ -0,0 +1,82 @@
+package presentation
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"quoteship/domain"
+)
+
+// portsResponse is the response payload of the ports endpoint, it lists the ports of the registry.
+type portsResponse struct {
+	Ports []portResponse `json:"ports"` // Ports lists the ports, sorted by code.
+}
+
+// portResponse is a single port of the port registry.
+type portResponse struct {
+	Code     string `json:"code"`     // Code is the UN/LOCODE of the port (e.g., "CNSGH").
+	Name     string `json:"name"`     // Name is the name of the port (e.g., "Shanghai").
+	Country  string `json:"country"`  // Country is the ISO 3166-1 alpha-2 code of the country of the port (e.g., "CN").
+	Timezone string `json:"timezone"` // Timezone is the IANA time zone of the port (e.g., "Asia/Shanghai").
+	Active   bool   `json:"active"`   // Active reports whether shipment offers can be submitted for the port.
+}
+
+// requestedPortUpdate is the request payload of the port update endpoint.
+type requestedPortUpdate struct {
+	Active *bool `json:"active"` // Active enables or disables the port, it is required.
+}
+
+// GetPorts is an HTTP handler that lists the ports of the port registry the shipment offers are validated against,
+// sorted by code, e.g., {"ports": [{"code": "CNSGH", "name": "Shanghai", ..., "active": true}]}.
+func (h ShipmentHandler) GetPorts(writer http.ResponseWriter, _ *http.Request) {
+	ports := h.ports.Ports()
+
+	response := portsResponse{Ports: make([]portResponse, 0, len(ports))}
+	for _, port := range ports {
+		response.Ports = append(response.Ports, newPortResponse(port))
+	}
+
+	writeJSONResponse(writer, http.StatusOK, response)
+}
+
+// UpdatePort is an HTTP handler that enables or disables the port given by the {code} path value. It expects a JSON
+// payload with the `active` flag, e.g., {"active": false}, and returns the updated port. The shipment offers for a
+// disabled port are rejected from then on, the quotes already submitted for it are kept. The change lasts until the
+// server restarts. The handler returns 404 Not Found if the registry does not list the port.
+func (h ShipmentHandler) UpdatePort(writer http.ResponseWriter, request *http.Request) {
+	// Check request headers for Content-Type and validate it is application/json
+	if !strings.HasPrefix(request.Header.Get("Content-Type"), "application/json") {
+		slog.Warn("invalid content type", "content-type", request.Header.Get("Content-Type"))
+		writeJSONResponse(writer, http.StatusUnsupportedMediaType, map[string]string{"error": ErrInvalidContentType.Error()})
+		return
+	}
+
+	var update requestedPortUpdate
+	if err := json.NewDecoder(request.Body).Decode(&update); err != nil || update.Active == nil {
+		slog.Error("error decoding request payload", "error", err)
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": ErrInvalidRequestPayload.Error()})
+		return
+	}
+
+	port, err := h.ports.SetActive(request.PathValue("code"), *update.Active)
+	if errors.Is(err, domain.ErrUnknownPort) {
+		writeJSONResponse(writer, http.StatusNotFound, map[string]string{"error": domain.ErrUnknownPort.Error()})
+		return
+	}
+	if err != nil {
+		slog.Error("error updating port", "error", err)
+		writeJSONResponse(writer, http.StatusInternalServerError, map[string]string{"error": ErrIntervalServerError.Error()})
+		return
+	}
+
+	slog.Info("port updated", "code", port.Code, "active", port.Active)
+	writeJSONResponse(writer, http.StatusOK, newPortResponse(port))
+}
+
+// newPortResponse converts a port of the registry to its response payload.
+func newPortResponse(port domain.Port) portResponse {
+	return portResponse{Code: port.Code, Name: port.Name, Country: port.Country, Timezone: port.Timezone, Active: port.Active}
+}
@@ -0,0 +1,149 @@
+package presentation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"quoteship/domain"
+)
+
+func TestShipmentHandler_GetPorts(t *testing.T) {
+	mux := newV1Mux(t, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/ports", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var response portsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	// Every port of the bundled registry is listed, sorted by code
+	ports := make(map[string]portResponse, len(response.Ports))
+	for i, port := range response.Ports {
+		if i > 0 && response.Ports[i-1].Code >= port.Code {
+			t.Errorf("expected ports sorted by code, got %q before %q", response.Ports[i-1].Code, port.Code)
+		}
+		ports[port.Code] = port
+	}
+	if expected := (portResponse{Code: "CNSGH", Name: "Shanghai", Country: "CN", Timezone: "Asia/Shanghai", Active: true}); ports["CNSGH"] != expected {
+		t.Errorf("expected port %+v, got %+v", expected, ports["CNSGH"])
+	}
+	if ports["CNTXG"].Code == "" || ports["CNTXG"].Active {
+		t.Errorf("expected inactive port CNTXG, got %+v", ports["CNTXG"])
+	}
+}
+
+func TestShipmentHandler_UpdatePort(t *testing.T) {
+	mux := newV1Mux(t, nil)
+
+	// The steps run in order, each one sees the ports updated by the previous ones
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "disable port",
+			method:         http.MethodPatch,
+			path:           "/admin/ports/CNSGH",
+			contentType:    "application/json",
+			body:           `{"active": false}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"code":"CNSGH","name":"Shanghai","country":"CN","timezone":"Asia/Shanghai","active":false}` + "\n",
+		},
+		{
+			name:           "offer for the disabled port rejected",
+			method:         http.MethodPost,
+			path:           "/v1/quotes",
+			contentType:    "application/json",
+			body:           `{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "offer for the disabled destination port rejected",
+			method:         http.MethodPost,
+			path:           "/v1/quotes",
+			contentType:    "application/json",
+			body:           `{"company": 1, "price": 100, "origin": "SGSIN", "destination": "CNSGH", "date": "2023-01-01"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "enable port",
+			method:         http.MethodPatch,
+			path:           "/admin/ports/CNSGH",
+			contentType:    "application/json",
+			body:           `{"active": true}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"code":"CNSGH","name":"Shanghai","country":"CN","timezone":"Asia/Shanghai","active":true}` + "\n",
+		},
+		{
+			name:           "offer for the enabled port accepted",
+			method:         http.MethodPost,
+			path:           "/v1/quotes",
+			contentType:    "application/json",
+			body:           `{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown port",
+			method:         http.MethodPatch,
+			path:           "/admin/ports/DEBRV",
+			contentType:    "application/json",
+			body:           `{"active": true}`,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"` + domain.ErrUnknownPort.Error() + `"}` + "\n",
+		},
+		{
+			name:           "missing active flag",
+			method:         http.MethodPatch,
+			path:           "/admin/ports/CNSGH",
+			contentType:    "application/json",
+			body:           `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"` + ErrInvalidRequestPayload.Error() + `"}` + "\n",
+		},
+		{
+			name:           "invalid content type",
+			method:         http.MethodPatch,
+			path:           "/admin/ports/CNSGH",
+			contentType:    "text/plain",
+			body:           `{"active": false}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedBody:   `{"error":"` + ErrInvalidContentType.Error() + `"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Prefer", "handling=strict")
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			// Check the status code
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			// Check the response body, the problem details of the rejected offers are checked by the strict tests
+			if tt.expectedBody != "" && rec.Body.String() != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
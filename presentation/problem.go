@@ -0,0 +1,87 @@
+package presentation
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const (
+	HandlingStrict  = "strict"  // HandlingStrict rejects an invalid shipment offer with 422 Unprocessable Entity and a problem details body.
+	HandlingLenient = "lenient" // HandlingLenient discards an invalid shipment offer with 200 OK and no body. It is the default for compatibility.
+
+	ProblemTypeInvalidOffer = "urn:quoteship:problem:invalid-shipment-offer" // ProblemTypeInvalidOffer identifies the problem details of an invalid shipment offer.
+
+	CodeRequired      = "required"       // CodeRequired reports a missing field.
+	CodeInvalidFormat = "invalid_format" // CodeInvalidFormat reports a field that cannot be parsed, e.g., a date that is not formatted "YYYY-MM-DD".
+	CodeOutOfRange    = "out_of_range"   // CodeOutOfRange reports a number outside of its bounds.
+	CodeInvalidValue  = "invalid_value"  // CodeInvalidValue reports a value that is not one of the accepted ones, e.g., an unknown origin port.
+	CodeUnsupported   = "unsupported"    // CodeUnsupported reports a well-formed value the service does not support, e.g., a currency without exchange rate.
+	CodeBeforeDate    = "before_date"    // CodeBeforeDate reports a validity date before the start date of the quote.
+	CodeInactive      = "inactive"       // CodeInactive reports a port of the registry that is disabled.
+	CodeSuspended     = "suspended"      // CodeSuspended reports a company of the registry that is suspended.
+
+	problemContentType = "application/problem+json" // problemContentType is the media type of the problem details responses, as defined by RFC 9457.
+	handlingPreference = "handling"                 // handlingPreference is the preference of the Prefer header that selects the handling of invalid offers, as defined by RFC 7240.
+)
+
+// fieldError describes an invalid field of a shipment offer.
+type fieldError struct {
+	Field   string `json:"field"`   // Field is the JSON name of the invalid field, e.g., "price".
+	Code    string `json:"code"`    // Code is the machine-readable reason the field is invalid, e.g., CodeOutOfRange.
+	Message string `json:"message"` // Message is the human-readable reason the field is invalid.
+	err     error  // err is the domain error recorded in the audit trail of the company for the field.
+}
+
+// problemDetails is a problem details response body as defined by RFC 9457, extended with the invalid fields.
+type problemDetails struct {
+	Type   string       `json:"type"`             // Type identifies the problem type, e.g., ProblemTypeInvalidOffer.
+	Title  string       `json:"title"`            // Title is a short summary of the problem type.
+	Status int          `json:"status"`           // Status is the HTTP status code of the response.
+	Detail string       `json:"detail,omitempty"` // Detail explains this occurrence of the problem.
+	Errors []fieldError `json:"errors,omitempty"` // Errors lists every invalid field.
+}
+
+// strictHandling reports whether an invalid offer of the request is rejected with a problem details body, as requested
+// by "Prefer: handling=strict" or "Prefer: handling=lenient", or as configured when the request has no handling
+// preference.
+func (h ShipmentHandler) strictHandling(request *http.Request) bool {
+	for _, preference := range strings.Split(strings.Join(request.Header.Values("Prefer"), ","), ",") {
+		token, _, _ := strings.Cut(preference, ";") // The parameters of the preference are ignored
+		name, value, _ := strings.Cut(strings.TrimSpace(token), "=")
+		if !strings.EqualFold(strings.TrimSpace(name), handlingPreference) {
+			continue
+		}
+
+		switch strings.ToLower(strings.Trim(strings.TrimSpace(value), `"`)) {
+		case HandlingStrict:
+			return true
+		case HandlingLenient:
+			return false
+		}
+	}
+
+	return h.strict
+}
+
+// writeInvalidOfferResponse writes the 422 Unprocessable Entity problem details of a shipment offer with invalid
+// fields.
+func writeInvalidOfferResponse(writer http.ResponseWriter, fieldErrors []fieldError) {
+	writeProblemResponse(writer, problemDetails{
+		Type:   ProblemTypeInvalidOffer,
+		Title:  "Invalid shipment offer",
+		Status: http.StatusUnprocessableEntity,
+		Detail: "The shipment offer was discarded because some of its fields are invalid.",
+		Errors: fieldErrors,
+	})
+}
+
+// writeProblemResponse writes the problem details to the writer with their status code.
+func writeProblemResponse(writer http.ResponseWriter, problem problemDetails) {
+	writer.Header().Set("Content-Type", problemContentType)
+	writer.WriteHeader(problem.Status)
+	if err := json.NewEncoder(writer).Encode(problem); err != nil {
+		slog.Error("error writing response", "error", err)
+	}
+}
@@ -0,0 +1,223 @@
+package presentation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"quoteship/app"
+	"quoteship/persistence"
+	"quoteship/ports"
+)
+
+func TestShipmentHandler_strictHandling(t *testing.T) {
+	tests := []struct {
+		name           string
+		strict         bool
+		prefer         []string
+		expectedStrict bool
+	}{
+		{name: "lenient by default"},
+		{name: "strict by configuration", strict: true, expectedStrict: true},
+		{name: "strict by request", prefer: []string{"handling=strict"}, expectedStrict: true},
+		{name: "lenient by request", strict: true, prefer: []string{"handling=lenient"}},
+		{name: "among other preferences", prefer: []string{"return=minimal, Handling=\"STRICT\"; foo=bar"}, expectedStrict: true},
+		{name: "in a second header", prefer: []string{"return=minimal", "handling=strict"}, expectedStrict: true},
+		{name: "unknown handling", strict: true, prefer: []string{"handling=loose"}, expectedStrict: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CreateShipmentHandler(nil, WithStrictValidation(tt.strict))
+
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			for _, prefer := range tt.prefer {
+				req.Header.Add("Prefer", prefer)
+			}
+
+			if strict := handler.strictHandling(req); strict != tt.expectedStrict {
+				t.Errorf("expected strict %t, got %t", tt.expectedStrict, strict)
+			}
+		})
+	}
+}
+
+func TestShipmentHandler_SubmitShipmentOffer_strict(t *testing.T) {
+	shipmentRepository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	shipmentService, err := app.CreateShipmentService(shipmentRepository, app.WithPortRegistry(ports.Bundled()))
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		strict          bool
+		prefer          string
+		body            requestedShipmentOffer
+		expectedStatus  int
+		expectedProblem *problemDetails
+	}{
+		{
+			name:           "lenient - invalid offer",
+			body:           requestedShipmentOffer{Company: 0, Price: "100", Origin: "CNSGH", Date: "2023-01-01"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "strict - valid offer",
+			prefer:         "handling=strict",
+			body:           requestedShipmentOffer{Company: 1, Price: "100", Origin: "CNSGH", Date: "2023-01-01"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "strict - every invalid field",
+			prefer:         "handling=strict",
+			body:           requestedShipmentOffer{Company: 1000, Price: "0.001", Origin: "NYC", Destination: "NYC", Equipment: "reefer", Currency: "usd", Date: "01-01-2023", ValidUntil: "soon"},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedProblem: &problemDetails{
+				Type:   ProblemTypeInvalidOffer,
+				Title:  "Invalid shipment offer",
+				Status: http.StatusUnprocessableEntity,
+				Detail: "The shipment offer was discarded because some of its fields are invalid.",
+				Errors: []fieldError{
+					{Field: "company", Code: CodeOutOfRange, Message: "company must be between 1 and 999"},
+					{Field: "price", Code: CodeInvalidFormat, Message: "price must be a positive decimal with at most 2 decimal places"},
+					{Field: "origin", Code: CodeInvalidFormat, Message: "origin must be a UN/LOCODE of five uppercase letters or digits"},
+					{Field: "destination", Code: CodeInvalidValue, Message: `destination must be a port other than the origin, or "*"`},
+					{Field: "equipment", Code: CodeInvalidValue, Message: "equipment must be one of [20DV 40DV 40HC 40RF]"},
+					{Field: "currency", Code: CodeInvalidFormat, Message: "currency must be an ISO 4217 code of three uppercase letters"},
+					{Field: "date", Code: CodeInvalidFormat, Message: "date must be formatted YYYY-MM-DD"},
+					{Field: "validUntil", Code: CodeInvalidFormat, Message: "validUntil must be formatted YYYY-MM-DD"},
+				},
+			},
+		},
+		{
+			name:           "strict by configuration - missing origin and early validity",
+			strict:         true,
+			body:           requestedShipmentOffer{Company: 1, Price: "100", Date: "2023-01-01", ValidUntil: "2022-12-31"},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedProblem: &problemDetails{
+				Type:   ProblemTypeInvalidOffer,
+				Title:  "Invalid shipment offer",
+				Status: http.StatusUnprocessableEntity,
+				Detail: "The shipment offer was discarded because some of its fields are invalid.",
+				Errors: []fieldError{
+					{Field: "origin", Code: CodeRequired, Message: "origin is required"},
+					{Field: "validUntil", Code: CodeBeforeDate, Message: "validUntil cannot be before date"},
+				},
+			},
+		},
+		{
+			name:           "strict - unsupported currency",
+			prefer:         "handling=strict",
+			body:           requestedShipmentOffer{Company: 1, Price: "100", Origin: "CNSGH", Date: "2023-01-02", Currency: "EUR"},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedProblem: &problemDetails{
+				Type:   ProblemTypeInvalidOffer,
+				Title:  "Invalid shipment offer",
+				Status: http.StatusUnprocessableEntity,
+				Detail: "The shipment offer was discarded because some of its fields are invalid.",
+				Errors: []fieldError{{Field: "currency", Code: CodeUnsupported, Message: `currency "EUR" has no exchange rate`}},
+			},
+		},
+		{
+			name:           "strict - inactive origin",
+			prefer:         "handling=strict",
+			body:           requestedShipmentOffer{Company: 1, Price: "100", Origin: "CNTXG", Destination: "DEBRV", Date: "2023-01-01"},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedProblem: &problemDetails{
+				Type:   ProblemTypeInvalidOffer,
+				Title:  "Invalid shipment offer",
+				Status: http.StatusUnprocessableEntity,
+				Detail: "The shipment offer was discarded because some of its fields are invalid.",
+				Errors: []fieldError{{Field: "origin", Code: CodeInactive, Message: `origin "CNTXG" is not active`}},
+			},
+		},
+		{
+			name:           "strict - unknown destination",
+			prefer:         "handling=strict",
+			body:           requestedShipmentOffer{Company: 1, Price: "100", Origin: "CNSGH", Destination: "DEBRV", Date: "2023-01-01"},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedProblem: &problemDetails{
+				Type:   ProblemTypeInvalidOffer,
+				Title:  "Invalid shipment offer",
+				Status: http.StatusUnprocessableEntity,
+				Detail: "The shipment offer was discarded because some of its fields are invalid.",
+				Errors: []fieldError{{Field: "destination", Code: CodeInvalidValue, Message: `destination "DEBRV" is not a port of the registry`}},
+			},
+		},
+		{
+			name:           "strict - inactive destination",
+			prefer:         "handling=strict",
+			body:           requestedShipmentOffer{Company: 1, Price: "100", Origin: "CNSGH", Destination: "CNTXG", Date: "2023-01-01"},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedProblem: &problemDetails{
+				Type:   ProblemTypeInvalidOffer,
+				Title:  "Invalid shipment offer",
+				Status: http.StatusUnprocessableEntity,
+				Detail: "The shipment offer was discarded because some of its fields are invalid.",
+				Errors: []fieldError{{Field: "destination", Code: CodeInactive, Message: `destination "CNTXG" is not active`}},
+			},
+		},
+		{
+			name:           "lenient by request with strict configuration",
+			strict:         true,
+			prefer:         "handling=lenient",
+			body:           requestedShipmentOffer{Company: 1, Origin: "CNSGH", Date: "2023-01-01"},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CreateShipmentHandler(shipmentService, WithStrictValidation(tt.strict))
+
+			body, err := json.Marshal(tt.body)
+			if err != nil {
+				t.Fatalf("failed to marshal JSON body: %v", err)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.prefer != "" {
+				req.Header.Set("Prefer", tt.prefer)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.SubmitShipmentOffer(rec, req)
+
+			// Check the status code
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			// Lenient responses have no body
+			if tt.expectedProblem == nil {
+				if rec.Body.Len() != 0 {
+					t.Errorf("expected no body, got %q", rec.Body.String())
+				}
+				return
+			}
+
+			if contentType := rec.Header().Get("Content-Type"); contentType != problemContentType {
+				t.Errorf("expected content type %q, got %q", problemContentType, contentType)
+			}
+			if applied := rec.Header().Get("Preference-Applied"); applied != "handling=strict" {
+				t.Errorf("expected applied preference %q, got %q", "handling=strict", applied)
+			}
+
+			var problem problemDetails
+			if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+				t.Fatalf("failed to decode response body: %v", err)
+			}
+			if !reflect.DeepEqual(&problem, tt.expectedProblem) {
+				t.Errorf("expected problem %+v, got %+v", tt.expectedProblem, problem)
+			}
+		})
+	}
+}
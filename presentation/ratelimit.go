@@ -0,0 +1,203 @@
+package presentation
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxRateLimitBuckets = 10000 // DefaultMaxRateLimitBuckets is the default number of token buckets a RateLimiter keeps in memory.
+)
+
+var (
+	ErrInvalidRateLimit = errors.New("invalid rate limit")
+	ErrRateLimited      = errors.New("rate limit exceeded")
+)
+
+// RateLimit is the limit of a token bucket. A bucket holds at most Burst tokens and is refilled with Rate tokens per
+// second, every request takes a token and is rejected when the bucket is empty.
+type RateLimit struct {
+	Rate  float64 // Rate is the number of requests per second accepted in the long run, zero disables the limit.
+	Burst int     // Burst is the number of requests accepted at once after an idle period.
+}
+
+// RateLimits are the limits of the RateLimiter, every request takes a token from each bucket it belongs to.
+type RateLimits struct {
+	PerIP      RateLimit // PerIP limits the requests of every client IP address, authenticated or not.
+	PerKey     RateLimit // PerKey limits the requests of every API key, identified by its name.
+	PerCompany RateLimit // PerCompany limits the requests of all the submitter API keys of every company.
+}
+
+// RateLimiter limits the requests of the clients with token buckets, created on the first request of a client. At most
+// maxBuckets buckets are kept in memory, the least recently used one is evicted to make room for a new one. The evicted
+// bucket is most likely full again, so the client it belonged to is not granted more requests than the limit.
+type RateLimiter struct {
+	limits     RateLimits               // limits are the limits of the buckets of every kind.
+	maxBuckets int                      // maxBuckets is the maximum number of buckets kept in memory.
+	buckets    map[string]*list.Element // buckets maps the key of every bucket to its element in recent.
+	recent     *list.List               // recent lists the buckets, the most recently used first.
+	mu         sync.Mutex               // mu synchronizes access to buckets and recent.
+	now        func() time.Time         // now returns the current time the buckets are refilled at, it is replaced in tests.
+}
+
+// tokenBucket is the token bucket of a client.
+type tokenBucket struct {
+	key     string    // key identifies the bucket in the buckets of the RateLimiter, e.g., "ip:192.0.2.1".
+	tokens  float64   // tokens is the number of tokens left when the bucket was last updated.
+	updated time.Time // updated is the time the bucket was last refilled.
+}
+
+// NewRateLimiter creates a RateLimiter with the limits, that keeps at most maxBuckets buckets in memory.
+func NewRateLimiter(limits RateLimits, maxBuckets int) (*RateLimiter, error) {
+	for _, limit := range []RateLimit{limits.PerIP, limits.PerKey, limits.PerCompany} {
+		if limit.Rate < 0 || math.IsNaN(limit.Rate) || math.IsInf(limit.Rate, 0) || (limit.Rate > 0 && limit.Burst < 1) {
+			return nil, fmt.Errorf("%w: rate %v, burst %d", ErrInvalidRateLimit, limit.Rate, limit.Burst)
+		}
+	}
+	if maxBuckets <= 0 {
+		return nil, fmt.Errorf("%w: maximum number of buckets %d", ErrInvalidRateLimit, maxBuckets)
+	}
+
+	return &RateLimiter{
+		limits:     limits,
+		maxBuckets: maxBuckets,
+		buckets:    make(map[string]*list.Element),
+		recent:     list.New(),
+		now:        time.Now,
+	}, nil
+}
+
+// WithRateLimiter makes the requests be limited by the limiter, the ones over the limits are rejected with 429 Too Many
+// Requests and a Retry-After header. The requests are limited per client IP address before they are authenticated, and
+// per API key and per company once they are. Without limiter, every request is accepted.
+func WithRateLimiter(limiter *RateLimiter) HandlerOption {
+	return func(h *ShipmentHandler) {
+		h.limiter = limiter
+	}
+}
+
+// ParseRateLimit parses a rate limit formatted "<rate>:<burst>", e.g., "5:20" for 5 requests per second with bursts of
+// 20 requests. The burst is optional, it defaults to the rate rounded up. An empty value disables the limit.
+func ParseRateLimit(value string) (RateLimit, error) {
+	if strings.TrimSpace(value) == "" {
+		return RateLimit{}, nil
+	}
+
+	rateValue, burstValue, hasBurst := strings.Cut(value, ":")
+	rate, err := strconv.ParseFloat(strings.TrimSpace(rateValue), 64)
+	if err != nil || rate <= 0 || math.IsInf(rate, 0) {
+		return RateLimit{}, fmt.Errorf("%w: %q", ErrInvalidRateLimit, value)
+	}
+
+	burst := int(math.Ceil(rate))
+	if hasBurst {
+		burst, err = strconv.Atoi(strings.TrimSpace(burstValue))
+		if err != nil || burst < 1 {
+			return RateLimit{}, fmt.Errorf("%w: %q", ErrInvalidRateLimit, value)
+		}
+	}
+
+	return RateLimit{Rate: rate, Burst: burst}, nil
+}
+
+// limitClient is the rate limiting middleware of a route for the client IP address, it runs before the request is
+// authenticated so the clients without a valid API key are limited too.
+func (h ShipmentHandler) limitClient(next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if h.limiter != nil && !h.limiter.admit(writer, "ip:"+clientIP(request), h.limiter.limits.PerIP) {
+			return
+		}
+
+		next(writer, request)
+	}
+}
+
+// limitPrincipal is the rate limiting middleware of a route for the authenticated API key and its company. The requests
+// that were not authenticated are only limited by their client IP address.
+func (h ShipmentHandler) limitPrincipal(next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, authenticated := requestPrincipal(request)
+		if h.limiter != nil && authenticated {
+			if !h.limiter.admit(writer, "key:"+principal.Name, h.limiter.limits.PerKey) {
+				return
+			}
+			if principal.Company != 0 && !h.limiter.admit(writer, "company:"+strconv.Itoa(principal.Company), h.limiter.limits.PerCompany) {
+				return
+			}
+		}
+
+		next(writer, request)
+	}
+}
+
+// admit takes a token from the bucket with the key, and responds with 429 Too Many Requests when the bucket is empty.
+// It reports whether the request can be handled, every request is admitted when the limit is disabled.
+func (l *RateLimiter) admit(writer http.ResponseWriter, key string, limit RateLimit) bool {
+	if limit.Rate == 0 {
+		return true
+	}
+
+	allowed, retryAfter := l.take(key, limit)
+	if allowed {
+		return true
+	}
+
+	slog.Warn("rate limit exceeded", "bucket", key, "retry_after", retryAfter)
+	writer.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds())))))
+	writeJSONResponse(writer, http.StatusTooManyRequests, map[string]string{"error": ErrRateLimited.Error()})
+
+	return false
+}
+
+// take refills the bucket with the key, created full on its first use, and takes a token from it. It reports whether a
+// token was taken, or else the time until the bucket holds a token again.
+func (l *RateLimiter) take(key string, limit RateLimit) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	var bucket *tokenBucket
+	if element, exists := l.buckets[key]; exists {
+		l.recent.MoveToFront(element)
+		bucket = element.Value.(*tokenBucket)
+		elapsed := max(0, now.Sub(bucket.updated).Seconds())
+		bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+elapsed*limit.Rate)
+	} else {
+		// Evict the least recently used bucket to keep the memory bounded
+		if l.recent.Len() >= l.maxBuckets {
+			oldest := l.recent.Remove(l.recent.Back()).(*tokenBucket)
+			delete(l.buckets, oldest.key)
+		}
+		bucket = &tokenBucket{key: key, tokens: float64(limit.Burst)}
+		l.buckets[key] = l.recent.PushFront(bucket)
+	}
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second))
+	}
+	bucket.tokens--
+
+	return true, 0
+}
+
+// clientIP returns the IP address of the client of the request. The X-Forwarded-For header is not trusted, since any
+// client can set it.
+func clientIP(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+
+	return host
+}
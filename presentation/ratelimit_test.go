@@ -0,0 +1,253 @@
+package presentation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"quoteship/app"
+	"quoteship/persistence"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		expectedLimit RateLimit
+		expectedError error
+	}{
+		{name: "disabled", value: "", expectedLimit: RateLimit{}},
+		{name: "rate and burst", value: "5:20", expectedLimit: RateLimit{Rate: 5, Burst: 20}},
+		{name: "rate only", value: "2.5", expectedLimit: RateLimit{Rate: 2.5, Burst: 3}},
+		{name: "rate below one request per second", value: "0.1", expectedLimit: RateLimit{Rate: 0.1, Burst: 1}},
+		{name: "invalid rate - zero", value: "0:10", expectedError: ErrInvalidRateLimit},
+		{name: "invalid rate - negative", value: "-1:10", expectedError: ErrInvalidRateLimit},
+		{name: "invalid rate - not a number", value: "fast:10", expectedError: ErrInvalidRateLimit},
+		{name: "invalid burst - zero", value: "5:0", expectedError: ErrInvalidRateLimit},
+		{name: "invalid burst - missing", value: "5:", expectedError: ErrInvalidRateLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, err := ParseRateLimit(tt.value)
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if limit != tt.expectedLimit {
+				t.Errorf("expected limit %+v, got %+v", tt.expectedLimit, limit)
+			}
+		})
+	}
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	tests := []struct {
+		name          string
+		limits        RateLimits
+		maxBuckets    int
+		expectedError error
+	}{
+		{name: "valid limits", limits: RateLimits{PerIP: RateLimit{Rate: 1, Burst: 1}}, maxBuckets: 1},
+		{name: "disabled limits", maxBuckets: 1},
+		{name: "invalid rate", limits: RateLimits{PerKey: RateLimit{Rate: -1, Burst: 1}}, maxBuckets: 1, expectedError: ErrInvalidRateLimit},
+		{name: "invalid burst", limits: RateLimits{PerCompany: RateLimit{Rate: 1}}, maxBuckets: 1, expectedError: ErrInvalidRateLimit},
+		{name: "invalid maximum number of buckets", maxBuckets: 0, expectedError: ErrInvalidRateLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRateLimiter(tt.limits, tt.maxBuckets); !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_take(t *testing.T) {
+	limit := RateLimit{Rate: 2, Burst: 3}
+	limiter, err := NewRateLimiter(RateLimits{PerIP: limit}, 2)
+	if err != nil {
+		t.Fatalf("failed to create rate limiter: %v", err)
+	}
+	now := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	// The steps run in order, the clock advances by the elapsed time before each one
+	tests := []struct {
+		name               string
+		key                string
+		elapsed            time.Duration
+		expectedAllowed    bool
+		expectedRetryAfter time.Duration
+	}{
+		{name: "first request of the burst", key: "ip:192.0.2.1", expectedAllowed: true},
+		{name: "second request of the burst", key: "ip:192.0.2.1", expectedAllowed: true},
+		{name: "last request of the burst", key: "ip:192.0.2.1", expectedAllowed: true},
+		{name: "empty bucket", key: "ip:192.0.2.1", expectedRetryAfter: 500 * time.Millisecond},
+		{name: "partially refilled bucket", key: "ip:192.0.2.1", elapsed: 250 * time.Millisecond, expectedRetryAfter: 250 * time.Millisecond},
+		{name: "refilled bucket", key: "ip:192.0.2.1", elapsed: 250 * time.Millisecond, expectedAllowed: true},
+		{name: "bucket of another client", key: "ip:192.0.2.2", expectedAllowed: true},
+		{name: "least recently used bucket evicted", key: "ip:192.0.2.3", expectedAllowed: true},
+		{name: "evicted bucket created full", key: "ip:192.0.2.1", expectedAllowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.elapsed)
+
+			allowed, retryAfter := limiter.take(tt.key, limit)
+			if allowed != tt.expectedAllowed {
+				t.Errorf("expected allowed %t, got %t", tt.expectedAllowed, allowed)
+			}
+			if retryAfter != tt.expectedRetryAfter {
+				t.Errorf("expected retry after %v, got %v", tt.expectedRetryAfter, retryAfter)
+			}
+			if len(limiter.buckets) > 2 || limiter.recent.Len() != len(limiter.buckets) {
+				t.Errorf("expected at most 2 buckets, got %d buckets and %d recent buckets", len(limiter.buckets), limiter.recent.Len())
+			}
+		})
+	}
+}
+
+func TestShipmentHandler_rateLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	content := `{"keys": [
+		{"name": "dashboard", "sha256": "` + persistence.HashAPIKey("qs_consumer") + `", "role": "consumer"},
+		{"name": "blue-whale-ci", "sha256": "` + persistence.HashAPIKey("qs_ci") + `", "role": "submitter", "company": 1},
+		{"name": "blue-whale-erp", "sha256": "` + persistence.HashAPIKey("qs_erp") + `", "role": "submitter", "company": 1}
+	]}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write API keys file: %v", err)
+	}
+	keys, err := persistence.OpenAPIKeyStore(path)
+	if err != nil {
+		t.Fatalf("failed to open API key store: %v", err)
+	}
+	limiter, err := NewRateLimiter(RateLimits{
+		PerIP:      RateLimit{Rate: 1, Burst: 3},
+		PerKey:     RateLimit{Rate: 1, Burst: 2},
+		PerCompany: RateLimit{Rate: 0.5, Burst: 3},
+	}, DefaultMaxRateLimitBuckets)
+	if err != nil {
+		t.Fatalf("failed to create rate limiter: %v", err)
+	}
+	now := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	shipmentRepository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	shipmentService, err := app.CreateShipmentService(shipmentRepository)
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, shipmentService, WithAPIKeys(keys), WithRateLimiter(limiter))
+
+	quotes := `{"origin":"CNSGH","total":0,"limit":20,"offset":0,"sort":"price","quotes":[]}` + "\n"
+	rateLimited := `{"error":"` + ErrRateLimited.Error() + `"}` + "\n"
+
+	// The steps run in order at the same time, each one takes the tokens left by the previous ones
+	tests := []struct {
+		name               string
+		remoteAddr         string
+		apiKey             string
+		expectedStatus     int
+		expectedBody       string
+		expectedRetryAfter string
+	}{
+		{
+			name:           "first request of the key",
+			remoteAddr:     "192.0.2.1:41000",
+			apiKey:         "qs_ci",
+			expectedStatus: http.StatusOK,
+			expectedBody:   quotes,
+		},
+		{
+			name:           "last request of the key",
+			remoteAddr:     "192.0.2.1:41001",
+			apiKey:         "qs_ci",
+			expectedStatus: http.StatusOK,
+			expectedBody:   quotes,
+		},
+		{
+			name:               "key limited from another IP address",
+			remoteAddr:         "192.0.2.2:41000",
+			apiKey:             "qs_ci",
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedBody:       rateLimited,
+			expectedRetryAfter: "1",
+		},
+		{
+			name:           "other key of the company",
+			remoteAddr:     "192.0.2.2:41001",
+			apiKey:         "qs_erp",
+			expectedStatus: http.StatusOK,
+			expectedBody:   quotes,
+		},
+		{
+			name:               "company limited",
+			remoteAddr:         "192.0.2.2:41002",
+			apiKey:             "qs_erp",
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedBody:       rateLimited,
+			expectedRetryAfter: "2",
+		},
+		{
+			name:           "unauthenticated request",
+			remoteAddr:     "192.0.2.1:41002",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"` + ErrUnauthenticated.Error() + `"}` + "\n",
+		},
+		{
+			name:               "IP address limited before authentication",
+			remoteAddr:         "192.0.2.1:41003",
+			apiKey:             "qs_consumer",
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedBody:       rateLimited,
+			expectedRetryAfter: "1",
+		},
+		{
+			name:           "key without company",
+			remoteAddr:     "192.0.2.3:41000",
+			apiKey:         "qs_consumer",
+			expectedStatus: http.StatusOK,
+			expectedBody:   quotes,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/origins/CNSGH/quotes", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			// Check the status code
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			// Check the response body
+			if rec.Body.String() != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, rec.Body.String())
+			}
+
+			// Check the time to wait before retrying
+			if got := rec.Header().Get("Retry-After"); got != tt.expectedRetryAfter {
+				t.Errorf("expected Retry-After header %q, got %q", tt.expectedRetryAfter, got)
+			}
+		})
+	}
+}
@@ -7,10 +7,24 @@ import (
 	"quoteship/domain"
 )
 
-// RegisterRoutes registers routes for the requested Shipment service.
-func RegisterRoutes(mux *http.ServeMux, s domain.ShipmentService) {
+// RegisterRoutes registers routes for the requested Shipment service, the options configure the Shipment handler.
+func RegisterRoutes(mux *http.ServeMux, s domain.ShipmentService, options ...HandlerOption) {
 	// Create a new Shipment handler.
-	h := CreateShipmentHandler(s)
+	h := CreateShipmentHandler(s, options...)
+
+	// Wrap the handler functions with the authentication middleware, the consumer API keys can read, the submitter API
+	// keys can also submit and delete the quotes of their company, and only the admin API keys can call the admin
+	// routes. The requests are rate limited per client IP address before they are authenticated, and per API key and
+	// company once they are.
+	guard := func(role domain.Role, next http.HandlerFunc) http.HandlerFunc {
+		return h.limitClient(h.authenticate(role, h.limitPrincipal(next)))
+	}
+	read := func(next http.HandlerFunc) http.HandlerFunc { return guard(domain.RoleConsumer, next) }
+	submit := func(next http.HandlerFunc) http.HandlerFunc { return guard(domain.RoleSubmitter, next) }
+	admin := func(next http.HandlerFunc) http.HandlerFunc { return guard(domain.RoleAdmin, next) }
+
+	getLatestExpectedRates := read(h.GetLatestExpectedRates)
+	submitShipmentOffer := submit(h.SubmitShipmentOffer)
 
 	// Register the handler functions with the provided ServeMux. The handler functions are registered at the specified
 	// routes with the corresponding HTTP methods.
@@ -18,17 +32,64 @@ func RegisterRoutes(mux *http.ServeMux, s domain.ShipmentService) {
 		switch request.Method {
 		case http.MethodGet:
 			// Call the GetLatestExpectedRates handler function when a GET request is received at the root route.
-			h.GetLatestExpectedRates(writer, request)
+			getLatestExpectedRates(writer, request)
 		case http.MethodPost:
 			// Call the SubmitShipmentOffer handler function when a POST request is received at the root route.
-			h.SubmitShipmentOffer(writer, request)
+			submitShipmentOffer(writer, request)
 		default:
 			http.Error(writer, "Method Not Allowed", http.StatusMethodNotAllowed)
 		}
 	})
 
+	// Register the batch submission handler, the valid shipment offers of the batch are stored at once.
+	mux.HandleFunc("POST /quotes:batch", submit(h.SubmitShipmentOffers))
+
+	// Register the CSV tariff handlers, the import submits the rows of the tariff like a batch.
+	mux.HandleFunc("POST /quotes:import", submit(h.ImportTariff))
+	mux.HandleFunc("GET /quotes:export", read(h.ExportTariff))
+
+	// Register the quote history handler, the origin and company are read from the path values.
+	mux.HandleFunc("GET /origins/{origin}/companies/{company}/history", read(h.GetQuoteHistory))
+
+	// Register the admin handler listing the quotes excluded as outliers from the expected rates.
+	mux.HandleFunc("GET /admin/outliers", admin(h.GetExcludedQuotes))
+
+	// Register the admin handlers of the port registry, the port to enable or disable is read from the path value.
+	mux.HandleFunc("GET /admin/ports", admin(h.GetPorts))
+	mux.HandleFunc("PATCH /admin/ports/{code}", admin(h.UpdatePort))
+
+	// Register the admin handlers of the company registry, the company to register or update is read from the path
+	// value.
+	mux.HandleFunc("GET /admin/companies", admin(h.GetCompanies))
+	mux.HandleFunc("PUT /admin/companies/{company}", admin(h.RegisterCompany))
+	mux.HandleFunc("PATCH /admin/companies/{company}", admin(h.UpdateCompany))
+
+	// Register the handlers of the versioned API, one route per resource and method. The unknown resources of the API
+	// are not found, instead of falling back to the root route.
+	mux.HandleFunc("GET "+APIVersionPrefix+"/rates", getLatestExpectedRates)
+	mux.HandleFunc("GET "+APIVersionPrefix+"/rates/{origin}", read(h.GetOriginExpectedRates))
+	mux.HandleFunc("POST "+APIVersionPrefix+"/quotes", submitShipmentOffer)
+	mux.HandleFunc("GET "+APIVersionPrefix+"/origins/{origin}/quotes", read(h.GetOriginQuotes))
+	mux.HandleFunc("DELETE "+APIVersionPrefix+"/origins/{origin}/companies/{company}", submit(h.DeleteCompanyQuotes))
+	mux.HandleFunc(APIVersionPrefix+"/", NotFound)
+
 	slog.Info("Creating routes for requestedShipmentOffer service...")
 	slog.Info("Registered GetLatestExpectedRates handler at / using GET method")
 	slog.Info("Registered SubmitShipmentOffer handler at / using POST method")
+	slog.Info("Registered SubmitShipmentOffers handler at /quotes:batch using POST method")
+	slog.Info("Registered ImportTariff handler at /quotes:import using POST method")
+	slog.Info("Registered ExportTariff handler at /quotes:export using GET method")
+	slog.Info("Registered GetQuoteHistory handler at /origins/{origin}/companies/{company}/history using GET method")
+	slog.Info("Registered GetExcludedQuotes handler at /admin/outliers using GET method")
+	slog.Info("Registered GetPorts handler at /admin/ports using GET method")
+	slog.Info("Registered UpdatePort handler at /admin/ports/{code} using PATCH method")
+	slog.Info("Registered GetCompanies handler at /admin/companies using GET method")
+	slog.Info("Registered RegisterCompany handler at /admin/companies/{company} using PUT method")
+	slog.Info("Registered UpdateCompany handler at /admin/companies/{company} using PATCH method")
+	slog.Info("Registered GetLatestExpectedRates handler at /v1/rates using GET method")
+	slog.Info("Registered GetOriginExpectedRates handler at /v1/rates/{origin} using GET method")
+	slog.Info("Registered SubmitShipmentOffer handler at /v1/quotes using POST method")
+	slog.Info("Registered GetOriginQuotes handler at /v1/origins/{origin}/quotes using GET method")
+	slog.Info("Registered DeleteCompanyQuotes handler at /v1/origins/{origin}/companies/{company} using DELETE method")
 	slog.Info("Created routes for requestedShipmentOffer service")
 }
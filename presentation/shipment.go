@@ -3,60 +3,226 @@ package presentation
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"math/big"
+	"mime"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"quoteship/domain"
+	"quoteship/ports"
 )
 
 const (
-	OriginShanghai  = "CNSGH" // Shanghai
-	OriginSingapore = "SGSIN" // Singapore
-	OriginShenzhen  = "CNSNZ" // Shenzhen
-	OriginNingbo    = "CNNBO" // Ningbo
-	OriginGuangzhou = "CNGGZ" // Guangzhou
-
 	MinCompanyID = 1
 	MaxCompanyID = 999
 	MinPrice     = 1
 	MaxPrice     = 99999
+	MinTop       = 1   // MinTop is the smallest number of lowest-priced offers a request can aggregate.
+	MaxTop       = 100 // MaxTop is the largest number of lowest-priced offers a request can aggregate.
+
+	QuoteCountsHeader = "X-Quote-Counts" // QuoteCountsHeader lists the number of quotes every expected rate is calculated from.
+
+	GroupByOrigin    = "origin"    // GroupByOrigin groups the expected rates by origin, using the wildcard lane of every origin.
+	GroupByLane      = "lane"      // GroupByLane nests the expected rates by origin and destination.
+	GroupByEquipment = "equipment" // GroupByEquipment nests the expected rates by origin and equipment type, using the wildcard lane of every origin.
+
+	PriceFormatUnits   = "units"   // PriceFormatUnits returns prices as JSON integers in whole units of the currency, rounded with the rounding mode. It is the default for existing clients.
+	PriceFormatDecimal = "decimal" // PriceFormatDecimal returns prices as decimal strings with two decimal places (e.g., "123.45").
+	PriceFormatMinor   = "minor"   // PriceFormatMinor returns prices as JSON integers in minor units of the currency (e.g., 12345 for 123.45).
+
+	RatesVersionLegacy     = "1" // RatesVersionLegacy returns the expected rates as a flat map of rates. It is the default for existing clients.
+	RatesVersionStatistics = "2" // RatesVersionStatistics returns every expected rate with the statistics of its quotes.
+
+	priceFormatParameter  = "prices"  // priceFormatParameter is the media type parameter of the Accept header that selects the price format.
+	ratesVersionParameter = "version" // ratesVersionParameter is the media type parameter of the Accept header that selects the version of the expected rates response.
+	priceDecimalPlaces    = 2         // priceDecimalPlaces is the maximum number of decimal places of a submitted price, one per digit of domain.MinorUnitsPerUnit.
 
 	dateFormat = "2006-01-02" // Go's reference format for date parsing
 )
 
 var (
-	ErrInvalidRequestPayload = errors.New("invalid request payload")
-	ErrInvalidContentType    = errors.New("invalid content type")
-	ErrIntervalServerError   = errors.New("internal server error")
-
-	expectedRatesPerOriginNum = 10 // Number of expected rates per origin port
+	ErrInvalidRequestPayload   = errors.New("invalid request payload")
+	ErrInvalidContentType      = errors.New("invalid content type")
+	ErrIntervalServerError     = errors.New("internal server error")
+	ErrInvalidAsOfDate         = errors.New("invalid asOf date")
+	ErrInvalidGroupBy          = errors.New("invalid groupBy value")
+	ErrUnsupportedPriceFormat  = errors.New("unsupported price format")
+	ErrUnsupportedRatesVersion = errors.New("unsupported expected rates version")
 )
 
 // ShipmentHandler is a struct that contains the domain.ShipmentService interface. Through this interface, the handler can
 // interact with the domain layer to perform operations related to shipment data.
 type ShipmentHandler struct {
-	s domain.ShipmentService // s is the service that provides business logic for managing and retrieving shipment data.
+	s          domain.ShipmentService // s is the service that provides business logic for managing and retrieving shipment data.
+	rounding   domain.RoundingMode    // rounding rounds the prices returned in whole units.
+	strict     bool                   // strict rejects the invalid shipment offers submitted without a handling preference with a problem details body.
+	ports      domain.PortRegistry    // ports lists the ports the shipment offers can be submitted for, the bundled registry by default.
+	companies  domain.CompanyRegistry // companies lists the companies that can submit shipment offers and their names, when nil every company is accepted.
+	keys       domain.APIKeyStore     // keys authenticates the API keys of the requests, when nil every request is accepted.
+	signatures *RequestVerifier       // signatures verifies the signed shipment offers, when nil the signature headers are ignored.
+	limiter    *RateLimiter           // limiter limits the requests per client, API key and company, when nil every request is accepted.
+}
+
+// HandlerOption configures an optional behaviour of the ShipmentHandler.
+type HandlerOption func(*ShipmentHandler)
+
+// WithRoundingMode sets the rounding mode of the prices returned in whole units, domain.RoundHalfEven by default.
+func WithRoundingMode(mode domain.RoundingMode) HandlerOption {
+	return func(h *ShipmentHandler) {
+		h.rounding = mode
+	}
+}
+
+// WithStrictValidation makes strict handling the default of the shipment offers submitted without a handling
+// preference, instead of lenient handling. A request can still select its handling with the Prefer header.
+func WithStrictValidation(strict bool) HandlerOption {
+	return func(h *ShipmentHandler) {
+		h.strict = strict
+	}
+}
+
+// WithPortRegistry makes the port endpoints list and update the ports of the registry, instead of the bundled registry.
+// A nil registry keeps the bundled one. The shipment offers are validated against the registry of the service layer.
+func WithPortRegistry(registry domain.PortRegistry) HandlerOption {
+	return func(h *ShipmentHandler) {
+		if registry != nil {
+			h.ports = registry
+		}
+	}
+}
+
+// WithCompanyRegistry makes the company endpoints manage the companies of the registry, and the names of the companies
+// be listed next to their identifiers. The shipment offers are validated against the registry of the service layer.
+func WithCompanyRegistry(registry domain.CompanyRegistry) HandlerOption {
+	return func(h *ShipmentHandler) {
+		h.companies = registry
+	}
 }
 
 // requestedShipmentOffer is a struct that represents the expected structure of a shipment offer request payload. This
 // struct is used to decode the request body for requested shipment offers.
 type requestedShipmentOffer struct {
-	Company int    `json:"company"` // Company is the name of the company that provided the quote.
-	Price   int    `json:"price"`   // Price is the cost of the shipment.
-	Origin  string `json:"origin"`  // Origin is the located port where the shipment starts (e.g., "CNSGH").
-	Date    string `json:"date"`    // Date is the date when the shipment will start. It should be in the format "YYYY-MM-DD".
-}
-
-// GetLatestExpectedRates is an HTTP handler that retrieves the latest expected rates for shipments grouped by origin and
-// sorted by price. It considers the `top` lowest-priced offers for each origin and returns the expected rates.
-// The handler returns a JSON response containing the expected rates for each origin port, e.g., {"CNSGH": 100, "SGSIN": 200}.
-func (h ShipmentHandler) GetLatestExpectedRates(writer http.ResponseWriter, _ *http.Request) {
-	// Calling the GetLatestExpectedRates method from the service layer to get the expected rates
-	expectedRates, err := h.s.GetLatestExpectedRates(expectedRatesPerOriginNum)
+	Company     int         `json:"company"`               // Company is the identifier of the company that provided the quote.
+	Price       json.Number `json:"price"`                 // Price is the cost of the shipment, a JSON number or decimal string with at most two decimal places (e.g., 123.45).
+	Origin      string      `json:"origin"`                // Origin is the located port where the shipment starts (e.g., "CNSGH").
+	Destination string      `json:"destination,omitempty"` // Destination is the optional port where the shipment ends (e.g., "NLRTM"), empty or "*" for every destination.
+	Date        string      `json:"date"`                  // Date is the date when the shipment will start. It should be in the format "YYYY-MM-DD".
+	ValidUntil  string      `json:"validUntil,omitempty"`  // ValidUntil is the optional last date the quote is in effect, inclusive. It should be in the format "YYYY-MM-DD".
+	Equipment   string      `json:"equipment,omitempty"`   // Equipment is the optional container type the quote is priced for (e.g., "40HC"), "20DV" when omitted.
+	Currency    string      `json:"currency,omitempty"`    // Currency is the optional ISO 4217 code of the currency of the price (e.g., "EUR"), the base currency when omitted.
+}
+
+// quoteHistoryResponse is the response payload of the quote history endpoint, it lists the audit trail of a company for
+// an origin.
+type quoteHistoryResponse struct {
+	Origin      string              `json:"origin"`                // Origin is the located port of the quotes (e.g., "CNSGH").
+	Company     int                 `json:"company"`               // Company is the identifier of the company that submitted the quotes.
+	CompanyName string              `json:"companyName,omitempty"` // CompanyName is the name of the company in the company registry, it is omitted when the registry does not list it.
+	History     []quoteHistoryEntry `json:"history"`               // History lists every submission of the company for the origin, in the order they were received.
+}
+
+// quoteHistoryEntry is a single submission of the quote history endpoint response payload.
+type quoteHistoryEntry struct {
+	ReceivedAt  string `json:"receivedAt,omitempty"` // ReceivedAt is the time the submission was received, in RFC 3339 format. It is omitted when unknown.
+	Destination string `json:"destination"`          // Destination is the submitted destination port, "*" for a quote valid for every destination.
+	Status      string `json:"status"`               // Status is the outcome of the submission, "accepted" or "rejected".
+	Reason      string `json:"reason,omitempty"`     // Reason explains why the submission was rejected.
+	Price       any    `json:"price"`                // Price is the submitted cost of the shipment, in the negotiated price format.
+	Currency    string `json:"currency,omitempty"`   // Currency is the currency of the submitted cost, it is omitted for quotes submitted before currencies existed.
+	Date        string `json:"date,omitempty"`       // Date is the submitted start date, in the format "YYYY-MM-DD". It is omitted when it could not be parsed.
+	ValidUntil  string `json:"validUntil,omitempty"` // ValidUntil is the submitted last date the quote is in effect, in the format "YYYY-MM-DD".
+	Equipment   string `json:"equipment"`            // Equipment is the submitted container type of the quote.
+}
+
+// excludedQuotesResponse is the response payload of the excluded quotes endpoint, it lists the quotes excluded as
+// outliers from the expected rates.
+type excludedQuotesResponse struct {
+	Excluded []excludedQuote `json:"excluded"` // Excluded lists the excluded quotes, in the order of the lanes and then of the equipment types.
+}
+
+// excludedQuote is a single quote of the excluded quotes endpoint response payload.
+type excludedQuote struct {
+	Origin      string `json:"origin"`                // Origin is the located port of the quote (e.g., "CNSGH").
+	Destination string `json:"destination"`           // Destination is the destination port of the lane the quote is excluded from, "*" for the wildcard lane.
+	Equipment   string `json:"equipment"`             // Equipment is the container type of the quote.
+	Company     int    `json:"company"`               // Company is the identifier of the company that submitted the quote.
+	CompanyName string `json:"companyName,omitempty"` // CompanyName is the name of the company in the company registry, it is omitted when the registry does not list it.
+	Price       any    `json:"price"`                 // Price is the price of the quote in the base currency, in the negotiated price format.
+	Date        string `json:"date"`                  // Date is the start date of the quote, in the format "YYYY-MM-DD".
+	Reason      string `json:"reason"`                // Reason explains why the quote is an outlier.
+}
+
+// expectedRatesResponse is the version 2 response payload of the expected rates endpoint.
+type expectedRatesResponse struct {
+	Version string `json:"version"` // Version is the version of the response, RatesVersionStatistics.
+	Rates   any    `json:"rates"`   // Rates are the expectedRate entries, keyed like the version 1 response.
+}
+
+// expectedRate is an expected rate of the version 2 response payload, together with the statistics of its quotes. The
+// prices are in the negotiated price format.
+type expectedRate struct {
+	Rate        any    `json:"rate"`                  // Rate is the expected price of a shipment.
+	Quotes      int    `json:"quotes"`                // Quotes is the number of quotes the rate is calculated from.
+	Min         any    `json:"min"`                   // Min is the price of the cheapest quote.
+	Max         any    `json:"max"`                   // Max is the price of the most expensive quote.
+	StdDev      any    `json:"stdDev"`                // StdDev is the population standard deviation of the prices of the quotes.
+	OldestQuote string `json:"oldestQuote"`           // OldestQuote is the earliest date of the quotes, in the format "YYYY-MM-DD".
+	NewestQuote string `json:"newestQuote"`           // NewestQuote is the latest date of the quotes, in the format "YYYY-MM-DD".
+	PublishedAt string `json:"publishedAt,omitempty"` // PublishedAt is the time the batch of the quotes was published, in RFC 3339 format. It is omitted for the rates as of a date.
+}
+
+// GetLatestExpectedRates is an HTTP handler that retrieves the latest expected rates for shipments grouped by origin,
+// e.g., {"CNSGH": 100, "SGSIN": 200}. The query parameters (top, groupBy, equipment, currency, method and asOf) and the
+// `prices` and `version` parameters of the Accept header select the quotes, their aggregation and the response format,
+// as documented in the README.
+func (h ShipmentHandler) GetLatestExpectedRates(writer http.ResponseWriter, request *http.Request) {
+	h.writeExpectedRates(writer, request, "")
+}
+
+// writeExpectedRates writes the expected rates requested like GetLatestExpectedRates describes, restricted to the rates
+// of the origin unless it is empty. An origin without expected rates is answered with 404 Not Found.
+func (h ShipmentHandler) writeExpectedRates(writer http.ResponseWriter, request *http.Request, origin string) {
+	format, err := negotiatePriceFormat(request)
+	if err != nil {
+		writeJSONResponse(writer, http.StatusNotAcceptable, map[string]string{"error": err.Error()})
+		return
+	}
+
+	version, err := negotiateRatesVersion(request)
+	if err != nil {
+		writeJSONResponse(writer, http.StatusNotAcceptable, map[string]string{"error": err.Error()})
+		return
+	}
+
+	query, groupBy, err := parseRateQuery(request)
 	if err != nil {
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if query.Equipment == "" && groupBy != GroupByEquipment {
+		query.Equipment = domain.DefaultEquipment // A single rate per origin or lane, for the default equipment type
+	}
+
+	// Calling the GetExpectedRates method from the service layer to get the expected rates
+	rates, err := h.s.GetExpectedRates(query)
+	switch {
+	case errors.Is(err, domain.ErrUnsupportedCurrency):
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": domain.ErrUnsupportedCurrency.Error()})
+		return
+	case errors.Is(err, domain.ErrInvalidAggregation):
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": domain.ErrInvalidAggregation.Error()})
+		return
+	case err != nil && origin != "":
+		// An origin without rates is not found
+		writeJSONResponse(writer, http.StatusNotFound, map[string]string{"error": domain.ErrNoExpectedRates.Error()})
+		return
+	case err != nil:
 		// Return a nil response with a status of Bad Request if the expected rates are nil
 		writer.Header().Set("Content-Type", "application/json")
 		writer.WriteHeader(http.StatusBadRequest)
@@ -69,6 +235,26 @@ func (h ShipmentHandler) GetLatestExpectedRates(writer http.ResponseWriter, _ *h
 		return
 	}
 
+	if origin != "" {
+		// Keep the rates of the origin only, an origin without rates is not found
+		if rates = originRates(rates, origin); len(rates) == 0 {
+			writeJSONResponse(writer, http.StatusNotFound, map[string]string{"error": domain.ErrNoExpectedRates.Error()})
+			return
+		}
+	}
+
+	var expectedRates any
+	switch version {
+	case RatesVersionStatistics:
+		expectedRates = expectedRatesResponse{Version: version, Rates: groupExpectedRates(rates, groupBy, func(rate domain.ExpectedRate) any {
+			return h.formatRateStatistics(rate, format)
+		})}
+	default:
+		expectedRates = groupExpectedRates(rates, groupBy, func(rate domain.ExpectedRate) any {
+			return h.formatRate(rate, format)
+		})
+	}
+
 	// Marshal the expected rates into a JSON byte slice
 	expectedRatesMarshaled, err := json.Marshal(expectedRates)
 	if err != nil {
@@ -77,7 +263,9 @@ func (h ShipmentHandler) GetLatestExpectedRates(writer http.ResponseWriter, _ *h
 		return
 	}
 
-	writer.Header().Set("Content-Type", "application/json") // Set header first
+	writer.Header().Set("Content-Type", ratesContentType(format, version)) // Set header first
+	writer.Header().Set("Vary", "Accept")
+	writer.Header().Set(QuoteCountsHeader, quoteCounts(rates, groupBy))
 
 	writer.WriteHeader(http.StatusOK) // Write status code before writing body
 
@@ -97,10 +285,227 @@ func (h ShipmentHandler) GetLatestExpectedRates(writer http.ResponseWriter, _ *h
 	}
 }
 
+// parseRateQuery parses the `asOf`, `top`, `groupBy`, `equipment`, `currency` and `method` query parameters of the
+// request into a rate query, and returns it with the groupBy value. The equipment type is left empty when it is not
+// provided. It returns the error to respond with if a parameter is invalid.
+func parseRateQuery(request *http.Request) (domain.RateQuery, string, error) {
+	var query domain.RateQuery
+
+	if asOf := request.URL.Query().Get("asOf"); asOf != "" {
+		// Parse the date string into a time.Time object, the date should be in the format "YYYY-MM-DD"
+		date, err := time.Parse(dateFormat, asOf)
+		if err != nil {
+			return query, "", ErrInvalidAsOfDate
+		}
+		query.AsOf = date
+	}
+
+	if top := request.URL.Query().Get("top"); top != "" {
+		// The top must be an integer in range, a zero top would use the default of the origins
+		parsedTop, err := strconv.Atoi(top)
+		if err != nil || parsedTop < MinTop || parsedTop > MaxTop {
+			return query, "", domain.ErrInvalidTopValue
+		}
+		query.Top = parsedTop
+	}
+
+	groupBy := request.URL.Query().Get("groupBy")
+	switch groupBy {
+	case "", GroupByOrigin, GroupByEquipment:
+		// continue
+	case GroupByLane:
+		query.Lanes = true
+	default:
+		return query, "", ErrInvalidGroupBy
+	}
+
+	query.Equipment = domain.Equipment(request.URL.Query().Get("equipment"))
+	if query.Equipment != "" && !query.Equipment.Valid() {
+		return query, "", domain.ErrInvalidEquipment
+	}
+
+	query.Currency = domain.Currency(request.URL.Query().Get("currency"))
+	if query.Currency != "" && !query.Currency.Valid() {
+		return query, "", domain.ErrInvalidCurrency
+	}
+
+	query.Method = request.URL.Query().Get("method")
+
+	return query, groupBy, nil
+}
+
+// groupExpectedRates keys the expected rates, formatted with formatRate, by origin or, depending on groupBy, nests them
+// by origin and destination or by origin and equipment type.
+func groupExpectedRates(rates []domain.ExpectedRate, groupBy string, formatRate func(domain.ExpectedRate) any) any {
+	if groupBy != GroupByLane && groupBy != GroupByEquipment {
+		ratesByOrigin := make(map[string]any, len(rates))
+		for _, rate := range rates {
+			ratesByOrigin[rate.Origin] = formatRate(rate)
+		}
+		return ratesByOrigin
+	}
+
+	nestedRates := make(map[string]map[string]any)
+	for _, rate := range rates {
+		if nestedRates[rate.Origin] == nil {
+			nestedRates[rate.Origin] = make(map[string]any)
+		}
+
+		key := rate.Destination
+		if groupBy == GroupByEquipment {
+			key = string(rate.Equipment)
+		}
+		nestedRates[rate.Origin][key] = formatRate(rate)
+	}
+	return nestedRates
+}
+
+// quoteCounts formats the number of quotes of every expected rate as the X-Quote-Counts header, sorted by key. The
+// rates are keyed like groupExpectedRates keys them, with a "/" between the nested keys, e.g.,
+// "CNSGH=10, CNSGH/NLRTM=4".
+func quoteCounts(rates []domain.ExpectedRate, groupBy string) string {
+	counts := make([]string, 0, len(rates))
+	for _, rate := range rates {
+		key := rate.Origin
+		switch groupBy {
+		case GroupByLane:
+			key += "/" + rate.Destination
+		case GroupByEquipment:
+			key += "/" + string(rate.Equipment)
+		}
+		counts = append(counts, key+"="+strconv.Itoa(rate.Quotes))
+	}
+	sort.Strings(counts)
+
+	return strings.Join(counts, ", ")
+}
+
+// negotiatePriceFormat returns the price format requested by the `prices` parameter of a JSON media type of the Accept
+// header, e.g., "Accept: application/json; prices=decimal". It returns PriceFormatUnits when no price format is
+// requested, and ErrUnsupportedPriceFormat for an unknown one.
+func negotiatePriceFormat(request *http.Request) (string, error) {
+	switch format := acceptedJSONParameter(request, priceFormatParameter); format {
+	case "":
+		return PriceFormatUnits, nil
+	case PriceFormatUnits, PriceFormatDecimal, PriceFormatMinor:
+		return format, nil
+	default:
+		return "", ErrUnsupportedPriceFormat
+	}
+}
+
+// negotiateRatesVersion returns the version of the expected rates response requested by the `version` parameter of a
+// JSON media type of the Accept header, e.g., "Accept: application/json; version=2". It returns RatesVersionLegacy when
+// no version is requested, and ErrUnsupportedRatesVersion for an unknown one.
+func negotiateRatesVersion(request *http.Request) (string, error) {
+	switch version := acceptedJSONParameter(request, ratesVersionParameter); version {
+	case "":
+		return RatesVersionLegacy, nil
+	case RatesVersionLegacy, RatesVersionStatistics:
+		return version, nil
+	default:
+		return "", ErrUnsupportedRatesVersion
+	}
+}
+
+// acceptedJSONParameter returns the value of the parameter of the first JSON media type of the Accept header that has
+// it, or an empty string if there is none.
+func acceptedJSONParameter(request *http.Request, parameter string) string {
+	for _, accepted := range strings.Split(strings.Join(request.Header.Values("Accept"), ","), ",") {
+		mediaType, params, err := mime.ParseMediaType(accepted)
+		if err != nil || (mediaType != "application/json" && mediaType != "application/*" && mediaType != "*/*") {
+			continue
+		}
+
+		if value := params[parameter]; value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// priceFormatContentType returns the Content-Type of a response with prices in the price format, the default format is
+// returned as plain "application/json".
+func priceFormatContentType(format string) string {
+	return ratesContentType(format, RatesVersionLegacy)
+}
+
+// ratesContentType returns the Content-Type of an expected rates response in the price format and version, the default
+// format and version are returned as plain "application/json".
+func ratesContentType(format, version string) string {
+	params := make(map[string]string)
+	if format != PriceFormatUnits {
+		params[priceFormatParameter] = format
+	}
+	if version != RatesVersionLegacy {
+		params[ratesVersionParameter] = version
+	}
+	if len(params) == 0 {
+		return "application/json"
+	}
+
+	return mime.FormatMediaType("application/json", params)
+}
+
+// formatRate formats the expected rate in the price format. Whole units are rounded from the exact rate, so the rate
+// is only rounded once.
+func (h ShipmentHandler) formatRate(rate domain.ExpectedRate, format string) any {
+	if format != PriceFormatUnits || rate.Exact == nil {
+		return h.formatPrice(rate.Rate, format)
+	}
+
+	return h.formatAmount(rate.Exact, format)
+}
+
+// formatRateStatistics formats the expected rate and the statistics of its quotes in the price format.
+func (h ShipmentHandler) formatRateStatistics(rate domain.ExpectedRate, format string) expectedRate {
+	formatted := expectedRate{
+		Rate:        h.formatRate(rate, format),
+		Quotes:      rate.Quotes,
+		Min:         h.formatAmount(rate.Min, format),
+		Max:         h.formatAmount(rate.Max, format),
+		StdDev:      h.formatAmount(rate.StdDev, format),
+		OldestQuote: formatDate(rate.Oldest),
+		NewestQuote: formatDate(rate.Newest),
+	}
+	if !rate.PublishedAt.IsZero() {
+		formatted.PublishedAt = rate.PublishedAt.UTC().Format(time.RFC3339)
+	}
+
+	return formatted
+}
+
+// formatAmount formats the exact amount, in minor units, in the price format. Whole units are rounded from the exact
+// amount, so the amount is only rounded once. A nil amount is formatted as null.
+func (h ShipmentHandler) formatAmount(amount *big.Rat, format string) any {
+	switch {
+	case amount == nil:
+		return nil
+	case format == PriceFormatUnits:
+		return h.rounding.Round(new(big.Rat).Quo(amount, big.NewRat(domain.MinorUnitsPerUnit, 1)))
+	default:
+		return h.formatPrice(h.rounding.Round(amount), format)
+	}
+}
+
+// formatPrice formats the price, in minor units, in the price format.
+func (h ShipmentHandler) formatPrice(price int, format string) any {
+	switch format {
+	case PriceFormatDecimal:
+		return big.NewRat(int64(price), domain.MinorUnitsPerUnit).FloatString(priceDecimalPlaces)
+	case PriceFormatMinor:
+		return price
+	default:
+		return h.rounding.Round(big.NewRat(int64(price), domain.MinorUnitsPerUnit))
+	}
+}
+
 // SubmitShipmentOffer is an HTTP handler that submits a new shipment offer to the system. It expects a JSON payload
 // containing the details of the shipment offer. The handler decodes the request body, validates the offer, and submits
 // the shipment to the service layer. The handler returns a JSON response with a status of OK if the shipment was
-// successfully submitted.
+// successfully submitted. With request signing, the signature of the request is verified before the body is decoded,
+// see RequestVerifier, and a request with an invalid, stale or replayed signature is rejected with 401 Unauthorized.
 func (h ShipmentHandler) SubmitShipmentOffer(writer http.ResponseWriter, request *http.Request) {
 	// Check request headers for Content-Type and validate it is application/json
 	if !strings.HasPrefix(request.Header.Get("Content-Type"), "application/json") {
@@ -109,6 +514,12 @@ func (h ShipmentHandler) SubmitShipmentOffer(writer http.ResponseWriter, request
 		return
 	}
 
+	// Verify the signature of the request before decoding it
+	signer, verified := h.verifySignature(writer, request)
+	if !verified {
+		return
+	}
+
 	var shipmentOffer requestedShipmentOffer
 	// Decode the request body into the requestedShipmentOffer struct
 	if err := json.NewDecoder(request.Body).Decode(&shipmentOffer); err != nil {
@@ -125,16 +536,48 @@ func (h ShipmentHandler) SubmitShipmentOffer(writer http.ResponseWriter, request
 		}
 	}(request.Body)
 
+	// Reject the shipment offers of another company than the one of the API key, before they reach the audit trail
+	if !authorizeCompanies(writer, request, shipmentOffer.Company) {
+		return
+	}
+
+	// Reject the signed shipment offers of another company, and the unsigned ones of a company that signs its offers
+	if !h.authorizeSigner(writer, signer, shipmentOffer.Company) {
+		return
+	}
+
+	// Report the handling of invalid offers when it is strict
+	strict := h.strictHandling(request)
+	if strict {
+		writer.Header().Set("Preference-Applied", handlingPreference+"="+HandlingStrict)
+	}
+
 	// Validate and parse the shipment offer
-	shipment, err := validateAndParseShipment(shipmentOffer)
+	shipment, err := h.validateAndParseShipment(shipmentOffer)
 	if err != nil {
-		h.s.IncrementShipmentUnitsCount() // Increment the shipment units count for statistics reporting and average rate calculation
+		h.rejectShipment(rejectedShipment(shipmentOffer), err)
+		if strict {
+			writeInvalidOfferResponse(writer, h.validateShipmentOffer(shipmentOffer))
+			return
+		}
 		writeJSONResponse(writer, http.StatusOK, nil)
 		return
 	}
 
 	// Submit the shipment to the service layer
-	if err = h.s.SubmitShipment(&shipment); err != nil {
+	err = h.s.SubmitShipment(&shipment)
+	if invalidField, rejected := serviceFieldError(shipment, err); rejected {
+		// Offers rejected by the service layer, e.g., for a port of the registry that is disabled, are rejected like
+		// the invalid ones
+		h.rejectShipment(&shipment, invalidField.err)
+		if strict {
+			writeInvalidOfferResponse(writer, []fieldError{invalidField})
+			return
+		}
+		writeJSONResponse(writer, http.StatusOK, nil)
+		return
+	}
+	if err != nil {
 		slog.Error("error adding shipment offer", "error", err)
 		writeJSONResponse(writer, http.StatusInternalServerError, map[string]string{"error": ErrIntervalServerError.Error()})
 		return
@@ -143,40 +586,364 @@ func (h ShipmentHandler) SubmitShipmentOffer(writer http.ResponseWriter, request
 	writeJSONResponse(writer, http.StatusOK, nil) // Increment the shipment units count for statistics reporting and average rate calculation
 }
 
-// validateAndParseShipment validates the requestedShipmentOffer and parses it into a domain.ShipmentUnit struct.
-func validateAndParseShipment(shipmentOffer requestedShipmentOffer) (domain.ShipmentUnit, error) {
-	switch {
-	case shipmentOffer.Company < MinCompanyID || shipmentOffer.Company > MaxCompanyID:
-		return domain.ShipmentUnit{}, domain.ErrInvalidCompany
-	case shipmentOffer.Price < MinPrice || shipmentOffer.Price > MaxPrice:
-		return domain.ShipmentUnit{}, domain.ErrInvalidPrice
-	case shipmentOffer.Origin != OriginShanghai && shipmentOffer.Origin != OriginSingapore && shipmentOffer.Origin != OriginShenzhen && shipmentOffer.Origin != OriginNingbo && shipmentOffer.Origin != OriginGuangzhou:
-		return domain.ShipmentUnit{}, domain.ErrInvalidOriginPort
-	default:
-		// continue
+// rejectShipment records the rejection of the shipment offer in the audit trail of its company and counts the offer,
+// like the accepted ones. Offers without an origin or a company cannot be recorded.
+func (h ShipmentHandler) rejectShipment(shipment *domain.ShipmentUnit, reason error) {
+	if recordErr := h.s.RecordRejectedShipment(shipment, reason); recordErr != nil {
+		slog.Debug("rejected shipment offer not recorded", "error", recordErr)
+	}
+
+	h.s.IncrementShipmentUnitsCount() // Increment the shipment units count for statistics reporting and average rate calculation
+}
+
+// company returns the company with the identifier, and whether it is registered. Without company registry, every
+// company is registered and active, without a name.
+func (h ShipmentHandler) company(id int) (domain.Company, bool) {
+	if h.companies == nil {
+		return domain.Company{ID: id, Status: domain.CompanyActive}, true
 	}
 
+	return h.companies.Company(id)
+}
+
+// companyName returns the name of the company with the identifier, or an empty string if the company registry does not
+// list it.
+func (h ShipmentHandler) companyName(id int) string {
+	company, _ := h.company(id)
+
+	return company.Name
+}
+
+// validateAndParseShipment validates the requestedShipmentOffer and parses it into a domain.ShipmentUnit struct, with
+// its price in minor units. It returns the domain error of the first invalid field.
+func (h ShipmentHandler) validateAndParseShipment(shipmentOffer requestedShipmentOffer) (domain.ShipmentUnit, error) {
+	if fieldErrors := h.validateShipmentOffer(shipmentOffer); len(fieldErrors) > 0 {
+		return domain.ShipmentUnit{}, fieldErrors[0].err
+	}
+
+	price, _ := parseMinorUnits(shipmentOffer.Price)
+
 	// Parse the date string into a time.Time object, the date should be in the format "YYYY-MM-DD"
-	parsedDate, err := time.Parse(dateFormat, shipmentOffer.Date)
-	if err != nil {
-		return domain.ShipmentUnit{}, domain.ErrInvalidDate
+	parsedDate, _ := time.Parse(dateFormat, shipmentOffer.Date)
+
+	// Parse the optional validity date, the quote is in effect until the end of that day so it expires on the next one
+	var validUntil time.Time
+	if shipmentOffer.ValidUntil != "" {
+		parsedValidUntil, _ := time.Parse(dateFormat, shipmentOffer.ValidUntil)
+		validUntil = parsedValidUntil.AddDate(0, 0, 1)
 	}
 
 	shipment := domain.ShipmentUnit{
-		Origin: shipmentOffer.Origin,
+		Origin:      shipmentOffer.Origin,
+		Destination: wildcardDestination(shipmentOffer.Destination),
 		ShipmentQuote: domain.ShipmentQuote{
-			Company: shipmentOffer.Company,
-			Price:   shipmentOffer.Price,
-			Date:    parsedDate,
+			Company:    shipmentOffer.Company,
+			Price:      price,
+			Quoted:     domain.Money{Amount: price, Currency: domain.Currency(shipmentOffer.Currency)},
+			Date:       parsedDate,
+			ValidUntil: validUntil,
+			Equipment:  domain.DefaultEquipment,
 		},
 	}
+	if shipmentOffer.Equipment != "" {
+		shipment.Equipment = domain.Equipment(shipmentOffer.Equipment)
+	}
 
 	return shipment, nil
 }
 
-// writeJSONResponse writes a JSON response to the writer with the specified status code and data.
+// validateShipmentOffer returns every invalid field of the requestedShipmentOffer, in the order they are validated: the
+// company, price, origin, destination, equipment, currency, date and validity date. The ports must be UN/LOCODEs, they
+// and the company are only checked against the registries by the service layer, see serviceFieldError.
+func (h ShipmentHandler) validateShipmentOffer(shipmentOffer requestedShipmentOffer) []fieldError {
+	var fieldErrors []fieldError
+	invalid := func(field, code, message string, err error) {
+		fieldErrors = append(fieldErrors, fieldError{Field: field, Code: code, Message: message, err: err})
+	}
+
+	if shipmentOffer.Company < MinCompanyID || shipmentOffer.Company > MaxCompanyID {
+		invalid("company", CodeOutOfRange, fmt.Sprintf("company must be between %d and %d", MinCompanyID, MaxCompanyID), domain.ErrInvalidCompany)
+	}
+
+	price, validPrice := parseMinorUnits(shipmentOffer.Price)
+	switch {
+	case shipmentOffer.Price == "":
+		invalid("price", CodeRequired, "price is required", domain.ErrInvalidPrice)
+	case !validPrice:
+		invalid("price", CodeInvalidFormat, fmt.Sprintf("price must be a positive decimal with at most %d decimal places", priceDecimalPlaces), domain.ErrInvalidPrice)
+	case price < MinPrice*domain.MinorUnitsPerUnit || price > MaxPrice*domain.MinorUnitsPerUnit:
+		invalid("price", CodeOutOfRange, fmt.Sprintf("price must be between %d and %d", MinPrice, MaxPrice), domain.ErrInvalidPrice)
+	}
+
+	switch {
+	case shipmentOffer.Origin == "":
+		invalid("origin", CodeRequired, "origin is required", domain.ErrInvalidOriginPort)
+	case !ports.ValidCode(shipmentOffer.Origin):
+		invalid("origin", CodeInvalidFormat, "origin must be a UN/LOCODE of five uppercase letters or digits", domain.ErrInvalidOriginPort)
+	}
+
+	switch {
+	case wildcardDestination(shipmentOffer.Destination) == "":
+		// continue, the quote applies to every destination
+	case shipmentOffer.Destination == shipmentOffer.Origin:
+		invalid("destination", CodeInvalidValue, fmt.Sprintf("destination must be a port other than the origin, or %q", domain.WildcardDestination), domain.ErrInvalidDestinationPort)
+	case !ports.ValidCode(shipmentOffer.Destination):
+		invalid("destination", CodeInvalidFormat, fmt.Sprintf("destination must be a UN/LOCODE of five uppercase letters or digits, or %q", domain.WildcardDestination), domain.ErrInvalidDestinationPort)
+	}
+
+	if shipmentOffer.Equipment != "" && !domain.Equipment(shipmentOffer.Equipment).Valid() {
+		invalid("equipment", CodeInvalidValue, fmt.Sprintf("equipment must be one of %v", domain.EquipmentTypes), domain.ErrInvalidEquipment)
+	}
+
+	if shipmentOffer.Currency != "" && !domain.Currency(shipmentOffer.Currency).Valid() {
+		invalid("currency", CodeInvalidFormat, "currency must be an ISO 4217 code of three uppercase letters", domain.ErrInvalidCurrency)
+	}
+
+	// Parse the date string into a time.Time object, the date should be in the format "YYYY-MM-DD"
+	parsedDate, dateErr := time.Parse(dateFormat, shipmentOffer.Date)
+	switch {
+	case shipmentOffer.Date == "":
+		invalid("date", CodeRequired, "date is required", domain.ErrInvalidDate)
+	case dateErr != nil:
+		invalid("date", CodeInvalidFormat, "date must be formatted YYYY-MM-DD", domain.ErrInvalidDate)
+	}
+
+	// The optional validity date cannot be before the date, it is only compared with a valid date
+	if shipmentOffer.ValidUntil != "" {
+		parsedValidUntil, err := time.Parse(dateFormat, shipmentOffer.ValidUntil)
+		switch {
+		case err != nil:
+			invalid("validUntil", CodeInvalidFormat, "validUntil must be formatted YYYY-MM-DD", domain.ErrInvalidValidity)
+		case dateErr == nil && parsedValidUntil.Before(parsedDate):
+			invalid("validUntil", CodeBeforeDate, "validUntil cannot be before date", domain.ErrInvalidValidity)
+		}
+	}
+
+	return fieldErrors
+}
+
+// serviceFieldError returns the invalid field of a shipment the service layer rejected with err, for the errors that
+// can only be detected once the shipment is submitted: a currency without exchange rate, a port the port registry does
+// not list or that is disabled, and a company the company registry does not list or that is suspended. It reports false
+// for any other error.
+func serviceFieldError(shipment domain.ShipmentUnit, err error) (fieldError, bool) {
+	switch {
+	case err == nil:
+		return fieldError{}, false
+	case errors.Is(err, domain.ErrUnsupportedCurrency):
+		return fieldError{Field: "currency", Code: CodeUnsupported, Message: fmt.Sprintf("currency %q has no exchange rate", shipment.Quoted.Currency), err: domain.ErrUnsupportedCurrency}, true
+	case errors.Is(err, domain.ErrInvalidOriginPort):
+		return portFieldError("origin", shipment.Origin, err, domain.ErrInvalidOriginPort)
+	case errors.Is(err, domain.ErrInvalidDestinationPort):
+		return portFieldError("destination", shipment.Destination, err, domain.ErrInvalidDestinationPort)
+	case errors.Is(err, domain.ErrUnknownCompany):
+		return fieldError{Field: "company", Code: CodeInvalidValue, Message: fmt.Sprintf("company %d is not registered", shipment.Company), err: domain.ErrInvalidCompany}, true
+	case errors.Is(err, domain.ErrSuspendedCompany):
+		return fieldError{Field: "company", Code: CodeSuspended, Message: fmt.Sprintf("company %d is suspended", shipment.Company), err: domain.ErrInvalidCompany}, true
+	}
+
+	return fieldError{}, false
+}
+
+// portFieldError returns the invalid port field of a shipment the service layer rejected with err, reported with
+// fieldErr, and false unless the port registry does not list the port or the port is disabled.
+func portFieldError(field, port string, err, fieldErr error) (fieldError, bool) {
+	switch {
+	case errors.Is(err, domain.ErrUnknownPort):
+		return fieldError{Field: field, Code: CodeInvalidValue, Message: fmt.Sprintf("%s %q is not a port of the registry", field, port), err: fieldErr}, true
+	case errors.Is(err, domain.ErrInactivePort):
+		return fieldError{Field: field, Code: CodeInactive, Message: fmt.Sprintf("%s %q is not active", field, port), err: fieldErr}, true
+	}
+
+	return fieldError{}, false
+}
+
+// parseMinorUnits parses a positive decimal price with at most two decimal places (e.g., 123.45) into minor units
+// (e.g., 12345). It reports false for a missing price, a negative price, an exponent or more decimal places.
+func parseMinorUnits(price json.Number) (int, bool) {
+	whole, fraction, _ := strings.Cut(price.String(), ".")
+	if whole == "" || len(fraction) > priceDecimalPlaces {
+		return 0, false
+	}
+	for _, char := range whole + fraction {
+		if char < '0' || char > '9' {
+			return 0, false
+		}
+	}
+
+	minorUnits, err := strconv.Atoi(whole + fraction + strings.Repeat("0", priceDecimalPlaces-len(fraction)))
+	if err != nil {
+		return 0, false
+	}
+
+	return minorUnits, true
+}
+
+// wildcardDestination returns the destination as stored by the service, the wildcard "*" is stored as an empty
+// destination.
+func wildcardDestination(destination string) string {
+	if destination == domain.WildcardDestination {
+		return ""
+	}
+
+	return destination
+}
+
+// rejectedShipment converts a requestedShipmentOffer that failed validation into a domain.ShipmentUnit for the audit
+// trail, keeping the fields that can be parsed and leaving the others to their zero value.
+func rejectedShipment(shipmentOffer requestedShipmentOffer) *domain.ShipmentUnit {
+	price, _ := parseMinorUnits(shipmentOffer.Price)
+
+	shipment := &domain.ShipmentUnit{
+		Origin:      shipmentOffer.Origin,
+		Destination: wildcardDestination(shipmentOffer.Destination),
+		ShipmentQuote: domain.ShipmentQuote{
+			Company:   shipmentOffer.Company,
+			Price:     price,
+			Quoted:    domain.Money{Amount: price, Currency: domain.Currency(shipmentOffer.Currency)},
+			Equipment: domain.Equipment(shipmentOffer.Equipment),
+		},
+	}
+
+	if parsedDate, err := time.Parse(dateFormat, shipmentOffer.Date); err == nil {
+		shipment.Date = parsedDate
+	}
+	if parsedValidUntil, err := time.Parse(dateFormat, shipmentOffer.ValidUntil); err == nil {
+		shipment.ValidUntil = parsedValidUntil.AddDate(0, 0, 1)
+	}
+
+	return shipment
+}
+
+// GetQuoteHistory is an HTTP handler that retrieves the audit trail of a company for an origin, given by the {origin}
+// and {company} path values. Every accepted and rejected submission is listed in the order it was received, rejected
+// ones with the reason of the rejection. The prices are returned in the price format negotiated with the Accept header,
+// like the expected rates. The handler returns 404 Not Found if the company never submitted a quote for the origin.
+func (h ShipmentHandler) GetQuoteHistory(writer http.ResponseWriter, request *http.Request) {
+	origin := request.PathValue("origin")
+
+	format, err := negotiatePriceFormat(request)
+	if err != nil {
+		writeJSONResponse(writer, http.StatusNotAcceptable, map[string]string{"error": err.Error()})
+		return
+	}
+
+	company, err := strconv.Atoi(request.PathValue("company"))
+	if err != nil {
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": domain.ErrInvalidCompany.Error()})
+		return
+	}
+
+	// Calling the GetQuoteHistory method from the service layer to get the audit trail
+	history, err := h.s.GetQuoteHistory(origin, company)
+	switch {
+	case errors.Is(err, domain.ErrNoQuoteHistory):
+		writeJSONResponse(writer, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, domain.ErrInvalidOriginPort), errors.Is(err, domain.ErrInvalidCompany):
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	case err != nil:
+		slog.Error("error retrieving quote history", "error", err)
+		writeJSONResponse(writer, http.StatusInternalServerError, map[string]string{"error": ErrIntervalServerError.Error()})
+		return
+	}
+
+	response := quoteHistoryResponse{Origin: origin, Company: company, CompanyName: h.companyName(company), History: make([]quoteHistoryEntry, 0, len(history))}
+	for _, entry := range history {
+		historyEntry := quoteHistoryEntry{
+			Destination: entry.Lane().Destination,
+			Status:      string(entry.Status),
+			Reason:      entry.Reason,
+			Price:       h.formatPrice(entry.Price, format),
+			Date:        formatDate(entry.Date),
+			Equipment:   string(entry.EquipmentType()),
+		}
+		if entry.Quoted.Currency != "" {
+			historyEntry.Price, historyEntry.Currency = h.formatPrice(entry.Quoted.Amount, format), string(entry.Quoted.Currency) // Show the price as submitted
+		}
+		if !entry.ReceivedAt.IsZero() {
+			historyEntry.ReceivedAt = entry.ReceivedAt.Format(time.RFC3339)
+		}
+		historyEntry.ValidUntil = formatValidUntil(entry.ValidUntil)
+		response.History = append(response.History, historyEntry)
+	}
+
+	writer.Header().Set("Content-Type", priceFormatContentType(format))
+	writer.Header().Set("Vary", "Accept")
+	writeJSONResponse(writer, http.StatusOK, response)
+}
+
+// GetExcludedQuotes is an HTTP handler that lists the quotes the outlier filter of the service excludes from the
+// expected rates. The quotes are those of the wildcard lane of every origin, or of every lane with `groupBy=lane`, of
+// every equipment type unless `equipment` is provided, and of the latest published batch unless `asOf` is provided,
+// like the expected rates. The prices are in the base currency, in the price format negotiated with the Accept header.
+// The list is empty when the service has no outlier filter.
+func (h ShipmentHandler) GetExcludedQuotes(writer http.ResponseWriter, request *http.Request) {
+	format, err := negotiatePriceFormat(request)
+	if err != nil {
+		writeJSONResponse(writer, http.StatusNotAcceptable, map[string]string{"error": err.Error()})
+		return
+	}
+
+	query, _, err := parseRateQuery(request)
+	if err != nil {
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Calling the GetExcludedQuotes method from the service layer to get the outliers
+	excluded, err := h.s.GetExcludedQuotes(query)
+	if err != nil {
+		slog.Error("error retrieving excluded quotes", "error", err)
+		writeJSONResponse(writer, http.StatusInternalServerError, map[string]string{"error": ErrIntervalServerError.Error()})
+		return
+	}
+
+	response := excludedQuotesResponse{Excluded: make([]excludedQuote, 0, len(excluded))}
+	for _, quote := range excluded {
+		response.Excluded = append(response.Excluded, excludedQuote{
+			Origin:      quote.Origin,
+			Destination: quote.Destination,
+			Equipment:   string(quote.EquipmentType()),
+			Company:     quote.Company,
+			CompanyName: h.companyName(quote.Company),
+			Price:       h.formatPrice(quote.Price, format),
+			Date:        formatDate(quote.Date),
+			Reason:      quote.Reason,
+		})
+	}
+
+	writer.Header().Set("Content-Type", priceFormatContentType(format))
+	writer.Header().Set("Vary", "Accept")
+	writeJSONResponse(writer, http.StatusOK, response)
+}
+
+// formatDate formats the date in the format "YYYY-MM-DD", the zero date is formatted as an empty string.
+func formatDate(date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+
+	return date.Format(dateFormat)
+}
+
+// formatValidUntil formats the time a quote expires at as the last date it is in effect, like it was submitted, or
+// returns an empty string if the quote does not expire.
+func formatValidUntil(validUntil time.Time) string {
+	if validUntil.IsZero() {
+		return ""
+	}
+
+	return formatDate(validUntil.AddDate(0, 0, -1)) // The quote expires on the day after its last date
+}
+
+// writeJSONResponse writes a JSON response to the writer with the specified status code and data. A JSON Content-Type
+// already set by the handler, e.g., with a price format, is kept.
 func writeJSONResponse(writer http.ResponseWriter, status int, data interface{}) {
-	writer.Header().Set("Content-Type", "application/json")
+	if !strings.HasPrefix(writer.Header().Get("Content-Type"), "application/json") {
+		writer.Header().Set("Content-Type", "application/json")
+	}
 	writer.WriteHeader(status)
 	if data != nil {
 		err := json.NewEncoder(writer).Encode(data)
@@ -187,7 +954,12 @@ func writeJSONResponse(writer http.ResponseWriter, status int, data interface{})
 	}
 }
 
-// CreateShipmentHandler creates a new requestedShipmentOffer handler.
-func CreateShipmentHandler(s domain.ShipmentService) *ShipmentHandler {
-	return &ShipmentHandler{s: s}
+// CreateShipmentHandler creates a new requestedShipmentOffer handler with the provided options.
+func CreateShipmentHandler(s domain.ShipmentService, options ...HandlerOption) *ShipmentHandler {
+	handler := &ShipmentHandler{s: s, rounding: domain.DefaultRoundingMode, ports: ports.Bundled()}
+	for _, option := range options {
+		option(handler)
+	}
+
+	return handler
 }
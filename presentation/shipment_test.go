@@ -8,12 +8,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 	"time"
 
 	"quoteship/app"
 	"quoteship/domain"
 	"quoteship/persistence"
+	"quoteship/ports"
 )
 
 func TestShipmentHandler_SubmitShipmentOfferOffer(t *testing.T) {
@@ -28,7 +30,8 @@ func TestShipmentHandler_SubmitShipmentOfferOffer(t *testing.T) {
 	}
 
 	handler := ShipmentHandler{
-		s: shipmentService,
+		s:     shipmentService,
+		ports: ports.Bundled(),
 	}
 
 	tests := []struct {
@@ -42,14 +45,14 @@ func TestShipmentHandler_SubmitShipmentOfferOffer(t *testing.T) {
 		{
 			name:           "Invalid Content-Type",
 			contentType:    "text/plain",
-			body:           requestedShipmentOffer{Company: 1, Price: 100, Origin: "NYC", Date: "2023-01-01"},
+			body:           requestedShipmentOffer{Company: 1, Price: "100", Origin: "NYC", Date: "2023-01-01"},
 			expectedStatus: http.StatusUnsupportedMediaType,
 			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", ErrInvalidContentType.Error()),
 		},
 		{
 			name:           "Missing Content-Type",
 			contentType:    "",
-			body:           requestedShipmentOffer{Company: 1, Price: 100, Origin: "NYC", Date: "2023-01-01"},
+			body:           requestedShipmentOffer{Company: 1, Price: "100", Origin: "NYC", Date: "2023-01-01"},
 			expectedStatus: http.StatusUnsupportedMediaType,
 			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", ErrInvalidContentType.Error()),
 		},
@@ -63,42 +66,49 @@ func TestShipmentHandler_SubmitShipmentOfferOffer(t *testing.T) {
 		{
 			name:           "Invalid date format",
 			contentType:    "application/json",
-			body:           requestedShipmentOffer{Company: 1, Price: 100, Origin: OriginShanghai, Date: "01-01-2023"},
+			body:           requestedShipmentOffer{Company: 1, Price: "100", Origin: "CNSGH", Date: "01-01-2023"},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "",
 		},
 		{
 			name:           "Invalid company - lower bound",
 			contentType:    "application/json",
-			body:           requestedShipmentOffer{Company: 0, Price: 100, Origin: OriginShanghai, Date: "2023-01-01"},
+			body:           requestedShipmentOffer{Company: 0, Price: "100", Origin: "CNSGH", Date: "2023-01-01"},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "",
 		},
 		{
 			name:           "Invalid company - upper bound",
 			contentType:    "application/json",
-			body:           requestedShipmentOffer{Company: 1000, Price: 100, Origin: OriginShanghai, Date: "2023-01-01"},
+			body:           requestedShipmentOffer{Company: 1000, Price: "100", Origin: "CNSGH", Date: "2023-01-01"},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "",
 		},
 		{
 			name:           "Invalid price - lower bound",
 			contentType:    "application/json",
-			body:           requestedShipmentOffer{Company: 1, Price: 0, Origin: OriginShanghai, Date: "2023-01-01"},
+			body:           requestedShipmentOffer{Company: 1, Price: "0", Origin: "CNSGH", Date: "2023-01-01"},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "",
 		},
 		{
 			name:           "Invalid price - upper bound",
 			contentType:    "application/json",
-			body:           requestedShipmentOffer{Company: 1, Price: 100000, Origin: OriginShanghai, Date: "2023-01-01"},
+			body:           requestedShipmentOffer{Company: 1, Price: "100000", Origin: "CNSGH", Date: "2023-01-01"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "",
+		},
+		{
+			name:           "Unsupported currency",
+			contentType:    "application/json",
+			body:           requestedShipmentOffer{Company: 1, Price: "100", Origin: "CNSGH", Date: "2023-01-01", Currency: "EUR"},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "",
 		},
 		{
 			name:                       "Valid request",
 			contentType:                "application/json",
-			body:                       requestedShipmentOffer{Company: 1, Price: 100, Origin: OriginShanghai, Date: "2023-01-01"},
+			body:                       requestedShipmentOffer{Company: 1, Price: "100", Origin: "CNSGH", Date: "2023-01-01"},
 			expectedStatus:             http.StatusOK,
 			expectedBody:               "",
 			expectedShipmentUnitsCount: len(shipmentRepository.GetLatestSortedShipmentsByOrigin()) + 1,
@@ -168,7 +178,7 @@ func TestShipmentHandler_GetLatestExpectedRates(t *testing.T) {
 		Origin: "NYC",
 		ShipmentQuote: domain.ShipmentQuote{
 			Company: 1,
-			Price:   100,
+			Price:   10000,
 			Date:    time.Now(),
 		},
 	})
@@ -180,7 +190,7 @@ func TestShipmentHandler_GetLatestExpectedRates(t *testing.T) {
 		Origin: "NYC",
 		ShipmentQuote: domain.ShipmentQuote{
 			Company: 2,
-			Price:   150,
+			Price:   15000,
 			Date:    time.Now(),
 		},
 	})
@@ -188,11 +198,37 @@ func TestShipmentHandler_GetLatestExpectedRates(t *testing.T) {
 		t.Fatalf("failed to add shipment unit: %v", err)
 	}
 
+	err = shipmentRepository.AddOrUpdate(domain.ShipmentUnit{
+		Origin:      "NYC",
+		Destination: "ROT",
+		ShipmentQuote: domain.ShipmentQuote{
+			Company: 3,
+			Price:   20000,
+			Date:    time.Now(),
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to add shipment unit: %v", err)
+	}
+
+	err = shipmentRepository.AddOrUpdate(domain.ShipmentUnit{
+		Origin: "NYC",
+		ShipmentQuote: domain.ShipmentQuote{
+			Company:   1,
+			Price:     90000,
+			Date:      time.Now(),
+			Equipment: domain.Equipment40RF,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to add shipment unit: %v", err)
+	}
+
 	err = shipmentRepository.AddOrUpdate(domain.ShipmentUnit{
 		Origin: "LA",
 		ShipmentQuote: domain.ShipmentQuote{
 			Company: 1,
-			Price:   466,
+			Price:   46655,
 			Date:    time.Now(),
 		},
 	})
@@ -206,24 +242,211 @@ func TestShipmentHandler_GetLatestExpectedRates(t *testing.T) {
 	}
 
 	handler := ShipmentHandler{
-		s: shipmentService,
+		s:     shipmentService,
+		ports: ports.Bundled(),
 	}
 
+	today, tomorrow := time.Now().Format(dateFormat), time.Now().AddDate(0, 0, 1).Format(dateFormat)
+
 	tests := []struct {
-		name           string
-		expectedStatus int
-		expectedBody   interface{}
+		name                string
+		query               string
+		accept              string
+		expectedStatus      int
+		expectedBody        interface{}
+		expectedQuoteCounts string
+		expectedContentType string
 	}{
 		{
-			name:           "valid request",
+			name:                "valid request",
+			expectedStatus:      http.StatusOK,
+			expectedBody:        map[string]int{"NYC": 125, "LA": 467}, // 466.55 rounded to whole units
+			expectedQuoteCounts: "LA=1, NYC=2",
+		},
+		{
+			name:           "valid request - whole units",
+			accept:         "application/json; prices=units",
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]int{"NYC": 125, "LA": 467},
+		},
+		{
+			name:           "valid request - decimal prices",
+			accept:         "application/json; prices=decimal",
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]string{"NYC": "125.00", "LA": "466.55"},
+		},
+		{
+			name:           "valid request - minor unit prices",
+			query:          "?groupBy=lane",
+			accept:         "text/html, application/json; prices=minor",
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]map[string]int{"NYC": {"*": 12500, "ROT": 15000}, "LA": {"*": 46655}},
+		},
+		{
+			name:                "valid request - version 1",
+			accept:              "application/json; version=1",
+			expectedStatus:      http.StatusOK,
+			expectedBody:        map[string]int{"NYC": 125, "LA": 467},
+			expectedContentType: "application/json",
+		},
+		{
+			name:           "valid request - version 2",
+			query:          "?asOf=" + tomorrow,
+			accept:         "application/json; version=2",
+			expectedStatus: http.StatusOK,
+			expectedBody: expectedRatesResponse{Version: "2", Rates: map[string]expectedRate{
+				"NYC": {Rate: 125, Quotes: 2, Min: 100, Max: 150, StdDev: 25, OldestQuote: today, NewestQuote: today},
+				"LA":  {Rate: 467, Quotes: 1, Min: 467, Max: 467, StdDev: 0, OldestQuote: today, NewestQuote: today},
+			}},
+			expectedContentType: "application/json; version=2",
+		},
+		{
+			name:           "valid request - version 2 with decimal prices grouped by lane",
+			query:          "?groupBy=lane&asOf=" + tomorrow,
+			accept:         "application/json; prices=decimal; version=2",
+			expectedStatus: http.StatusOK,
+			expectedBody: expectedRatesResponse{Version: "2", Rates: map[string]map[string]expectedRate{
+				"NYC": {
+					"*":   {Rate: "125.00", Quotes: 2, Min: "100.00", Max: "150.00", StdDev: "25.00", OldestQuote: today, NewestQuote: today},
+					"ROT": {Rate: "150.00", Quotes: 3, Min: "100.00", Max: "200.00", StdDev: "40.82", OldestQuote: today, NewestQuote: today},
+				},
+				"LA": {
+					"*": {Rate: "466.55", Quotes: 1, Min: "466.55", Max: "466.55", StdDev: "0.00", OldestQuote: today, NewestQuote: today},
+				},
+			}},
+			expectedContentType: "application/json; prices=decimal; version=2",
+		},
+		{
+			name:           "unsupported version",
+			accept:         "application/json; version=3",
+			expectedStatus: http.StatusNotAcceptable,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", ErrUnsupportedRatesVersion.Error()),
+		},
+		{
+			name:           "unsupported price format",
+			accept:         "application/json; prices=cents",
+			expectedStatus: http.StatusNotAcceptable,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", ErrUnsupportedPriceFormat.Error()),
+		},
+		{
+			name:           "valid request - as of tomorrow",
+			query:          "?asOf=" + time.Now().AddDate(0, 0, 1).Format(dateFormat),
 			expectedStatus: http.StatusOK,
-			expectedBody:   map[string]int{"NYC": 125, "LA": 466},
+			expectedBody:   map[string]int{"NYC": 125, "LA": 467},
+		},
+		{
+			name:           "valid request - as of a date without quotes",
+			query:          "?asOf=2000-01-01",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "null",
+		},
+		{
+			name:           "valid request - grouped by origin",
+			query:          "?groupBy=origin",
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]int{"NYC": 125, "LA": 467},
+		},
+		{
+			name:                "valid request - grouped by lane",
+			query:               "?groupBy=lane",
+			expectedStatus:      http.StatusOK,
+			expectedBody:        map[string]map[string]int{"NYC": {"*": 125, "ROT": 150}, "LA": {"*": 467}},
+			expectedQuoteCounts: "LA/*=1, NYC/*=2, NYC/ROT=3",
+		},
+		{
+			name:           "valid request - equipment type",
+			query:          "?equipment=40RF",
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]int{"NYC": 900},
+		},
+		{
+			name:                "valid request - grouped by equipment type",
+			query:               "?groupBy=equipment",
+			expectedStatus:      http.StatusOK,
+			expectedBody:        map[string]map[string]int{"NYC": {"20DV": 125, "40RF": 900}, "LA": {"20DV": 467}},
+			expectedQuoteCounts: "LA/20DV=1, NYC/20DV=2, NYC/40RF=1",
+		},
+		{
+			name:                "valid request - top",
+			query:               "?top=1",
+			expectedStatus:      http.StatusOK,
+			expectedBody:        map[string]int{"NYC": 100, "LA": 467},
+			expectedQuoteCounts: "LA=1, NYC=1",
+		},
+		{
+			name:           "invalid top - lower bound",
+			query:          "?top=0",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", domain.ErrInvalidTopValue.Error()),
+		},
+		{
+			name:           "invalid top - upper bound",
+			query:          "?top=101",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", domain.ErrInvalidTopValue.Error()),
+		},
+		{
+			name:           "invalid top - not a number",
+			query:          "?top=ten",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", domain.ErrInvalidTopValue.Error()),
+		},
+		{
+			name:           "invalid equipment type",
+			query:          "?equipment=reefer",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", domain.ErrInvalidEquipment.Error()),
+		},
+		{
+			name:           "valid request - base currency",
+			query:          "?currency=USD",
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]int{"NYC": 125, "LA": 467},
+		},
+		{
+			name:           "unsupported currency",
+			query:          "?currency=EUR",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", domain.ErrUnsupportedCurrency.Error()),
+		},
+		{
+			name:           "invalid currency",
+			query:          "?currency=euro",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", domain.ErrInvalidCurrency.Error()),
+		},
+		{
+			name:           "valid request - aggregation method",
+			query:          "?method=max",
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]int{"NYC": 150, "LA": 467},
+		},
+		{
+			name:           "invalid aggregation method",
+			query:          "?method=mode",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", domain.ErrInvalidAggregation.Error()),
+		},
+		{
+			name:           "invalid group by",
+			query:          "?groupBy=company",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", ErrInvalidGroupBy.Error()),
+		},
+		{
+			name:           "invalid as of date",
+			query:          "?asOf=01-01-2000",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", ErrInvalidAsOfDate.Error()),
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create a new HTTP request
-			req := httptest.NewRequest(http.MethodGet, "/expected-rates", nil)
+			req := httptest.NewRequest(http.MethodGet, "/expected-rates"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
 
 			// Record the response
 			rec := httptest.NewRecorder()
@@ -234,6 +457,16 @@ func TestShipmentHandler_GetLatestExpectedRates(t *testing.T) {
 				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
 			}
 
+			// Check the negotiated content type
+			if tt.expectedContentType != "" && rec.Header().Get("Content-Type") != tt.expectedContentType {
+				t.Errorf("expected content type %q, got %q", tt.expectedContentType, rec.Header().Get("Content-Type"))
+			}
+
+			// Check the number of quotes used per rate
+			if tt.expectedQuoteCounts != "" && rec.Header().Get(QuoteCountsHeader) != tt.expectedQuoteCounts {
+				t.Errorf("expected quote counts %q, got %q", tt.expectedQuoteCounts, rec.Header().Get(QuoteCountsHeader))
+			}
+
 			// Check the response body
 			switch expectedBody := tt.expectedBody.(type) {
 			case string:
@@ -255,6 +488,71 @@ func TestShipmentHandler_GetLatestExpectedRates(t *testing.T) {
 	}
 }
 
+func TestShipmentHandler_GetLatestExpectedRates_quoteCounts(t *testing.T) {
+	shipmentRepository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	for _, shipment := range []domain.ShipmentUnit{
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 10000, Date: time.Now()}},
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 2, Price: 15000, Date: time.Now()}},
+		{Origin: "NYC", ShipmentQuote: domain.ShipmentQuote{Company: 3, Price: 20000, Date: time.Now()}},
+		{Origin: "LA", ShipmentQuote: domain.ShipmentQuote{Company: 1, Price: 46655, Date: time.Now()}},
+	} {
+		if err = shipmentRepository.AddOrUpdate(shipment); err != nil {
+			t.Fatalf("failed to add shipment unit: %v", err)
+		}
+	}
+
+	shipmentService, err := app.CreateShipmentService(shipmentRepository, app.WithTop(app.DefaultTop, map[string]int{"NYC": 2}))
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+	handler := ShipmentHandler{s: shipmentService}
+
+	tests := []struct {
+		name                string
+		query               string
+		expectedQuoteCounts map[string]int
+	}{
+		{name: "per-origin default", expectedQuoteCounts: map[string]int{"NYC": 2, "LA": 1}},
+		{name: "top overrides the per-origin default", query: "?top=1", expectedQuoteCounts: map[string]int{"NYC": 1, "LA": 1}},
+		{name: "fewer quotes than the top", query: "?top=5", expectedQuoteCounts: map[string]int{"NYC": 3, "LA": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			req.Header.Set("Accept", "application/json; version=2")
+
+			rec := httptest.NewRecorder()
+			handler.GetLatestExpectedRates(rec, req)
+
+			// Check the status code
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+
+			// Check the number of quotes of every rate in the response body
+			var body struct {
+				Rates map[string]struct {
+					Quotes int `json:"quotes"`
+				} `json:"rates"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode response body: %v", err)
+			}
+			quoteCounts := make(map[string]int, len(body.Rates))
+			for origin, rate := range body.Rates {
+				quoteCounts[origin] = rate.Quotes
+			}
+			if !reflect.DeepEqual(quoteCounts, tt.expectedQuoteCounts) {
+				t.Errorf("expected quote counts %v, got %v", tt.expectedQuoteCounts, quoteCounts)
+			}
+		})
+	}
+}
+
 func TestShipmentHandler_validateAndParseShipment(t *testing.T) {
 	tests := []struct {
 		name                 string
@@ -266,17 +564,19 @@ func TestShipmentHandler_validateAndParseShipment(t *testing.T) {
 			name: "Valid request",
 			offer: requestedShipmentOffer{
 				Company: 1,
-				Price:   100,
-				Origin:  OriginShanghai,
+				Price:   "100",
+				Origin:  "CNSGH",
 				Date:    "2023-01-01",
 			},
 			expectedError: nil,
 			expectedShipmentUnit: domain.ShipmentUnit{
-				Origin: OriginShanghai,
+				Origin: "CNSGH",
 				ShipmentQuote: domain.ShipmentQuote{
-					Company: 1,
-					Price:   100,
-					Date:    time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+					Company:   1,
+					Price:     10000,
+					Quoted:    domain.Money{Amount: 10000},
+					Date:      time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+					Equipment: domain.Equipment20DV,
 				},
 			},
 		},
@@ -284,8 +584,8 @@ func TestShipmentHandler_validateAndParseShipment(t *testing.T) {
 			name: "Invalid company",
 			offer: requestedShipmentOffer{
 				Company: 0,
-				Price:   100,
-				Origin:  OriginShanghai,
+				Price:   "100",
+				Origin:  "CNSGH",
 				Date:    "2023-01-01",
 			},
 			expectedError: domain.ErrInvalidCompany,
@@ -294,8 +594,66 @@ func TestShipmentHandler_validateAndParseShipment(t *testing.T) {
 			name: "Invalid price",
 			offer: requestedShipmentOffer{
 				Company: 1,
-				Price:   0,
-				Origin:  OriginShanghai,
+				Price:   "0",
+				Origin:  "CNSGH",
+				Date:    "2023-01-01",
+			},
+			expectedError: domain.ErrInvalidPrice,
+		},
+		{
+			name: "Valid request with a decimal price",
+			offer: requestedShipmentOffer{
+				Company: 1,
+				Price:   "123.45",
+				Origin:  "CNSGH",
+				Date:    "2023-01-01",
+			},
+			expectedShipmentUnit: domain.ShipmentUnit{
+				Origin: "CNSGH",
+				ShipmentQuote: domain.ShipmentQuote{
+					Company:   1,
+					Price:     12345,
+					Quoted:    domain.Money{Amount: 12345},
+					Date:      time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+					Equipment: domain.Equipment20DV,
+				},
+			},
+		},
+		{
+			name: "Invalid price - too many decimal places",
+			offer: requestedShipmentOffer{
+				Company: 1,
+				Price:   "123.456",
+				Origin:  "CNSGH",
+				Date:    "2023-01-01",
+			},
+			expectedError: domain.ErrInvalidPrice,
+		},
+		{
+			name: "Invalid price - below the minimum price",
+			offer: requestedShipmentOffer{
+				Company: 1,
+				Price:   "0.99",
+				Origin:  "CNSGH",
+				Date:    "2023-01-01",
+			},
+			expectedError: domain.ErrInvalidPrice,
+		},
+		{
+			name: "Invalid price - exponent",
+			offer: requestedShipmentOffer{
+				Company: 1,
+				Price:   "1e3",
+				Origin:  "CNSGH",
+				Date:    "2023-01-01",
+			},
+			expectedError: domain.ErrInvalidPrice,
+		},
+		{
+			name: "Invalid price - missing",
+			offer: requestedShipmentOffer{
+				Company: 1,
+				Origin:  "CNSGH",
 				Date:    "2023-01-01",
 			},
 			expectedError: domain.ErrInvalidPrice,
@@ -304,27 +662,216 @@ func TestShipmentHandler_validateAndParseShipment(t *testing.T) {
 			name: "Invalid origin",
 			offer: requestedShipmentOffer{
 				Company: 1,
-				Price:   100,
+				Price:   "100",
 				Origin:  "invalid",
 				Date:    "2023-01-01",
 			},
 			expectedError: domain.ErrInvalidOriginPort,
 		},
+		{
+			name: "Valid request with an origin of the registry",
+			offer: requestedShipmentOffer{
+				Company: 1,
+				Price:   "100",
+				Origin:  "NLRTM",
+				Date:    "2023-01-01",
+			},
+			expectedShipmentUnit: domain.ShipmentUnit{
+				Origin: "NLRTM",
+				ShipmentQuote: domain.ShipmentQuote{
+					Company:   1,
+					Price:     10000,
+					Quoted:    domain.Money{Amount: 10000},
+					Date:      time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+					Equipment: domain.Equipment20DV,
+				},
+			},
+		},
 		{
 			name: "Invalid date",
 			offer: requestedShipmentOffer{
 				Company: 1,
-				Price:   100,
-				Origin:  OriginShanghai,
+				Price:   "100",
+				Origin:  "CNSGH",
 				Date:    "01-01-2023",
 			},
 			expectedError: domain.ErrInvalidDate,
 		},
+		{
+			name: "Valid request with validity date",
+			offer: requestedShipmentOffer{
+				Company:    1,
+				Price:      "100",
+				Origin:     "CNSGH",
+				Date:       "2023-01-01",
+				ValidUntil: "2023-01-31",
+			},
+			expectedError: nil,
+			expectedShipmentUnit: domain.ShipmentUnit{
+				Origin: "CNSGH",
+				ShipmentQuote: domain.ShipmentQuote{
+					Company:    1,
+					Price:      10000,
+					Quoted:     domain.Money{Amount: 10000},
+					Date:       time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+					ValidUntil: time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+					Equipment:  domain.Equipment20DV,
+				},
+			},
+		},
+		{
+			name: "Valid request with a destination",
+			offer: requestedShipmentOffer{
+				Company:     1,
+				Price:       "100",
+				Origin:      "CNSGH",
+				Destination: "NLRTM",
+				Date:        "2023-01-01",
+			},
+			expectedShipmentUnit: domain.ShipmentUnit{
+				Origin:      "CNSGH",
+				Destination: "NLRTM",
+				ShipmentQuote: domain.ShipmentQuote{
+					Company:   1,
+					Price:     10000,
+					Quoted:    domain.Money{Amount: 10000},
+					Date:      time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+					Equipment: domain.Equipment20DV,
+				},
+			},
+		},
+		{
+			name: "Valid request with the wildcard destination",
+			offer: requestedShipmentOffer{
+				Company:     1,
+				Price:       "100",
+				Origin:      "CNSGH",
+				Destination: domain.WildcardDestination,
+				Date:        "2023-01-01",
+			},
+			expectedShipmentUnit: domain.ShipmentUnit{
+				Origin: "CNSGH",
+				ShipmentQuote: domain.ShipmentQuote{
+					Company:   1,
+					Price:     10000,
+					Quoted:    domain.Money{Amount: 10000},
+					Date:      time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+					Equipment: domain.Equipment20DV,
+				},
+			},
+		},
+		{
+			name: "Invalid destination port",
+			offer: requestedShipmentOffer{
+				Company:     1,
+				Price:       "100",
+				Origin:      "CNSGH",
+				Destination: "nlrtm",
+				Date:        "2023-01-01",
+			},
+			expectedError: domain.ErrInvalidDestinationPort,
+		},
+		{
+			name: "Destination port is the origin",
+			offer: requestedShipmentOffer{
+				Company:     1,
+				Price:       "100",
+				Origin:      "CNSGH",
+				Destination: "CNSGH",
+				Date:        "2023-01-01",
+			},
+			expectedError: domain.ErrInvalidDestinationPort,
+		},
+		{
+			name: "Valid request with an equipment type",
+			offer: requestedShipmentOffer{
+				Company:   1,
+				Price:     "100",
+				Origin:    "CNSGH",
+				Date:      "2023-01-01",
+				Equipment: "40RF",
+			},
+			expectedShipmentUnit: domain.ShipmentUnit{
+				Origin: "CNSGH",
+				ShipmentQuote: domain.ShipmentQuote{
+					Company:   1,
+					Price:     10000,
+					Quoted:    domain.Money{Amount: 10000},
+					Date:      time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+					Equipment: domain.Equipment40RF,
+				},
+			},
+		},
+		{
+			name: "Valid request with a currency",
+			offer: requestedShipmentOffer{
+				Company:  1,
+				Price:    "100",
+				Origin:   "CNSGH",
+				Date:     "2023-01-01",
+				Currency: "EUR",
+			},
+			expectedShipmentUnit: domain.ShipmentUnit{
+				Origin: "CNSGH",
+				ShipmentQuote: domain.ShipmentQuote{
+					Company:   1,
+					Price:     10000,
+					Quoted:    domain.Money{Amount: 10000, Currency: "EUR"},
+					Date:      time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+					Equipment: domain.Equipment20DV,
+				},
+			},
+		},
+		{
+			name: "Invalid currency",
+			offer: requestedShipmentOffer{
+				Company:  1,
+				Price:    "100",
+				Origin:   "CNSGH",
+				Date:     "2023-01-01",
+				Currency: "euro",
+			},
+			expectedError: domain.ErrInvalidCurrency,
+		},
+		{
+			name: "Invalid equipment type",
+			offer: requestedShipmentOffer{
+				Company:   1,
+				Price:     "100",
+				Origin:    "CNSGH",
+				Date:      "2023-01-01",
+				Equipment: "40ft",
+			},
+			expectedError: domain.ErrInvalidEquipment,
+		},
+		{
+			name: "Invalid validity date",
+			offer: requestedShipmentOffer{
+				Company:    1,
+				Price:      "100",
+				Origin:     "CNSGH",
+				Date:       "2023-01-01",
+				ValidUntil: "31-01-2023",
+			},
+			expectedError: domain.ErrInvalidValidity,
+		},
+		{
+			name: "Validity date before date",
+			offer: requestedShipmentOffer{
+				Company:    1,
+				Price:      "100",
+				Origin:     "CNSGH",
+				Date:       "2023-01-01",
+				ValidUntil: "2022-12-31",
+			},
+			expectedError: domain.ErrInvalidValidity,
+		},
 	}
 
+	handler := CreateShipmentHandler(nil)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			shipment, err := validateAndParseShipment(tt.offer)
+			shipment, err := handler.validateAndParseShipment(tt.offer)
 			if !errors.Is(err, tt.expectedError) {
 				t.Errorf("expected error %v, got %v", tt.expectedError, err)
 			}
@@ -335,3 +882,225 @@ func TestShipmentHandler_validateAndParseShipment(t *testing.T) {
 	}
 
 }
+
+func TestShipmentHandler_GetQuoteHistory(t *testing.T) {
+	shipmentRepository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	shipmentService, err := app.CreateShipmentService(shipmentRepository)
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, shipmentService)
+
+	// Submit an accepted offer, a duplicate of it and an offer rejected by the validation
+	for _, offer := range []requestedShipmentOffer{
+		{Company: 1, Price: "100.25", Origin: "CNSGH", Date: "2023-01-01", ValidUntil: "2023-01-31"},
+		{Company: 1, Price: "90", Origin: "CNSGH", Date: "2023-01-01"},
+		{Company: 1, Price: "0", Origin: "CNSGH", Date: "2023-02-01"},
+		{Company: 1, Price: "120", Origin: "CNSGH", Destination: "NLRTM", Date: "2023-02-01", Equipment: "40HC"},
+		{Company: 1, Price: "80", Origin: "CNSGH", Date: "2023-03-01", Currency: "GBP"},
+	} {
+		body, err := json.Marshal(offer)
+		if err != nil {
+			t.Fatalf("failed to marshal JSON body: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		accept         string
+		expectedStatus int
+		expectedBody   interface{}
+	}{
+		{
+			name:           "valid request",
+			path:           "/origins/CNSGH/companies/1/history",
+			expectedStatus: http.StatusOK,
+			expectedBody: quoteHistoryResponse{
+				Origin:  "CNSGH",
+				Company: 1,
+				History: []quoteHistoryEntry{
+					{Destination: "*", Status: "accepted", Price: 100.0, Currency: "USD", Date: "2023-01-01", ValidUntil: "2023-01-31", Equipment: "20DV"},
+					{Destination: "*", Status: "rejected", Reason: domain.ErrDuplicateQuote.Error(), Price: 90.0, Currency: "USD", Date: "2023-01-01", Equipment: "20DV"},
+					{Destination: "*", Status: "rejected", Reason: domain.ErrInvalidPrice.Error(), Price: 0.0, Date: "2023-02-01", Equipment: "20DV"},
+					{Destination: "NLRTM", Status: "accepted", Price: 120.0, Currency: "USD", Date: "2023-02-01", Equipment: "40HC"},
+					{Destination: "*", Status: "rejected", Reason: domain.ErrUnsupportedCurrency.Error(), Price: 80.0, Currency: "GBP", Date: "2023-03-01", Equipment: "20DV"},
+				},
+			},
+		},
+		{
+			name:           "valid request - decimal prices",
+			path:           "/origins/CNSGH/companies/1/history",
+			accept:         "application/json; prices=decimal",
+			expectedStatus: http.StatusOK,
+			expectedBody: quoteHistoryResponse{
+				Origin:  "CNSGH",
+				Company: 1,
+				History: []quoteHistoryEntry{
+					{Destination: "*", Status: "accepted", Price: "100.25", Currency: "USD", Date: "2023-01-01", ValidUntil: "2023-01-31", Equipment: "20DV"},
+					{Destination: "*", Status: "rejected", Reason: domain.ErrDuplicateQuote.Error(), Price: "90.00", Currency: "USD", Date: "2023-01-01", Equipment: "20DV"},
+					{Destination: "*", Status: "rejected", Reason: domain.ErrInvalidPrice.Error(), Price: "0.00", Date: "2023-02-01", Equipment: "20DV"},
+					{Destination: "NLRTM", Status: "accepted", Price: "120.00", Currency: "USD", Date: "2023-02-01", Equipment: "40HC"},
+					{Destination: "*", Status: "rejected", Reason: domain.ErrUnsupportedCurrency.Error(), Price: "80.00", Currency: "GBP", Date: "2023-03-01", Equipment: "20DV"},
+				},
+			},
+		},
+		{
+			name:           "no history",
+			path:           "/origins/CNSGH/companies/2/history",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   map[string]string{"error": domain.ErrNoQuoteHistory.Error()},
+		},
+		{
+			name:           "invalid company",
+			path:           "/origins/CNSGH/companies/first/history",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]string{"error": domain.ErrInvalidCompany.Error()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			// Check the status code
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			// Decode the response body like the expected one, the receive times are not compared
+			actualBody := reflect.New(reflect.TypeOf(tt.expectedBody))
+			if err := json.NewDecoder(rec.Body).Decode(actualBody.Interface()); err != nil {
+				t.Fatalf("failed to decode response body: %v", err)
+			}
+			if response, ok := actualBody.Interface().(*quoteHistoryResponse); ok {
+				for i := range response.History {
+					if response.History[i].ReceivedAt == "" {
+						t.Errorf("expected receive time of entry %d", i)
+					}
+					response.History[i].ReceivedAt = ""
+				}
+			}
+
+			if !reflect.DeepEqual(actualBody.Elem().Interface(), tt.expectedBody) {
+				t.Errorf("expected body %+v, got %+v", tt.expectedBody, actualBody.Elem().Interface())
+			}
+		})
+	}
+}
+
+func TestShipmentHandler_GetExcludedQuotes(t *testing.T) {
+	shipmentRepository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	date := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, price := range []int{100, 85000, 90000, 95000, 100000} {
+		shipment := domain.ShipmentUnit{Origin: "CNSGH", ShipmentQuote: domain.ShipmentQuote{Company: i + 1, Price: price, Date: date}}
+		if err = shipmentRepository.AddOrUpdate(shipment); err != nil {
+			t.Fatalf("failed to add shipment unit: %v", err)
+		}
+	}
+
+	shipmentService, err := app.CreateShipmentService(shipmentRepository, app.WithOutlierFilter(app.IQRFilter{Multiplier: app.DefaultIQRMultiplier}))
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+	shipmentServiceWithoutFilter, err := app.CreateShipmentService(shipmentRepository)
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	reason := "price 1.00 is below the lower fence 700.00 of the interquartile range"
+
+	tests := []struct {
+		name           string
+		service        domain.ShipmentService
+		query          string
+		accept         string
+		expectedStatus int
+		expectedBody   any
+	}{
+		{
+			name:           "valid request",
+			service:        shipmentService,
+			expectedStatus: http.StatusOK,
+			expectedBody: excludedQuotesResponse{Excluded: []excludedQuote{
+				{Origin: "CNSGH", Destination: "*", Equipment: "20DV", Company: 1, Price: 1.0, Date: "2023-01-01", Reason: reason},
+			}},
+		},
+		{
+			name:           "valid request - decimal prices",
+			service:        shipmentService,
+			query:          "?equipment=20DV",
+			accept:         "application/json; prices=decimal",
+			expectedStatus: http.StatusOK,
+			expectedBody: excludedQuotesResponse{Excluded: []excludedQuote{
+				{Origin: "CNSGH", Destination: "*", Equipment: "20DV", Company: 1, Price: "1.00", Date: "2023-01-01", Reason: reason},
+			}},
+		},
+		{
+			name:           "valid request - other equipment type",
+			service:        shipmentService,
+			query:          "?equipment=40HC",
+			expectedStatus: http.StatusOK,
+			expectedBody:   excludedQuotesResponse{Excluded: []excludedQuote{}},
+		},
+		{
+			name:           "valid request - without outlier filter",
+			service:        shipmentServiceWithoutFilter,
+			expectedStatus: http.StatusOK,
+			expectedBody:   excludedQuotesResponse{Excluded: []excludedQuote{}},
+		},
+		{
+			name:           "invalid equipment type",
+			service:        shipmentService,
+			query:          "?equipment=reefer",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]string{"error": domain.ErrInvalidEquipment.Error()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			RegisterRoutes(mux, tt.service)
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/outliers"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			// Check the status code
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			// Decode the response body like the expected one
+			actualBody := reflect.New(reflect.TypeOf(tt.expectedBody))
+			if err := json.NewDecoder(rec.Body).Decode(actualBody.Interface()); err != nil {
+				t.Fatalf("failed to decode response body: %v", err)
+			}
+			if !reflect.DeepEqual(actualBody.Elem().Interface(), tt.expectedBody) {
+				t.Errorf("expected body %+v, got %+v", tt.expectedBody, actualBody.Elem().Interface())
+			}
+		})
+	}
+}
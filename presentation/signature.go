@@ -0,0 +1,229 @@
+package presentation
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"quoteship/domain"
+)
+
+const (
+	SignatureHeader          = "X-Signature"           // SignatureHeader carries the hex encoded HMAC-SHA256 signature of a signed submission.
+	SignatureCompanyHeader   = "X-Signature-Company"   // SignatureCompanyHeader carries the identifier of the company that signed the submission.
+	SignatureTimestampHeader = "X-Signature-Timestamp" // SignatureTimestampHeader carries the Unix time in seconds the submission was signed at.
+	SignatureNonceHeader     = "X-Signature-Nonce"     // SignatureNonceHeader carries a value the company never reuses, so the submission cannot be replayed.
+
+	maxNonceLength      = 128     // maxNonceLength is the maximum length of a nonce, so the nonce cache stays small.
+	maxSignedBodyLength = 1 << 20 // maxSignedBodyLength is the maximum length of a signed body, which is read at once to be verified.
+)
+
+var (
+	ErrInvalidSigningWindow = errors.New("signing window must be positive")
+	ErrInvalidSignature     = errors.New("invalid request signature")
+	ErrStaleSignature       = errors.New("request signed outside the signing window")
+	ErrReplayedRequest      = errors.New("request nonce already used")
+	ErrSignatureRequired    = errors.New("request signature required for the company")
+	ErrSignedBodyTooLarge   = errors.New("signed request body too large")
+)
+
+// RequestVerifier verifies the signed submissions. A signed submission carries the identifier of the company, the time
+// it was signed at and a nonce in the X-Signature-Company, X-Signature-Timestamp and X-Signature-Nonce headers, and
+// the HMAC-SHA256 of the timestamp, nonce and body, keyed with the secret of the company, in the X-Signature header.
+// A submission is only accepted if it was signed within the signing window, and with a nonce the company did not use
+// within the window, so a captured submission cannot be replayed.
+type RequestVerifier struct {
+	secrets domain.SigningSecretStore // secrets provides the secrets shared with the companies that sign their submissions.
+	window  time.Duration             // window is the maximum difference between the signing time of a submission and the time it is verified.
+	nonces  map[string]time.Time      // nonces maps the nonces used by every company to the time they can be reused, once their submission is stale.
+	pruned  time.Time                 // pruned is the last time the reusable nonces were removed from nonces.
+	mu      sync.Mutex                // mu synchronizes access to nonces and pruned.
+	now     func() time.Time          // now returns the current time the signatures are verified at, it is replaced in tests.
+}
+
+// NewRequestVerifier creates a RequestVerifier of the submissions signed with the secrets, within the window.
+func NewRequestVerifier(secrets domain.SigningSecretStore, window time.Duration) (*RequestVerifier, error) {
+	if window <= 0 {
+		return nil, ErrInvalidSigningWindow
+	}
+
+	return &RequestVerifier{secrets: secrets, window: window, nonces: make(map[string]time.Time), now: time.Now}, nil
+}
+
+// WithRequestSigning makes the submitted shipment offers, batches and tariffs be verified by the verifier before they
+// are decoded. The unsigned shipment offers are still accepted, except those of the companies that have a signing
+// secret. Without verifier, the signature headers are ignored.
+func WithRequestSigning(verifier *RequestVerifier) HandlerOption {
+	return func(h *ShipmentHandler) {
+		h.signatures = verifier
+	}
+}
+
+// Sign returns the hex encoded HMAC-SHA256 signature of a submission, the value of the X-Signature header. The
+// signature is keyed with the secret of the company and covers the timestamp and the nonce headers, and the body,
+// separated by newlines.
+func Sign(secret []byte, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "\n" + nonce + "\n"))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify reads the body of the request and verifies its signature. It returns the body and the company that signed it,
+// or a nil body and zero if the request is not signed, in which case the body is left unread.
+func (v *RequestVerifier) verify(request *http.Request) ([]byte, int, error) {
+	signature := request.Header.Get(SignatureHeader)
+	if signature == "" {
+		return nil, 0, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(request.Body, maxSignedBodyLength+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(body) > maxSignedBodyLength {
+		return nil, 0, ErrSignedBodyTooLarge
+	}
+
+	company, err := strconv.Atoi(request.Header.Get(SignatureCompanyHeader))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: invalid %s header", ErrInvalidSignature, SignatureCompanyHeader)
+	}
+	secret, exists := v.secrets.Secret(company)
+	if !exists {
+		return nil, 0, fmt.Errorf("%w: company %d has no signing secret", ErrInvalidSignature, company)
+	}
+
+	timestamp := request.Header.Get(SignatureTimestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: invalid %s header", ErrInvalidSignature, SignatureTimestampHeader)
+	}
+	nonce := request.Header.Get(SignatureNonceHeader)
+	if nonce == "" || len(nonce) > maxNonceLength {
+		return nil, 0, fmt.Errorf("%w: invalid %s header", ErrInvalidSignature, SignatureNonceHeader)
+	}
+
+	expected, _ := hex.DecodeString(Sign(secret, timestamp, nonce, body))
+	provided, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, provided) {
+		return nil, 0, ErrInvalidSignature
+	}
+
+	// Only the nonces of the valid signatures are recorded, so the nonces of a company cannot be used up by others
+	signedAt := time.Unix(seconds, 0)
+	if err := v.useNonce(company, nonce, signedAt); err != nil {
+		return nil, 0, err
+	}
+
+	return body, company, nil
+}
+
+// useNonce records the nonce of the company used by a submission signed at signedAt. It returns ErrStaleSignature if
+// the submission was not signed within the window, and ErrReplayedRequest if the company already used the nonce within
+// the window. The nonces can be reused once their submission is stale, and are removed once per window.
+func (v *RequestVerifier) useNonce(company int, nonce string, signedAt time.Time) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := v.now()
+	if signedAt.Before(now.Add(-v.window)) || signedAt.After(now.Add(v.window)) {
+		return ErrStaleSignature
+	}
+
+	if now.Sub(v.pruned) >= v.window {
+		for key, reusableAt := range v.nonces {
+			if now.After(reusableAt) {
+				delete(v.nonces, key)
+			}
+		}
+		v.pruned = now
+	}
+
+	key := strconv.Itoa(company) + ":" + nonce
+	if reusableAt, used := v.nonces[key]; used && !now.After(reusableAt) {
+		return ErrReplayedRequest
+	}
+	v.nonces[key] = signedAt.Add(v.window)
+
+	return nil
+}
+
+// checkSigner reports whether the shipment offer of the company can be accepted from the signer, the company that
+// signed the request or zero if the request is not signed. A signed shipment offer must be of the company that signed
+// it, and the shipment offers of the companies that have a signing secret must be signed.
+func (v *RequestVerifier) checkSigner(signer, company int) error {
+	if signer != 0 && signer != company {
+		return ErrCompanyMismatch
+	}
+	if _, exists := v.secrets.Secret(company); signer == 0 && exists {
+		return ErrSignatureRequired
+	}
+
+	return nil
+}
+
+// verifySignature verifies the signature of the request before its body is decoded, with request signing, and responds
+// with the error otherwise. The body of a signed request is read at once to be verified, and replaced by a copy. It
+// returns the company that signed the request, or zero if the request is not signed or without request signing.
+func (h ShipmentHandler) verifySignature(writer http.ResponseWriter, request *http.Request) (int, bool) {
+	if h.signatures == nil {
+		return 0, true
+	}
+
+	body, signer, err := h.signatures.verify(request)
+	if err != nil {
+		writeSignatureError(writer, err)
+		return 0, false
+	}
+	if body != nil {
+		request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	return signer, true
+}
+
+// authorizeSigner reports whether the shipment offers of every company can be accepted from the signer, see
+// checkSigner, and responds with the error otherwise. Without request signing, every company is authorized.
+func (h ShipmentHandler) authorizeSigner(writer http.ResponseWriter, signer int, companies ...int) bool {
+	if h.signatures == nil {
+		return true
+	}
+
+	for _, company := range companies {
+		if err := h.signatures.checkSigner(signer, company); err != nil {
+			writeSignatureError(writer, err)
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeSignatureError writes the error response of a submission whose signature was rejected.
+func writeSignatureError(writer http.ResponseWriter, err error) {
+	slog.Warn("rejected signed submission", "error", err)
+
+	switch {
+	case errors.Is(err, ErrSignedBodyTooLarge):
+		writeJSONResponse(writer, http.StatusRequestEntityTooLarge, map[string]string{"error": ErrSignedBodyTooLarge.Error()})
+	case errors.Is(err, ErrCompanyMismatch):
+		writeJSONResponse(writer, http.StatusForbidden, map[string]string{"error": ErrCompanyMismatch.Error()})
+	case errors.Is(err, ErrInvalidSignature):
+		writeJSONResponse(writer, http.StatusUnauthorized, map[string]string{"error": ErrInvalidSignature.Error()})
+	case errors.Is(err, ErrStaleSignature), errors.Is(err, ErrReplayedRequest), errors.Is(err, ErrSignatureRequired):
+		writeJSONResponse(writer, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	default:
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": ErrInvalidRequestPayload.Error()})
+	}
+}
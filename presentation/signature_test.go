@@ -0,0 +1,358 @@
+package presentation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"quoteship/app"
+	"quoteship/persistence"
+)
+
+// newSigningMux returns a ServeMux with the registered routes of a new service, whose submissions of company 1 are
+// signed with the secret, and the time the signatures are verified at.
+func newSigningMux(t *testing.T, secret string) (*http.ServeMux, time.Time) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	if err := os.WriteFile(path, []byte(`{"secrets": [{"company": 1, "secret": "`+secret+`"}]}`), 0o600); err != nil {
+		t.Fatalf("failed to write signing secrets file: %v", err)
+	}
+	secrets, err := persistence.OpenSigningSecrets(path)
+	if err != nil {
+		t.Fatalf("failed to open signing secrets: %v", err)
+	}
+	verifier, err := NewRequestVerifier(secrets, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create request verifier: %v", err)
+	}
+	now := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+	verifier.now = func() time.Time { return now }
+
+	shipmentRepository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	shipmentService, err := app.CreateShipmentService(shipmentRepository)
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, shipmentService, WithRequestSigning(verifier))
+
+	return mux, now
+}
+
+// signRequest sets the signature headers of the request, signed by the company with the secret at signedAt.
+func signRequest(req *http.Request, company, secret, nonce string, signedAt time.Time, body string) {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	req.Header.Set(SignatureCompanyHeader, company)
+	req.Header.Set(SignatureTimestampHeader, timestamp)
+	req.Header.Set(SignatureNonceHeader, nonce)
+	req.Header.Set(SignatureHeader, Sign([]byte(secret), timestamp, nonce, []byte(body)))
+}
+
+func TestShipmentHandler_SubmitShipmentOffer_signed(t *testing.T) {
+	secret := "4c8a0f2d9b7e61a35f0c8d2e7b9a1f64"
+	mux, now := newSigningMux(t, secret)
+
+	// The steps run in order, each one sees the nonces used by the previous ones
+	tests := []struct {
+		name           string
+		body           string
+		unsigned       bool
+		company        string
+		signedAt       time.Time
+		nonce          string
+		secret         string
+		signedBody     string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "signed offer",
+			body:           `{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}`,
+			company:        "1",
+			signedAt:       now,
+			nonce:          "6f1d2c",
+			secret:         secret,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "replayed offer",
+			body:           `{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}`,
+			company:        "1",
+			signedAt:       now,
+			nonce:          "6f1d2c",
+			secret:         secret,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"` + ErrReplayedRequest.Error() + `"}` + "\n",
+		},
+		{
+			name:           "offer signed with another secret",
+			body:           `{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-02"}`,
+			company:        "1",
+			signedAt:       now,
+			nonce:          "a90e4b",
+			secret:         strings.Repeat("0", 32),
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"` + ErrInvalidSignature.Error() + `"}` + "\n",
+		},
+		{
+			name:           "tampered offer",
+			body:           `{"company": 1, "price": 1, "origin": "CNSGH", "date": "2023-01-02"}`,
+			company:        "1",
+			signedAt:       now,
+			nonce:          "a90e4b",
+			secret:         secret,
+			signedBody:     `{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-02"}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"` + ErrInvalidSignature.Error() + `"}` + "\n",
+		},
+		{
+			name:           "offer signed before the window",
+			body:           `{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-02"}`,
+			company:        "1",
+			signedAt:       now.Add(-6 * time.Minute),
+			nonce:          "a90e4b",
+			secret:         secret,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"` + ErrStaleSignature.Error() + `"}` + "\n",
+		},
+		{
+			name:           "offer signed with the nonce of a rejected offer",
+			body:           `{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-02"}`,
+			company:        "1",
+			signedAt:       now.Add(time.Minute),
+			nonce:          "a90e4b",
+			secret:         secret,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "offer signed by another company",
+			body:           `{"company": 2, "price": 100, "origin": "CNSGH", "date": "2023-01-03"}`,
+			company:        "1",
+			signedAt:       now,
+			nonce:          "c35f07",
+			secret:         secret,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"` + ErrCompanyMismatch.Error() + `"}` + "\n",
+		},
+		{
+			name:           "offer signed by a company without secret",
+			body:           `{"company": 2, "price": 100, "origin": "CNSGH", "date": "2023-01-03"}`,
+			company:        "2",
+			signedAt:       now,
+			nonce:          "d21b98",
+			secret:         secret,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"` + ErrInvalidSignature.Error() + `"}` + "\n",
+		},
+		{
+			name:           "unsigned offer of a company with secret",
+			body:           `{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-03"}`,
+			unsigned:       true,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"` + ErrSignatureRequired.Error() + `"}` + "\n",
+		},
+		{
+			name:           "unsigned offer of a company without secret",
+			body:           `{"company": 2, "price": 100, "origin": "CNSGH", "date": "2023-01-03"}`,
+			unsigned:       true,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/quotes", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if !tt.unsigned {
+				signedBody := tt.body
+				if tt.signedBody != "" {
+					signedBody = tt.signedBody
+				}
+				signRequest(req, tt.company, tt.secret, tt.nonce, tt.signedAt, signedBody)
+			}
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			// Check the status code
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			// Check the response body
+			if rec.Body.String() != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestShipmentHandler_SubmitShipmentOffers_signed(t *testing.T) {
+	secret := "4c8a0f2d9b7e61a35f0c8d2e7b9a1f64"
+	mux, now := newSigningMux(t, secret)
+
+	// The steps run in order, each one sees the nonces used by the previous ones
+	tests := []struct {
+		name           string
+		path           string
+		contentType    string
+		body           string
+		unsigned       bool
+		company        string
+		nonce          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "signed batch",
+			path:           "/quotes:batch",
+			contentType:    "application/json",
+			body:           `[{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}]`,
+			company:        "1",
+			nonce:          "6f1d2c",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"accepted":1,"rejected":0,"results":[{"index":0,"status":"accepted"}]}` + "\n",
+		},
+		{
+			name:           "replayed batch",
+			path:           "/quotes:batch",
+			contentType:    "application/json",
+			body:           `[{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}]`,
+			company:        "1",
+			nonce:          "6f1d2c",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"` + ErrReplayedRequest.Error() + `"}` + "\n",
+		},
+		{
+			name:           "signed batch with an offer of another company",
+			path:           "/quotes:batch",
+			contentType:    "application/json",
+			body:           `[{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-02"}, {"company": 2, "price": 100, "origin": "CNSGH", "date": "2023-01-02"}]`,
+			company:        "1",
+			nonce:          "a90e4b",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"` + ErrCompanyMismatch.Error() + `"}` + "\n",
+		},
+		{
+			name:           "unsigned batch with an offer of a company with secret",
+			path:           "/quotes:batch",
+			contentType:    "application/x-ndjson",
+			body:           `{"company": 2, "price": 100, "origin": "CNSGH", "date": "2023-01-02"}` + "\n" + `{"company": 1, "price": 1, "origin": "CNSGH", "date": "2023-01-02"}`,
+			unsigned:       true,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"` + ErrSignatureRequired.Error() + `"}` + "\n",
+		},
+		{
+			name:           "unsigned batch of a company without secret",
+			path:           "/quotes:batch",
+			contentType:    "application/json",
+			body:           `[{"company": 2, "price": 100, "origin": "CNSGH", "date": "2023-01-02"}]`,
+			unsigned:       true,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"accepted":1,"rejected":0,"results":[{"index":0,"status":"accepted"}]}` + "\n",
+		},
+		{
+			name:           "signed tariff",
+			path:           "/quotes:import",
+			contentType:    "text/csv",
+			body:           "company,price,origin,date\n1,100,CNSGH,2023-01-03\n",
+			company:        "1",
+			nonce:          "c35f07",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"accepted":1,"rejected":0,"results":[{"index":0,"line":2,"status":"accepted"}]}` + "\n",
+		},
+		{
+			name:           "signed tariff with a row of another company",
+			path:           "/quotes:import",
+			contentType:    "text/csv",
+			body:           "company,price,origin,date\n1,100,CNSGH,2023-01-04\n2,100,CNSGH,2023-01-04\n",
+			company:        "1",
+			nonce:          "d21b98",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"` + ErrCompanyMismatch.Error() + `"}` + "\n",
+		},
+		{
+			name:           "unsigned tariff with a row of a company with secret",
+			path:           "/quotes:import",
+			contentType:    "text/csv",
+			body:           "company,price,origin,date\n2,100,CNSGH,2023-01-04\n1,1,CNSGH,2023-01-04\n",
+			unsigned:       true,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"` + ErrSignatureRequired.Error() + `"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if !tt.unsigned {
+				signRequest(req, tt.company, secret, tt.nonce, now, tt.body)
+			}
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			// Check the status code
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			// Check the response body
+			if rec.Body.String() != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequestVerifier_useNonce(t *testing.T) {
+	verifier, err := NewRequestVerifier(nil, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create request verifier: %v", err)
+	}
+	now := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+	verifier.now = func() time.Time { return now }
+
+	if err := verifier.useNonce(1, "6f1d2c", now); err != nil {
+		t.Fatalf("expected nonce to be accepted, got %v", err)
+	}
+	if err := verifier.useNonce(2, "6f1d2c", now); err != nil {
+		t.Errorf("expected nonce of another company to be accepted, got %v", err)
+	}
+
+	// The nonce is kept until its submission is stale, then it can be used again
+	now = now.Add(time.Minute)
+	if err := verifier.useNonce(1, "6f1d2c", now); !errors.Is(err, ErrReplayedRequest) {
+		t.Errorf("expected error %v, got %v", ErrReplayedRequest, err)
+	}
+	now = now.Add(time.Second)
+	if err := verifier.useNonce(1, "6f1d2c", now); err != nil {
+		t.Errorf("expected nonce to be accepted once stale, got %v", err)
+	}
+
+	// The stale nonces are removed once per window
+	now = now.Add(time.Minute)
+	if err := verifier.useNonce(1, "7a2e90", now); err != nil {
+		t.Fatalf("expected nonce to be accepted, got %v", err)
+	}
+	if len(verifier.nonces) != 2 {
+		t.Errorf("expected the stale nonces to be removed, got %d nonces", len(verifier.nonces))
+	}
+
+	if _, err := NewRequestVerifier(nil, 0); !errors.Is(err, ErrInvalidSigningWindow) {
+		t.Errorf("expected error %v, got %v", ErrInvalidSigningWindow, err)
+	}
+}
@@ -0,0 +1,370 @@
+package presentation
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"quoteship/domain"
+)
+
+const (
+	DefaultDelimiter = ',' // DefaultDelimiter separates the fields of a CSV tariff when no delimiter is provided.
+
+	csvContentType     = "text/csv"  // csvContentType is the media type of a CSV tariff, as defined by RFC 4180.
+	delimiterParameter = "delimiter" // delimiterParameter is the query parameter that selects the delimiter of a CSV tariff.
+	headerParameter    = "header"    // headerParameter is the media type parameter of a CSV tariff that tells whether its first row is a header, "present" or "absent".
+)
+
+var (
+	ErrInvalidDelimiter  = errors.New("invalid CSV delimiter")
+	ErrInvalidCSVRow     = errors.New("invalid CSV row")
+	ErrTariffNotImported = errors.New("tariff not imported")
+)
+
+// tariffColumns are the columns of a CSV tariff, in the order of the rows of a tariff without header. The company,
+// price, origin and date are required, the other columns are optional and can be left out of the rows.
+var tariffColumns = []string{"company", "price", "origin", "date", "destination", "validUntil", "equipment", "currency"}
+
+// requiredTariffColumns is the number of leading tariffColumns a tariff with a header must have.
+const requiredTariffColumns = 4
+
+// TariffError is a row of a CSV tariff that was rejected, with the line it starts on.
+type TariffError struct {
+	Line   int    // Line is the line the row starts on, starting at 1.
+	Reason string // Reason explains why the row was rejected, followed by the messages of its invalid fields.
+}
+
+// Error returns the line and the reason of the rejected row, e.g., "line 3: invalid price provided".
+func (e TariffError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+// ImportTariff posts the CSV tariff read from the reader, with fields separated by the delimiter, to the tariff import
+// endpoint of the service at the server URL (e.g., "http://localhost:3142"), authenticated with the API key unless it
+// is empty. It returns the number of rows that were accepted and the rejected rows in the order of their lines, or an
+// error wrapping ErrTariffNotImported if the service did not import the tariff.
+func ImportTariff(ctx context.Context, client *http.Client, serverURL, apiKey string, reader io.Reader, delimiter rune) (int, []TariffError, error) {
+	endpoint, err := url.JoinPath(serverURL, "/quotes:import")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"?"+url.Values{delimiterParameter: {string(delimiter)}}.Encode(), reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	request.Header.Set("Content-Type", csvContentType)
+	if apiKey != "" {
+		request.Header.Set(apiKeyHeader, apiKey)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		var errorResponse struct {
+			Error string `json:"error"` // Error is the reason the service did not import the tariff.
+		}
+		if err = json.NewDecoder(response.Body).Decode(&errorResponse); err != nil || errorResponse.Error == "" {
+			errorResponse.Error = http.StatusText(response.StatusCode)
+		}
+		return 0, nil, fmt.Errorf("%w: %d %s", ErrTariffNotImported, response.StatusCode, errorResponse.Error)
+	}
+
+	var imported batchSubmissionResponse
+	if err = json.NewDecoder(response.Body).Decode(&imported); err != nil {
+		return 0, nil, fmt.Errorf("%w: %w", ErrTariffNotImported, err)
+	}
+
+	var tariffErrors []TariffError
+	for _, result := range imported.Results {
+		if result.Status == string(domain.AuditAccepted) {
+			continue
+		}
+
+		reason := result.Reason
+		for _, fieldError := range result.Errors {
+			reason += "; " + fieldError.Message
+		}
+		tariffErrors = append(tariffErrors, TariffError{Line: result.Line, Reason: reason})
+	}
+
+	return imported.Accepted, tariffErrors, nil
+}
+
+// ParseDelimiter parses the delimiter of a CSV tariff: a single character other than a quote or a line break, "tab" or
+// "semicolon", as a semicolon cannot be written unescaped in a query string. An empty delimiter is the
+// DefaultDelimiter.
+func ParseDelimiter(value string) (rune, error) {
+	switch value {
+	case "":
+		return DefaultDelimiter, nil
+	case "tab", `\t`:
+		return '\t', nil
+	case "semicolon":
+		return ';', nil
+	}
+
+	delimiter, size := utf8.DecodeRuneInString(value)
+	if size != len(value) || delimiter == utf8.RuneError || delimiter == '"' || delimiter == '\r' || delimiter == '\n' {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidDelimiter, value)
+	}
+
+	return delimiter, nil
+}
+
+// ImportTariff is an HTTP handler that submits the rows of a CSV tariff, with "Content-Type: text/csv", like a batch
+// submission. Every row is a shipment offer with the columns company, price, origin and date, optionally followed by
+// destination, validUntil, equipment and currency. The first row is a header when it names any of these columns, and
+// the columns of the following rows are then read in the order of the header, the unknown ones being ignored. The
+// "header=present" or "header=absent" parameter of the Content-Type forces the first row to be read as a header or as a
+// shipment offer. The fields are separated by commas, or by the character of the optional `delimiter` query parameter
+// (e.g., ?delimiter=semicolon or ?delimiter=tab). The handler returns 200 OK with the outcome of every row, like the
+// batch submission, where each result has the line the row starts on. A body that is not CSV or a header without the
+// required columns is rejected with 400 Bad Request. With request signing, the tariff is verified like a batch.
+func (h ShipmentHandler) ImportTariff(writer http.ResponseWriter, request *http.Request) {
+	// Defer closing the request body after the function returns
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.Error("error closing request body", "error", err)
+		}
+	}(request.Body)
+
+	mediaType, params, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if mediaType != csvContentType {
+		slog.Warn("invalid content type", "content-type", request.Header.Get("Content-Type"))
+		writeJSONResponse(writer, http.StatusUnsupportedMediaType, map[string]string{"error": ErrInvalidContentType.Error()})
+		return
+	}
+
+	delimiter, err := ParseDelimiter(request.URL.Query().Get(delimiterParameter))
+	if err != nil {
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Verify the signature of the request before decoding it
+	signer, verified := h.verifySignature(writer, request)
+	if !verified {
+		return
+	}
+
+	elements, err := decodeCSVBatch(request.Body, delimiter, params[headerParameter])
+	if errors.Is(err, ErrBatchTooLarge) {
+		writeJSONResponse(writer, http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		slog.Error("error decoding CSV tariff", "error", err)
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Reject the whole tariff if any of its rows is of another company than the one of the API key
+	if !authorizeCompanies(writer, request, batchCompanies(elements)...) {
+		return
+	}
+
+	// Reject the whole tariff if it is signed by another company than the one of any of its rows, or if it is unsigned
+	// and any of its rows is of a company that signs its offers
+	if !h.authorizeSigner(writer, signer, batchCompanies(elements)...) {
+		return
+	}
+
+	writeJSONResponse(writer, http.StatusOK, h.submitBatch(elements))
+}
+
+// ExportTariff is an HTTP handler that returns the quotes of the latest published batch as a CSV tariff, the quotes the
+// expected rates are calculated from. The tariff has a header and one row per quote with the company, price, origin,
+// date, destination, validUntil and equipment columns, grouped by lane and sorted by price. The prices are decimals in
+// the base currency (e.g., 123.45), so the tariff can be imported again. The fields are separated by commas, or by the
+// character of the optional `delimiter` query parameter.
+func (h ShipmentHandler) ExportTariff(writer http.ResponseWriter, request *http.Request) {
+	delimiter, err := ParseDelimiter(request.URL.Query().Get(delimiterParameter))
+	if err != nil {
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	shipmentsByLane, err := h.s.GetLatestQuotes()
+	if err != nil {
+		slog.Error("error getting latest quotes", "error", err)
+		writeJSONResponse(writer, http.StatusInternalServerError, map[string]string{"error": ErrIntervalServerError.Error()})
+		return
+	}
+
+	writer.Header().Set("Content-Type", mime.FormatMediaType(csvContentType, map[string]string{"charset": "utf-8", headerParameter: "present"}))
+	writer.Header().Set("Content-Disposition", `attachment; filename="tariff.csv"`)
+	writer.WriteHeader(http.StatusOK)
+
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Comma = delimiter
+
+	// The currency is left out, the prices are in the base currency
+	rows := [][]string{tariffColumns[:len(tariffColumns)-1]}
+	for _, shipments := range shipmentsByLane {
+		lane := shipments.Lane()
+		for _, quote := range shipments.Quotes {
+			rows = append(rows, []string{
+				strconv.Itoa(quote.Company),
+				big.NewRat(int64(quote.Price), domain.MinorUnitsPerUnit).FloatString(priceDecimalPlaces),
+				lane.Origin,
+				formatDate(quote.Date),
+				lane.Destination,
+				formatValidUntil(quote.ValidUntil),
+				string(quote.EquipmentType()),
+			})
+		}
+	}
+
+	if err := csvWriter.WriteAll(rows); err != nil {
+		slog.Error("error writing response", "error", err)
+	}
+}
+
+// decodeCSVBatch decodes the rows of a CSV tariff with fields separated by the delimiter. The header parameter of the
+// media type tells whether the first row is a header, it is detected when empty. A row that is not a shipment offer,
+// e.g., with a company that is not a number, is returned with ErrInvalidCSVRow, while a body that is not CSV or a
+// header without the required columns fails the whole tariff.
+func decodeCSVBatch(reader io.Reader, delimiter rune, header string) ([]batchElement, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.Comma = delimiter
+	csvReader.FieldsPerRecord = -1 // The optional columns can be left out of every row
+	csvReader.TrimLeadingSpace = true
+
+	columns := tariffColumns
+	var elements []batchElement
+	for first := true; ; first = false {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidRequestPayload, err)
+		}
+		line, _ := csvReader.FieldPos(0)
+
+		if first && tariffHeader(record, header) {
+			if columns, err = headerColumns(record); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidRequestPayload, err)
+			}
+			continue
+		}
+
+		if len(elements) == MaxBatchSize {
+			return nil, ErrBatchTooLarge
+		}
+		elements = append(elements, decodeCSVRow(record, columns, line))
+	}
+
+	return elements, nil
+}
+
+// tariffHeader reports whether the first row of a CSV tariff is a header: as told by the header parameter, or when it
+// names any of the tariffColumns.
+func tariffHeader(record []string, header string) bool {
+	switch strings.ToLower(header) {
+	case "present":
+		return true
+	case "absent":
+		return false
+	}
+
+	for _, field := range record {
+		if tariffColumn(field) != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// headerColumns returns the tariffColumns named by the fields of the header, an empty string for the unknown ones. It
+// returns an error if a required column is missing or if a column is named twice.
+func headerColumns(record []string) ([]string, error) {
+	columns := make([]string, len(record))
+	named := make(map[string]bool, len(record))
+	for i, field := range record {
+		column := tariffColumn(field)
+		if column != "" && named[column] {
+			return nil, fmt.Errorf("duplicate column %q", column)
+		}
+		columns[i], named[column] = column, true
+	}
+
+	for _, column := range tariffColumns[:requiredTariffColumns] {
+		if !named[column] {
+			return nil, fmt.Errorf("missing column %q", column)
+		}
+	}
+
+	return columns, nil
+}
+
+// tariffColumn returns the tariffColumns entry named by the field regardless of case, or an empty string.
+func tariffColumn(field string) string {
+	for _, column := range tariffColumns {
+		if strings.EqualFold(strings.TrimSpace(field), column) {
+			return column
+		}
+	}
+
+	return ""
+}
+
+// decodeCSVRow decodes a row of a CSV tariff that starts on the line, with the fields in the order of the columns.
+func decodeCSVRow(record []string, columns []string, line int) batchElement {
+	element := batchElement{line: line}
+	if len(record) > len(columns) {
+		element.err = fmt.Errorf("%w: %d fields, at most %d expected", ErrInvalidCSVRow, len(record), len(columns))
+		return element
+	}
+
+	for i, field := range record {
+		field = strings.TrimSpace(field)
+		switch columns[i] {
+		case "company":
+			if field == "" {
+				continue
+			}
+			company, err := strconv.Atoi(field)
+			if err != nil {
+				element.err = fmt.Errorf("%w: company %q is not a number", ErrInvalidCSVRow, field)
+				return element
+			}
+			element.offer.Company = company
+		case "price":
+			element.offer.Price = json.Number(field)
+		case "origin":
+			element.offer.Origin = field
+		case "date":
+			element.offer.Date = field
+		case "destination":
+			element.offer.Destination = field
+		case "validUntil":
+			element.offer.ValidUntil = field
+		case "equipment":
+			element.offer.Equipment = field
+		case "currency":
+			element.offer.Currency = field
+		}
+	}
+
+	return element
+}
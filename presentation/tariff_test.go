@@ -0,0 +1,368 @@
+package presentation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"quoteship/app"
+	"quoteship/domain"
+	"quoteship/persistence"
+)
+
+func TestParseDelimiter(t *testing.T) {
+	tests := []struct {
+		name              string
+		value             string
+		expectedDelimiter rune
+		expectedError     error
+	}{
+		{name: "default", value: "", expectedDelimiter: ','},
+		{name: "comma", value: ",", expectedDelimiter: ','},
+		{name: "pipe", value: "|", expectedDelimiter: '|'},
+		{name: "tab", value: "tab", expectedDelimiter: '\t'},
+		{name: "escaped tab", value: `\t`, expectedDelimiter: '\t'},
+		{name: "semicolon", value: "semicolon", expectedDelimiter: ';'},
+		{name: "multibyte character", value: "§", expectedDelimiter: '§'},
+		{name: "several characters", value: ",,", expectedError: ErrInvalidDelimiter},
+		{name: "quote", value: `"`, expectedError: ErrInvalidDelimiter},
+		{name: "line break", value: "\n", expectedError: ErrInvalidDelimiter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delimiter, err := ParseDelimiter(tt.value)
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if delimiter != tt.expectedDelimiter {
+				t.Errorf("expected delimiter %q, got %q", tt.expectedDelimiter, delimiter)
+			}
+		})
+	}
+}
+
+func TestShipmentHandler_ImportTariff(t *testing.T) {
+	tests := []struct {
+		name             string
+		contentType      string
+		delimiter        string
+		body             string
+		expectedStatus   int
+		expectedBody     string
+		expectedResponse *batchSubmissionResponse
+	}{
+		{
+			name:           "Invalid Content-Type",
+			contentType:    "application/json",
+			body:           "1,100,CNSGH,2023-01-01\n",
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedBody:   fmt.Sprintf(`{"error":"%s"}`+"\n", ErrInvalidContentType.Error()),
+		},
+		{
+			name:           "Invalid delimiter",
+			contentType:    "text/csv",
+			delimiter:      "ab",
+			body:           "1,100,CNSGH,2023-01-01\n",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid CSV delimiter: \"ab\""}` + "\n",
+		},
+		{
+			name:           "Header without a required column",
+			contentType:    "text/csv",
+			body:           "company,price,origin\n1,100,CNSGH\n",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid request payload: missing column \"date\""}` + "\n",
+		},
+		{
+			name:           "Not CSV",
+			contentType:    "text/csv",
+			body:           "1,100,CNSGH,2023-01-01\n2,1\"00,CNSGH,2023-01-01\n",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid request payload: parse error on line 2, column 4: bare \" in non-quoted-field"}` + "\n",
+		},
+		{
+			name:        "Without header",
+			contentType: "text/csv",
+			body: "1,100,CNSGH,2023-01-01\n" +
+				"\n" +
+				"2,0,CNSGH,2023-01-01\n" +
+				"abc,100,CNSGH,2023-01-01\n" +
+				"3,150.50,CNSGH,2023-01-01,NLRTM,2023-01-31,40HC\n" +
+				"4,100,CNSGH,2023-01-01,,,,,extra\n",
+			expectedStatus: http.StatusOK,
+			expectedResponse: &batchSubmissionResponse{
+				Accepted: 2,
+				Rejected: 3,
+				Results: []batchItemResult{
+					{Index: 0, Line: 1, Status: "accepted"},
+					{Index: 1, Line: 3, Status: "rejected", Reason: domain.ErrInvalidPrice.Error(), Errors: []fieldError{
+						{Field: "price", Code: CodeOutOfRange, Message: "price must be between 1 and 99999"},
+					}},
+					{Index: 2, Line: 4, Status: "rejected", Reason: `invalid CSV row: company "abc" is not a number`},
+					{Index: 3, Line: 5, Status: "accepted"},
+					{Index: 4, Line: 6, Status: "rejected", Reason: "invalid CSV row: 9 fields, at most 8 expected"},
+				},
+			},
+		},
+		{
+			name:        "With header, semicolons and a multiline field",
+			contentType: "text/csv",
+			delimiter:   "semicolon",
+			body: "Origin; Date; Company; Notes; Price\n" +
+				"CNSGH;2023-01-01;1;\"first line\nsecond line\";100\n" +
+				"SGSIN;2023-01-01;2;;\n",
+			expectedStatus: http.StatusOK,
+			expectedResponse: &batchSubmissionResponse{
+				Accepted: 1,
+				Rejected: 1,
+				Results: []batchItemResult{
+					{Index: 0, Line: 2, Status: "accepted"},
+					{Index: 1, Line: 4, Status: "rejected", Reason: domain.ErrInvalidPrice.Error(), Errors: []fieldError{
+						{Field: "price", Code: CodeRequired, Message: "price is required"},
+					}},
+				},
+			},
+		},
+		{
+			name:           "Header forced absent",
+			contentType:    "text/csv; header=absent",
+			body:           "company,price,origin,date\n",
+			expectedStatus: http.StatusOK,
+			expectedResponse: &batchSubmissionResponse{
+				Accepted: 0,
+				Rejected: 1,
+				Results: []batchItemResult{
+					{Index: 0, Line: 1, Status: "rejected", Reason: `invalid CSV row: company "company" is not a number`},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shipmentRepository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+			if err != nil {
+				t.Fatalf("failed to create shipment repository: %v", err)
+			}
+			shipmentService, err := app.CreateShipmentService(shipmentRepository)
+			if err != nil {
+				t.Fatalf("failed to create shipment service: %v", err)
+			}
+			handler := CreateShipmentHandler(shipmentService)
+
+			target := "/quotes:import"
+			if tt.delimiter != "" {
+				target += "?delimiter=" + tt.delimiter
+			}
+			req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+
+			rec := httptest.NewRecorder()
+			handler.ImportTariff(rec, req)
+
+			// Check the status code
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			// Check the response body
+			if tt.expectedResponse == nil {
+				if rec.Body.String() != tt.expectedBody {
+					t.Errorf("expected body %q, got %q", tt.expectedBody, rec.Body.String())
+				}
+				return
+			}
+
+			var response batchSubmissionResponse
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response body: %v", err)
+			}
+			if !reflect.DeepEqual(&response, tt.expectedResponse) {
+				t.Errorf("expected response %+v, got %+v", tt.expectedResponse, response)
+			}
+		})
+	}
+}
+
+func TestShipmentHandler_ExportTariff(t *testing.T) {
+	shipmentRepository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	shipmentService, err := app.CreateShipmentService(shipmentRepository)
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+	handler := CreateShipmentHandler(shipmentService)
+
+	date := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	errs := shipmentService.SubmitShipments([]domain.ShipmentUnit{
+		{Origin: "CNSGH", ShipmentQuote: domain.ShipmentQuote{Company: 1, Quoted: domain.Money{Amount: 20000}, Date: date, Equipment: domain.DefaultEquipment}},
+		{Origin: "CNSGH", ShipmentQuote: domain.ShipmentQuote{Company: 2, Quoted: domain.Money{Amount: 15050}, Date: date, ValidUntil: date.AddDate(0, 1, 0), Equipment: domain.DefaultEquipment}},
+		{Origin: "SGSIN", Destination: "NLRTM", ShipmentQuote: domain.ShipmentQuote{Company: 1, Quoted: domain.Money{Amount: 30000}, Date: date, Equipment: domain.Equipment40HC}},
+	})
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("failed to submit shipments: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name                string
+		delimiter           string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "Default delimiter",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8; header=present",
+			expectedBody: "company,price,origin,date,destination,validUntil,equipment\n" +
+				"2,150.50,CNSGH,2023-01-01,*,2023-01-31,20DV\n" +
+				"1,200.00,CNSGH,2023-01-01,*,,20DV\n" +
+				"1,300.00,SGSIN,2023-01-01,NLRTM,,40HC\n",
+		},
+		{
+			name:                "Tab delimiter",
+			delimiter:           "tab",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8; header=present",
+			expectedBody: "company\tprice\torigin\tdate\tdestination\tvalidUntil\tequipment\n" +
+				"2\t150.50\tCNSGH\t2023-01-01\t*\t2023-01-31\t20DV\n" +
+				"1\t200.00\tCNSGH\t2023-01-01\t*\t\t20DV\n" +
+				"1\t300.00\tSGSIN\t2023-01-01\tNLRTM\t\t40HC\n",
+		},
+		{
+			name:                "Invalid delimiter",
+			delimiter:           "\"",
+			expectedStatus:      http.StatusBadRequest,
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"invalid CSV delimiter: \"\\\"\""}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/quotes:export"
+			if tt.delimiter != "" {
+				target += "?delimiter=" + tt.delimiter
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+
+			rec := httptest.NewRecorder()
+			handler.ExportTariff(rec, req)
+
+			// Check the status code
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if contentType := rec.Header().Get("Content-Type"); contentType != tt.expectedContentType {
+				t.Errorf("expected content type %q, got %q", tt.expectedContentType, contentType)
+			}
+			if rec.Body.String() != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, rec.Body.String())
+			}
+		})
+	}
+
+	// An exported tariff is imported again as is
+	exported := httptest.NewRecorder()
+	handler.ExportTariff(exported, httptest.NewRequest(http.MethodGet, "/quotes:export", nil))
+
+	importRepository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	importService, err := app.CreateShipmentService(importRepository)
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+	importMux := http.NewServeMux()
+	RegisterRoutes(importMux, importService)
+	server := httptest.NewServer(importMux)
+	defer server.Close()
+
+	accepted, tariffErrors, err := ImportTariff(context.Background(), server.Client(), server.URL, "", exported.Body, DefaultDelimiter)
+	if err != nil || accepted != 3 || len(tariffErrors) != 0 {
+		t.Fatalf("expected 3 accepted rows, got %d accepted, errors %v and %v", accepted, tariffErrors, err)
+	}
+
+	imported, err := importService.GetLatestQuotes()
+	if err != nil {
+		t.Fatalf("failed to get latest quotes: %v", err)
+	}
+	if original, _ := shipmentService.GetLatestQuotes(); !reflect.DeepEqual(imported, original) {
+		t.Errorf("expected imported quotes %+v, got %+v", original, imported)
+	}
+}
+
+func TestImportTariff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	content := `{"keys": [{"name": "operations", "sha256": "` + persistence.HashAPIKey("qs_admin") + `", "role": "admin"}]}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write API keys file: %v", err)
+	}
+	keys, err := persistence.OpenAPIKeyStore(path)
+	if err != nil {
+		t.Fatalf("failed to open API key store: %v", err)
+	}
+	shipmentRepository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	shipmentService, err := app.CreateShipmentService(shipmentRepository)
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, shipmentService, WithAPIKeys(keys))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tariff := "company|price|origin|date\n" +
+		"1|100|CNSGH|2023-01-01\n" +
+		"2|100|NYC|01-01-2023\n"
+
+	accepted, tariffErrors, err := ImportTariff(context.Background(), server.Client(), server.URL, "qs_admin", strings.NewReader(tariff), '|')
+	if err != nil {
+		t.Fatalf("failed to import tariff: %v", err)
+	}
+	if accepted != 1 {
+		t.Errorf("expected 1 accepted row, got %d", accepted)
+	}
+
+	expectedErrors := []TariffError{{
+		Line:   3,
+		Reason: "invalid origin port provided; origin must be a UN/LOCODE of five uppercase letters or digits; date must be formatted YYYY-MM-DD",
+	}}
+	if !reflect.DeepEqual(tariffErrors, expectedErrors) {
+		t.Errorf("expected errors %+v, got %+v", expectedErrors, tariffErrors)
+	}
+	if expected := "line 3: " + expectedErrors[0].Reason; tariffErrors[0].Error() != expected {
+		t.Errorf("expected error message %q, got %q", expected, tariffErrors[0].Error())
+	}
+
+	// The quotes of the accepted rows are stored by the server
+	if quotes, err := shipmentService.GetLatestQuotes(); err != nil || len(quotes) != 1 || quotes[0].Origin != "CNSGH" {
+		t.Errorf("expected the quotes of CNSGH, got %+v and %v", quotes, err)
+	}
+
+	if _, _, err = ImportTariff(context.Background(), server.Client(), server.URL, "qs_admin", strings.NewReader("company,price\n"), DefaultDelimiter); !errors.Is(err, ErrTariffNotImported) || !strings.Contains(err.Error(), ErrInvalidRequestPayload.Error()) {
+		t.Errorf("expected error %v with %v, got %v", ErrTariffNotImported, ErrInvalidRequestPayload, err)
+	}
+	if _, _, err = ImportTariff(context.Background(), server.Client(), server.URL, "", strings.NewReader(tariff), '|'); !errors.Is(err, ErrTariffNotImported) || !strings.Contains(err.Error(), ErrUnauthenticated.Error()) {
+		t.Errorf("expected error %v with %v, got %v", ErrTariffNotImported, ErrUnauthenticated, err)
+	}
+}
@@ -0,0 +1,246 @@
+package presentation
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"quoteship/domain"
+)
+
+const (
+	APIVersionPrefix = "/v1" // APIVersionPrefix is the path prefix of the resource-oriented API, the root route keeps its legacy behaviour.
+
+	DefaultPageLimit = 20  // DefaultPageLimit is the number of quotes of a page when no `limit` is provided.
+	MaxPageLimit     = 100 // MaxPageLimit is the largest number of quotes a page can contain.
+
+	SortByPrice          = "price"   // SortByPrice sorts the quotes by price, cheapest first. It is the default order.
+	SortByPriceDesc      = "-price"  // SortByPriceDesc sorts the quotes by price, most expensive first.
+	SortByDate           = "date"    // SortByDate sorts the quotes by date, earliest first.
+	SortByDateDesc       = "-date"   // SortByDateDesc sorts the quotes by date, most recent first.
+	SortByCompany        = "company" // SortByCompany sorts the quotes by company identifier.
+	linkRelationNext     = "next"    // linkRelationNext is the relation of the Link header to the next page of quotes.
+	limitQueryParameter  = "limit"   // limitQueryParameter is the query parameter that sets the number of quotes of a page.
+	offsetQueryParameter = "offset"  // offsetQueryParameter is the query parameter that sets the number of quotes skipped before a page.
+)
+
+var (
+	ErrInvalidLimit  = errors.New("invalid limit value")
+	ErrInvalidOffset = errors.New("invalid offset value")
+	ErrInvalidSort   = errors.New("invalid sort value")
+	ErrNotFound      = errors.New("not found")
+)
+
+// quoteOrders maps every `sort` value to the order it sorts the quotes in. Quotes that are equal in that order keep the
+// order of the latest batch, by lane and then by price.
+var quoteOrders = map[string]func(a, b domain.ShipmentQuote) bool{
+	SortByPrice:     func(a, b domain.ShipmentQuote) bool { return a.Price < b.Price },
+	SortByPriceDesc: func(a, b domain.ShipmentQuote) bool { return a.Price > b.Price },
+	SortByDate:      func(a, b domain.ShipmentQuote) bool { return a.Date.Before(b.Date) },
+	SortByDateDesc:  func(a, b domain.ShipmentQuote) bool { return a.Date.After(b.Date) },
+	SortByCompany:   func(a, b domain.ShipmentQuote) bool { return a.Company < b.Company },
+}
+
+// originQuotesResponse is the response payload of the origin quotes endpoint, it lists a page of the quotes of an
+// origin.
+type originQuotesResponse struct {
+	Origin string        `json:"origin"` // Origin is the located port of the quotes (e.g., "CNSGH").
+	Total  int           `json:"total"`  // Total is the number of quotes of the origin, on every page.
+	Limit  int           `json:"limit"`  // Limit is the largest number of quotes of the page.
+	Offset int           `json:"offset"` // Offset is the number of quotes skipped before the page.
+	Sort   string        `json:"sort"`   // Sort is the order of the quotes, e.g., "price" or "-date".
+	Quotes []originQuote `json:"quotes"` // Quotes lists the quotes of the page.
+}
+
+// originQuote is a single quote of the origin quotes endpoint response payload.
+type originQuote struct {
+	Destination string `json:"destination"`           // Destination is the destination port of the quote, "*" for a quote valid for every destination.
+	Equipment   string `json:"equipment"`             // Equipment is the container type of the quote.
+	Company     int    `json:"company"`               // Company is the identifier of the company that submitted the quote.
+	CompanyName string `json:"companyName,omitempty"` // CompanyName is the name of the company in the company registry, it is omitted when the registry does not list it.
+	Price       any    `json:"price"`                 // Price is the price of the quote in the base currency, in the negotiated price format.
+	Date        string `json:"date"`                  // Date is the start date of the quote, in the format "YYYY-MM-DD".
+	ValidUntil  string `json:"validUntil,omitempty"`  // ValidUntil is the last date the quote is in effect, in the format "YYYY-MM-DD". It is omitted when the quote does not expire.
+}
+
+// laneQuote is a quote of the latest batch together with its lane.
+type laneQuote struct {
+	domain.Lane                      // Lane is the lane of the quote.
+	quote       domain.ShipmentQuote // quote is the quote itself.
+}
+
+// GetOriginExpectedRates is an HTTP handler that retrieves the latest expected rates of the origin given by the
+// {origin} path value. It accepts the query parameters and the Accept header of GetLatestExpectedRates and returns the
+// same response restricted to the origin, e.g., {"CNSGH": 100}. The handler returns 404 Not Found if the origin has no
+// expected rate.
+func (h ShipmentHandler) GetOriginExpectedRates(writer http.ResponseWriter, request *http.Request) {
+	h.writeExpectedRates(writer, request, request.PathValue("origin"))
+}
+
+// GetOriginQuotes is an HTTP handler that lists the quotes of the latest published batch for the origin given by the
+// {origin} path value, the quotes the expected rates are calculated from, for every lane and equipment type.
+// The quotes are sorted by price, cheapest first, or in the order of the optional `sort` query parameter: "price",
+// "-price", "date", "-date" or "company". The list is paginated with the optional `limit` query parameter, 20 quotes by
+// default and at most 100, and the `offset` query parameter, the number of quotes to skip. When more quotes follow the
+// page, the Link header points to the next page, e.g., `</v1/origins/CNSGH/quotes?limit=20&offset=20>; rel="next"`.
+// The prices are in the base currency, in the price format negotiated with the Accept header. An origin without quotes
+// has an empty list, and an invalid `limit`, `offset` or `sort` is rejected with 400 Bad Request.
+func (h ShipmentHandler) GetOriginQuotes(writer http.ResponseWriter, request *http.Request) {
+	origin := request.PathValue("origin")
+
+	format, err := negotiatePriceFormat(request)
+	if err != nil {
+		writeJSONResponse(writer, http.StatusNotAcceptable, map[string]string{"error": err.Error()})
+		return
+	}
+
+	limit, offset, order, err := parsePageQuery(request)
+	if err != nil {
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Calling the GetLatestQuotes method from the service layer to get the quotes of every lane
+	shipmentsByLane, err := h.s.GetLatestQuotes()
+	if err != nil {
+		slog.Error("error getting latest quotes", "error", err)
+		writeJSONResponse(writer, http.StatusInternalServerError, map[string]string{"error": ErrIntervalServerError.Error()})
+		return
+	}
+
+	var quotes []laneQuote
+	for _, shipments := range shipmentsByLane {
+		if shipments.Origin != origin {
+			continue
+		}
+		lane := shipments.Lane()
+		for _, quote := range shipments.Quotes {
+			quotes = append(quotes, laneQuote{Lane: lane, quote: quote})
+		}
+	}
+	less := quoteOrders[order]
+	sort.SliceStable(quotes, func(i, j int) bool { return less(quotes[i].quote, quotes[j].quote) })
+
+	response := originQuotesResponse{Origin: origin, Total: len(quotes), Limit: limit, Offset: offset, Sort: order, Quotes: []originQuote{}}
+	start := min(offset, len(quotes)) // An offset past the last quote is an empty page
+	end := min(start+limit, len(quotes))
+	for _, quote := range quotes[start:end] {
+		response.Quotes = append(response.Quotes, originQuote{
+			Destination: quote.Destination,
+			Equipment:   string(quote.quote.EquipmentType()),
+			Company:     quote.quote.Company,
+			CompanyName: h.companyName(quote.quote.Company),
+			Price:       h.formatPrice(quote.quote.Price, format),
+			Date:        formatDate(quote.quote.Date),
+			ValidUntil:  formatValidUntil(quote.quote.ValidUntil),
+		})
+	}
+
+	if end < len(quotes) {
+		writer.Header().Set("Link", nextPageLink(request.URL, limit, end))
+	}
+	writer.Header().Set("Content-Type", priceFormatContentType(format))
+	writer.Header().Set("Vary", "Accept")
+	writeJSONResponse(writer, http.StatusOK, response)
+}
+
+// DeleteCompanyQuotes is an HTTP handler that deletes every quote of a company for an origin, given by the {origin}
+// and {company} path values, for every lane and equipment type. The deleted quotes no longer count towards the expected
+// rates, and the deletion is listed in the quote history of the company. The handler returns 204 No Content once the
+// quotes are deleted, or 404 Not Found if the company has no quote for the origin.
+func (h ShipmentHandler) DeleteCompanyQuotes(writer http.ResponseWriter, request *http.Request) {
+	origin := request.PathValue("origin")
+
+	company, err := strconv.Atoi(request.PathValue("company"))
+	if err != nil {
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": domain.ErrInvalidCompany.Error()})
+		return
+	}
+
+	// Only the company of the API key can delete its quotes
+	if !authorizeCompanies(writer, request, company) {
+		return
+	}
+
+	// Calling the DeleteCompanyQuotes method from the service layer to delete the quotes
+	_, err = h.s.DeleteCompanyQuotes(origin, company)
+	switch {
+	case errors.Is(err, domain.ErrNoCompanyQuotes):
+		writeJSONResponse(writer, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, domain.ErrInvalidOriginPort), errors.Is(err, domain.ErrInvalidCompany):
+		writeJSONResponse(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	case err != nil:
+		slog.Error("error deleting company quotes", "error", err)
+		writeJSONResponse(writer, http.StatusInternalServerError, map[string]string{"error": ErrIntervalServerError.Error()})
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// NotFound is an HTTP handler that answers the requests for an unknown resource of the versioned API with 404 Not
+// Found, so they never reach the legacy root route.
+func NotFound(writer http.ResponseWriter, _ *http.Request) {
+	writeJSONResponse(writer, http.StatusNotFound, map[string]string{"error": ErrNotFound.Error()})
+}
+
+// parsePageQuery parses the `limit`, `offset` and `sort` query parameters of the request. It returns the error to
+// respond with if a parameter is invalid.
+func parsePageQuery(request *http.Request) (int, int, string, error) {
+	limit, offset, order := DefaultPageLimit, 0, SortByPrice
+
+	if value := request.URL.Query().Get(limitQueryParameter); value != "" {
+		parsedLimit, err := strconv.Atoi(value)
+		if err != nil || parsedLimit < 1 || parsedLimit > MaxPageLimit {
+			return 0, 0, "", ErrInvalidLimit
+		}
+		limit = parsedLimit
+	}
+
+	if value := request.URL.Query().Get(offsetQueryParameter); value != "" {
+		parsedOffset, err := strconv.Atoi(value)
+		if err != nil || parsedOffset < 0 {
+			return 0, 0, "", ErrInvalidOffset
+		}
+		offset = parsedOffset
+	}
+
+	if value := request.URL.Query().Get("sort"); value != "" {
+		if _, exists := quoteOrders[value]; !exists {
+			return 0, 0, "", ErrInvalidSort
+		}
+		order = value
+	}
+
+	return limit, offset, order, nil
+}
+
+// nextPageLink returns the Link header value pointing to the page at offset, keeping the other query parameters of the
+// request URL.
+func nextPageLink(requestURL *url.URL, limit, offset int) string {
+	query := requestURL.Query()
+	query.Set(limitQueryParameter, strconv.Itoa(limit))
+	query.Set(offsetQueryParameter, strconv.Itoa(offset))
+
+	next := url.URL{Path: requestURL.Path, RawQuery: query.Encode()}
+
+	return fmt.Sprintf("<%s>; rel=%q", next.String(), linkRelationNext)
+}
+
+// originRates returns the expected rates of the origin.
+func originRates(rates []domain.ExpectedRate, origin string) []domain.ExpectedRate {
+	var filtered []domain.ExpectedRate
+	for _, rate := range rates {
+		if rate.Origin == origin {
+			filtered = append(filtered, rate)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,289 @@
+package presentation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"quoteship/app"
+	"quoteship/domain"
+	"quoteship/persistence"
+	"quoteship/ports"
+)
+
+// newV1Mux returns a ServeMux with the registered routes of a new service, after submitting the offers to the versioned
+// API.
+func newV1Mux(t *testing.T, offers []string) *http.ServeMux {
+	t.Helper()
+
+	shipmentRepository, err := persistence.NewShipmentOfferRepository(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to create shipment repository: %v", err)
+	}
+	registry := ports.Bundled() // Shared by the service and the port endpoints
+	shipmentService, err := app.CreateShipmentService(shipmentRepository, app.WithPortRegistry(registry))
+	if err != nil {
+		t.Fatalf("failed to create shipment service: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, shipmentService, WithPortRegistry(registry))
+
+	for _, offer := range offers {
+		req := httptest.NewRequest(http.MethodPost, "/v1/quotes", strings.NewReader(offer))
+		req.Header.Set("Content-Type", "application/json")
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("failed to submit offer %s: status %d", offer, rec.Code)
+		}
+	}
+
+	return mux
+}
+
+func TestRegisterRoutes_v1(t *testing.T) {
+	mux := newV1Mux(t, []string{
+		`{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}`,
+		`{"company": 2, "price": 300, "origin": "CNSGH", "date": "2023-02-01", "validUntil": "2023-12-31"}`,
+		`{"company": 3, "price": 200, "origin": "CNSGH", "destination": "NLRTM", "date": "2023-03-01", "equipment": "40HC"}`,
+		`{"company": 4, "price": 500, "origin": "SGSIN", "date": "2023-01-01"}`,
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedLink   string
+		expectedBody   interface{}
+	}{
+		{
+			name:           "expected rates",
+			path:           "/v1/rates",
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]int{"CNSGH": 200, "SGSIN": 500},
+		},
+		{
+			name:           "expected rates of the root route",
+			path:           "/",
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]int{"CNSGH": 200, "SGSIN": 500},
+		},
+		{
+			name:           "expected rates of an origin",
+			path:           "/v1/rates/CNSGH",
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]int{"CNSGH": 200},
+		},
+		{
+			name:           "expected rates of an origin - grouped by lane",
+			path:           "/v1/rates/CNSGH?groupBy=lane&equipment=40HC",
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]map[string]int{"CNSGH": {"NLRTM": 200}},
+		},
+		{
+			name:           "expected rates of an origin without quotes",
+			path:           "/v1/rates/CNNBO",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   map[string]string{"error": domain.ErrNoExpectedRates.Error()},
+		},
+		{
+			name:           "expected rates of an origin - invalid query",
+			path:           "/v1/rates/CNSGH?groupBy=company",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]string{"error": ErrInvalidGroupBy.Error()},
+		},
+		{
+			name:           "quotes of an origin",
+			path:           "/v1/origins/CNSGH/quotes",
+			expectedStatus: http.StatusOK,
+			expectedBody: originQuotesResponse{Origin: "CNSGH", Total: 3, Limit: DefaultPageLimit, Sort: SortByPrice, Quotes: []originQuote{
+				{Destination: "*", Equipment: "20DV", Company: 1, Price: 100.0, Date: "2023-01-01"},
+				{Destination: "NLRTM", Equipment: "40HC", Company: 3, Price: 200.0, Date: "2023-03-01"},
+				{Destination: "*", Equipment: "20DV", Company: 2, Price: 300.0, Date: "2023-02-01", ValidUntil: "2023-12-31"},
+			}},
+		},
+		{
+			name:           "quotes of an origin - sorted by date and paginated",
+			path:           "/v1/origins/CNSGH/quotes?sort=-date&limit=2",
+			expectedStatus: http.StatusOK,
+			expectedLink:   `</v1/origins/CNSGH/quotes?limit=2&offset=2&sort=-date>; rel="next"`,
+			expectedBody: originQuotesResponse{Origin: "CNSGH", Total: 3, Limit: 2, Sort: SortByDateDesc, Quotes: []originQuote{
+				{Destination: "NLRTM", Equipment: "40HC", Company: 3, Price: 200.0, Date: "2023-03-01"},
+				{Destination: "*", Equipment: "20DV", Company: 2, Price: 300.0, Date: "2023-02-01", ValidUntil: "2023-12-31"},
+			}},
+		},
+		{
+			name:           "quotes of an origin - last page",
+			path:           "/v1/origins/CNSGH/quotes?sort=-date&limit=2&offset=2",
+			expectedStatus: http.StatusOK,
+			expectedBody: originQuotesResponse{Origin: "CNSGH", Total: 3, Limit: 2, Offset: 2, Sort: SortByDateDesc, Quotes: []originQuote{
+				{Destination: "*", Equipment: "20DV", Company: 1, Price: 100.0, Date: "2023-01-01"},
+			}},
+		},
+		{
+			name:           "quotes of an origin - offset past the last quote",
+			path:           "/v1/origins/CNSGH/quotes?offset=10",
+			expectedStatus: http.StatusOK,
+			expectedBody:   originQuotesResponse{Origin: "CNSGH", Total: 3, Limit: DefaultPageLimit, Offset: 10, Sort: SortByPrice, Quotes: []originQuote{}},
+		},
+		{
+			name:           "quotes of an origin without quotes",
+			path:           "/v1/origins/CNNBO/quotes",
+			expectedStatus: http.StatusOK,
+			expectedBody:   originQuotesResponse{Origin: "CNNBO", Limit: DefaultPageLimit, Sort: SortByPrice, Quotes: []originQuote{}},
+		},
+		{
+			name:           "quotes of an origin - invalid limit",
+			path:           "/v1/origins/CNSGH/quotes?limit=101",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]string{"error": ErrInvalidLimit.Error()},
+		},
+		{
+			name:           "quotes of an origin - invalid offset",
+			path:           "/v1/origins/CNSGH/quotes?offset=-1",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]string{"error": ErrInvalidOffset.Error()},
+		},
+		{
+			name:           "quotes of an origin - invalid sort",
+			path:           "/v1/origins/CNSGH/quotes?sort=origin",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   map[string]string{"error": ErrInvalidSort.Error()},
+		},
+		{
+			name:           "unknown resource",
+			path:           "/v1/ports",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   map[string]string{"error": ErrNotFound.Error()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			// Check the status code
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			// Check the link to the next page
+			if link := rec.Header().Get("Link"); link != tt.expectedLink {
+				t.Errorf("expected Link header %q, got %q", tt.expectedLink, link)
+			}
+
+			// Decode the response body like the expected one
+			actualBody := reflect.New(reflect.TypeOf(tt.expectedBody))
+			if err := json.NewDecoder(rec.Body).Decode(actualBody.Interface()); err != nil {
+				t.Fatalf("failed to decode response body: %v", err)
+			}
+			if !reflect.DeepEqual(actualBody.Elem().Interface(), tt.expectedBody) {
+				t.Errorf("expected body %+v, got %+v", tt.expectedBody, actualBody.Elem().Interface())
+			}
+		})
+	}
+}
+
+func TestShipmentHandler_DeleteCompanyQuotes(t *testing.T) {
+	mux := newV1Mux(t, []string{
+		`{"company": 1, "price": 100, "origin": "CNSGH", "date": "2023-01-01"}`,
+		`{"company": 1, "price": 150, "origin": "CNSGH", "destination": "NLRTM", "date": "2023-01-01"}`,
+		`{"company": 2, "price": 300, "origin": "CNSGH", "date": "2023-01-01"}`,
+	})
+
+	// The steps run in order, each one sees the quotes deleted by the previous ones
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "invalid company",
+			method:         http.MethodDelete,
+			path:           "/v1/origins/CNSGH/companies/first",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"` + domain.ErrInvalidCompany.Error() + `"}` + "\n",
+		},
+		{
+			name:           "company without quotes",
+			method:         http.MethodDelete,
+			path:           "/v1/origins/CNSGH/companies/3",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"` + domain.ErrNoCompanyQuotes.Error() + `"}` + "\n",
+		},
+		{
+			name:           "quotes of the company deleted",
+			method:         http.MethodDelete,
+			path:           "/v1/origins/CNSGH/companies/1",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "deleted quotes no longer count towards the expected rates",
+			method:         http.MethodGet,
+			path:           "/v1/rates/CNSGH?groupBy=lane",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"CNSGH":{"*":300}}`,
+		},
+		{
+			name:           "deleted quotes no longer listed",
+			method:         http.MethodGet,
+			path:           "/v1/origins/CNSGH/quotes",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"origin":"CNSGH","total":1,"limit":20,"offset":0,"sort":"price","quotes":[{"destination":"*","equipment":"20DV","company":2,"price":300,"date":"2023-01-01"}]}` + "\n",
+		},
+		{
+			name:           "quotes of the company already deleted",
+			method:         http.MethodDelete,
+			path:           "/v1/origins/CNSGH/companies/1",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"` + domain.ErrNoCompanyQuotes.Error() + `"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			// Check the status code
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			// Check the response body
+			if rec.Body.String() != tt.expectedBody {
+				t.Errorf("expected body %q, got %q", tt.expectedBody, rec.Body.String())
+			}
+		})
+	}
+
+	// The deletion is recorded in the audit trail of every lane it removed quotes from
+	req := httptest.NewRequest(http.MethodGet, "/origins/CNSGH/companies/1/history", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	var response quoteHistoryResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	var statuses []string
+	for _, entry := range response.History {
+		statuses = append(statuses, entry.Status)
+	}
+	if expected := []string{"accepted", "accepted", "deleted", "deleted"}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected statuses %v, got %v", expected, statuses)
+	}
+}